@@ -0,0 +1,40 @@
+// k8m-agent 运行在被纳管集群内，主动连接 k8m 并建立反向隧道，
+// 适用于 apiserver 无法被 k8m 直接访问的集群，如位于 NAT、防火墙之后
+package main
+
+import (
+	"context"
+	goflag "flag"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/pflag"
+	"github.com/weibaohui/k8m/pkg/agent"
+	"k8s.io/klog/v2"
+)
+
+// Version 通过 -ldflags "-X main.Version=" 设置
+var Version = "dev"
+
+func main() {
+	opts := &agent.Options{Version: Version}
+	insecure, _ := strconv.ParseBool(os.Getenv("K8M_INSECURE_SKIP_VERIFY"))
+
+	klog.InitFlags(nil)
+	pflag.CommandLine.AddGoFlagSet(goflag.CommandLine)
+	pflag.StringVar(&opts.Server, "server", os.Getenv("K8M_SERVER"), "k8m 访问地址，如 https://k8m.example.com，也可通过环境变量 K8M_SERVER 设置")
+	pflag.StringVar(&opts.Token, "token", os.Getenv("K8M_AGENT_TOKEN"), "在 k8m 中创建 Agent 时生成的Token，也可通过环境变量 K8M_AGENT_TOKEN 设置")
+	pflag.StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig 文件路径，为空时使用集群内 ServiceAccount")
+	pflag.BoolVar(&opts.InsecureSkipVerify, "insecure-skip-verify", insecure, "不校验 k8m 的 TLS 证书，也可通过环境变量 K8M_INSECURE_SKIP_VERIFY 设置")
+	pflag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	klog.Infof("k8m-agent %s 启动，连接 %s", Version, opts.Server)
+	if err := agent.Run(ctx, opts); err != nil {
+		klog.Fatalf("k8m-agent 运行失败: %v", err)
+	}
+}
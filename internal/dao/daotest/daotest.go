@@ -0,0 +1,42 @@
+// Package daotest 为单元测试准备临时 sqlite 数据库，避免测试在源码目录下生成 data/k8m.db
+//
+// models 包初始化时即连接数据库并迁移表结构，在 TestMain 中设置环境变量为时已晚，
+// 因此在本包初始化时设置 SQLITE_PATH。本包不依赖 k8m 的其他包，且导入路径排序在前，
+// 按 Go 1.21 起的包初始化顺序会先于 models 包初始化。
+//
+// 使用方式：在测试文件中导入本包，并在 TestMain 中调用 Run 以便测试结束后删除临时目录
+//
+//	func TestMain(m *testing.M) {
+//		daotest.Run(m)
+//	}
+package daotest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dir 本包创建的临时目录，已通过环境变量指定数据库时为空
+var dir string
+
+func init() {
+	if os.Getenv("SQLITE_PATH") != "" || os.Getenv("SQLITE_DSN") != "" {
+		return
+	}
+	tmp, err := os.MkdirTemp("", "k8m-test-")
+	if err != nil {
+		panic("创建测试数据库临时目录失败: " + err.Error())
+	}
+	dir = tmp
+	_ = os.Setenv("SQLITE_PATH", filepath.Join(dir, "k8m.db"))
+}
+
+// Run 执行测试并在结束后删除临时数据库目录
+func Run(m *testing.M) {
+	code := m.Run()
+	if dir != "" {
+		_ = os.RemoveAll(dir)
+	}
+	os.Exit(code)
+}
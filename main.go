@@ -17,15 +17,18 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/weibaohui/k8m/pkg/cb"
+	"github.com/weibaohui/k8m/pkg/comm"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/controller/admin/ai_prompt"
 	"github.com/weibaohui/k8m/pkg/controller/admin/cluster"
 	"github.com/weibaohui/k8m/pkg/controller/admin/config"
 	"github.com/weibaohui/k8m/pkg/controller/admin/event"
 	"github.com/weibaohui/k8m/pkg/controller/admin/inspection"
+	"github.com/weibaohui/k8m/pkg/controller/admin/knowledge"
 	"github.com/weibaohui/k8m/pkg/controller/admin/mcp"
 	"github.com/weibaohui/k8m/pkg/controller/admin/menu"
 	"github.com/weibaohui/k8m/pkg/controller/admin/user"
+	"github.com/weibaohui/k8m/pkg/controller/agent"
 	"github.com/weibaohui/k8m/pkg/controller/chat"
 	"github.com/weibaohui/k8m/pkg/controller/cluster_status"
 	"github.com/weibaohui/k8m/pkg/controller/cm"
@@ -38,6 +41,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/controller/helm"
 	"github.com/weibaohui/k8m/pkg/controller/ingressclass"
 	"github.com/weibaohui/k8m/pkg/controller/k8sgpt"
+	"github.com/weibaohui/k8m/pkg/controller/kubeproxy"
 	"github.com/weibaohui/k8m/pkg/controller/log"
 	"github.com/weibaohui/k8m/pkg/controller/login"
 	"github.com/weibaohui/k8m/pkg/controller/node"
@@ -45,12 +49,14 @@ import (
 	"github.com/weibaohui/k8m/pkg/controller/param"
 	"github.com/weibaohui/k8m/pkg/controller/pod"
 	"github.com/weibaohui/k8m/pkg/controller/rs"
+	"github.com/weibaohui/k8m/pkg/controller/scim"
 	"github.com/weibaohui/k8m/pkg/controller/sso"
 	"github.com/weibaohui/k8m/pkg/controller/storageclass"
 	"github.com/weibaohui/k8m/pkg/controller/sts"
 	"github.com/weibaohui/k8m/pkg/controller/svc"
 	"github.com/weibaohui/k8m/pkg/controller/template"
 	"github.com/weibaohui/k8m/pkg/controller/user/apikey"
+	"github.com/weibaohui/k8m/pkg/controller/user/kubeconfig"
 	"github.com/weibaohui/k8m/pkg/controller/user/mcpkey"
 	"github.com/weibaohui/k8m/pkg/controller/user/profile"
 	"github.com/weibaohui/k8m/pkg/eventhandler/watcher"
@@ -61,7 +67,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/lease"
 	"github.com/weibaohui/k8m/pkg/lua"
 	"github.com/weibaohui/k8m/pkg/middleware"
-	_ "github.com/weibaohui/k8m/pkg/models" // 注册模型
+	"github.com/weibaohui/k8m/pkg/models"
 	"github.com/weibaohui/k8m/pkg/service"
 	_ "github.com/weibaohui/k8m/swagger"
 	"github.com/weibaohui/kom/callbacks"
@@ -83,6 +89,39 @@ var BuildDate string
 func Init() {
 	// 初始化配置
 	cfg := flag.Init()
+	// 轮换主密钥，执行后退出
+	if cfg.RotateMasterKey {
+		keyID, count, err := models.RotateMasterKey()
+		if err != nil {
+			klog.Fatalf("轮换主密钥未完成，当前主密钥 [%s]，已重新加密 %d 个字段，请勿删除历史主密钥: %v", keyID, count, err)
+		}
+		klog.Infof("轮换主密钥完成，当前主密钥 [%s]，重新加密 %d 个字段。使用密钥文件的运行中实例会自动加载新密钥，使用 MASTER_KEY 环境变量时需更新后重启所有实例。确认无误后可删除历史主密钥", keyID, count)
+		os.Exit(0)
+	}
+	// 轮换JWT签名密钥，执行后退出
+	if cfg.RotateJwtKey {
+		kid, err := service.JwtKeyService().Rotate()
+		if err != nil {
+			klog.Fatalf("轮换JWT签名密钥失败: %v", err)
+		}
+		klog.Infof("轮换JWT签名密钥完成，当前签名密钥 [%s]，历史密钥签发的Token在过期前仍可验证", kid)
+		os.Exit(0)
+	}
+	if service.JwtKeyService().IsDefaultSecret() {
+		if service.JwtKeyService().Algorithm() == service.JwtAlgHS256 {
+			klog.Warning(color.RedString("当前使用默认的 jwt-token-secret 签发Token，任何人均可伪造登录凭证！请通过 --jwt-token-secret 或环境变量 JWT_TOKEN_SECRET 修改，或设置 --jwt-signing-alg=RS256/ES256 启用非对称签名"))
+		} else {
+			klog.Warning(color.RedString("当前 jwt-token-secret 为默认值，已拒绝所有HS256签名的Token，历史Token及API密钥需重新生成"))
+		}
+	} else if service.JwtKeyService().Algorithm() != service.JwtAlgHS256 {
+		if cfg.JwtHMACAcceptBefore == "" {
+			klog.Infof("已启用 %s 签名，拒绝HS256签名的Token，历史Token及API密钥需重新生成", service.JwtKeyService().Algorithm())
+		} else if deadline, err := service.ParseJwtHMACAcceptBefore(cfg.JwtHMACAcceptBefore); err != nil {
+			klog.Errorf("%v，已拒绝HS256签名的Token", err)
+		} else {
+			klog.Warningf("已启用 %s 签名，%s 之前仍接受 jwt-token-secret 签名的历史Token，请在此之前重新生成API密钥", service.JwtKeyService().Algorithm(), deadline.Format(time.DateTime))
+		}
+	}
 	// 从数据库中更新配置
 	err := service.ConfigService().UpdateFlagFromDBConfig()
 	if err != nil {
@@ -112,6 +151,8 @@ func Init() {
 
 		// 先把自定义钩子注册登记
 		service.ClusterService().SetRegisterCallbackFunc(cb.RegisterDefaultCallbacks)
+		// AI 写操作预览与执行使用同一权限校验
+		service.SetPermissionCheckFunc(comm.CheckPermissionLogic)
 
 		if cfg.InCluster {
 			klog.V(6).Infof("启用InCluster模式，自动注册纳管宿主集群")
@@ -136,6 +177,9 @@ func Init() {
 				service.ClusterService().Connect(clusterInfo.ClusterID)
 			}
 		}
+		// 从宿主集群中的 Argo CD、Cluster API Secret 自动发现集群
+		service.ClusterDiscoveryService().Start(context.Background())
+
 		// 打印集群连接信息
 		klog.Infof("处理%d个集群，其中%d个集群已连接", len(service.ClusterService().AllClusters()), len(service.ClusterService().ConnectedClusters()))
 
@@ -190,6 +234,12 @@ func Init() {
 					// leader 启动对event的webhook处理
 					watcher.NewEventWatcher().Start()
 					worker.NewEventWorker().Start()
+					// 定期同步LDAP用户组
+					service.GroupMappingService().StartLdapSync(ctx)
+					// 定期扫描集群证书，即将过期时提醒
+					service.ClusterCertificateService().Start(ctx)
+					// 记录集群心跳、状态变化，断开及恢复时通知
+					service.ClusterHealthService().Start(ctx)
 				},
 				OnStoppedLeading: func() {
 					klog.V(2).Infof("[leader] 不再是Leader，停止定时任务（集群巡检、Helm仓库更新）")
@@ -222,6 +272,15 @@ func main() {
 
 	cfg := flag.Init()
 
+	// 来源IP用于登录失败锁定及审计，仅信任配置的反向代理传递的 X-Forwarded-For
+	var trustedProxies []string
+	if cfg.TrustedProxies != "" {
+		trustedProxies = utils.SplitAndTrim(cfg.TrustedProxies, ",")
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		klog.Fatalf("trusted-proxies 配置错误: %v", err)
+	}
+
 	// 开启Recovery中间件
 	if !cfg.Debug {
 		r.Use(middleware.CustomRecovery())
@@ -232,7 +291,8 @@ func main() {
 		pprof.Register(r)
 	}
 	r.Use(cors.Default())
-	r.Use(gzip.Gzip(gzip.BestCompression))
+	// Kubernetes API 代理及集群 Agent 隧道需原样转发 watch 流及协议升级，不压缩
+	r.Use(gzip.Gzip(gzip.BestCompression, gzip.WithExcludedPaths([]string{service.KubeProxyPathPrefix, "/agent/"})))
 	r.Use(middleware.SetCacheHeaders())
 	r.Use(middleware.AuthMiddleware())
 	r.Use(middleware.EnsureSelectedClusterMiddleware())
@@ -297,10 +357,26 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	// JWT签名公钥
+	config.RegisterJWKSRoutes(r)
+
+	// SCIM 用户及用户组同步，使用专用 Token 认证
+	scimGroup := r.Group("/scim/v2", middleware.ScimAuthMiddleware())
+	{
+		scim.RegisterScimRoutes(scimGroup)
+	}
+
 	auth := r.Group("/auth")
 	{
 		login.RegisterLoginRoutes(auth)
 		sso.RegisterAuthRoutes(auth)
+		kubeconfig.RegisterKubeconfigAuthRoutes(auth)
+	}
+
+	// 集群 Agent 反向隧道，使用 Agent Token 认证
+	agentGroup := r.Group("/agent")
+	{
+		agent.RegisterAgentRoutes(agentGroup)
 	}
 
 	// 公共参数
@@ -391,6 +467,12 @@ func main() {
 
 	}
 
+	// Kubernetes API 代理，供 kubectl 等客户端使用API密钥访问集群
+	kubeProxy := r.Group("/k8s/proxy/:cluster", middleware.AuthMiddleware())
+	{
+		kubeproxy.RegisterKubeProxyRoutes(kubeProxy)
+	}
+
 	mgm := r.Group("/mgm", middleware.AuthMiddleware())
 	{
 		template.RegisterTemplateRoutes(mgm)
@@ -398,6 +480,8 @@ func main() {
 		profile.RegisterProfileRoutes(mgm)
 		// API密钥管理
 		apikey.RegisterAPIKeysRoutes(mgm)
+		// 下载 kubeconfig
+		kubeconfig.RegisterKubeconfigRoutes(mgm)
 		// MCP密钥管理
 		mcpkey.RegisterMCPKeysRoutes(mgm)
 		// log
@@ -416,12 +500,20 @@ func main() {
 		config.RegisterSSOConfigRoutes(admin)
 		// ldap
 		config.RegisterLdapConfigRoutes(admin)
+		// JWT签名密钥
+		config.RegisterJwtKeyRoutes(admin)
 		// 平台参数配置
 		config.RegisterConfigRoutes(admin)
 		// 大模型列表管理
 		config.RegisterAIModelConfigRoutes(admin)
+		// AI用量统计及配额
+		config.RegisterAIUsageRoutes(admin)
+		// AI脱敏记录
+		config.RegisterAIRedactionRoutes(admin)
 		// AI提示词管理
 		ai_prompt.RegisterAdminAIPromptRoutes(admin)
+		// 知识库管理
+		knowledge.RegisterAdminKnowledgeRoutes(admin)
 		// 集群巡检定时任务
 		inspection.RegisterAdminScheduleRoutes(admin)
 		// K8s事件转发配置
@@ -441,8 +533,18 @@ func main() {
 		user.RegisterAdminUserRoutes(admin)
 		// 用户组管理相关
 		user.RegisterAdminUserGroupRoutes(admin)
+		// 外部用户组映射规则
+		user.RegisterAdminGroupMappingRoutes(admin)
+		// SCIM Token
+		user.RegisterAdminScimTokenRoutes(admin)
+		// 登录失败记录及解锁
+		user.RegisterAdminLoginLockRoutes(admin)
+		// 用户下载的 kubeconfig
+		user.RegisterAdminUserKubeconfigRoutes(admin)
 		// 管理集群、纳管\解除纳管\扫描
 		cluster.RegisterAdminClusterRoutes(admin)
+		// 集群 Agent
+		cluster.RegisterAdminClusterAgentRoutes(admin)
 		// helm Repo 操作
 		helm.RegisterHelmRepoRoutes(admin)
 
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ConnectPath k8m 接收 Agent 连接的地址
+	ConnectPath = "/agent/connect"
+	// TunnelSecretHeader k8m 在握手响应中下发的隧道密钥，经隧道转发的请求需携带该密钥
+	TunnelSecretHeader = "X-K8m-Tunnel-Secret"
+	// VersionHeader Agent 版本
+	VersionHeader = "X-K8m-Agent-Version"
+	// APIServerHeader Agent 所在集群的 apiserver 地址，仅用于展示
+	APIServerHeader = "X-K8m-Agent-APIServer"
+
+	// minBackoff、maxBackoff 断线重连的间隔范围
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Options Agent 启动参数
+type Options struct {
+	Server             string // k8m 访问地址，如 https://k8m.example.com
+	Token              string // 在 k8m 中创建 Agent 时生成的Token
+	Kubeconfig         string // 为空时使用集群内 ServiceAccount
+	InsecureSkipVerify bool   // 不校验 k8m 的 TLS 证书
+	Version            string
+}
+
+// Run 连接 k8m 并保持隧道，断开后按指数退避重连，直到 ctx 结束
+// Agent 只向外发起连接，集群无需暴露 apiserver，适用于 NAT、防火墙之后的集群
+func Run(ctx context.Context, opts *Options) error {
+	if opts.Server == "" || opts.Token == "" {
+		return fmt.Errorf("k8m 地址及 Agent Token 不能为空")
+	}
+	config, err := loadRestConfig(opts.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("加载集群配置失败: %w", err)
+	}
+	handler, err := newProxyHandler(config)
+	if err != nil {
+		return fmt.Errorf("创建 apiserver 代理失败: %w", err)
+	}
+
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := connect(ctx, opts, config.Host, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// 稳定运行过一段时间后断开，重置重连间隔
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+		klog.Warningf("与 k8m 的隧道断开: %v，%s 后重连", err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// connect 建立一次隧道连接，k8m 作为 yamux 客户端经隧道发起请求，Agent 作为服务端转发到 apiserver
+func connect(ctx context.Context, opts *Options, apiServer string, handler http.Handler) error {
+	target, err := connectURL(opts.Server)
+	if err != nil {
+		return err
+	}
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify},
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+opts.Token)
+	header.Set(VersionHeader, opts.Version)
+	header.Set(APIServerHeader, apiServer)
+	ws, resp, err := dialer.DialContext(ctx, target, header)
+	if err != nil {
+		if resp != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			_ = resp.Body.Close()
+			return fmt.Errorf("连接 k8m 失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return fmt.Errorf("连接 k8m 失败: %w", err)
+	}
+	secret := resp.Header.Get(TunnelSecretHeader)
+	if secret == "" {
+		_ = ws.Close()
+		return fmt.Errorf("k8m 未下发隧道密钥")
+	}
+
+	session, err := yamux.Server(NewConn(ws), nil)
+	if err != nil {
+		_ = ws.Close()
+		return err
+	}
+	defer session.Close()
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Close()
+		case <-session.CloseChan():
+		}
+	}()
+	klog.Infof("已连接 k8m: %s", opts.Server)
+
+	srv := &http.Server{
+		Handler:           secretHandler(secret, handler),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	return srv.Serve(session)
+}
+
+// connectURL 将 k8m 访问地址转换为 websocket 连接地址
+func connectURL(server string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(server, "/"))
+	if err != nil {
+		return "", fmt.Errorf("k8m 地址格式错误: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("k8m 地址应以 http:// 或 https:// 开头: %s", server)
+	}
+	u.Path += ConnectPath
+	return u.String(), nil
+}
+
+// secretHandler 只接受携带隧道密钥的请求，避免 k8m 所在主机上的其他进程借用隧道访问集群
+// 校验后移除该请求头，由 Agent 使用自身的 ServiceAccount 访问 apiserver
+func secretHandler(secret string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + secret)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "invalid tunnel secret", http.StatusUnauthorized)
+			return
+		}
+		req.Header.Del("Authorization")
+		next.ServeHTTP(w, req)
+	})
+}
+
+// newProxyHandler 转发到 apiserver，支持 watch 及 exec、logs、port-forward 等协议升级
+func newProxyHandler(config *rest.Config) (http.Handler, error) {
+	server, _, err := rest.DefaultServerUrlFor(config)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	upgrade, err := utils.NewUpgradeTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		location := *server
+		location.Path = path.Join("/", server.Path, req.URL.Path)
+		if strings.HasSuffix(req.URL.Path, "/") && !strings.HasSuffix(location.Path, "/") {
+			location.Path += "/"
+		}
+		location.RawQuery = req.URL.RawQuery
+		handler := proxy.NewUpgradeAwareHandler(&location, rt, false, false, errorResponder{})
+		handler.UpgradeTransport = upgrade
+		handler.UseLocationHost = true
+		handler.ServeHTTP(w, req)
+	}), nil
+}
+
+// loadRestConfig 未指定 kubeconfig 时使用集群内 ServiceAccount
+func loadRestConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		return rest.InClusterConfig()
+	}
+	return clientcmd.BuildConfigFromFlags("", kubeconfig)
+}
+
+type errorResponder struct{}
+
+func (errorResponder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	klog.Errorf("转发请求 %s 失败: %v", req.URL.Path, err)
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
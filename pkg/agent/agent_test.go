@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+)
+
+func TestConnectURL(t *testing.T) {
+	tests := []struct {
+		server  string
+		want    string
+		wantErr bool
+	}{
+		{server: "http://k8m.example.com", want: "ws://k8m.example.com/agent/connect"},
+		{server: "https://k8m.example.com/", want: "wss://k8m.example.com/agent/connect"},
+		{server: "https://example.com/k8m", want: "wss://example.com/k8m/agent/connect"},
+		{server: "k8m.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := connectURL(tt.server)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s 期望错误 %v，实际 %v", tt.server, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s 期望 %s，实际 %s", tt.server, tt.want, got)
+		}
+	}
+}
+
+// TestTunnel 经 websocket 上的 yamux 隧道发起 HTTP 请求，Agent 侧校验隧道密钥
+func TestTunnel(t *testing.T) {
+	const secret = "s3cret"
+	backend := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "" {
+			t.Errorf("隧道密钥不应转发给 apiserver")
+		}
+		_, _ = io.WriteString(w, req.URL.Path)
+	})
+
+	sessions := make(chan *yamux.Session, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		if err != nil {
+			t.Errorf("升级 websocket 失败: %v", err)
+			return
+		}
+		session, err := yamux.Client(NewConn(ws), nil)
+		if err != nil {
+			t.Errorf("创建 yamux 客户端失败: %v", err)
+			return
+		}
+		sessions <- session
+	}))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	agentSession, err := yamux.Server(NewConn(ws), nil)
+	if err != nil {
+		t.Fatalf("创建 yamux 服务端失败: %v", err)
+	}
+	defer agentSession.Close()
+	go func() {
+		_ = http.Serve(agentSession, secretHandler(secret, backend))
+	}()
+	session := <-sessions
+	defer session.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) { return session.Open() },
+	}}
+	tests := []struct {
+		token string
+		code  int
+	}{
+		{token: "Bearer " + secret, code: http.StatusOK},
+		{token: "Bearer wrong", code: http.StatusUnauthorized},
+		{token: "", code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://tunnel/api/v1/pods", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", tt.token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("经隧道请求失败: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("Authorization=%q 期望状态码 %d，实际 %d", tt.token, tt.code, resp.StatusCode)
+		}
+		if tt.code == http.StatusOK && string(body) != "/api/v1/pods" {
+			t.Errorf("期望响应 /api/v1/pods，实际 %s", body)
+		}
+	}
+}
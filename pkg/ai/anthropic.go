@@ -0,0 +1,482 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"k8s.io/klog/v2"
+)
+
+const (
+	anthropicClientName       = "anthropic"
+	anthropicDefaultBaseURL   = "https://api.anthropic.com/v1"
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicClient Anthropic Messages API 客户端
+// 对话、历史、工具调用等逻辑与 OpenAI 客户端保持一致，
+// 在 HTTP 层由 anthropicTransport 将 Chat Completions 请求/响应与 Messages API 互相转换，
+// 因此上层仍然可以使用 openai.ChatCompletionStream 处理流式结果。
+type AnthropicClient struct {
+	OpenAIClient
+}
+
+func (c *AnthropicClient) GetName() string {
+	return anthropicClientName
+}
+
+func (c *AnthropicClient) Configure(config IAIConfig) error {
+	// 鉴权由 anthropicTransport 通过 x-api-key 完成
+	cfg := openai.DefaultConfig("")
+	cfg.BaseURL = anthropicDefaultBaseURL
+	if baseURL := config.GetBaseURL(); baseURL != "" {
+		cfg.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+
+	transport, err := newHTTPTransport(config)
+	if err != nil {
+		return err
+	}
+	maxTokens := config.GetMaxTokens()
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	cfg.HTTPClient = &http.Client{
+		Transport: &anthropicTransport{
+			Origin:    transport,
+			ApiKey:    config.GetPassword(),
+			MaxTokens: maxTokens,
+		},
+	}
+	return c.init(cfg, config)
+}
+
+// anthropicTransport 将 OpenAI Chat Completions 协议转换为 Anthropic Messages API
+type anthropicTransport struct {
+	Origin    http.RoundTripper
+	ApiKey    string
+	MaxTokens int
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	TopP        *float32           `json:"top_p,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	ID         string             `json:"id"`
+	Model      string             `json:"model"`
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+}
+
+type anthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStreamEvent 流式事件，不同 type 使用其中不同字段
+type anthropicStreamEvent struct {
+	Type         string            `json:"type"`
+	Index        int               `json:"index"`
+	Message      anthropicResponse `json:"message"`
+	ContentBlock anthropicContent  `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *anthropicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+		return nil, fmt.Errorf("anthropic provider 不支持该请求: %s", req.URL.Path)
+	}
+	var oaReq openai.ChatCompletionRequest
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(body, &oaReq); err != nil {
+			return nil, err
+		}
+	}
+
+	payload, err := json.Marshal(t.convertRequest(&oaReq))
+	if err != nil {
+		return nil, err
+	}
+
+	newReq := req.Clone(req.Context())
+	newReq.URL.Path = strings.TrimSuffix(req.URL.Path, "/chat/completions") + "/messages"
+	newReq.Body = io.NopCloser(bytes.NewReader(payload))
+	newReq.ContentLength = int64(len(payload))
+	newReq.Header.Del("Authorization")
+	newReq.Header.Set("Content-Type", "application/json")
+	newReq.Header.Set("x-api-key", t.ApiKey)
+	newReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := t.Origin.RoundTrip(newReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return convertAnthropicError(resp), nil
+	}
+	if oaReq.Stream {
+		return convertAnthropicStream(resp), nil
+	}
+	return convertAnthropicResponse(resp)
+}
+
+// convertRequest 将 OpenAI 请求转换为 Anthropic 请求
+// system 消息合并为 system 字段；tool 消息转换为 user 角色的 tool_result；相邻同角色消息合并
+func (t *anthropicTransport) convertRequest(r *openai.ChatCompletionRequest) *anthropicRequest {
+	ar := &anthropicRequest{
+		Model:     r.Model,
+		MaxTokens: t.MaxTokens,
+		Stream:    r.Stream,
+	}
+	if r.MaxTokens > 0 {
+		ar.MaxTokens = r.MaxTokens
+	}
+	if r.Temperature > 0 {
+		temperature := r.Temperature
+		// Anthropic temperature 取值范围为 0-1
+		if temperature > 1 {
+			temperature = 1
+		}
+		ar.Temperature = &temperature
+	}
+	if r.TopP > 0 && r.TopP < 1 {
+		topP := r.TopP
+		ar.TopP = &topP
+	}
+
+	var systems []string
+	for _, m := range r.Messages {
+		var role string
+		var blocks []anthropicContent
+		switch m.Role {
+		case openai.ChatMessageRoleSystem:
+			if m.Content != "" {
+				systems = append(systems, m.Content)
+			}
+			continue
+		case openai.ChatMessageRoleTool:
+			role = openai.ChatMessageRoleUser
+			blocks = append(blocks, anthropicContent{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   m.Content,
+			})
+		case openai.ChatMessageRoleAssistant:
+			role = openai.ChatMessageRoleAssistant
+			if m.Content != "" {
+				blocks = append(blocks, anthropicContent{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContent{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+		default:
+			role = openai.ChatMessageRoleUser
+			if m.Content != "" {
+				blocks = append(blocks, anthropicContent{Type: "text", Text: m.Content})
+			}
+			for _, part := range m.MultiContent {
+				if part.Type == openai.ChatMessagePartTypeText && part.Text != "" {
+					blocks = append(blocks, anthropicContent{Type: "text", Text: part.Text})
+				}
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(ar.Messages); n > 0 && ar.Messages[n-1].Role == role {
+			ar.Messages[n-1].Content = append(ar.Messages[n-1].Content, blocks...)
+			continue
+		}
+		ar.Messages = append(ar.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	ar.System = strings.Join(systems, "\n")
+
+	for _, tool := range r.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		ar.Tools = append(ar.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	return ar
+}
+
+// convertAnthropicFinishReason 将 stop_reason 转换为 OpenAI finish_reason
+func convertAnthropicFinishReason(reason string) openai.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "max_tokens":
+		return openai.FinishReasonLength
+	case "tool_use":
+		return openai.FinishReasonToolCalls
+	default:
+		return openai.FinishReasonStop
+	}
+}
+
+// convertAnthropicError 将 Anthropic 错误转换为 OpenAI 错误格式，以便上层得到 *openai.APIError
+func convertAnthropicError(resp *http.Response) *http.Response {
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	var ae anthropicError
+	message := string(body)
+	errType := "anthropic_error"
+	if err := json.Unmarshal(body, &ae); err == nil && ae.Error.Message != "" {
+		message = ae.Error.Message
+		errType = ae.Error.Type
+	}
+	payload, _ := json.Marshal(openai.ErrorResponse{
+		Error: &openai.APIError{Message: message, Type: errType},
+	})
+	return replaceBody(resp, payload, "application/json")
+}
+
+func convertAnthropicResponse(resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var ar anthropicResponse
+	if err = json.Unmarshal(body, &ar); err != nil {
+		return nil, err
+	}
+
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	var texts []string
+	for _, block := range ar.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			index := len(msg.ToolCalls)
+			msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+				Index: &index,
+				ID:    block.ID,
+				Type:  openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      block.Name,
+					Arguments: string(block.Input),
+				},
+			})
+		}
+	}
+	msg.Content = strings.Join(texts, "")
+
+	payload, err := json.Marshal(openai.ChatCompletionResponse{
+		ID:      ar.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   ar.Model,
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Index:        0,
+				Message:      msg,
+				FinishReason: convertAnthropicFinishReason(ar.StopReason),
+			},
+		},
+		Usage: openai.Usage{
+			PromptTokens:     ar.Usage.InputTokens,
+			CompletionTokens: ar.Usage.OutputTokens,
+			TotalTokens:      ar.Usage.InputTokens + ar.Usage.OutputTokens,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replaceBody(resp, payload, "application/json"), nil
+}
+
+// convertAnthropicStream 将 Anthropic SSE 事件流实时转换为 OpenAI chunk 流
+func convertAnthropicStream(resp *http.Response) *http.Response {
+	pr, pw := io.Pipe()
+	origin := resp.Body
+	go func() {
+		defer origin.Close()
+		var id, model string
+		var usage anthropicUsage
+		created := time.Now().Unix()
+		// Anthropic content block 序号 -> OpenAI tool_calls 序号
+		toolIndex := map[int]int{}
+
+		write := func(delta openai.ChatCompletionStreamChoiceDelta, finish openai.FinishReason, u *openai.Usage) error {
+			chunk := openai.ChatCompletionStreamResponse{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   model,
+				Choices: []openai.ChatCompletionStreamChoice{
+					{Index: 0, Delta: delta, FinishReason: finish},
+				},
+				Usage: u,
+			}
+			data, err := json.Marshal(chunk)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(pw, "data: %s\n\n", data)
+			return err
+		}
+
+		scanner := bufio.NewScanner(origin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			var ev anthropicStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+				klog.V(6).Infof("anthropic stream 解析失败: %v", err)
+				continue
+			}
+			var err error
+			switch ev.Type {
+			case "message_start":
+				id = ev.Message.ID
+				model = ev.Message.Model
+				usage.InputTokens = ev.Message.Usage.InputTokens
+				err = write(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, "", nil)
+			case "content_block_start":
+				if ev.ContentBlock.Type == "tool_use" {
+					index := len(toolIndex)
+					toolIndex[ev.Index] = index
+					err = write(openai.ChatCompletionStreamChoiceDelta{
+						ToolCalls: []openai.ToolCall{{
+							Index:    &index,
+							ID:       ev.ContentBlock.ID,
+							Type:     openai.ToolTypeFunction,
+							Function: openai.FunctionCall{Name: ev.ContentBlock.Name},
+						}},
+					}, "", nil)
+				}
+			case "content_block_delta":
+				switch ev.Delta.Type {
+				case "text_delta":
+					err = write(openai.ChatCompletionStreamChoiceDelta{Content: ev.Delta.Text}, "", nil)
+				case "input_json_delta":
+					index := toolIndex[ev.Index]
+					err = write(openai.ChatCompletionStreamChoiceDelta{
+						ToolCalls: []openai.ToolCall{{
+							Index:    &index,
+							Function: openai.FunctionCall{Arguments: ev.Delta.PartialJSON},
+						}},
+					}, "", nil)
+				}
+			case "message_delta":
+				usage.OutputTokens = ev.Usage.OutputTokens
+				err = write(openai.ChatCompletionStreamChoiceDelta{}, convertAnthropicFinishReason(ev.Delta.StopReason), &openai.Usage{
+					PromptTokens:     usage.InputTokens,
+					CompletionTokens: usage.OutputTokens,
+					TotalTokens:      usage.InputTokens + usage.OutputTokens,
+				})
+			case "message_stop":
+				_, err = io.WriteString(pw, "data: [DONE]\n\n")
+			case "error":
+				err = fmt.Errorf("anthropic stream error: %s %s", ev.Error.Type, ev.Error.Message)
+			}
+			if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		_ = pw.CloseWithError(scanner.Err())
+	}()
+	return replaceBody(resp, pr, "text/event-stream")
+}
+
+// replaceBody 替换响应体，并同步修正相关 Header
+func replaceBody(resp *http.Response, body any, contentType string) *http.Response {
+	switch b := body.(type) {
+	case []byte:
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		resp.ContentLength = int64(len(b))
+	case io.ReadCloser:
+		resp.Body = b
+		resp.ContentLength = -1
+	}
+	resp.Header = resp.Header.Clone()
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Type", contentType)
+	return resp
+}
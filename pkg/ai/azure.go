@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const azureClientName = "azure"
+
+// AzureOpenAIClient Azure OpenAI 客户端
+// 复用 OpenAI 客户端的对话逻辑，仅在鉴权方式、URL 及 api-version 上有所区别
+type AzureOpenAIClient struct {
+	OpenAIClient
+}
+
+func (c *AzureOpenAIClient) GetName() string {
+	return azureClientName
+}
+
+func (c *AzureOpenAIClient) Configure(config IAIConfig) error {
+	baseURL := config.GetBaseURL()
+	if baseURL == "" {
+		return errors.New("azure openai 需要配置 API 地址")
+	}
+	cfg := openai.DefaultAzureConfig(config.GetPassword(), baseURL)
+	if v := config.GetApiVersion(); v != "" {
+		cfg.APIVersion = v
+	}
+	// Azure 以部署名称区分模型，未配置部署名称时使用模型名称
+	deployment := config.GetEngine()
+	cfg.AzureModelMapperFunc = func(model string) string {
+		if deployment != "" {
+			return deployment
+		}
+		return model
+	}
+
+	transport, err := newHTTPTransport(config)
+	if err != nil {
+		return err
+	}
+	cfg.HTTPClient = &http.Client{
+		Transport: transport,
+	}
+	// 旧版本 api-version 不支持 stream_options
+	c.disableStreamUsage = true
+	return c.init(cfg, config)
+}
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Embedder 支持生成文本向量的客户端
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embed 调用 OpenAI 兼容的 embeddings 接口，模型使用配置中的模型名称
+func (c *OpenAIClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(c.model),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings 返回数量 %d 与请求数量 %d 不一致", len(resp.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embeddings 返回序号 %d 越界", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// Embed 使用主模型生成向量，失败时尝试备用模型
+func (f *FallbackClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	primary, ok := f.primary.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("模型 %s 不支持 embeddings", f.primary.GetName())
+	}
+	vectors, err := primary.Embed(ctx, texts)
+	if !f.shouldFallback(ctx, err) {
+		return vectors, err
+	}
+	if fallback, ok := f.fallback.(Embedder); ok {
+		return fallback.Embed(ctx, texts)
+	}
+	return vectors, err
+}
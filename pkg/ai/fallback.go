@@ -0,0 +1,202 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"k8s.io/klog/v2"
+)
+
+// memoryHolder 可共享对话历史的客户端
+type memoryHolder interface {
+	setMemory(m *memoryService)
+}
+
+// FallbackClient 为主模型增加超时控制，并在主模型超时或出错时切换到备用模型
+// 主、备模型共享同一份对话历史，切换后上下文不丢失
+type FallbackClient struct {
+	primary  IAI
+	fallback IAI
+	timeout  time.Duration
+	memory   *memoryService
+}
+
+// NewFallbackClient 创建带备用模型的客户端
+// fallback 可为 nil，此时仅对主模型做超时控制；timeout 为 0 表示不限制
+func NewFallbackClient(primary IAI, fallback IAI, timeout time.Duration) *FallbackClient {
+	f := &FallbackClient{
+		primary:  primary,
+		fallback: fallback,
+		timeout:  timeout,
+		memory:   NewMemoryService(),
+	}
+	f.setMemory(f.memory)
+	return f
+}
+
+func (f *FallbackClient) setMemory(m *memoryService) {
+	f.memory = m
+	for _, c := range []IAI{f.primary, f.fallback} {
+		if h, ok := c.(memoryHolder); ok {
+			h.setMemory(m)
+		}
+	}
+}
+
+func (f *FallbackClient) Configure(config IAIConfig) error {
+	return f.primary.Configure(config)
+}
+
+func (f *FallbackClient) GetName() string {
+	return f.primary.GetName()
+}
+
+func (f *FallbackClient) Close() {
+	f.primary.Close()
+	if f.fallback != nil {
+		f.fallback.Close()
+	}
+}
+
+func (f *FallbackClient) SetTools(tools []openai.Tool) {
+	f.primary.SetTools(tools)
+	if f.fallback != nil {
+		f.fallback.SetTools(tools)
+	}
+}
+
+func (f *FallbackClient) SaveAIHistory(ctx context.Context, content string) {
+	f.primary.SaveAIHistory(ctx, content)
+}
+
+func (f *FallbackClient) GetHistory(ctx context.Context) []openai.ChatCompletionMessage {
+	return f.primary.GetHistory(ctx)
+}
+
+func (f *FallbackClient) ClearHistory(ctx context.Context) error {
+	return f.primary.ClearHistory(ctx)
+}
+
+// shouldFallback 判断主模型失败后是否切换备用模型，调用方主动取消时不切换
+func (f *FallbackClient) shouldFallback(ctx context.Context, err error) bool {
+	if err == nil || f.fallback == nil {
+		return false
+	}
+	if ctx.Err() != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	return true
+}
+
+// restoreHistory 主模型失败时恢复调用前的历史，避免备用模型收到重复的用户消息
+func (f *FallbackClient) restoreHistory(ctx context.Context, snapshot []openai.ChatCompletionMessage) {
+	f.memory.SetUserHistory(getUsernameFromContext(ctx), snapshot)
+}
+
+func (f *FallbackClient) GetCompletion(ctx context.Context, contents ...any) (string, error) {
+	snapshot := f.primary.GetHistory(ctx)
+	tctx, cancel := f.withTimeout(ctx)
+	result, err := f.primary.GetCompletion(tctx, contents...)
+	cancel()
+	if !f.shouldFallback(ctx, err) {
+		return result, err
+	}
+	klog.Warningf("AI 模型 %s 请求失败，切换备用模型 %s: %v", f.primary.GetName(), f.fallback.GetName(), err)
+	f.restoreHistory(ctx, snapshot)
+	return f.fallback.GetCompletion(ctx, contents...)
+}
+
+func (f *FallbackClient) GetCompletionWithTools(ctx context.Context, contents ...any) ([]openai.ToolCall, string, error) {
+	snapshot := f.primary.GetHistory(ctx)
+	tctx, cancel := f.withTimeout(ctx)
+	calls, result, err := f.primary.GetCompletionWithTools(tctx, contents...)
+	cancel()
+	if !f.shouldFallback(ctx, err) {
+		return calls, result, err
+	}
+	klog.Warningf("AI 模型 %s 请求失败，切换备用模型 %s: %v", f.primary.GetName(), f.fallback.GetName(), err)
+	f.restoreHistory(ctx, snapshot)
+	return f.fallback.GetCompletionWithTools(ctx, contents...)
+}
+
+func (f *FallbackClient) GetStreamCompletion(ctx context.Context, contents ...any) (*openai.ChatCompletionStream, error) {
+	return f.stream(ctx, contents, IAI.GetStreamCompletion)
+}
+
+func (f *FallbackClient) GetStreamCompletionWithTools(ctx context.Context, contents ...any) (*openai.ChatCompletionStream, error) {
+	return f.stream(ctx, contents, IAI.GetStreamCompletionWithTools)
+}
+
+type streamFunc func(c IAI, ctx context.Context, contents ...any) (*openai.ChatCompletionStream, error)
+
+// stream 流式请求的超时只作用于建立连接阶段，连接建立后的读取不受限制
+func (f *FallbackClient) stream(ctx context.Context, contents []any, fn streamFunc) (*openai.ChatCompletionStream, error) {
+	snapshot := f.primary.GetHistory(ctx)
+	var stream *openai.ChatCompletionStream
+	var err error
+	if f.timeout <= 0 {
+		stream, err = fn(f.primary, ctx, contents...)
+	} else {
+		sctx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(f.timeout, cancel)
+		// 连接建立后 sctx 需在读取期间保持有效，由响应 Body 关闭时释放
+		stream, err = fn(f.primary, context.WithValue(sctx, streamCancelKey{}, cancel), contents...)
+		if !timer.Stop() && err == nil {
+			// 定时器已触发，连接已被取消
+			_ = stream.Close()
+			err = context.DeadlineExceeded
+		}
+		if err != nil {
+			cancel()
+		}
+	}
+	if !f.shouldFallback(ctx, err) {
+		return stream, err
+	}
+	klog.Warningf("AI 模型 %s 流式请求失败，切换备用模型 %s: %v", f.primary.GetName(), f.fallback.GetName(), err)
+	f.restoreHistory(ctx, snapshot)
+	return fn(f.fallback, ctx, contents...)
+}
+
+func (f *FallbackClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, f.timeout)
+}
+
+// streamCancelKey 流式请求的 cancel，随请求 context 传递到 streamReleaseTransport
+type streamCancelKey struct{}
+
+// streamReleaseTransport 在响应 Body 关闭时调用流式请求的 cancel，释放为超时控制创建的 context
+type streamReleaseTransport struct {
+	Origin http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *streamReleaseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Origin.RoundTrip(req)
+	cancel, ok := req.Context().Value(streamCancelKey{}).(context.CancelFunc)
+	if !ok || err != nil {
+		return resp, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+	return err
+}
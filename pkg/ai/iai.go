@@ -55,11 +55,21 @@ type IAIConfig interface {
 	GetCompartmentId() string
 	GetOrganizationId() string
 	GetCustomHeaders() []http.Header
+	GetApiVersion() string
 }
 
+// NewClient 根据 provider 名称创建对应的客户端，未知的 provider 按 OpenAI 兼容接口处理
 func NewClient(provider string) IAI {
-	// default client
-	return &OpenAIClient{}
+	switch provider {
+	case azureClientName:
+		return &AzureOpenAIClient{}
+	case anthropicClientName:
+		return &AnthropicClient{}
+	case ollamaClientName, "localai":
+		return &OllamaClient{}
+	default:
+		return &OpenAIClient{}
+	}
 }
 
 type Configuration struct {
@@ -86,6 +96,7 @@ type Provider struct {
 	MaxTokens      int
 	OrganizationId string
 	CustomHeaders  []http.Header
+	ApiVersion     string
 }
 
 func (p *Provider) GetBaseURL() string {
@@ -150,6 +161,10 @@ func (p *Provider) GetCustomHeaders() []http.Header {
 	return p.CustomHeaders
 }
 
+func (p *Provider) GetApiVersion() string {
+	return p.ApiVersion
+}
+
 var passwordlessProviders = []string{"localai", "ollama", "amazonsagemaker", "amazonbedrock", "googlevertexai", "oci"}
 
 func NeedPassword(backend string) bool {
@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	ollamaClientName     = "ollama"
+	ollamaDefaultBaseURL = "http://localhost:11434/v1"
+)
+
+// OllamaClient 本地模型客户端（Ollama、LocalAI 等）
+// 通过其提供的 OpenAI 兼容接口访问，API Key 可为空
+type OllamaClient struct {
+	OpenAIClient
+}
+
+func (c *OllamaClient) GetName() string {
+	return ollamaClientName
+}
+
+func (c *OllamaClient) Configure(config IAIConfig) error {
+	token := config.GetPassword()
+	if token == "" {
+		// 本地模型不校验密钥，但部分兼容实现要求 Authorization 头不能为空
+		token = ollamaClientName
+	}
+	cfg := openai.DefaultConfig(token)
+	cfg.BaseURL = ollamaDefaultBaseURL
+	if baseURL := config.GetBaseURL(); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+
+	transport, err := newHTTPTransport(config)
+	if err != nil {
+		return err
+	}
+	cfg.HTTPClient = &http.Client{
+		Transport: transport,
+	}
+	return c.init(cfg, config)
+}
@@ -21,6 +21,8 @@ type OpenAIClient struct {
 	maxHistory  int32
 	memory      *memoryService
 
+	disableStreamUsage bool // 部分服务端不支持 stream_options，此时用量按字符数估算
+
 	// organizationId string
 }
 
@@ -55,40 +57,39 @@ func (c *OpenAIClient) SetTools(tools []openai.Tool) {
 }
 
 func (c *OpenAIClient) Configure(config IAIConfig) error {
-	token := config.GetPassword()
-	cfg := openai.DefaultConfig(token)
+	cfg := openai.DefaultConfig(config.GetPassword())
 	orgId := config.GetOrganizationId()
-	proxyEndpoint := config.GetProxyEndpoint()
 
 	baseURL := config.GetBaseURL()
 	if baseURL != "" {
 		cfg.BaseURL = baseURL
 	}
 
-	transport := &http.Transport{}
-	if proxyEndpoint != "" {
-		proxyUrl, err := url.Parse(proxyEndpoint)
-		if err != nil {
-			return err
-		}
-		transport.Proxy = http.ProxyURL(proxyUrl)
-	} else {
-		klog.V(6).Info("openai client using default proxy from environment")
-		transport.Proxy = http.ProxyFromEnvironment
-	}
-
 	if orgId != "" {
 		cfg.OrgID = orgId
 	}
 
-	customHeaders := config.GetCustomHeaders()
+	transport, err := newHTTPTransport(config)
+	if err != nil {
+		return err
+	}
 	cfg.HTTPClient = &http.Client{
-		Transport: &OpenAIHeaderTransport{
-			Origin:  transport,
-			Headers: customHeaders,
-		},
+		Transport: transport,
 	}
+	return c.init(cfg, config)
+}
 
+// init 使用已构建好的 ClientConfig 创建底层客户端，并设置模型参数
+// 供 OpenAI 兼容的各类 provider 复用
+func (c *OpenAIClient) init(cfg openai.ClientConfig, config IAIConfig) error {
+	// 统一在最外层脱敏及统计用量，此时请求、响应均为 OpenAI 格式
+	if hc, ok := cfg.HTTPClient.(*http.Client); ok {
+		hc.Transport = &streamReleaseTransport{
+			Origin: &redactTransport{
+				Origin: &usageTransport{Origin: hc.Transport, Provider: c.providerName(config)},
+			},
+		}
+	}
 	client := openai.NewClientWithConfig(cfg)
 	if client == nil {
 		return errors.New("error creating OpenAI client")
@@ -99,6 +100,41 @@ func (c *OpenAIClient) Configure(config IAIConfig) error {
 	c.temperature = config.GetTemperature()
 	c.topP = config.GetTopP()
 	c.maxHistory = config.GetMaxHistory()
-	c.memory = NewMemoryService()
+	if c.memory == nil {
+		c.memory = NewMemoryService()
+	}
 	return nil
 }
+
+// providerName 用于用量统计的 provider 名称
+func (c *OpenAIClient) providerName(config IAIConfig) string {
+	if p, ok := config.(*Provider); ok && p.Name != "" {
+		return p.Name
+	}
+	return openAIClientName
+}
+
+// newHTTPTransport 按配置构建带代理与自定义 Header 的 RoundTripper
+func newHTTPTransport(config IAIConfig) (http.RoundTripper, error) {
+	transport := &http.Transport{}
+	proxyEndpoint := config.GetProxyEndpoint()
+	if proxyEndpoint != "" {
+		proxyUrl, err := url.Parse(proxyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	} else {
+		klog.V(6).Info("openai client using default proxy from environment")
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	return &OpenAIHeaderTransport{
+		Origin:  transport,
+		Headers: config.GetCustomHeaders(),
+	}, nil
+}
+
+func (c *OpenAIClient) setMemory(m *memoryService) {
+	c.memory = m
+}
@@ -65,11 +65,12 @@ func (c *OpenAIClient) GetStreamCompletion(ctx context.Context, contents ...any)
 
 	c.fillChatHistory(ctx, contents)
 	stream, err := c.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
-		Model:       c.model,
-		Messages:    c.GetHistory(ctx),
-		Temperature: c.temperature,
-		TopP:        c.topP,
-		Stream:      true,
+		Model:         c.model,
+		Messages:      c.GetHistory(ctx),
+		Temperature:   c.temperature,
+		TopP:          c.topP,
+		Stream:        true,
+		StreamOptions: c.streamOptions(),
 	})
 	return stream, err
 }
@@ -78,12 +79,21 @@ func (c *OpenAIClient) GetStreamCompletionWithTools(ctx context.Context, content
 
 	c.fillChatHistory(ctx, contents)
 	stream, err := c.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
-		Model:    c.model,
-		Messages: c.GetHistory(ctx),
-		Tools:    c.tools,
-		Stream:   true,
+		Model:         c.model,
+		Messages:      c.GetHistory(ctx),
+		Tools:         c.tools,
+		Stream:        true,
+		StreamOptions: c.streamOptions(),
 	})
 	klog.V(6).Infof("GetStreamCompletionWithTools 携带 history length: %d", len(c.GetHistory(ctx)))
 	klog.V(8).Infof("GetStreamCompletionWithTools c.history: %v", utils.ToJSON(c.GetHistory(ctx)))
 	return stream, err
 }
+
+// streamOptions 流式请求时要求服务端在最后一个 chunk 中返回用量
+func (c *OpenAIClient) streamOptions() *openai.StreamOptions {
+	if c.disableStreamUsage {
+		return nil
+	}
+	return &openai.StreamOptions{IncludeUsage: true}
+}
@@ -0,0 +1,409 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/weibaohui/k8m/pkg/constants"
+)
+
+func testContext() context.Context {
+	return context.WithValue(context.Background(), constants.JwtUserName, "tester")
+}
+
+// newOpenAIServer 模拟 OpenAI 兼容接口，返回固定内容
+func newOpenAIServer(t *testing.T, content string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}},
+			},
+		})
+	}))
+}
+
+func newTestClient(t *testing.T, provider string, baseURL string) IAI {
+	client := NewClient(provider)
+	err := client.Configure(&Provider{Name: provider, Model: "test-model", Password: "test-key", BaseURL: baseURL, MaxHistory: 10})
+	if err != nil {
+		t.Fatalf("配置客户端失败: %v", err)
+	}
+	return client
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		provider string
+		expected string
+	}{
+		{provider: "openai", expected: openAIClientName},
+		{provider: "", expected: openAIClientName},
+		{provider: "azure", expected: azureClientName},
+		{provider: "anthropic", expected: anthropicClientName},
+		{provider: "ollama", expected: ollamaClientName},
+		{provider: "localai", expected: ollamaClientName},
+	}
+	for _, tt := range tests {
+		if got := NewClient(tt.provider).GetName(); got != tt.expected {
+			t.Errorf("NewClient(%q) = %s, 期望 %s", tt.provider, got, tt.expected)
+		}
+	}
+}
+
+func TestAnthropicCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("期望请求 /v1/messages, 实际 %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("缺少 Anthropic 鉴权头: %v", r.Header)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("不应携带 Authorization 头")
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("解析请求失败: %v", err)
+		}
+		if req.System == "" {
+			t.Errorf("system 消息应转换为 system 字段")
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Role != "user" || !strings.Contains(req.Messages[0].Content[0].Text, "你好") {
+			t.Errorf("消息转换错误: %+v", req.Messages)
+		}
+		if req.MaxTokens != anthropicDefaultMaxTokens {
+			t.Errorf("期望默认 max_tokens %d, 实际 %d", anthropicDefaultMaxTokens, req.MaxTokens)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"msg_1","model":"claude","content":[{"type":"text","text":"你好，我是助手"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, "anthropic", server.URL+"/v1")
+	result, err := client.GetCompletion(testContext(), "你好")
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if result != "你好，我是助手" {
+		t.Errorf("返回内容错误: %s", result)
+	}
+}
+
+func TestAnthropicError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, "anthropic", server.URL+"/v1")
+	_, err := client.GetCompletion(testContext(), "你好")
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("期望 APIError, 实际 %v", err)
+	}
+	if apiErr.HTTPStatusCode != http.StatusUnauthorized || apiErr.Message != "invalid x-api-key" {
+		t.Errorf("错误转换不正确: %+v", apiErr)
+	}
+}
+
+func TestAnthropicStreamWithTools(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude","usage":{"input_tokens":12}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"查看"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Pod"}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"list_pod"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"namespace\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"default\"}"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":8}}`,
+		`{"type":"message_stop"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream || len(req.Tools) != 1 || req.Tools[0].Name != "list_pod" {
+			t.Errorf("流式或工具参数转换错误: %+v", req)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(ev), &typed)
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, ev)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, "anthropic", server.URL+"/v1")
+	client.SetTools([]openai.Tool{{
+		Type:     openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{Name: "list_pod", Description: "列出Pod"},
+	}})
+	stream, err := client.GetStreamCompletionWithTools(testContext(), "列出default下的Pod")
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	var name, args string
+	var finish openai.FinishReason
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取流失败: %v", err)
+		}
+		for _, choice := range resp.Choices {
+			content.WriteString(choice.Delta.Content)
+			for _, tc := range choice.Delta.ToolCalls {
+				if tc.Index == nil || *tc.Index != 0 {
+					t.Errorf("工具调用序号错误: %v", tc.Index)
+				}
+				name += tc.Function.Name
+				args += tc.Function.Arguments
+			}
+			if choice.FinishReason != "" {
+				finish = choice.FinishReason
+			}
+		}
+	}
+	if content.String() != "查看Pod" {
+		t.Errorf("文本内容错误: %s", content.String())
+	}
+	if name != "list_pod" || args != `{"namespace":"default"}` {
+		t.Errorf("工具调用错误: %s %s", name, args)
+	}
+	if finish != openai.FinishReasonToolCalls {
+		t.Errorf("结束原因错误: %s", finish)
+	}
+}
+
+func TestOllamaCompletion(t *testing.T) {
+	server := newOpenAIServer(t, "ollama", 0)
+	defer server.Close()
+
+	client := NewClient("ollama")
+	if err := client.Configure(&Provider{Model: "qwen", BaseURL: server.URL}); err != nil {
+		t.Fatalf("配置客户端失败: %v", err)
+	}
+	result, err := client.GetCompletion(testContext(), "你好")
+	if err != nil || result != "ollama" {
+		t.Errorf("请求失败: %s %v", result, err)
+	}
+}
+
+func TestFallbackOnError(t *testing.T) {
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failed.Close()
+	backup := newOpenAIServer(t, "backup", 0)
+	defer backup.Close()
+
+	client := NewFallbackClient(newTestClient(t, "openai", failed.URL), newTestClient(t, "openai", backup.URL), 0)
+	ctx := testContext()
+	result, err := client.GetCompletion(ctx, "你好")
+	if err != nil {
+		t.Fatalf("备用模型请求失败: %v", err)
+	}
+	if result != "backup" {
+		t.Errorf("期望备用模型返回, 实际 %s", result)
+	}
+
+	// 主模型失败时不应在历史中留下重复的用户消息
+	var users int
+	for _, m := range client.GetHistory(ctx) {
+		if m.Role == openai.ChatMessageRoleUser {
+			users++
+		}
+	}
+	if users != 1 {
+		t.Errorf("期望历史中有 1 条用户消息, 实际 %d", users)
+	}
+}
+
+func TestFallbackOnTimeout(t *testing.T) {
+	slow := newOpenAIServer(t, "slow", time.Second)
+	defer slow.Close()
+	backup := newOpenAIServer(t, "backup", 0)
+	defer backup.Close()
+
+	client := NewFallbackClient(newTestClient(t, "openai", slow.URL), newTestClient(t, "openai", backup.URL), 100*time.Millisecond)
+	start := time.Now()
+	result, err := client.GetCompletion(testContext(), "你好")
+	if err != nil {
+		t.Fatalf("备用模型请求失败: %v", err)
+	}
+	if result != "backup" {
+		t.Errorf("期望备用模型返回, 实际 %s", result)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("超时未生效，耗时 %v", time.Since(start))
+	}
+}
+
+func TestAzureCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt-deploy/chat/completions" {
+			t.Errorf("期望按部署名称请求, 实际 %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("api-version 错误: %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("api-key") != "test-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("缺少 Azure 鉴权头: %v", r.Header)
+		}
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if _, ok := req["stream_options"]; ok {
+			t.Errorf("Azure 请求不应携带 stream_options")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "azure"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient("azure")
+	err := client.Configure(&Provider{Model: "gpt-4o", Engine: "gpt-deploy", ApiVersion: "2024-06-01", Password: "test-key", BaseURL: server.URL, MaxHistory: 10})
+	if err != nil {
+		t.Fatalf("配置客户端失败: %v", err)
+	}
+	result, err := client.GetCompletion(testContext(), "你好")
+	if err != nil || result != "azure" {
+		t.Errorf("请求失败: %s %v", result, err)
+	}
+
+	if err := NewClient("azure").Configure(&Provider{Model: "gpt-4o", Password: "test-key"}); err == nil {
+		t.Error("未配置 API 地址时应返回错误")
+	}
+}
+
+func TestFallbackStreamRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, content := range []string{"hello", " world"} {
+			data, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+				Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: content}}},
+			})
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+			// 第二个分片晚于超时时间到达，连接建立后的读取不应被取消
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewFallbackClient(newTestClient(t, "openai", server.URL), nil, 100*time.Millisecond)
+	stream, err := client.GetStreamCompletion(testContext(), "你好")
+	if err != nil {
+		t.Fatalf("流式请求失败: %v", err)
+	}
+	var content strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取流失败: %v", err)
+		}
+		if len(resp.Choices) > 0 {
+			content.WriteString(resp.Choices[0].Delta.Content)
+		}
+	}
+	_ = stream.Close()
+	if content.String() != "hello world" {
+		t.Errorf("流内容错误: %q", content.String())
+	}
+
+	// 响应 Body 关闭时释放流式请求的 context
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := &streamReleaseTransport{Origin: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})}
+	req, _ := http.NewRequestWithContext(context.WithValue(ctx, streamCancelKey{}, cancel), http.MethodPost, server.URL, nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("读取完成前不应释放 context")
+	}
+	_ = resp.Body.Close()
+	if ctx.Err() == nil {
+		t.Error("关闭 Body 后应释放 context")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestUsageRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: "test-model",
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "ok"}},
+			},
+			Usage: openai.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10},
+		})
+	}))
+	defer server.Close()
+
+	usages := make(chan *Usage, 1)
+	SetUsageRecorder(func(ctx context.Context, u *Usage) { usages <- u })
+	defer SetUsageRecorder(nil)
+
+	client := newTestClient(t, "openai", server.URL)
+	ctx := WithCluster(WithFeature(testContext(), constants.AIFeatureLog), "c1")
+	if _, err := client.GetCompletion(ctx, "你好"); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	select {
+	case u := <-usages:
+		if u.Username != "tester" || u.Feature != constants.AIFeatureLog || u.Cluster != "c1" || u.Model != "test-model" {
+			t.Errorf("用量归属错误: %+v", u)
+		}
+		if u.TotalTokens != 10 || u.Estimated {
+			t.Errorf("用量统计错误: %+v", u)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未记录用量")
+	}
+}
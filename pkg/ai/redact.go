@@ -0,0 +1,323 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 脱敏类别
+const (
+	RedactKindSecret   = "SECRET"
+	RedactKindEnv      = "ENV"
+	RedactKindToken    = "TOKEN"
+	RedactKindPassword = "PASSWORD"
+	RedactKindIP       = "IP"
+	RedactKindEmail    = "EMAIL"
+)
+
+// DefaultRedactEnvPatterns 默认需要脱敏的环境变量名规则
+var DefaultRedactEnvPatterns = []string{
+	`(?i)passw(or)?d`, `(?i)secret`, `(?i)token`, `(?i)api_?key`, `(?i)access_?key`, `(?i)private_?key`, `(?i)credential`,
+}
+
+var (
+	redactMu          sync.RWMutex
+	redactEnabled     bool
+	redactEnvPatterns []*regexp.Regexp
+)
+
+// SetRedactOptions 设置发送给大模型前的脱敏选项，envPatterns 为空时使用默认规则
+func SetRedactOptions(enabled bool, envPatterns []string) error {
+	var patterns []string
+	for _, p := range envPatterns {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = DefaultRedactEnvPatterns
+	}
+	var compiled []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("环境变量脱敏规则[%s]错误: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	redactEnabled = enabled
+	redactEnvPatterns = compiled
+	return nil
+}
+
+// newRedactorFromOptions 按当前选项创建脱敏器，未启用时返回 nil
+func newRedactorFromOptions() *Redactor {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+	if !redactEnabled {
+		return nil
+	}
+	return NewRedactor(redactEnvPatterns)
+}
+
+// RedactedItem 一条脱敏记录，Preview 仅保留原值首尾字符，不记录原文
+type RedactedItem struct {
+	Kind        string `json:"kind"`
+	Placeholder string `json:"placeholder"`
+	Preview     string `json:"preview"`
+}
+
+// Redactor 将敏感信息替换为可还原的占位符
+// 同一个值在一次请求内始终对应同一个占位符，大模型在回答中引用占位符时可还原为原值
+type Redactor struct {
+	envPatterns  []*regexp.Regexp
+	placeholders map[string]string // 原值 -> 占位符
+	originals    map[string]string // 占位符 -> 原值
+	counters     map[string]int
+	items        []RedactedItem
+}
+
+// NewRedactor 创建脱敏器，envPatterns 用于匹配需要脱敏的环境变量名
+func NewRedactor(envPatterns []*regexp.Regexp) *Redactor {
+	return &Redactor{
+		envPatterns:  envPatterns,
+		placeholders: map[string]string{},
+		originals:    map[string]string{},
+		counters:     map[string]int{},
+	}
+}
+
+var (
+	placeholderRe = regexp.MustCompile(`\[\[REDACTED_[A-Z]+_\d+\]\]`)
+
+	secretKindYAMLRe = regexp.MustCompile(`(?m)^[ \t]*kind:[ \t]*["']?Secret["']?[ \t]*$`)
+	secretKindJSONRe = regexp.MustCompile(`"kind"\s*:\s*"Secret"`)
+	secretDataJSONRe = regexp.MustCompile(`("(?:data|stringData)"\s*:\s*\{)([^{}]*)(\})`)
+	jsonStringKVRe   = regexp.MustCompile(`("[^"]*"\s*:\s*")((?:[^"\\]|\\.)*)(")`)
+	privateKeyRe     = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+
+	// 环境变量：YAML 的 name/value、JSON 的 name/value、NAME=value、kubectl describe 的 NAME: value
+	envYAMLRe     = regexp.MustCompile(`(?m)^([ \t]*-?[ \t]*name:[ \t]*["']?)([A-Za-z_][A-Za-z0-9_.-]*)(["']?[ \t]*\r?\n[ \t]*value:[ \t]*)(.*?)[ \t]*$`)
+	envJSONRe     = regexp.MustCompile(`("name"\s*:\s*"([A-Za-z_][A-Za-z0-9_.-]*)"\s*,\s*"value"\s*:\s*")((?:[^"\\]|\\.)*)(")`)
+	envAssignRe   = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|[^\s"',;&]+)`)
+	envDescribeRe = regexp.MustCompile(`(?m)^([ \t]+([A-Z_][A-Z0-9_]*):[ \t]+)(\S.*?)[ \t]*$`)
+
+	passwordRe = regexp.MustCompile(`(?i)((?:"|')?\b(?:password|passwd|pwd)\b(?:"|')?[ \t]*[:=][ \t]*(?:"|')?)([^\s"',}]+)`)
+	urlCredRe  = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:)([^\s@/]+)(@)`)
+
+	tokenRes = []*regexp.Regexp{
+		regexp.MustCompile(`eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]+`),
+		regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{16,}|gh[pousr]_[A-Za-z0-9]{30,}|AKIA[0-9A-Z]{16}|xox[abprs]-[A-Za-z0-9-]{10,})\b`),
+	}
+	bearerRe = regexp.MustCompile(`(?i)(\bbearer[ \t]+)([A-Za-z0-9\-._~+/]{8,}=*)`)
+
+	emailRe = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+	ipv4Re  = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+)
+
+// Redact 对文本脱敏
+func (r *Redactor) Redact(text string) string {
+	if text == "" {
+		return text
+	}
+	text = r.redactSecretData(text)
+	text = privateKeyRe.ReplaceAllStringFunc(text, func(s string) string {
+		return r.mask(RedactKindSecret, s)
+	})
+
+	text = replaceSubmatch(envYAMLRe, text, func(g []string) string {
+		if !r.matchEnv(g[2]) {
+			return g[0]
+		}
+		return g[1] + g[2] + g[3] + r.maskQuoted(RedactKindEnv, g[4])
+	})
+	text = replaceSubmatch(envJSONRe, text, func(g []string) string {
+		if !r.matchEnv(g[2]) {
+			return g[0]
+		}
+		return g[1] + r.mask(RedactKindEnv, g[3]) + g[4]
+	})
+	text = replaceSubmatch(envAssignRe, text, func(g []string) string {
+		if !r.matchEnv(g[1]) {
+			return g[0]
+		}
+		return g[1] + "=" + r.maskQuoted(RedactKindEnv, g[2])
+	})
+	text = replaceSubmatch(envDescribeRe, text, func(g []string) string {
+		if !r.matchEnv(g[2]) {
+			return g[0]
+		}
+		return g[1] + r.mask(RedactKindEnv, g[3])
+	})
+
+	text = replaceSubmatch(passwordRe, text, func(g []string) string {
+		return g[1] + r.mask(RedactKindPassword, g[2])
+	})
+	text = replaceSubmatch(urlCredRe, text, func(g []string) string {
+		return g[1] + r.mask(RedactKindPassword, g[2]) + g[3]
+	})
+
+	for _, re := range tokenRes {
+		text = re.ReplaceAllStringFunc(text, func(s string) string {
+			return r.mask(RedactKindToken, s)
+		})
+	}
+	text = replaceSubmatch(bearerRe, text, func(g []string) string {
+		return g[1] + r.mask(RedactKindToken, g[2])
+	})
+
+	text = emailRe.ReplaceAllStringFunc(text, func(s string) string {
+		return r.mask(RedactKindEmail, s)
+	})
+	text = ipv4Re.ReplaceAllStringFunc(text, func(s string) string {
+		// 本地回环及通配地址不含敏感信息，保留以便大模型理解
+		if s == "127.0.0.1" || s == "0.0.0.0" {
+			return s
+		}
+		return r.mask(RedactKindIP, s)
+	})
+	return text
+}
+
+// redactSecretData 对 Secret 资源 data、stringData 中的值脱敏，支持 YAML 与 JSON
+func (r *Redactor) redactSecretData(text string) string {
+	isYAML := secretKindYAMLRe.MatchString(text)
+	if !isYAML && !secretKindJSONRe.MatchString(text) {
+		return text
+	}
+	// JSON 格式，同时覆盖 YAML 中 last-applied-configuration 注解内的 JSON
+	text = replaceSubmatch(secretDataJSONRe, text, func(g []string) string {
+		body := replaceSubmatch(jsonStringKVRe, g[2], func(kv []string) string {
+			return kv[1] + r.mask(RedactKindSecret, kv[2]) + kv[3]
+		})
+		return g[1] + body + g[3]
+	})
+	if !isYAML {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	inData := false
+	dataIndent, keyIndent := 0, -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if inData {
+			if trimmed == "" {
+				continue
+			}
+			if indent <= dataIndent {
+				inData = false
+			} else {
+				if keyIndent < 0 {
+					keyIndent = indent
+				}
+				if indent > keyIndent {
+					// 多行值的后续行
+					lines[i] = line[:indent] + r.mask(RedactKindSecret, trimmed)
+					continue
+				}
+				if k, v, ok := strings.Cut(line[indent:], ":"); ok {
+					v = strings.TrimSpace(v)
+					if v != "" && !strings.HasPrefix(v, "|") && !strings.HasPrefix(v, ">") {
+						lines[i] = line[:indent] + k + ": " + r.maskQuoted(RedactKindSecret, v)
+					}
+				}
+				continue
+			}
+		}
+		if trimmed == "data:" || trimmed == "stringData:" {
+			inData = true
+			dataIndent = indent
+			keyIndent = -1
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Redactor) matchEnv(name string) bool {
+	for _, re := range r.envPatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskQuoted 对可能带引号的值脱敏，保留引号
+func (r *Redactor) maskQuoted(kind string, v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[:1] + r.mask(kind, v[1:len(v)-1]) + v[len(v)-1:]
+	}
+	return r.mask(kind, v)
+}
+
+// mask 返回值对应的占位符，空值、已脱敏的值及 Kubernetes 引用描述保持不变
+func (r *Redactor) mask(kind string, value string) string {
+	if value == "" || placeholderRe.MatchString(value) || strings.HasPrefix(value, "<set to the key") {
+		return value
+	}
+	if p, ok := r.placeholders[value]; ok {
+		return p
+	}
+	r.counters[kind]++
+	p := fmt.Sprintf("[[REDACTED_%s_%d]]", kind, r.counters[kind])
+	r.placeholders[value] = p
+	r.originals[p] = value
+	r.items = append(r.items, RedactedItem{Kind: kind, Placeholder: p, Preview: redactPreview(value)})
+	return p
+}
+
+// Restore 将文本中的占位符还原为原值
+func (r *Redactor) Restore(text string) string {
+	if len(r.originals) == 0 {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(p string) string {
+		if v, ok := r.originals[p]; ok {
+			return v
+		}
+		return p
+	})
+}
+
+// Items 本次脱敏的记录
+func (r *Redactor) Items() []RedactedItem {
+	return r.items
+}
+
+// redactPreview 保留首尾字符用于审计，短值全部隐藏
+func redactPreview(value string) string {
+	runes := []rune(value)
+	if len(runes) < 8 {
+		return "****"
+	}
+	return string(runes[:2]) + "****" + string(runes[len(runes)-2:])
+}
+
+// replaceSubmatch 与 ReplaceAllStringFunc 类似，但回调可获取各分组
+func replaceSubmatch(re *regexp.Regexp, text string, fn func(groups []string) string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		groups := make([]string, len(m)/2)
+		for i := range groups {
+			if m[2*i] >= 0 {
+				groups[i] = text[m[2*i]:m[2*i+1]]
+			}
+		}
+		sb.WriteString(text[last:m[0]])
+		sb.WriteString(fn(groups))
+		last = m[1]
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
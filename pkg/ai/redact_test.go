@@ -0,0 +1,177 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func newTestRedactor() *Redactor {
+	var patterns []*regexp.Regexp
+	for _, p := range DefaultRedactEnvPatterns {
+		patterns = append(patterns, regexp.MustCompile(p))
+	}
+	return NewRedactor(patterns)
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		secrets []string // 脱敏后不应出现的内容
+		keeps   []string // 脱敏后应保留的内容
+	}{
+		{
+			name:    "Secret YAML",
+			input:   "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  username: YWRtaW4=\n  password: cGFzc3dvcmQxMjM=\ntype: Opaque",
+			secrets: []string{"YWRtaW4=", "cGFzc3dvcmQxMjM="},
+			keeps:   []string{"username:", "type: Opaque", "name: db"},
+		},
+		{
+			name:    "Secret JSON",
+			input:   `{"kind":"Secret","data":{"token":"c2VjcmV0dG9rZW4="}}`,
+			secrets: []string{"c2VjcmV0dG9rZW4="},
+		},
+		{
+			name:    "环境变量 YAML",
+			input:   "env:\n- name: DB_PASSWORD\n  value: \"p@ssw0rd!\"\n- name: LOG_LEVEL\n  value: debug",
+			secrets: []string{"p@ssw0rd!"},
+			keeps:   []string{"DB_PASSWORD", "value: debug"},
+		},
+		{
+			name:    "环境变量赋值",
+			input:   "export API_KEY=abcdef123456 HOME=/root",
+			secrets: []string{"abcdef123456"},
+			keeps:   []string{"HOME=/root"},
+		},
+		{
+			name:    "Token 与密码",
+			input:   "Authorization: Bearer abcdefghijklmnop\nmysql://root:rootpass@db:3306\nlogin password=hunter22",
+			secrets: []string{"abcdefghijklmnop", "rootpass", "hunter22"},
+		},
+		{
+			name:    "IP 与邮箱",
+			input:   "connect to 10.0.12.5 failed, contact ops@example.com, listen 0.0.0.0",
+			secrets: []string{"10.0.12.5", "ops@example.com"},
+			keeps:   []string{"0.0.0.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedactor()
+			out := r.Redact(tt.input)
+			for _, s := range tt.secrets {
+				if strings.Contains(out, s) {
+					t.Errorf("脱敏后仍包含 %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.keeps {
+				if !strings.Contains(out, s) {
+					t.Errorf("脱敏后缺少 %q:\n%s", s, out)
+				}
+			}
+			if restored := r.Restore(out); restored != tt.input {
+				t.Errorf("还原结果不一致:\n期望 %s\n实际 %s", tt.input, restored)
+			}
+		})
+	}
+}
+
+func TestRedactStreamRestore(t *testing.T) {
+	if err := SetRedactOptions(true, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetRedactOptions(false, nil) }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		last := req.Messages[len(req.Messages)-1].Content
+		if strings.Contains(last, "10.1.2.3") {
+			t.Errorf("请求中包含未脱敏的 IP: %s", last)
+		}
+		placeholder := placeholderRe.FindString(last)
+		if placeholder == "" {
+			t.Fatalf("请求中缺少占位符: %s", last)
+		}
+		// 占位符被拆分到多个分片中
+		parts := []string{"节点 ", placeholder[:5], placeholder[5:12], placeholder[12:] + " 不可达"}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, p := range parts {
+			chunk := openai.ChatCompletionStreamResponse{Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: p}}}}
+			data, _ := json.Marshal(chunk)
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, "openai", server.URL)
+	stream, err := client.GetStreamCompletion(context.Background(), "节点 10.1.2.3 的状态")
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取流失败: %v", err)
+		}
+		for _, choice := range resp.Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+	if content.String() != "节点 10.1.2.3 不可达" {
+		t.Errorf("还原结果错误: %s", content.String())
+	}
+}
+
+func TestRedactEmbeddings(t *testing.T) {
+	if err := SetRedactOptions(true, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetRedactOptions(false, nil) }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/embeddings") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		resp := openai.EmbeddingResponse{}
+		for i, text := range req.Input {
+			if strings.Contains(text, "10.1.2.3") || strings.Contains(text, "admin@example.com") {
+				t.Errorf("向量化请求中包含未脱敏的内容: %s", text)
+			}
+			resp.Data = append(resp.Data, openai.Embedding{Index: i, Embedding: []float32{1, 0}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, "openai", server.URL).(Embedder)
+	vectors, err := client.Embed(context.Background(), []string{"节点 10.1.2.3 不可达", "联系 admin@example.com"})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if len(vectors) != 2 {
+		t.Errorf("期望 2 个向量，实际 %d", len(vectors))
+	}
+}
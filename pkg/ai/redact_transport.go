@@ -0,0 +1,368 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RedactionRecorder 脱敏记录回调，由 service 层注册
+type RedactionRecorder func(ctx context.Context, items []RedactedItem)
+
+var (
+	redactionRecorderMu sync.RWMutex
+	redactionRecorder   RedactionRecorder
+)
+
+// SetRedactionRecorder 注册脱敏记录回调
+func SetRedactionRecorder(fn RedactionRecorder) {
+	redactionRecorderMu.Lock()
+	defer redactionRecorderMu.Unlock()
+	redactionRecorder = fn
+}
+
+func recordRedaction(ctx context.Context, items []RedactedItem) {
+	redactionRecorderMu.RLock()
+	fn := redactionRecorder
+	redactionRecorderMu.RUnlock()
+	if fn != nil && len(items) > 0 {
+		fn(ctx, items)
+	}
+}
+
+// redactTransport 在发送给大模型前对消息脱敏，并在响应中还原占位符
+// 位于最外层，处理的始终是 OpenAI 格式的请求与响应，适用于所有 provider
+type redactTransport struct {
+	Origin http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *redactTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := newRedactorFromOptions()
+	chat := strings.HasSuffix(req.URL.Path, "/chat/completions")
+	embeddings := strings.HasSuffix(req.URL.Path, "/embeddings")
+	if r == nil || req.Body == nil || !chat && !embeddings {
+		return t.Origin.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var redacted []byte
+	var stream, ok bool
+	if chat {
+		redacted, stream, ok = redactRequestBody(r, body)
+	} else {
+		redacted, ok = redactEmbeddingBody(r, body)
+	}
+	if !ok || len(r.Items()) == 0 {
+		return t.Origin.RoundTrip(withBody(req, body))
+	}
+	recordRedaction(req.Context(), r.Items())
+
+	resp, err := t.Origin.RoundTrip(withBody(req, redacted))
+	// 向量结果中不含占位符，无需还原
+	if err != nil || resp.StatusCode >= http.StatusBadRequest || embeddings {
+		return resp, err
+	}
+	if stream || strings.Contains(resp.Header.Get("Content-Type"), "event-stream") {
+		resp.Body = newRestoreStreamReader(r, resp.Body)
+	} else {
+		data, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(restoreJSON(r, string(data)))
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		resp.ContentLength = int64(len(data))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	return resp, nil
+}
+
+func withBody(req *http.Request, body []byte) *http.Request {
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req
+}
+
+// redactRequestBody 对 messages 中的文本内容及工具调用参数脱敏
+func redactRequestBody(r *Redactor, body []byte) ([]byte, bool, bool) {
+	var payload map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, false, false
+	}
+	stream, _ := payload["stream"].(bool)
+	messages, _ := payload["messages"].([]any)
+	for _, m := range messages {
+		msg, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch content := msg["content"].(type) {
+		case string:
+			msg["content"] = r.Redact(content)
+		case []any:
+			for _, p := range content {
+				if part, ok := p.(map[string]any); ok {
+					if text, ok := part["text"].(string); ok {
+						part["text"] = r.Redact(text)
+					}
+				}
+			}
+		}
+		toolCalls, _ := msg["tool_calls"].([]any)
+		for _, tc := range toolCalls {
+			if call, ok := tc.(map[string]any); ok {
+				if fn, ok := call["function"].(map[string]any); ok {
+					if args, ok := fn["arguments"].(string); ok {
+						fn["arguments"] = r.Redact(args)
+					}
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(payload); err != nil {
+		return nil, false, false
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), stream, true
+}
+
+// redactEmbeddingBody 对 embeddings 请求的 input 脱敏，input 可为字符串或字符串数组
+func redactEmbeddingBody(r *Redactor, body []byte) ([]byte, bool) {
+	var payload map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, false
+	}
+	switch input := payload["input"].(type) {
+	case string:
+		payload["input"] = r.Redact(input)
+	case []any:
+		for i, v := range input {
+			if text, ok := v.(string); ok {
+				input[i] = r.Redact(text)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(payload); err != nil {
+		return nil, false
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), true
+}
+
+// restoreJSON 在 JSON 文本中还原占位符，原值按 JSON 字符串转义
+func restoreJSON(r *Redactor, data string) string {
+	return placeholderRe.ReplaceAllStringFunc(data, func(p string) string {
+		v, ok := r.originals[p]
+		if !ok {
+			return p
+		}
+		quoted, _ := json.Marshal(v)
+		return string(quoted[1 : len(quoted)-1])
+	})
+}
+
+// restoreStreamReader 逐行还原 SSE 流中的占位符
+// 占位符可能被拆分到多个分片中，分片末尾疑似未完整的占位符会暂存，与下一分片合并后再还原
+type restoreStreamReader struct {
+	r       *Redactor
+	src     io.ReadCloser
+	scanner *bufio.Reader
+	out     bytes.Buffer
+	pending map[string]string
+	last    map[string]any
+	err     error
+}
+
+func newRestoreStreamReader(r *Redactor, src io.ReadCloser) *restoreStreamReader {
+	return &restoreStreamReader{
+		r:       r,
+		src:     src,
+		scanner: bufio.NewReader(src),
+		pending: map[string]string{},
+	}
+}
+
+func (s *restoreStreamReader) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 && s.err == nil {
+		line, err := s.scanner.ReadString('\n')
+		if line != "" {
+			s.processLine(line)
+		}
+		if err != nil {
+			s.flushPending()
+			s.err = err
+		}
+	}
+	if s.out.Len() > 0 {
+		return s.out.Read(p)
+	}
+	return 0, s.err
+}
+
+func (s *restoreStreamReader) Close() error {
+	return s.src.Close()
+}
+
+func (s *restoreStreamReader) processLine(line string) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "data:") {
+		s.out.WriteString(line)
+		return
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "[DONE]" {
+		s.flushPending()
+		s.out.WriteString(line)
+		return
+	}
+	var chunk map[string]any
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&chunk); err != nil {
+		s.out.WriteString(line)
+		return
+	}
+	s.last = chunk
+
+	choices, _ := chunk["choices"].([]any)
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		delta, _ := choice["delta"].(map[string]any)
+		if delta == nil {
+			continue
+		}
+		finished := choice["finish_reason"] != nil && choice["finish_reason"] != ""
+		if content, ok := delta["content"].(string); ok {
+			delta["content"] = s.restore("content:"+strconv.Itoa(i), content, finished)
+		}
+		toolCalls, _ := delta["tool_calls"].([]any)
+		for j, tc := range toolCalls {
+			if call, ok := tc.(map[string]any); ok {
+				if fn, ok := call["function"].(map[string]any); ok {
+					if args, ok := fn["arguments"].(string); ok {
+						key := "tool:" + strconv.Itoa(i) + ":" + strconv.Itoa(j)
+						if idx, ok := call["index"].(json.Number); ok {
+							key = "tool:" + strconv.Itoa(i) + ":" + idx.String()
+						}
+						fn["arguments"] = s.restore(key, args, finished)
+					}
+				}
+			}
+		}
+	}
+	s.writeChunk(chunk)
+}
+
+// restore 还原分片内容，返回可以立即输出的部分
+func (s *restoreStreamReader) restore(key string, text string, final bool) string {
+	text = s.pending[key] + text
+	delete(s.pending, key)
+	if !final {
+		if idx := partialPlaceholderIndex(text); idx >= 0 {
+			s.pending[key] = text[idx:]
+			text = text[:idx]
+		}
+	}
+	return s.r.Restore(text)
+}
+
+// flushPending 流结束前输出所有暂存内容
+func (s *restoreStreamReader) flushPending() {
+	if len(s.pending) == 0 || s.last == nil {
+		return
+	}
+	for key, text := range s.pending {
+		parts := strings.Split(key, ":")
+		idx, _ := strconv.Atoi(parts[1])
+		delta := map[string]any{}
+		if parts[0] == "content" {
+			delta["content"] = s.r.Restore(text)
+		} else {
+			toolIdx, _ := strconv.Atoi(parts[2])
+			delta["tool_calls"] = []any{map[string]any{
+				"index":    toolIdx,
+				"function": map[string]any{"arguments": s.r.Restore(text)},
+			}}
+		}
+		chunk := map[string]any{}
+		for k, v := range s.last {
+			chunk[k] = v
+		}
+		chunk["choices"] = []any{map[string]any{"index": idx, "delta": delta}}
+		delete(chunk, "usage")
+		s.writeChunk(chunk)
+		s.out.WriteString("\n")
+	}
+	s.pending = map[string]string{}
+}
+
+func (s *restoreStreamReader) writeChunk(chunk map[string]any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(chunk); err != nil {
+		return
+	}
+	s.out.WriteString("data: ")
+	s.out.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	s.out.WriteString("\n")
+}
+
+// partialPlaceholderIndex 返回文本末尾疑似未完整占位符的起始位置，没有则返回 -1
+func partialPlaceholderIndex(text string) int {
+	const prefix = "[[REDACTED_"
+	start := strings.LastIndex(text, "[")
+	if start < 0 {
+		return -1
+	}
+	// 向前找到连续的 [
+	if start > 0 && text[start-1] == '[' {
+		start--
+	}
+	tail := text[start:]
+	if strings.Contains(tail, "]]") {
+		return -1
+	}
+	if len(tail) <= len(prefix) {
+		if strings.HasPrefix(prefix, tail) {
+			return start
+		}
+		return -1
+	}
+	if !strings.HasPrefix(tail, prefix) || len(tail) > 40 {
+		return -1
+	}
+	for _, c := range tail[len(prefix):] {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == ']') {
+			return -1
+		}
+	}
+	return start
+}
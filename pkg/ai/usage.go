@@ -0,0 +1,270 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/weibaohui/k8m/pkg/constants"
+)
+
+// Usage 一次大模型请求的用量
+type Usage struct {
+	Username         string
+	Feature          constants.AIFeature
+	Cluster          string
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Latency          time.Duration
+	Estimated        bool // 服务端未返回用量时，按字符数估算
+	Err              error
+}
+
+// UsageRecorder 用量记录回调，由 service 层注册
+type UsageRecorder func(ctx context.Context, u *Usage)
+
+var (
+	recorderMu    sync.RWMutex
+	usageRecorder UsageRecorder
+)
+
+// SetUsageRecorder 注册用量记录回调
+func SetUsageRecorder(fn UsageRecorder) {
+	recorderMu.Lock()
+	defer recorderMu.Unlock()
+	usageRecorder = fn
+}
+
+func recordUsage(ctx context.Context, u *Usage) {
+	recorderMu.RLock()
+	fn := usageRecorder
+	recorderMu.RUnlock()
+	if fn != nil {
+		fn(ctx, u)
+	}
+}
+
+type featureKey struct{}
+type clusterKey struct{}
+
+// WithFeature 在 context 中标记本次请求所属的功能场景，用于用量统计
+func WithFeature(ctx context.Context, feature constants.AIFeature) context.Context {
+	return context.WithValue(ctx, featureKey{}, feature)
+}
+
+// FeatureFromContext 获取 context 中的功能场景
+func FeatureFromContext(ctx context.Context) constants.AIFeature {
+	feature, _ := ctx.Value(featureKey{}).(constants.AIFeature)
+	return feature
+}
+
+// WithCluster 在 context 中标记本次请求相关的集群，用于用量统计
+func WithCluster(ctx context.Context, cluster string) context.Context {
+	return context.WithValue(ctx, clusterKey{}, cluster)
+}
+
+// ClusterFromContext 获取 context 中的集群
+func ClusterFromContext(ctx context.Context) string {
+	cluster, _ := ctx.Value(clusterKey{}).(string)
+	return cluster
+}
+
+// tokenCounter 粗略估算 Token 数：ASCII 字符约 4 个一个 Token，其余字符（如中文）约 1 个一个 Token
+type tokenCounter struct {
+	ascii int
+	other int
+}
+
+func (t *tokenCounter) add(s string) {
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			t.ascii++
+		} else {
+			t.other++
+		}
+	}
+}
+
+func (t *tokenCounter) tokens() int {
+	return t.other + (t.ascii+3)/4
+}
+
+// usageTransport 在 HTTP 层统计每次请求的 Token 用量与耗时，适用于所有 provider
+type usageTransport struct {
+	Origin   http.RoundTripper
+	Provider string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	u := &Usage{
+		Username: getUsernameFromContext(ctx),
+		Feature:  FeatureFromContext(ctx),
+		Cluster:  ClusterFromContext(ctx),
+		Provider: t.Provider,
+	}
+	start := time.Now()
+	var promptBytes []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			promptBytes, _ = io.ReadAll(body)
+			_ = body.Close()
+		}
+	}
+	var reqBody struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(promptBytes, &reqBody)
+	u.Model = reqBody.Model
+
+	resp, err := t.Origin.RoundTrip(req)
+	if err != nil {
+		u.Latency = time.Since(start)
+		u.Err = err
+		recordUsage(ctx, u)
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		u.Latency = time.Since(start)
+		u.Err = fmt.Errorf("status code: %d", resp.StatusCode)
+		recordUsage(ctx, u)
+		return resp, nil
+	}
+
+	resp.Body = &usageReader{
+		ReadCloser: resp.Body,
+		stream:     strings.Contains(resp.Header.Get("Content-Type"), "event-stream"),
+		onDone: func(result *usageResult, readErr error) {
+			u.Latency = time.Since(start)
+			if result.model != "" {
+				u.Model = result.model
+			}
+			if result.usage != nil && result.usage.TotalTokens > 0 {
+				u.PromptTokens = result.usage.PromptTokens
+				u.CompletionTokens = result.usage.CompletionTokens
+				u.TotalTokens = result.usage.TotalTokens
+			} else {
+				u.Estimated = true
+				var prompt tokenCounter
+				prompt.add(string(promptBytes))
+				u.PromptTokens = prompt.tokens()
+				u.CompletionTokens = result.content.tokens()
+				u.TotalTokens = u.PromptTokens + u.CompletionTokens
+			}
+			if readErr != nil && readErr != io.EOF {
+				u.Err = readErr
+			}
+			recordUsage(ctx, u)
+		},
+	}
+	return resp, nil
+}
+
+type usageResult struct {
+	model   string
+	usage   *openai.Usage
+	content tokenCounter
+}
+
+// usageReader 透传响应体，同时从中解析用量信息，读取结束或关闭时回调一次
+type usageReader struct {
+	io.ReadCloser
+	stream bool
+	onDone func(result *usageResult, err error)
+
+	once    sync.Once
+	buf     bytes.Buffer
+	result  usageResult
+	readErr error
+}
+
+const maxUsageBufferSize = 4 * 1024 * 1024
+
+func (r *usageReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && r.buf.Len() < maxUsageBufferSize {
+		r.buf.Write(p[:n])
+		if r.stream {
+			r.consumeLines()
+		}
+	}
+	if err != nil {
+		r.readErr = err
+		r.finish()
+	}
+	return n, err
+}
+
+func (r *usageReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.finish()
+	return err
+}
+
+func (r *usageReader) finish() {
+	r.once.Do(func() {
+		if !r.stream {
+			var resp openai.ChatCompletionResponse
+			if err := json.Unmarshal(r.buf.Bytes(), &resp); err == nil {
+				r.result.model = resp.Model
+				r.result.usage = &resp.Usage
+				for _, choice := range resp.Choices {
+					r.addContent(choice.Message.Content)
+				}
+			}
+		}
+		r.onDone(&r.result, r.readErr)
+	})
+}
+
+// consumeLines 逐行解析 SSE 数据，仅保留未完成的最后一行
+func (r *usageReader) consumeLines() {
+	for {
+		data := r.buf.Bytes()
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			return
+		}
+		line := strings.TrimSpace(string(data[:idx]))
+		r.buf.Next(idx + 1)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if line == "[DONE]" {
+			continue
+		}
+		var chunk openai.ChatCompletionStreamResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			continue
+		}
+		if chunk.Model != "" {
+			r.result.model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			r.result.usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			r.addContent(choice.Delta.Content)
+			for _, tc := range choice.Delta.ToolCalls {
+				r.addContent(tc.Function.Name + tc.Function.Arguments)
+			}
+		}
+	}
+}
+
+func (r *usageReader) addContent(content string) {
+	r.result.content.add(content)
+}
@@ -0,0 +1,122 @@
+package kms
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// envelopePrefix 信封加密数据的前缀，格式为 enc:v1:<主密钥ID>:<加密的数据密钥>:<加密的数据>
+const envelopePrefix = "enc:v1:"
+
+// IsEncrypted 判断是否为信封加密后的数据
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// Encrypt 信封加密：每个值使用随机数据密钥加密，数据密钥再由 KMS 主密钥加密
+// 空值与已加密的值原样返回
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return encryptWith(k, plaintext)
+}
+
+// Decrypt 解密信封加密的数据，未加密的历史数据原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return decryptWith(k, value)
+}
+
+// Rewrap 使用当前主密钥重新加密数据密钥，数据本身不变。返回新值及是否发生变化
+func Rewrap(value string) (string, bool, error) {
+	if !IsEncrypted(value) {
+		return value, false, nil
+	}
+	k, err := Default()
+	if err != nil {
+		return "", false, err
+	}
+	keyID, wrapped, data, err := parseEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.ActiveKeyID() {
+		return value, false, nil
+	}
+	dek, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	newKeyID, newWrapped, err := k.Wrap(dek)
+	if err != nil {
+		return "", false, err
+	}
+	return formatEnvelope(newKeyID, newWrapped, data), true, nil
+}
+
+func encryptWith(k KMS, plaintext string) (string, error) {
+	dek := make([]byte, masterKeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	data, err := sealGCM(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", fmt.Errorf("加密数据失败: %w", err)
+	}
+	keyID, wrapped, err := k.Wrap(dek)
+	if err != nil {
+		return "", fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+	return formatEnvelope(keyID, wrapped, data), nil
+}
+
+func decryptWith(k KMS, value string) (string, error) {
+	keyID, wrapped, data, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %w", err)
+	}
+	plaintext, err := openGCM(dek, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("解密数据失败: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func formatEnvelope(keyID string, wrapped, data []byte) string {
+	return envelopePrefix + keyID + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(data)
+}
+
+func parseEnvelope(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, fmt.Errorf("加密数据格式错误")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("加密数据格式错误: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("加密数据格式错误: %w", err)
+	}
+	return parts[0], wrapped, data, nil
+}
@@ -0,0 +1,78 @@
+package kms
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/weibaohui/k8m/pkg/flag"
+)
+
+// KMS 密钥管理服务，负责数据密钥的加密（wrap）与解密（unwrap），主密钥不离开 KMS
+type KMS interface {
+	// Name 服务名称
+	Name() string
+	// ActiveKeyID 当前用于加密的主密钥ID
+	ActiveKeyID() string
+	// Wrap 使用当前主密钥加密数据密钥，返回主密钥ID与密文
+	Wrap(dek []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap 使用指定主密钥解密数据密钥
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Rotator 支持生成新主密钥的 KMS 实现
+type Rotator interface {
+	// Rotate 生成新的主密钥并设为当前密钥，历史密钥保留用于解密
+	Rotate() (keyID string, err error)
+}
+
+// Factory 根据启动参数创建 KMS
+type Factory func(cfg *flag.Config) (KMS, error)
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Factory{
+		"local": newLocalKMSFromConfig,
+	}
+	current KMS
+)
+
+// Register 注册 KMS 实现，通过 --kms-provider 参数选择
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[name] = factory
+}
+
+// SetDefault 设置当前使用的 KMS
+func SetDefault(k KMS) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = k
+}
+
+// Default 获取当前使用的 KMS，首次调用时根据启动参数初始化
+func Default() (KMS, error) {
+	mu.RLock()
+	k := current
+	mu.RUnlock()
+	if k != nil {
+		return k, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		return current, nil
+	}
+	cfg := flag.Init()
+	factory, ok := providers[cfg.KMSProvider]
+	if !ok {
+		return nil, fmt.Errorf("不支持的KMS类型: %s", cfg.KMSProvider)
+	}
+	k, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("初始化KMS[%s]失败: %w", cfg.KMSProvider, err)
+	}
+	current = k
+	return current, nil
+}
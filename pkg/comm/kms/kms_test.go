@@ -0,0 +1,175 @@
+package kms
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/flag"
+)
+
+func TestEnvelope(t *testing.T) {
+	dir := t.TempDir()
+	k, err := newLocalKMSFromConfig(&flag.Config{MasterKeyFile: filepath.Join(dir, "master.key")})
+	if err != nil {
+		t.Fatalf("初始化KMS失败: %v", err)
+	}
+	SetDefault(k)
+	defer SetDefault(nil)
+
+	tests := []struct {
+		name  string
+		plain string
+	}{
+		{"空值", ""},
+		{"Token", "eyJhbGciOiJSUzI1NiIsImtpZCI6IiJ9.payload.sig"},
+		{"中文及换行", "apiVersion: v1\nkind: Config\n# 集群配置\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := Encrypt(tt.plain)
+			if err != nil {
+				t.Fatalf("加密失败: %v", err)
+			}
+			if tt.plain != "" && (!IsEncrypted(encrypted) || strings.Contains(encrypted, tt.plain)) {
+				t.Fatalf("加密结果不正确: %s", encrypted)
+			}
+			again, _ := Encrypt(encrypted)
+			if again != encrypted {
+				t.Errorf("已加密的值不应重复加密")
+			}
+			decrypted, err := Decrypt(encrypted)
+			if err != nil {
+				t.Fatalf("解密失败: %v", err)
+			}
+			if decrypted != tt.plain {
+				t.Errorf("解密结果不一致: %q", decrypted)
+			}
+		})
+	}
+
+	if plain, err := Decrypt("plain-text"); err != nil || plain != "plain-text" {
+		t.Errorf("未加密的历史数据应原样返回: %q %v", plain, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "master.key")
+	k, err := newLocalKMSFromConfig(&flag.Config{MasterKeyFile: file})
+	if err != nil {
+		t.Fatalf("初始化KMS失败: %v", err)
+	}
+	SetDefault(k)
+	defer SetDefault(nil)
+
+	oldKeyID := k.ActiveKeyID()
+	encrypted, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(encrypted, envelopePrefix+oldKeyID+":") {
+		t.Fatalf("应使用当前主密钥: %s", encrypted)
+	}
+
+	l := k.(*LocalKMS)
+	newKeyID, err := l.Rotate()
+	if err != nil {
+		t.Fatalf("轮换失败: %v", err)
+	}
+	rewrapped, changed, err := Rewrap(encrypted)
+	if err != nil || !changed {
+		t.Fatalf("重新加密失败: %v %v", changed, err)
+	}
+	if !strings.HasPrefix(rewrapped, envelopePrefix+newKeyID+":") {
+		t.Errorf("应使用新主密钥: %s", rewrapped)
+	}
+	if plain, err := Decrypt(rewrapped); err != nil || plain != "secret" {
+		t.Errorf("重新加密后解密失败: %q %v", plain, err)
+	}
+
+	// 密钥文件中新密钥在首位，历史密钥保留
+	reloaded, err := newLocalKMSFromConfig(&flag.Config{MasterKeyFile: file})
+	if err != nil {
+		t.Fatalf("重新加载密钥文件失败: %v", err)
+	}
+	if reloaded.ActiveKeyID() != newKeyID {
+		t.Errorf("当前密钥应为 %s，实际 %s", newKeyID, reloaded.ActiveKeyID())
+	}
+	if _, err := decryptWith(reloaded, encrypted); err != nil {
+		t.Errorf("历史密钥应可解密: %v", err)
+	}
+	info, _ := os.Stat(file)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("密钥文件权限应为 0600，实际 %v", info.Mode().Perm())
+	}
+}
+
+func TestReloadAfterRotate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "master.key")
+	k, err := newLocalKMSFromConfig(&flag.Config{MasterKeyFile: file})
+	if err != nil {
+		t.Fatalf("初始化KMS失败: %v", err)
+	}
+	// 模拟另一个实例执行轮换
+	other, err := newLocalKMSFromConfig(&flag.Config{MasterKeyFile: file})
+	if err != nil {
+		t.Fatalf("初始化KMS失败: %v", err)
+	}
+	// 确保文件修改时间变化
+	time.Sleep(10 * time.Millisecond)
+	newKeyID, err := other.(*LocalKMS).Rotate()
+	if err != nil {
+		t.Fatalf("轮换失败: %v", err)
+	}
+	encrypted, err := encryptWith(other, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未加载的新密钥在解密时自动重新加载
+	if plain, err := decryptWith(k, encrypted); err != nil || plain != "secret" {
+		t.Fatalf("应重新加载密钥文件后解密: %q %v", plain, err)
+	}
+	if k.ActiveKeyID() != newKeyID {
+		t.Errorf("重新加载后当前密钥应为 %s，实际 %s", newKeyID, k.ActiveKeyID())
+	}
+	if _, err := k.Unwrap("missing", []byte("x")); err == nil {
+		t.Error("不存在的密钥应返回错误")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	tests := []struct {
+		name    string
+		content string
+		ids     []string
+		wantErr bool
+	}{
+		{"未写ID", key, []string{"default"}, false},
+		{"多个密钥", "new:" + key + ",old:" + key, []string{"new", "old"}, false},
+		{"注释与空行", "# comment\n\nk1:" + key + "\n", []string{"k1"}, false},
+		{"非base64", "k1:not-base64!", nil, true},
+		{"为空", " ", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("期望错误 %v，实际 %v", tt.wantErr, err)
+			}
+			for i, id := range tt.ids {
+				if keys[i].ID != id {
+					t.Errorf("第 %d 个密钥ID应为 %s，实际 %s", i, id, keys[i].ID)
+				}
+			}
+		})
+	}
+	if _, err := NewLocalKMS(Key{ID: "short", Key: []byte("123")}); err == nil {
+		t.Errorf("密钥长度不足时应报错")
+	}
+}
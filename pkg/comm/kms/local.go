@@ -0,0 +1,304 @@
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/flag"
+	"k8s.io/klog/v2"
+)
+
+// masterKeySize 主密钥长度，AES-256
+const masterKeySize = 32
+
+// reloadInterval 检查密钥文件是否被其他实例轮换的最小间隔
+const reloadInterval = 30 * time.Second
+
+// Key 主密钥
+type Key struct {
+	ID  string
+	Key []byte
+}
+
+// LocalKMS 本地主密钥实现，密钥来自环境变量或密钥文件
+// 密钥格式为每行一个 <keyID>:<base64编码的32字节密钥>，第一行为当前密钥，其余为历史密钥，仅用于解密
+// 密钥来自文件时，其他实例轮换后会自动重新加载；来自环境变量时需更新环境变量并重启所有实例
+type LocalKMS struct {
+	mu        sync.RWMutex
+	keys      map[string][]byte
+	active    string
+	order     []string
+	file      string    // 密钥来自文件时的路径，轮换时写回
+	modTime   time.Time // 已加载的密钥文件修改时间
+	checkedAt time.Time // 上次检查密钥文件的时间
+}
+
+// NewLocalKMS 使用给定的主密钥创建，第一个为当前密钥
+func NewLocalKMS(keys ...Key) (*LocalKMS, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("未配置主密钥")
+	}
+	l := &LocalKMS{keys: map[string][]byte{}}
+	for _, k := range keys {
+		if k.ID == "" || strings.ContainsAny(k.ID, ": \t") {
+			return nil, fmt.Errorf("主密钥ID [%s] 不合法", k.ID)
+		}
+		if len(k.Key) != masterKeySize {
+			return nil, fmt.Errorf("主密钥 [%s] 长度应为 %d 字节", k.ID, masterKeySize)
+		}
+		if _, ok := l.keys[k.ID]; ok {
+			return nil, fmt.Errorf("主密钥ID [%s] 重复", k.ID)
+		}
+		l.keys[k.ID] = k.Key
+		l.order = append(l.order, k.ID)
+	}
+	l.active = keys[0].ID
+	return l, nil
+}
+
+// newLocalKMSFromConfig 优先使用 MASTER_KEY 环境变量，其次读取密钥文件，文件不存在时自动生成
+func newLocalKMSFromConfig(cfg *flag.Config) (KMS, error) {
+	if cfg.MasterKey != "" {
+		keys, err := ParseKeys(cfg.MasterKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalKMS(keys...)
+	}
+
+	content, err := os.ReadFile(cfg.MasterKeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		key, genErr := GenerateKey()
+		if genErr != nil {
+			return nil, genErr
+		}
+		l, genErr := NewLocalKMS(key)
+		if genErr != nil {
+			return nil, genErr
+		}
+		l.file = cfg.MasterKeyFile
+		if genErr = l.save(); genErr != nil {
+			return nil, genErr
+		}
+		klog.Warningf("未配置主密钥，已自动生成并保存到 %s，请妥善备份，丢失后数据库中的加密数据将无法解密", cfg.MasterKeyFile)
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥文件失败: %w", err)
+	}
+	keys, err := ParseKeys(string(content))
+	if err != nil {
+		return nil, err
+	}
+	l, err := NewLocalKMS(keys...)
+	if err != nil {
+		return nil, err
+	}
+	l.file = cfg.MasterKeyFile
+	if info, err := os.Stat(l.file); err == nil {
+		l.modTime = info.ModTime()
+	}
+	l.checkedAt = time.Now()
+	return l, nil
+}
+
+// ParseKeys 解析主密钥，支持换行或逗号分隔，未写ID的密钥使用 default 作为ID
+func ParseKeys(content string) ([]Key, error) {
+	var keys []Key
+	for _, line := range strings.FieldsFunc(content, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded := "default", line
+		if i := strings.Index(line, ":"); i >= 0 {
+			id, encoded = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("主密钥 [%s] 不是合法的base64编码: %w", id, err)
+		}
+		keys = append(keys, Key{ID: id, Key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("未配置主密钥")
+	}
+	return keys, nil
+}
+
+// GenerateKey 生成随机主密钥，ID 为生成时间加随机后缀
+func GenerateKey() (Key, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return Key{}, fmt.Errorf("生成主密钥失败: %w", err)
+	}
+	return Key{ID: fmt.Sprintf("k%s-%x", time.Now().Format("20060102150405"), key[:2]), Key: key}, nil
+}
+
+func (l *LocalKMS) Name() string {
+	return "local"
+}
+
+func (l *LocalKMS) ActiveKeyID() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.active
+}
+
+func (l *LocalKMS) Wrap(dek []byte) (string, []byte, error) {
+	l.reload(false)
+	l.mu.RLock()
+	id, key := l.active, l.keys[l.active]
+	l.mu.RUnlock()
+	wrapped, err := sealGCM(key, dek, []byte(id))
+	return id, wrapped, err
+}
+
+func (l *LocalKMS) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	l.mu.RLock()
+	key, ok := l.keys[keyID]
+	l.mu.RUnlock()
+	if !ok {
+		// 可能是其他实例轮换后生成的新密钥
+		l.reload(true)
+		l.mu.RLock()
+		key, ok = l.keys[keyID]
+		l.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("主密钥 [%s] 不存在", keyID)
+	}
+	return openGCM(key, wrapped, []byte(keyID))
+}
+
+// Rotate 生成新的主密钥并写回密钥文件，密钥来自环境变量时需手动在首位添加新密钥
+func (l *LocalKMS) Rotate() (string, error) {
+	if l.file == "" {
+		return "", errors.New("主密钥来自环境变量 MASTER_KEY，请将新密钥添加到首位后重新执行")
+	}
+	key, err := GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	l.mu.Lock()
+	if _, ok := l.keys[key.ID]; ok {
+		l.mu.Unlock()
+		return "", fmt.Errorf("主密钥ID [%s] 已存在，请稍后重试", key.ID)
+	}
+	l.keys[key.ID] = key.Key
+	l.order = append([]string{key.ID}, l.order...)
+	l.active = key.ID
+	l.mu.Unlock()
+	return key.ID, l.save()
+}
+
+// reload 密钥文件修改后重新加载，force 为 false 时至多每 reloadInterval 检查一次
+func (l *LocalKMS) reload(force bool) {
+	l.mu.Lock()
+	if l.file == "" || !force && time.Since(l.checkedAt) < reloadInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.checkedAt = time.Now()
+	modTime := l.modTime
+	l.mu.Unlock()
+
+	info, err := os.Stat(l.file)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+	content, err := os.ReadFile(l.file)
+	if err != nil {
+		klog.Errorf("重新加载主密钥文件失败: %v", err)
+		return
+	}
+	keys, err := ParseKeys(string(content))
+	if err == nil {
+		_, err = NewLocalKMS(keys...)
+	}
+	if err != nil {
+		klog.Errorf("主密钥文件内容不合法，继续使用已加载的主密钥: %v", err)
+		return
+	}
+	l.mu.Lock()
+	l.keys = map[string][]byte{}
+	l.order = nil
+	for _, k := range keys {
+		l.keys[k.ID] = k.Key
+		l.order = append(l.order, k.ID)
+	}
+	l.active = keys[0].ID
+	l.modTime = info.ModTime()
+	l.mu.Unlock()
+	klog.Infof("主密钥文件已更新，重新加载，当前主密钥 [%s]", keys[0].ID)
+}
+
+// save 将主密钥写入文件，仅当前用户可读写
+func (l *LocalKMS) save() error {
+	l.mu.RLock()
+	var sb strings.Builder
+	sb.WriteString("# k8m 主密钥，第一行为当前密钥，其余为历史密钥。请妥善备份，勿删除仍在使用的历史密钥\n")
+	for _, id := range l.order {
+		sb.WriteString(id + ":" + base64.StdEncoding.EncodeToString(l.keys[id]) + "\n")
+	}
+	l.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.file), 0o700); err != nil {
+		return fmt.Errorf("创建主密钥目录失败: %w", err)
+	}
+	tmp := l.file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o600); err != nil {
+		return fmt.Errorf("写入主密钥文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, l.file); err != nil {
+		return err
+	}
+	if info, err := os.Stat(l.file); err == nil {
+		l.mu.Lock()
+		l.modTime = info.ModTime()
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// sealGCM 使用 AES-GCM 加密，输出 nonce+密文
+func sealGCM(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// openGCM 解密 sealGCM 的输出
+func openGCM(key, data, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("密文长度不合法")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		want    []string // 差异中应包含的行
+		notWant []string // 差异中不应包含的行
+	}{
+		{
+			name:   "内容相同",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+		},
+		{
+			name:    "修改副本数",
+			before:  "kind: Deployment\nspec:\n  replicas: 1\n  selector: {}\n",
+			after:   "kind: Deployment\nspec:\n  replicas: 3\n  selector: {}\n",
+			want:    []string{"-  replicas: 1", "+  replicas: 3", " spec:"},
+			notWant: []string{"+kind: Deployment", "-kind: Deployment"},
+		},
+		{
+			name:   "删除资源",
+			before: "kind: Pod\nmetadata:\n  name: nginx\n",
+			after:  "",
+			want:   []string{"-kind: Pod", "-  name: nginx"},
+		},
+		{
+			name:    "省略未变化内容",
+			before:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			after:   "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want:    []string{"@@", "-10", "+ten", " 9"},
+			notWant: []string{" 1", " 5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LineDiff(tt.before, tt.after, 1)
+			if len(tt.want) == 0 && got != "" {
+				t.Errorf("期望无差异，实际:\n%s", got)
+			}
+			lines := strings.Split(got, "\n")
+			for _, w := range tt.want {
+				if !containsLine(lines, w) {
+					t.Errorf("差异中缺少 %q:\n%s", w, got)
+				}
+			}
+			for _, w := range tt.notWant {
+				if containsLine(lines, w) {
+					t.Errorf("差异中不应包含 %q:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
@@ -9,28 +9,32 @@ import (
 	"github.com/weibaohui/k8m/pkg/constants"
 )
 
+// JWTKeyFunc 获取验证 Token 签名的密钥，由 service 注入以支持非对称签名及多密钥轮换。
+// 未注入时仅支持使用 jwtTokenSecret 的 HMAC 签名
+var JWTKeyFunc func(token *jwt.Token, jwtTokenSecret string) (any, error)
+
+// parseJWT 解析并验证 Token 签名
+func parseJWT(tokenString string, jwtTokenSecret string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if JWTKeyFunc != nil {
+			return JWTKeyFunc(token, jwtTokenSecret)
+		}
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+		}
+		return []byte(jwtTokenSecret), nil
+	})
+}
+
 // GetJWTClaims 从 Gin 上下文的请求头或查询参数中提取并解析 JWT，返回其 claims。
 // 若未提供 Token、Token 无效或 claims 解析失败，则返回相应错误。
 func GetJWTClaims(c *gin.Context, jwtTokenSecret string) (jwt.MapClaims, error) {
-	tokenString := c.GetHeader("Authorization")
+	tokenString := GetJWTToken(c)
 	if tokenString == "" {
-		// 尝试从query中获取
-		tokenString = c.Query("token")
-		if tokenString == "" {
-			return nil, fmt.Errorf("未提供 Token")
-		}
-	}
-
-	if strings.HasPrefix(tokenString, "Bearer ") {
-		// token Bear xxxx
-		tokenString = tokenString[7:]
+		return nil, fmt.Errorf("未提供 Token")
 	}
 
-	var jwtSecret = []byte(jwtTokenSecret)
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		return jwtSecret, nil
-	})
+	token, err := parseJWT(tokenString, jwtTokenSecret)
 	if err != nil || !token.Valid {
 		return nil, fmt.Errorf("Token 无效")
 	}
@@ -43,6 +47,16 @@ func GetJWTClaims(c *gin.Context, jwtTokenSecret string) (jwt.MapClaims, error)
 	return claims, nil
 }
 
+// GetJWTToken 从请求头或查询参数中提取 Token 字符串（不含 Bearer 前缀），未提供时返回空字符串
+func GetJWTToken(c *gin.Context) string {
+	tokenString := c.GetHeader("Authorization")
+	if tokenString == "" {
+		// 尝试从query中获取
+		tokenString = c.Query("token")
+	}
+	return strings.TrimPrefix(tokenString, "Bearer ")
+}
+
 // GetUsernameFromToken 从JWT令牌字符串中解析并返回用户名。
 // 如果令牌无效或未包含用户名字段，则返回错误。
 func GetUsernameFromToken(authToken string, jwtTokenSecret string) (string, error) {
@@ -76,11 +90,7 @@ func GetJwtMapClaimsFromToken(authToken string, jwtTokenSecret string) (jwt.MapC
 		authToken = authToken[7:]
 	}
 
-	var jwtSecret = []byte(jwtTokenSecret)
-
-	token, err := jwt.Parse(authToken, func(token *jwt.Token) (any, error) {
-		return jwtSecret, nil
-	})
+	token, err := parseJWT(authToken, jwtTokenSecret)
 	if err != nil || !token.Valid {
 		return nil, fmt.Errorf("Token 无效")
 	}
@@ -0,0 +1,13 @@
+package constants
+
+// AIFeature AI功能场景，用于为不同场景选择不同的大模型
+type AIFeature string
+
+const (
+	AIFeatureChat       AIFeature = "chat"       // 对话（GPTShell、资源解释、任意提问等）
+	AIFeatureLog        AIFeature = "log"        // 日志分析
+	AIFeatureInspection AIFeature = "inspection" // 巡检结果汇总
+	AIFeatureEvent      AIFeature = "event"      // 事件分析、事件汇总
+	AIFeatureK8sGPT     AIFeature = "k8sgpt"     // k8sgpt 问题解释
+	AIFeatureKnowledge  AIFeature = "knowledge"  // 知识库向量化
+)
@@ -9,3 +9,11 @@ const (
 	ClusterConnectStatusFailed       ClusterConnectStatus = "failed"       // 连接失败
 	ClusterConnectStatusConnecting   ClusterConnectStatus = "connecting"   // 连接中
 )
+
+// ClusterErrorType 集群连接错误类型，区分认证失败与网络故障
+type ClusterErrorType string
+
+const (
+	ClusterErrorTypeAuth    ClusterErrorType = "auth"    // 认证失败，如凭据过期、exec 插件不可用
+	ClusterErrorTypeNetwork ClusterErrorType = "network" // 网络故障，如连接超时、跳板机断开
+)
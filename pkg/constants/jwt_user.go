@@ -2,4 +2,12 @@ package constants
 
 const (
 	JwtUserName = "username"
+	// JwtApiKeyID API密钥 Token 中的密钥ID，用于校验密钥的有效期、作用范围及吊销状态
+	JwtApiKeyID = "api_key_id"
+	// JwtKubeconfigID 用户 kubeconfig Token 中的 kubeconfig ID，仅允许访问 Kubernetes API 代理
+	JwtKubeconfigID = "kubeconfig_id"
+	// JwtSessionID 登录 Token 中的会话ID，用于校验会话是否已被注销
+	JwtSessionID = "sid"
+	// JwtMFAEnroll 未满足2步验证策略时签发的 Token 标记，仅允许访问绑定2步验证相关接口
+	JwtMFAEnroll = "mfa_enroll"
 )
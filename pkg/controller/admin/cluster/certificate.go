@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+	"gorm.io/gorm"
+)
+
+// ListCertificates 集群证书列表
+// @Summary 集群证书列表
+// @Description 列出扫描到的客户端证书、TLS Secret 及 Webhook caBundle，可按剩余天数筛选即将过期的证书
+// @Tags cluster
+// @Param days query int false "仅列出该天数内过期的证书及异常的证书，不传则列出全部"
+// @Param cluster query string false "集群ID"
+// @Param kind query string false "证书来源：client_cert、tls_secret、webhook_ca"
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster/certificate/list [get]
+func (a *Controller) ListCertificates(c *gin.Context) {
+	params := dao.BuildParams(c)
+	if c.Query("orderBy") == "" {
+		params.OrderBy = "not_after"
+		params.OrderDir = "asc"
+	}
+	var queryFuncs []func(*gorm.DB) *gorm.DB
+	if days := c.Query("days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			amis.WriteJsonError(c, fmt.Errorf("天数格式错误: %s", days))
+			return
+		}
+		deadline := time.Now().AddDate(0, 0, n)
+		queryFuncs = append(queryFuncs, func(db *gorm.DB) *gorm.DB {
+			return db.Where("not_after < ? OR err <> ''", deadline)
+		})
+	}
+	m := &models.ClusterCertificate{}
+	items, total, err := m.List(params, queryFuncs...)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	now := time.Now()
+	for _, item := range items {
+		if item.NotAfter != nil {
+			days := int(math.Floor(item.NotAfter.Sub(now).Hours() / 24))
+			item.DaysLeft = &days
+		}
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// ScanCertificates 扫描全部已连接集群的证书
+// @Summary 扫描全部已连接集群的证书
+// @Security BearerAuth
+// @Success 200 {object} string "已开始扫描，请稍后刷新"
+// @Router /admin/cluster/certificate/scan [post]
+func (a *Controller) ScanCertificates(c *gin.Context) {
+	go service.ClusterCertificateService().ScanAll()
+	amis.WriteJsonOKMsg(c, "已开始扫描，请稍后刷新")
+}
+
+// ScanClusterCertificates 扫描单个集群的证书
+// @Summary 扫描单个集群的证书
+// @Security BearerAuth
+// @Param cluster path string true "Base64编码的集群ID"
+// @Success 200 {object} string
+// @Router /admin/cluster/{cluster}/certificate/scan [post]
+func (a *Controller) ScanClusterCertificates(c *gin.Context) {
+	clusterID, err := utils.DecodeBase64(c.Param("cluster"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	count, err := service.ClusterCertificateService().ScanCluster(clusterID)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("扫描完成，共 %d 个证书", count))
+}
@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/gin-gonic/gin"
@@ -27,6 +28,14 @@ func RegisterAdminClusterRoutes(admin *gin.RouterGroup) {
 	admin.POST("/cluster/token/save", ctrl.SaveTokenCluster)
 	admin.GET("/cluster/config/:id", ctrl.GetClusterConfig)
 	admin.POST("/cluster/config/save", ctrl.SaveClusterConfig)
+	admin.POST("/cluster/:cluster/tags/save", ctrl.SaveClusterTags)
+	admin.GET("/cluster/tag/match", ctrl.MatchClusters)
+	admin.GET("/cluster/health/summary", ctrl.HealthSummary)
+	admin.GET("/cluster/:cluster/health/report", ctrl.HealthReport)
+	admin.GET("/cluster/:cluster/health/events", ctrl.HealthEvents)
+	admin.GET("/cluster/certificate/list", ctrl.ListCertificates)
+	admin.POST("/cluster/certificate/scan", ctrl.ScanCertificates)
+	admin.POST("/cluster/:cluster/certificate/scan", ctrl.ScanClusterCertificates)
 }
 func RegisterUserClusterRoutes(mgm *gin.RouterGroup) {
 	ctrl := &Controller{}
@@ -147,6 +156,19 @@ func (a *Controller) GetClusterConfig(c *gin.Context) {
 		"timeout":  config.Timeout,
 		"qps":      config.QPS,
 		"burst":    config.Burst,
+
+		"impersonate":       config.Impersonate,
+		"impersonatePrefix": config.ImpersonatePrefix,
+
+		// 密码、私钥不返回，仅返回是否已配置
+		"proxyUsername":    config.ProxyUsername,
+		"proxyPasswordSet": config.ProxyPassword != "",
+		"sshHost":          config.SSHHost,
+		"sshUser":          config.SSHUser,
+		"sshAuthMethod":    config.SSHAuthMethod,
+		"sshPrivateKeySet": config.SSHPrivateKey != "",
+		"sshPassphraseSet": config.SSHPassphrase != "",
+		"sshKnownHosts":    config.SSHKnownHosts,
 	}
 
 	amis.WriteJsonData(c, configData)
@@ -169,6 +191,19 @@ func (a *Controller) SaveClusterConfig(c *gin.Context) {
 		Timeout  int     `json:"timeout"`
 		QPS      float32 `json:"qps"`
 		Burst    int     `json:"burst"`
+
+		Impersonate       bool   `json:"impersonate"`
+		ImpersonatePrefix string `json:"impersonatePrefix"`
+
+		// 密码、私钥为空时保留原值
+		ProxyUsername string `json:"proxyUsername"`
+		ProxyPassword string `json:"proxyPassword"`
+		SSHHost       string `json:"sshHost"`
+		SSHUser       string `json:"sshUser"`
+		SSHAuthMethod string `json:"sshAuthMethod"`
+		SSHPrivateKey string `json:"sshPrivateKey"`
+		SSHPassphrase string `json:"sshPassphrase"`
+		SSHKnownHosts string `json:"sshKnownHosts"`
 	}
 
 	if err := c.ShouldBindJSON(&configData); err != nil {
@@ -193,6 +228,53 @@ func (a *Controller) SaveClusterConfig(c *gin.Context) {
 	config.Timeout = configData.Timeout
 	config.QPS = configData.QPS
 	config.Burst = configData.Burst
+	if configData.Impersonate && config.IsAWSEKS {
+		amis.WriteJsonError(c, service.ErrImpersonateUnsupported)
+		return
+	}
+	config.Impersonate = configData.Impersonate
+	config.ImpersonatePrefix = strings.TrimSpace(configData.ImpersonatePrefix)
+
+	config.ProxyURL = strings.TrimSpace(config.ProxyURL)
+	config.ProxyUsername = strings.TrimSpace(configData.ProxyUsername)
+	if config.ProxyURL == "" || config.ProxyUsername == "" {
+		config.ProxyUsername = ""
+		config.ProxyPassword = ""
+	} else if configData.ProxyPassword != "" {
+		config.ProxyPassword = configData.ProxyPassword
+	}
+	config.SSHHost = strings.TrimSpace(configData.SSHHost)
+	if config.SSHHost == "" {
+		config.SSHUser = ""
+		config.SSHAuthMethod = ""
+		config.SSHPrivateKey = ""
+		config.SSHPassphrase = ""
+		config.SSHKnownHosts = ""
+	} else {
+		if config.IsAWSEKS {
+			amis.WriteJsonError(c, errors.New("AWS EKS 集群不支持 SSH 跳板机"))
+			return
+		}
+		config.SSHUser = strings.TrimSpace(configData.SSHUser)
+		config.SSHAuthMethod = configData.SSHAuthMethod
+		config.SSHKnownHosts = strings.TrimSpace(configData.SSHKnownHosts)
+		if config.SSHAuthMethod == service.ClusterSSHAuthAgent {
+			config.SSHPrivateKey = ""
+			config.SSHPassphrase = ""
+		} else {
+			// 更换私钥时口令随之更换
+			if configData.SSHPrivateKey != "" {
+				config.SSHPrivateKey = configData.SSHPrivateKey
+				config.SSHPassphrase = configData.SSHPassphrase
+			} else if configData.SSHPassphrase != "" {
+				config.SSHPassphrase = configData.SSHPassphrase
+			}
+		}
+	}
+	if err := service.ValidateClusterNetwork(config.ProxyURL, service.ClusterSSHConfigFromModel(config)); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 
 	// 保存更新
 	if err := config.Save(params); err != nil {
@@ -201,7 +283,7 @@ func (a *Controller) SaveClusterConfig(c *gin.Context) {
 	}
 	
 	// 更新已加载集群的配置参数
-	if err := service.ClusterService().UpdateClusterConfig(configData.ID, configData.ProxyURL, configData.Timeout, configData.QPS, configData.Burst); err != nil {
+	if err := service.ClusterService().UpdateClusterConfig(config); err != nil {
 		// 记录错误但不影响保存操作的成功响应
 		// 因为数据库已经保存成功，只是内存中的集群配置更新失败
 		// 下次重新扫描时会自动同步
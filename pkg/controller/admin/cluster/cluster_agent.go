@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type AgentController struct{}
+
+// RegisterAdminClusterAgentRoutes 注册集群 Agent 管理路由
+func RegisterAdminClusterAgentRoutes(admin *gin.RouterGroup) {
+	ctrl := &AgentController{}
+	admin.GET("/cluster_agent/list", ctrl.List)
+	admin.POST("/cluster_agent/create", ctrl.Create)
+	admin.POST("/cluster_agent/delete/:ids", ctrl.Delete)
+}
+
+// @Summary 获取集群 Agent 列表
+// @Description 获取通过 Agent 反向隧道接入的集群，包含隧道在线状态及最后连接信息
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster_agent/list [get]
+func (a *AgentController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.ClusterAgent{}
+	items, total, err := m.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	for _, item := range items {
+		item.Connected = service.ClusterAgentService().IsConnected(item.ID)
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 创建集群 Agent
+// @Description 创建 Agent 并生成Token及部署 YAML，Token 仅返回一次。在被纳管集群中部署后，集群以 Agent/名称 出现在集群列表中
+// @Security BearerAuth
+// @Param name body string true "名称"
+// @Param description body string false "描述"
+// @Success 200 {object} string "Token 及部署 YAML"
+// @Router /admin/cluster_agent/create [post]
+func (a *AgentController) Create(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	item, token, err := service.ClusterAgentService().CreateAgent(req.Name, req.Description, amis.GetLoginUser(c))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"cluster_id": "Agent/" + item.Name,
+		"token":      token,
+		"manifest":   service.ClusterAgentService().Manifest(serverURL(c), token),
+	})
+}
+
+// @Summary 删除集群 Agent
+// @Description 删除后 Agent Token 失效，隧道断开，集群从列表中移除
+// @Security BearerAuth
+// @Param ids path string true "Agent ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/cluster_agent/delete/{ids} [post]
+func (a *AgentController) Delete(c *gin.Context) {
+	var items []*models.ClusterAgent
+	err := dao.DB().Where("id in ?", utils.ToInt64Slice(c.Param("ids"))).Find(&items).Error
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	for _, item := range items {
+		if err := service.ClusterAgentService().Delete(item); err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+	}
+	amis.WriteJsonOK(c)
+}
+
+// serverURL k8m 的访问地址，经反向代理时根据 X-Forwarded-Proto 判断协议
+func serverURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
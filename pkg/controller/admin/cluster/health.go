@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+	"gorm.io/gorm"
+)
+
+// HealthReport 集群可用率报告
+// @Summary 集群可用率报告
+// @Description 统计时段内的可用率、心跳延迟分位数及中断时段，手动断开期间不计入可用率
+// @Tags cluster
+// @Param cluster path string true "Base64编码的集群ID"
+// @Param period query string false "统计时段，如 1h、24h、7d、30d，默认 24h"
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster/{cluster}/health/report [get]
+func (a *Controller) HealthReport(c *gin.Context) {
+	clusterID, err := utils.DecodeBase64(c.Param("cluster"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	period, err := service.ParseClusterHealthPeriod(c.Query("period"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	to := time.Now()
+	report, err := service.ClusterHealthService().Report(clusterID, to.Add(-period), to)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, report)
+}
+
+// HealthSummary 全部集群的可用率汇总
+// @Summary 全部集群的可用率汇总
+// @Tags cluster
+// @Param period query string false "统计时段，如 1h、24h、7d、30d，默认 24h"
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster/health/summary [get]
+func (a *Controller) HealthSummary(c *gin.Context) {
+	period, err := service.ParseClusterHealthPeriod(c.Query("period"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	to := time.Now()
+	var reports []*service.ClusterHealthReport
+	for _, cc := range service.ClusterService().AllClusters() {
+		report, err := service.ClusterHealthService().Report(cc.GetClusterID(), to.Add(-period), to)
+		if err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+		// 列表中不展示中断明细
+		report.Outages = nil
+		reports = append(reports, report)
+	}
+	amis.WriteJsonList(c, reports)
+}
+
+// HealthEvents 集群连接状态变化及自动重连记录
+// @Summary 集群连接状态变化及自动重连记录
+// @Tags cluster
+// @Param cluster path string true "Base64编码的集群ID"
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster/{cluster}/health/events [get]
+func (a *Controller) HealthEvents(c *gin.Context) {
+	clusterID, err := utils.DecodeBase64(c.Param("cluster"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	params := dao.BuildParams(c)
+	m := &models.ClusterStatusEvent{}
+	items, total, err := m.List(params, func(db *gorm.DB) *gorm.DB {
+		return db.Where("cluster = ?", clusterID)
+	})
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
@@ -44,6 +44,11 @@ func (a *Controller) SaveKubeConfig(c *gin.Context) {
 		klog.V(6).Infof("解析 集群 [%s]失败: %v", m.Server, err)
 		return
 	}
+	// exec 插件须在白名单中，auth-provider 仅支持 oidc
+	if err := service.ValidateKubeconfigAuth(config); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 	index := 0
 	total := len(config.Contexts)
 	for contextName, _ := range config.Contexts {
@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// SaveClusterTags 保存集群分组及标签
+// @Summary 保存集群分组及标签
+// @Description 标签键、值需符合 Kubernetes 标签规范；分组在标签选择器中以 group=xxx 匹配
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param cluster path string true "Base64编码的集群ID"
+// @Param body body object true "分组及标签，如 {\"group\":\"payments\",\"tags\":{\"env\":\"prod\"}}"
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster/{cluster}/tags/save [post]
+func (a *Controller) SaveClusterTags(c *gin.Context) {
+	clusterID, err := utils.DecodeBase64(c.Param("cluster"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	var req struct {
+		Group string            `json:"group"`
+		Tags  map[string]string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if err := service.ClusterTagService().Save(clusterID, req.Group, req.Tags); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, "保存成功")
+}
+
+// MatchClusters 预览标签选择器匹配的集群
+// @Summary 预览标签选择器匹配的集群
+// @Tags cluster
+// @Param selector query string true "集群标签选择器，如 env=prod,group in (payments)"
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster/tag/match [get]
+func (a *Controller) MatchClusters(c *gin.Context) {
+	clusters, err := service.ClusterTagService().MatchClusters(c.Query("selector"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"clusters": clusters,
+		"total":    len(clusters),
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/models"
@@ -69,7 +70,7 @@ func (m *AIController) TestConnection(c *gin.Context) {
 		amis.WriteJsonError(c, err)
 		return
 	}
-	client, err := service.AIService().TestClient(entity.ApiURL, entity.ApiKey, entity.ApiModel)
+	client, err := service.AIService().TestClient(&entity)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
@@ -101,14 +102,32 @@ func (m *AIController) Save(c *gin.Context) {
 	}
 
 	// 添加业务逻辑验证
-	if config.ApiURL == "" {
+	if config.Provider == "" {
+		config.Provider = "openai"
+	}
+	switch config.Provider {
+	case "openai", "azure", "anthropic", "ollama":
+	default:
+		amis.WriteJsonError(c, fmt.Errorf("不支持的模型提供方: %s", config.Provider))
+		return
+	}
+	// Anthropic、Ollama 有默认地址
+	if config.ApiURL == "" && (config.Provider == "openai" || config.Provider == "azure") {
 		amis.WriteJsonError(c, fmt.Errorf("API URL不能为空"))
 		return
 	}
-	if config.ApiKey == "" {
+	if config.ApiKey == "" && ai.NeedPassword(config.Provider) {
 		amis.WriteJsonError(c, fmt.Errorf("API Key不能为空"))
 		return
 	}
+	if config.Timeout < 0 {
+		amis.WriteJsonError(c, fmt.Errorf("超时时间不能小于0"))
+		return
+	}
+	if config.FallbackModelID != 0 && config.FallbackModelID == config.ID {
+		amis.WriteJsonError(c, fmt.Errorf("备用模型不能是自身"))
+		return
+	}
 	if config.Temperature < 0 || config.Temperature > 2 {
 		amis.WriteJsonError(c, fmt.Errorf("Temperature参数应在0-2之间"))
 		return
@@ -120,6 +139,8 @@ func (m *AIController) Save(c *gin.Context) {
 		return
 	}
 
+	// 模型配置可能正在被使用，重建客户端
+	_ = service.AIService().ResetDefaultClient()
 	amis.WriteJsonOK(c)
 }
 
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+type AIRedactionController struct {
+}
+
+// RegisterAIRedactionRoutes 注册AI脱敏记录路由
+func RegisterAIRedactionRoutes(admin *gin.RouterGroup) {
+	ctrl := &AIRedactionController{}
+	admin.GET("/ai/redaction/list", ctrl.List)
+	admin.POST("/ai/redaction/delete/:ids", ctrl.Delete)
+}
+
+// @Summary 获取AI脱敏记录列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/ai/redaction/list [get]
+func (m *AIRedactionController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+
+	log := &models.AIRedactionLog{}
+	items, total, err := log.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 删除AI脱敏记录
+// @Security BearerAuth
+// @Param ids path string true "记录ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/ai/redaction/delete/{ids} [post]
+func (m *AIRedactionController) Delete(c *gin.Context) {
+	ids := c.Param("ids")
+	params := dao.BuildParams(c)
+	log := &models.AIRedactionLog{}
+
+	err := log.Delete(params, ids)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOK(c)
+}
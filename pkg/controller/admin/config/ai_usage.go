@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type AIUsageController struct {
+}
+
+// RegisterAIUsageRoutes 注册AI用量统计及配额路由
+func RegisterAIUsageRoutes(admin *gin.RouterGroup) {
+	ctrl := &AIUsageController{}
+	admin.GET("/ai/usage/list", ctrl.List)
+	admin.GET("/ai/usage/stats", ctrl.Stats)
+	admin.GET("/ai/quota/list", ctrl.QuotaList)
+	admin.POST("/ai/quota/save", ctrl.QuotaSave)
+	admin.POST("/ai/quota/delete/:ids", ctrl.QuotaDelete)
+}
+
+// @Summary 获取AI用量明细列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/ai/usage/list [get]
+func (m *AIUsageController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+
+	log := &models.AIUsageLog{}
+	items, total, err := log.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 按维度统计AI用量
+// @Security BearerAuth
+// @Param dimension query string false "统计维度：user、group、cluster、feature、model，默认 user"
+// @Param start query string false "开始日期，格式 2006-01-02，默认本月第一天"
+// @Param end query string false "结束日期（含），格式 2006-01-02，默认今天"
+// @Success 200 {object} string
+// @Router /admin/ai/usage/stats [get]
+func (m *AIUsageController) Stats(c *gin.Context) {
+	dimension := c.DefaultQuery("dimension", "user")
+
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var err error
+	if s := c.Query("start"); s != "" {
+		if start, err = time.ParseInLocation(time.DateOnly, s, now.Location()); err != nil {
+			amis.WriteJsonError(c, fmt.Errorf("开始日期格式错误: %s", s))
+			return
+		}
+	}
+	if s := c.Query("end"); s != "" {
+		if end, err = time.ParseInLocation(time.DateOnly, s, now.Location()); err != nil {
+			amis.WriteJsonError(c, fmt.Errorf("结束日期格式错误: %s", s))
+			return
+		}
+	}
+
+	stats, err := service.AIUsageService().Stats(dimension, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonList(c, stats)
+}
+
+// @Summary 获取AI配额列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/ai/quota/list [get]
+func (m *AIUsageController) QuotaList(c *gin.Context) {
+	params := dao.BuildParams(c)
+
+	quota := &models.AIQuota{}
+	items, total, err := quota.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 创建或更新AI配额
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/ai/quota/save [post]
+func (m *AIUsageController) QuotaSave(c *gin.Context) {
+	params := dao.BuildParams(c)
+
+	var quota models.AIQuota
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+
+	switch quota.TargetType {
+	case service.AIQuotaTargetUser, service.AIQuotaTargetGroup:
+	default:
+		amis.WriteJsonError(c, fmt.Errorf("不支持的配额对象类型: %s", quota.TargetType))
+		return
+	}
+	if quota.TargetName == "" {
+		amis.WriteJsonError(c, fmt.Errorf("配额对象不能为空"))
+		return
+	}
+	if quota.DailyTokens < 0 || quota.MonthlyTokens < 0 {
+		amis.WriteJsonError(c, fmt.Errorf("Token上限不能小于0"))
+		return
+	}
+	if quota.Action == "" {
+		quota.Action = service.AIQuotaActionReject
+	}
+	switch quota.Action {
+	case service.AIQuotaActionReject:
+		quota.DowngradeModelID = 0
+	case service.AIQuotaActionDowngrade:
+		if quota.DowngradeModelID == 0 {
+			amis.WriteJsonError(c, fmt.Errorf("请选择降级使用的模型"))
+			return
+		}
+	default:
+		amis.WriteJsonError(c, fmt.Errorf("不支持的处理方式: %s", quota.Action))
+		return
+	}
+	if quota.ID == 0 {
+		quota.CreatedBy = params.UserName
+	}
+
+	if err := quota.Save(params); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOK(c)
+}
+
+// @Summary 删除AI配额
+// @Security BearerAuth
+// @Param ids path string true "配额ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/ai/quota/delete/{ids} [post]
+func (m *AIUsageController) QuotaDelete(c *gin.Context) {
+	ids := c.Param("ids")
+	params := dao.BuildParams(c)
+	quota := &models.AIQuota{}
+
+	err := quota.Delete(params, ids)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOK(c)
+}
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/models"
@@ -44,6 +48,12 @@ func (cc *Controller) Update(c *gin.Context) {
 	if config.EnableAI == false {
 		config.AnySelect = false
 	}
+	for _, p := range strings.Split(config.AIRedactEnvPatterns, "\n") {
+		if _, err := regexp.Compile(strings.TrimSpace(p)); err != nil {
+			amis.WriteJsonError(c, fmt.Errorf("环境变量脱敏规则[%s]错误: %w", p, err))
+			return
+		}
+	}
 
 	if err := service.ConfigService().UpdateConfig(&config); err != nil {
 		amis.WriteJsonError(c, err)
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type JwtKeyController struct {
+}
+
+// RegisterJwtKeyRoutes 注册JWT签名密钥管理路由
+func RegisterJwtKeyRoutes(admin *gin.RouterGroup) {
+	ctrl := &JwtKeyController{}
+	admin.GET("/jwt/keys/list", ctrl.List)
+	admin.GET("/jwt/keys/info", ctrl.Info)
+	admin.POST("/jwt/keys/rotate", ctrl.Rotate)
+	admin.POST("/jwt/keys/delete/:kid", ctrl.Delete)
+}
+
+// RegisterJWKSRoutes 注册公开的JWKS路由，供其他服务验证 k8m 签发的 Token
+func RegisterJWKSRoutes(r *gin.Engine) {
+	ctrl := &JwtKeyController{}
+	r.GET("/.well-known/jwks.json", ctrl.JWKS)
+}
+
+// @Summary 获取JWT签名公钥（JWKS）
+// @Success 200 {object} string
+// @Router /.well-known/jwks.json [get]
+func (j *JwtKeyController) JWKS(c *gin.Context) {
+	keys, err := service.JwtKeyService().JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// @Summary 获取JWT签名密钥列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/jwt/keys/list [get]
+func (j *JwtKeyController) List(c *gin.Context) {
+	items, err := service.JwtKeyService().List()
+	amis.WriteJsonListWithError(c, items, err)
+}
+
+// @Summary 获取JWT签名配置
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/jwt/keys/info [get]
+func (j *JwtKeyController) Info(c *gin.Context) {
+	amis.WriteJsonData(c, gin.H{
+		"algorithm":      service.JwtKeyService().Algorithm(),
+		"default_secret": service.JwtKeyService().IsDefaultSecret(),
+	})
+}
+
+// @Summary 轮换JWT签名密钥
+// @Description 生成新的签名密钥并设为当前密钥，历史密钥保留用于验证已签发的Token
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/jwt/keys/rotate [post]
+func (j *JwtKeyController) Rotate(c *gin.Context) {
+	kid, err := service.JwtKeyService().Rotate()
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, "轮换成功，当前签名密钥 "+kid)
+}
+
+// @Summary 删除历史JWT签名密钥
+// @Security BearerAuth
+// @Param kid path string true "密钥ID"
+// @Success 200 {object} string
+// @Router /admin/jwt/keys/delete/{kid} [post]
+func (j *JwtKeyController) Delete(c *gin.Context) {
+	err := service.JwtKeyService().Delete(c.Param("kid"))
+	amis.WriteJsonErrorOrOK(c, err)
+}
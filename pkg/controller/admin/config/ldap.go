@@ -104,7 +104,7 @@ func (lc *LdapConfigController) LDAPConfigSave(c *gin.Context) {
 
 	// 保存数据库，仅更新指定字段，避免覆盖其他字段
 	err = m.Save(params, func(db *gorm.DB) *gorm.DB {
-		return db.Select([]string{"name", "host", "port", "bind_dn", "bind_password", "base_dn", "user_filter", "login2_auth_close", "default_group", "enabled"})
+		return db.Select([]string{"name", "host", "port", "bind_dn", "bind_password", "base_dn", "user_filter", "login2_auth_close", "default_group", "group_attribute", "sync_interval", "enabled"})
 	})
 	if err != nil {
 		amis.WriteJsonError(c, err)
@@ -51,7 +51,8 @@ func (sc *SSOConfigController) Save(c *gin.Context) {
 	}
 
 	err = m.Save(params, func(db *gorm.DB) *gorm.DB {
-		return db.Select([]string{"name", "type", "client_id", "client_secret", "issuer", "prefer_user_name_keys", "scopes"})
+		return db.Select([]string{"name", "type", "client_id", "client_secret", "issuer", "prefer_user_name_keys", "scopes", "groups_key", "allowed_groups",
+			"auth_url", "token_url", "user_info_url", "idp_metadata_url", "idp_metadata", "entity_id"})
 	})
 	if err != nil {
 		amis.WriteJsonError(c, err)
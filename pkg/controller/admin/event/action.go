@@ -11,6 +11,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/eventhandler/worker"
 	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
 	"gorm.io/gorm"
 )
 
@@ -61,6 +62,14 @@ func (s *AdminEventController) Save(c *gin.Context) {
 		return
 	}
 
+	m.ClusterSelector = strings.TrimSpace(m.ClusterSelector)
+	if m.ClusterSelector != "" {
+		if _, err := service.ParseClusterSelector(m.ClusterSelector); err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+	}
+
 	// 验证AI总结配置
 	if m.AIEnabled {
 		if len(m.AIPromptTemplate) > 2000 {
@@ -13,6 +13,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/lua"
 	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
 	"gorm.io/gorm"
 )
 
@@ -149,6 +150,14 @@ func (s *AdminScheduleController) Save(c *gin.Context) {
 		return
 	}
 
+	m.ClusterSelector = strings.TrimSpace(m.ClusterSelector)
+	if m.ClusterSelector != "" {
+		if _, err := service.ParseClusterSelector(m.ClusterSelector); err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+	}
+
 	// 验证AI总结配置
 	if m.AIEnabled {
 		// 检查AI提示词模板长度
@@ -300,7 +309,7 @@ func (s *AdminScheduleController) Start(c *gin.Context) {
 	go func() {
 		// 立马执行一次
 		sb := lua.NewScheduleBackground()
-		clusters := strings.Split(one.Clusters, ",")
+		clusters := service.ClusterTagService().ResolveClusters(one.Clusters, one.ClusterSelector)
 		for _, cluster := range clusters {
 			_, _ = sb.RunByCluster(context.Background(), &one.ID, cluster, lua.TriggerTypeManual)
 		}
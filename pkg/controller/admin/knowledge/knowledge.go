@@ -0,0 +1,241 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// AdminKnowledgeController 知识库管理控制器
+type AdminKnowledgeController struct {
+}
+
+// RegisterAdminKnowledgeRoutes 注册知识库管理路由
+func RegisterAdminKnowledgeRoutes(admin *gin.RouterGroup) {
+	ctrl := &AdminKnowledgeController{}
+	admin.GET("/knowledge/doc/list", ctrl.List)
+	admin.POST("/knowledge/doc/save", ctrl.Save)
+	admin.POST("/knowledge/doc/delete/:ids", ctrl.Delete)
+	admin.POST("/knowledge/doc/upload", ctrl.Upload)
+	admin.POST("/knowledge/doc/reindex", ctrl.Reindex)
+	admin.POST("/knowledge/import/inspection", ctrl.ImportInspection)
+	admin.POST("/knowledge/import/incident", ctrl.ImportIncident)
+	admin.POST("/knowledge/import/openapi", ctrl.ImportOpenAPI)
+	admin.GET("/knowledge/search", ctrl.Search)
+}
+
+// @Summary 获取知识库文档列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/knowledge/doc/list [get]
+func (k *AdminKnowledgeController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.KnowledgeDoc{}
+
+	items, total, err := m.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 创建或更新知识库文档，保存后重建该文档索引
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/knowledge/doc/save [post]
+func (k *AdminKnowledgeController) Save(c *gin.Context) {
+	params := dao.BuildParams(c)
+	ctx := amis.GetContextWithUser(c)
+
+	var m models.KnowledgeDoc
+	if err := c.ShouldBindJSON(&m); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if strings.TrimSpace(m.Title) == "" || strings.TrimSpace(m.Content) == "" {
+		amis.WriteJsonError(c, fmt.Errorf("标题和内容不能为空"))
+		return
+	}
+	if m.Source == "" {
+		m.Source = service.KnowledgeSourceRunbook
+	}
+	if m.ID == 0 {
+		m.CreatedBy = params.UserName
+	}
+
+	if err := service.KnowledgeService().SaveDoc(ctx, &m); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if m.IndexError != "" {
+		amis.WriteJsonOKMsg(c, "保存成功，向量化失败已使用关键词检索："+m.IndexError)
+		return
+	}
+	amis.WriteJsonOK(c)
+}
+
+// @Summary 删除知识库文档
+// @Security BearerAuth
+// @Param ids path string true "文档ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/knowledge/doc/delete/{ids} [post]
+func (k *AdminKnowledgeController) Delete(c *gin.Context) {
+	ids := c.Param("ids")
+
+	err := service.KnowledgeService().DeleteDocs(utils.ToInt64Slice(ids))
+	amis.WriteJsonErrorOrOK(c, err)
+}
+
+// @Summary 上传Markdown/文本文件作为运维手册
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Param file formData file true "Markdown或文本文件"
+// @Param source formData string false "来源，默认 runbook"
+// @Success 200 {object} string
+// @Router /admin/knowledge/doc/upload [post]
+func (k *AdminKnowledgeController) Upload(c *gin.Context) {
+	params := dao.BuildParams(c)
+	ctx := amis.GetContextWithUser(c)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		amis.WriteJsonError(c, fmt.Errorf("获取上传的文件错误。\n %v", err))
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".md" && ext != ".markdown" && ext != ".txt" {
+		amis.WriteJsonError(c, fmt.Errorf("仅支持 .md、.markdown、.txt 文件"))
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		amis.WriteJsonError(c, fmt.Errorf("打开上传的文件错误。\n %v", err))
+		return
+	}
+	defer src.Close()
+	content, err := io.ReadAll(src)
+	if err != nil {
+		amis.WriteJsonError(c, fmt.Errorf("读取上传的文件内容错误。\n %v", err))
+		return
+	}
+
+	source := c.PostForm("source")
+	if source == "" {
+		source = service.KnowledgeSourceRunbook
+	}
+	m := &models.KnowledgeDoc{
+		Title:     strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename)),
+		Source:    source,
+		Content:   string(content),
+		Enabled:   true,
+		CreatedBy: params.UserName,
+	}
+	if err := service.KnowledgeService().SaveDoc(ctx, m); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{"value": m.ID})
+}
+
+// @Summary 重建全部知识库文档索引，切换向量模型后使用
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/knowledge/doc/reindex [post]
+func (k *AdminKnowledgeController) Reindex(c *gin.Context) {
+	go service.KnowledgeService().Reindex(context.Background())
+	amis.WriteJsonOKMsg(c, "已开始后台重建索引")
+}
+
+// @Summary 导入巡检AI总结到知识库
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/knowledge/import/inspection [post]
+func (k *AdminKnowledgeController) ImportInspection(c *gin.Context) {
+	params := dao.BuildParams(c)
+	ctx := amis.GetContextWithUser(c)
+
+	count, err := service.KnowledgeService().ImportInspectionSummaries(ctx, params.UserName)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("导入 %d 条巡检总结", count))
+}
+
+// @Summary 导入已恢复的集群中断到知识库
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/knowledge/import/incident [post]
+func (k *AdminKnowledgeController) ImportIncident(c *gin.Context) {
+	params := dao.BuildParams(c)
+	ctx := amis.GetContextWithUser(c)
+
+	count, err := service.KnowledgeService().ImportIncidents(ctx, params.UserName)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("导入 %d 条故障复盘", count))
+}
+
+type importOpenAPIRequest struct {
+	Cluster    string `json:"cluster"`
+	ApiVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+}
+
+// @Summary 导入资源的OpenAPI字段文档到知识库
+// @Security BearerAuth
+// @Param body body importOpenAPIRequest true "集群及资源类型"
+// @Success 200 {object} string
+// @Router /admin/knowledge/import/openapi [post]
+func (k *AdminKnowledgeController) ImportOpenAPI(c *gin.Context) {
+	params := dao.BuildParams(c)
+	ctx := amis.GetContextWithUser(c)
+
+	var req importOpenAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if req.Cluster == "" || req.ApiVersion == "" || req.Kind == "" {
+		amis.WriteJsonError(c, fmt.Errorf("集群、apiVersion、kind 不能为空"))
+		return
+	}
+	m, err := service.KnowledgeService().ImportOpenAPIDoc(ctx, req.Cluster, req.ApiVersion, req.Kind, params.UserName)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("已导入 %s，共 %d 个分片", m.Title, m.ChunkCount))
+}
+
+// @Summary 检索知识库，用于验证检索效果
+// @Security BearerAuth
+// @Param q query string true "问题"
+// @Success 200 {object} string
+// @Router /admin/knowledge/search [get]
+func (k *AdminKnowledgeController) Search(c *gin.Context) {
+	ctx := amis.GetContextWithUser(c)
+	q := c.Query("q")
+	if q == "" {
+		amis.WriteJsonList(c, []any{})
+		return
+	}
+	hits, err := service.KnowledgeService().Search(ctx, q, utils.ToInt(c.DefaultQuery("top_k", "5")))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonList(c, hits)
+}
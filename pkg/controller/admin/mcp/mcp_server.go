@@ -161,6 +161,7 @@ func addTools(ctx context.Context, params *dao.Params, entity models.MCPServerCo
 				Description: tool.Description,
 				InputSchema: utils.ToJSON(tool.InputSchema),
 				Enabled:     true,
+				AccessMode:  service.MCPToolAccessMode(tool),
 			}
 			err = mt.Save(params)
 			if err != nil {
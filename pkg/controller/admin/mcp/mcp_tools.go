@@ -1,11 +1,14 @@
 package mcp
 
 import (
+	"fmt"
+
 	"github.com/gin-gonic/gin"
 	"github.com/weibaohui/k8m/internal/dao"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +20,7 @@ func RegisterMCPToolRoutes(admin *gin.RouterGroup) {
 	ctrl := &ToolController{}
 	admin.GET("/mcp/server/:name/tools/list", ctrl.List)
 	admin.POST("/mcp/tool/save/id/:id/status/:status", ctrl.QuickSave)
+	admin.POST("/mcp/tool/save/id/:id/access_mode", ctrl.SaveAccessMode)
 
 }
 
@@ -66,3 +70,30 @@ func (m *ToolController) QuickSave(c *gin.Context) {
 	}
 	amis.WriteJsonErrorOrOK(c, err)
 }
+
+// @Summary 设置MCP工具读写类型，写入类工具由AI调用时需用户确认
+// @Security BearerAuth
+// @Param id path int true "工具ID"
+// @Param access_mode body string false "读写类型：read、write，为空时按工具名称自动判断"
+// @Success 200 {object} string
+// @Router /admin/mcp/tool/save/id/{id}/access_mode [post]
+func (m *ToolController) SaveAccessMode(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		AccessMode string `json:"access_mode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	switch req.AccessMode {
+	case "", service.MCPToolAccessRead, service.MCPToolAccessWrite:
+	default:
+		amis.WriteJsonError(c, fmt.Errorf("不支持的读写类型: %s", req.AccessMode))
+		return
+	}
+
+	err := dao.DB().Model(&models.MCPTool{}).Where("id = ?", utils.ToUInt(id)).
+		Update("access_mode", req.AccessMode).Error
+	amis.WriteJsonErrorOrOK(c, err)
+}
@@ -30,6 +30,8 @@ func RegisterClusterPermissionRoutes(admin *gin.RouterGroup) {
 	admin.GET("/cluster_permissions/cluster/:cluster/list", ctrl.ListClusterPermissionsByClusterID)      // 列出指定集群下所有授权情况
 	admin.GET("/cluster_permissions/cluster/:cluster/ns/list", ctrl.ListClusterNamespaceListByClusterID) // 列出指定集群下所有授权情况
 	admin.POST("/cluster_permissions/cluster/:cluster/role/:role/:authorization_type/save", ctrl.SaveClusterPermission)
+	admin.GET("/cluster_permissions/selector/list", ctrl.ListSelectorPermissions)                                // 列出按集群标签授权的条目
+	admin.POST("/cluster_permissions/selector/role/:role/:authorization_type/save", ctrl.SaveSelectorPermission) // 按集群标签授权
 	admin.POST("/cluster_permissions/delete/:ids", ctrl.DeleteClusterPermission)
 	admin.POST("/cluster_permissions/update_namespaces/:id", ctrl.UpdateNamespaces)
 	admin.POST("/cluster_permissions/update_blacklist_namespaces/:id", ctrl.UpdateBlacklistNamespaces)
@@ -209,6 +211,82 @@ func (a *AdminClusterPermission) SaveClusterPermission(c *gin.Context) {
 	amis.WriteJsonOK(c)
 }
 
+// @Summary 获取按集群标签授权的条目
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/cluster_permissions/selector/list [get]
+func (a *AdminClusterPermission) ListSelectorPermissions(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.ClusterUserRole{}
+	queryFuncs := genQueryFuncs(c, params)
+	queryFuncs = append(queryFuncs, func(db *gorm.DB) *gorm.DB {
+		return db.Where("cluster_selector <> ''").Order("cluster_selector asc, role asc, authorization_type desc, username asc")
+	})
+	items, total, err := m.List(params, queryFuncs...)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 按集群标签批量添加用户角色权限
+// @Description 匹配标签选择器的集群（包括后续新增或打上标签的集群）自动获得该权限
+// @Security BearerAuth
+// @Param role path string true "角色"
+// @Param authorization_type path string true "授权类型"
+// @Param body body object true "如 {\"cluster_selector\":\"env=prod\",\"users\":\"lisi,test\"}"
+// @Success 200 {object} string
+// @Router /admin/cluster_permissions/selector/role/{role}/{authorization_type}/save [post]
+func (a *AdminClusterPermission) SaveSelectorPermission(c *gin.Context) {
+	role := c.Param("role")
+	authorizationType := c.Param("authorization_type")
+	var req struct {
+		ClusterSelector string `json:"cluster_selector"`
+		Users           string `json:"users"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if !slice.Contain([]string{constants.RoleClusterReadonly, constants.RoleClusterPodExec, constants.RoleClusterAdmin}, role) {
+		amis.WriteJsonError(c, fmt.Errorf("不支持的角色: %s", role))
+		return
+	}
+	req.ClusterSelector = strings.TrimSpace(req.ClusterSelector)
+	if _, err := service.ParseClusterSelector(req.ClusterSelector); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if req.Users == "" {
+		amis.WriteJsonError(c, fmt.Errorf("用户列表不能为空"))
+		return
+	}
+	if authorizationType == "" {
+		authorizationType = "user"
+	}
+
+	params := dao.BuildParams(c)
+	for _, username := range strings.Split(req.Users, ",") {
+		var m models.ClusterUserRole
+		m.ClusterSelector = req.ClusterSelector
+		m.Role = role
+		m.Username = username
+		m.AuthorizationType = constants.ClusterAuthorizationType(authorizationType)
+		one, err := m.GetOne(params, func(db *gorm.DB) *gorm.DB {
+			return db.Where(m)
+		})
+		if err != nil || one == nil {
+			if err := m.Save(params); err != nil {
+				klog.V(6).Infof("新增用户标签授权失败: %s", err.Error())
+				continue
+			}
+		}
+	}
+	service.UserService().ClearCacheByKey("cluster")
+	amis.WriteJsonOK(c)
+}
+
 // @Summary 删除集群权限
 // @Security BearerAuth
 // @Param ids path string true "权限ID，多个用逗号分隔"
@@ -0,0 +1,151 @@
+package user
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type AdminGroupMappingController struct {
+}
+
+// RegisterAdminGroupMappingRoutes 注册外部用户组映射规则路由
+func RegisterAdminGroupMappingRoutes(admin *gin.RouterGroup) {
+	ctrl := &AdminGroupMappingController{}
+	admin.GET("/group_mapping/list", ctrl.List)
+	admin.POST("/group_mapping/save", ctrl.Save)
+	admin.POST("/group_mapping/delete/:ids", ctrl.Delete)
+	admin.POST("/group_mapping/save/id/:id/status/:enabled", ctrl.QuickSave)
+	admin.POST("/group_mapping/preview", ctrl.Preview)
+	admin.POST("/group_mapping/ldap_sync", ctrl.LdapSync)
+}
+
+// @Summary 获取用户组映射规则列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/group_mapping/list [get]
+func (a *AdminGroupMappingController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.GroupMappingRule{}
+	items, total, err := m.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 保存用户组映射规则
+// @Security BearerAuth
+// @Accept json
+// @Param data body models.GroupMappingRule true "映射规则"
+// @Success 200 {object} string
+// @Router /admin/group_mapping/save [post]
+func (a *AdminGroupMappingController) Save(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := models.GroupMappingRule{}
+	if err := c.ShouldBindJSON(&m); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if m.ExternalGroup == "" {
+		amis.WriteJsonError(c, fmt.Errorf("外部用户组不能为空"))
+		return
+	}
+	if m.MatchType == service.GroupMappingMatchRegex {
+		if _, err := regexp.Compile(m.ExternalGroup); err != nil {
+			amis.WriteJsonError(c, fmt.Errorf("正则表达式错误: %w", err))
+			return
+		}
+	} else {
+		m.MatchType = service.GroupMappingMatchExact
+	}
+	if m.UserGroups == "" && (m.Cluster == "" || m.ClusterRole == "") {
+		amis.WriteJsonError(c, fmt.Errorf("请至少配置映射的用户组或集群授权"))
+		return
+	}
+	if m.Cluster == "" {
+		m.ClusterRole, m.Namespaces = "", ""
+	}
+	if err := m.Save(params); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"id": m.ID,
+	})
+}
+
+// @Summary 删除用户组映射规则
+// @Description 已分配的用户组及授权在用户下次登录或LDAP同步时移除
+// @Security BearerAuth
+// @Param ids path string true "规则ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/group_mapping/delete/{ids} [post]
+func (a *AdminGroupMappingController) Delete(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.GroupMappingRule{}
+	err := m.Delete(params, c.Param("ids"))
+	amis.WriteJsonErrorOrOK(c, err)
+}
+
+// @Summary 快速更新用户组映射规则状态
+// @Security BearerAuth
+// @Param id path int true "规则ID"
+// @Param enabled path string true "状态，例如：true、false"
+// @Success 200 {object} string
+// @Router /admin/group_mapping/save/id/{id}/status/{enabled} [post]
+func (a *AdminGroupMappingController) QuickSave(c *gin.Context) {
+	var entity models.GroupMappingRule
+	entity.ID = utils.ToUInt(c.Param("id"))
+	entity.Enabled = c.Param("enabled") == "true"
+	err := dao.DB().Model(&entity).Select("enabled").Updates(entity).Error
+	amis.WriteJsonErrorOrOK(c, err)
+}
+
+// @Summary 预览用户组映射结果
+// @Description 输入用户来源及外部用户组，按当前启用的规则计算映射得到的用户组及集群授权
+// @Security BearerAuth
+// @Param source body string false "用户来源，SSO配置名称或 ldap_config"
+// @Param groups body string true "外部用户组，每行一个"
+// @Success 200 {object} string
+// @Router /admin/group_mapping/preview [post]
+func (a *AdminGroupMappingController) Preview(c *gin.Context) {
+	var req struct {
+		Source string `json:"source"`
+		Groups string `json:"groups"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	groups, grants, err := service.GroupMappingService().Preview(req.Source, utils.SplitAndTrim(req.Groups, "\n"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"user_groups": groups,
+		"grants":      grants,
+	})
+}
+
+// @Summary 立即同步LDAP用户组
+// @Description 从LDAP读取所有LDAP用户的用户组，按映射规则更新用户组及集群授权
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/group_mapping/ldap_sync [post]
+func (a *AdminGroupMappingController) LdapSync(c *gin.Context) {
+	count, err := service.GroupMappingService().SyncLdapUsers()
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("同步完成，处理 %d 个用户", count))
+}
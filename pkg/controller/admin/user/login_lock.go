@@ -0,0 +1,62 @@
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+	"gorm.io/gorm"
+)
+
+type AdminLoginLockController struct {
+}
+
+// RegisterAdminLoginLockRoutes 注册登录失败记录及解锁路由
+func RegisterAdminLoginLockRoutes(admin *gin.RouterGroup) {
+	ctrl := &AdminLoginLockController{}
+	admin.GET("/login_lock/list", ctrl.List)
+	admin.POST("/login_lock/unlock/:ids", ctrl.Unlock)
+}
+
+// @Summary 获取登录失败记录
+// @Description 按用户名、来源IP记录的连续登录失败次数及锁定状态
+// @Security BearerAuth
+// @Success 200 {object} []models.LoginLock
+// @Router /admin/login_lock/list [get]
+func (a *AdminLoginLockController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.LoginLock{}
+	// 仅占用过尝试、未失败过的记录不展示
+	items, total, err := m.List(params, func(db *gorm.DB) *gorm.DB {
+		return db.Where("last_failed_at IS NOT NULL")
+	})
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	now := time.Now()
+	for _, item := range items {
+		item.Locked = item.LockedUntil != nil && item.LockedUntil.After(now)
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 解除登录锁定
+// @Description 解除锁定并清除失败次数，写入操作日志
+// @Security BearerAuth
+// @Param ids path string true "记录ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/login_lock/unlock/{ids} [post]
+func (a *AdminLoginLockController) Unlock(c *gin.Context) {
+	count, err := service.LoginGuardService().Unlock(utils.ToInt64Slice(c.Param("ids")), amis.GetLoginUser(c))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("已解除 %d 条锁定", count))
+}
@@ -0,0 +1,81 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type AdminScimTokenController struct {
+}
+
+// RegisterAdminScimTokenRoutes 注册SCIM Token管理路由
+func RegisterAdminScimTokenRoutes(admin *gin.RouterGroup) {
+	ctrl := &AdminScimTokenController{}
+	admin.GET("/scim_token/list", ctrl.List)
+	admin.POST("/scim_token/create", ctrl.Create)
+	admin.POST("/scim_token/delete/:ids", ctrl.Delete)
+}
+
+// @Summary 获取SCIM Token列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/scim_token/list [get]
+func (a *AdminScimTokenController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.ScimToken{}
+	items, total, err := m.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 创建SCIM Token
+// @Description 创建供身份系统调用SCIM接口的Token，Token 仅在创建时返回一次
+// @Security BearerAuth
+// @Param name body string true "名称"
+// @Param expire_days body int false "有效天数，0表示不过期"
+// @Success 200 {object} string
+// @Router /admin/scim_token/create [post]
+func (a *AdminScimTokenController) Create(c *gin.Context) {
+	var req struct {
+		Name       string `json:"name" binding:"required"`
+		ExpireDays int    `json:"expire_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpireDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpireDays)
+		expiresAt = &t
+	}
+	token, err := service.ScimService().CreateToken(req.Name, amis.GetLoginUser(c), expiresAt)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"token": token,
+	})
+}
+
+// @Summary 删除SCIM Token
+// @Description 删除后使用该Token的请求立即被拒绝
+// @Security BearerAuth
+// @Param ids path string true "Token ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/scim_token/delete/{ids} [post]
+func (a *AdminScimTokenController) Delete(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.ScimToken{}
+	err := m.Delete(params, c.Param("ids"))
+	amis.WriteJsonErrorOrOK(c, err)
+}
@@ -1,7 +1,6 @@
 package user
 
 import (
-	"encoding/base64"
 	"fmt"
 
 	"github.com/duke-git/lancet/v2/slice"
@@ -12,6 +11,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/models"
 	"github.com/weibaohui/k8m/pkg/service"
 	"gorm.io/gorm"
+	"k8s.io/klog/v2"
 )
 
 type AdminUserController struct {
@@ -31,6 +31,9 @@ func RegisterAdminUserRoutes(admin *gin.RouterGroup) {
 	admin.GET("/user/option_list", ctrl.UserOptionList)
 	// 2FA 平台管理员可操作，管理用户
 	admin.POST("/user/2fa/disable/:id", ctrl.Disable2FA)
+	// 登录会话 平台管理员可查看、强制下线
+	admin.GET("/user/sessions/:id", ctrl.ListSessions)
+	admin.POST("/user/sessions/:id/revoke_all", ctrl.RevokeAllSessions)
 
 }
 
@@ -45,7 +48,7 @@ func (a *AdminUserController) List(c *gin.Context) {
 
 	queryFuncs := genQueryFuncs(c, params)
 	queryFuncs = append(queryFuncs, func(db *gorm.DB) *gorm.DB {
-		return db.Select([]string{"id", "group_names", "two_fa_enabled", "username", "two_fa_type", "two_fa_app_name", "source", "created_at", "updated_at", "disabled"})
+		return db.Select([]string{"id", "group_names", "two_fa_enabled", "username", "two_fa_type", "two_fa_app_name", "source", "created_at", "updated_at", "disabled", "email", "notify_webhooks"})
 	})
 	items, total, err := m.List(params, queryFuncs...)
 	if err != nil {
@@ -80,7 +83,7 @@ func (a *AdminUserController) Save(c *gin.Context) {
 			return db
 		} else {
 			// 修改
-			return db.Select([]string{"username", "group_names"})
+			return db.Select([]string{"username", "group_names", "email", "notify_webhooks"})
 		}
 	})
 	err = m.Save(params, queryFuncs...)
@@ -108,18 +111,27 @@ func (a *AdminUserController) Delete(c *gin.Context) {
 
 	queryFuncs := genQueryFuncs(c, params)
 
+	// 删除前记录用户名，用于注销其登录会话
+	var usernames []string
+	dao.DB().Model(&models.User{}).Where("id IN ?", utils.ToInt64Slice(ids)).Pluck("username", &usernames)
+
 	err := m.Delete(params, ids, queryFuncs...)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
 	// 清除用户的缓存
-	service.UserService().ClearCacheByKey(m.Username)
+	for _, username := range usernames {
+		service.UserService().ClearCacheByKey(username)
+		if _, err := service.SessionService().RevokeAll(username, "用户已删除", ""); err != nil {
+			klog.Errorf("注销用户[%s]会话失败: %v", username, err)
+		}
+	}
 	amis.WriteJsonOK(c)
 }
 
 // @Summary 更新用户密码
-// @Description 根据ID更新用户密码
+// @Description 根据ID重置用户密码，用户下次登录时须先修改密码
 // @Security BearerAuth
 // @Accept json
 // @Param id path string true "用户ID"
@@ -129,39 +141,32 @@ func (a *AdminUserController) Delete(c *gin.Context) {
 func (a *AdminUserController) UpdatePsw(c *gin.Context) {
 
 	id := c.Param("id")
-	params := dao.BuildParams(c)
 	m := models.User{}
 	err := c.ShouldBindJSON(&m)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
-	m.ID = uint(utils.ToInt64(id))
 
-	// 密码 + 盐重新计算
 	pswBytes, err := utils.AesDecrypt(m.Password)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
-	m.Salt = utils.RandNLengthString(8)
-	psw, err := utils.AesEncrypt([]byte(fmt.Sprintf("%s%s", string(pswBytes), m.Salt)))
-	if err != nil {
-		amis.WriteJsonError(c, err)
+	user := &models.User{}
+	if err := dao.DB().Select("id", "username").Where("id = ?", utils.ToInt64(id)).First(user).Error; err != nil {
+		amis.WriteJsonError(c, fmt.Errorf("用户不存在"))
 		return
 	}
-
-	m.Password = base64.StdEncoding.EncodeToString(psw)
-
-	queryFuncs := genQueryFuncs(c, params)
-	queryFuncs = append(queryFuncs, func(db *gorm.DB) *gorm.DB {
-		return db.Select([]string{"password", "salt"}).Updates(m)
-	})
-	err = m.Save(params, queryFuncs...)
+	err = service.PasswordService().Set(user.Username, string(pswBytes), true, false)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
+	// 重置密码后原有会话失效
+	if _, err := service.SessionService().RevokeAll(user.Username, "密码已被管理员重置", ""); err != nil {
+		klog.Errorf("注销用户[%s]会话失败: %v", user.Username, err)
+	}
 	amis.WriteJsonOK(c)
 }
 
@@ -265,7 +270,10 @@ func (a *AdminUserController) UserStatusQuickSave(c *gin.Context) {
 	disabled := c.Param("disabled")
 
 	var entity models.User
-	entity.ID = utils.ToUInt(id)
+	if err := dao.DB().Select("id", "username").First(&entity, utils.ToUInt(id)).Error; err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 
 	if disabled == "true" {
 		entity.Disabled = true
@@ -278,5 +286,10 @@ func (a *AdminUserController) UserStatusQuickSave(c *gin.Context) {
 		amis.WriteJsonError(c, err)
 		return
 	}
+	// 清除用户的缓存，禁用立即生效
+	service.UserService().ClearCacheByKey(entity.Username)
+	if entity.Disabled {
+		_, err = service.SessionService().RevokeAll(entity.Username, "用户已禁用", "")
+	}
 	amis.WriteJsonErrorOrOK(c, err)
 }
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type AdminUserKubeconfigController struct {
+}
+
+// RegisterAdminUserKubeconfigRoutes 注册用户 kubeconfig 管理路由
+func RegisterAdminUserKubeconfigRoutes(admin *gin.RouterGroup) {
+	ctrl := &AdminUserKubeconfigController{}
+	admin.GET("/user_kubeconfig/list", ctrl.List)
+	admin.POST("/user_kubeconfig/revoke/:ids", ctrl.Revoke)
+}
+
+// @Summary 获取用户下载的 kubeconfig 列表
+// @Description 查看所有用户在个人中心下载的 kubeconfig 及最后使用情况
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /admin/user_kubeconfig/list [get]
+func (a *AdminUserKubeconfigController) List(c *gin.Context) {
+	params := dao.BuildParams(c)
+	m := &models.UserKubeconfig{}
+	items, total, err := m.List(params)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// @Summary 吊销用户的 kubeconfig
+// @Description 吊销后使用该 kubeconfig 的请求立即被拒绝
+// @Security BearerAuth
+// @Param ids path string true "kubeconfig ID，多个用逗号分隔"
+// @Success 200 {object} string
+// @Router /admin/user_kubeconfig/revoke/{ids} [post]
+func (a *AdminUserKubeconfigController) Revoke(c *gin.Context) {
+	var items []*models.UserKubeconfig
+	err := dao.DB().Where("id in ?", utils.ToInt64Slice(c.Param("ids"))).Find(&items).Error
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	revokedBy := amis.GetLoginUser(c)
+	for _, item := range items {
+		if err := service.UserKubeconfigService().Revoke(item, revokedBy); err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+	}
+	amis.WriteJsonOK(c)
+}
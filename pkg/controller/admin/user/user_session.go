@@ -0,0 +1,58 @@
+package user
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// @Summary 获取用户登录会话
+// @Description 获取指定用户所有有效的登录会话
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} []models.UserSession
+// @Router /admin/user/sessions/{id} [get]
+func (a *AdminUserController) ListSessions(c *gin.Context) {
+	user, err := getUserByID(c.Param("id"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	sessions, err := service.SessionService().ListActive(user.Username)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, sessions)
+}
+
+// @Summary 强制用户下线
+// @Description 注销指定用户的所有登录会话，已签发的Token立即失效
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} string
+// @Router /admin/user/sessions/{id}/revoke_all [post]
+func (a *AdminUserController) RevokeAllSessions(c *gin.Context) {
+	user, err := getUserByID(c.Param("id"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	count, err := service.SessionService().RevokeAll(user.Username, "管理员强制下线", "")
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, fmt.Sprintf("已注销 %d 个会话", count))
+}
+
+func getUserByID(id string) (*models.User, error) {
+	var user models.User
+	err := dao.DB().Select("id", "username").First(&user, utils.ToUInt(id)).Error
+	return &user, err
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/weibaohui/k8m/pkg/agent"
+	"github.com/weibaohui/k8m/pkg/service"
+	"k8s.io/klog/v2"
+)
+
+type Controller struct{}
+
+// RegisterAgentRoutes 集群 Agent 连接入口，使用 Agent Token 认证，无需登录
+func RegisterAgentRoutes(r *gin.RouterGroup) {
+	ctrl := &Controller{}
+	r.GET("/connect", ctrl.Connect)
+}
+
+// Connect Agent 建立反向隧道
+// @Summary 集群 Agent 建立反向隧道
+// @Description Agent 使用 websocket 连接，k8m 经隧道访问 Agent 所在集群的 apiserver，支持 watch、exec、logs、port-forward
+// @Param Authorization header string true "Bearer Agent Token"
+// @Success 101 {object} string "切换为 websocket"
+// @Failure 401 {object} string "Token 无效"
+// @Router /agent/connect [get]
+func (ac *Controller) Connect(c *gin.Context) {
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	item, err := service.ClusterAgentService().Authenticate(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	secret, err := service.ClusterAgentService().TunnelSecret(item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// Agent 不是浏览器，不校验 Origin
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	header := http.Header{}
+	header.Set(agent.TunnelSecretHeader, secret)
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, header)
+	if err != nil {
+		klog.Errorf("Agent[%s]升级 websocket 失败: %v", item.Name, err)
+		return
+	}
+	defer ws.Close()
+
+	err = service.ClusterAgentService().Attach(item, ws, c.ClientIP(),
+		c.GetHeader(agent.VersionHeader), c.GetHeader(agent.APIServerHeader))
+	if err != nil {
+		klog.Errorf("Agent[%s]建立隧道失败: %v", item.Name, err)
+	}
+}
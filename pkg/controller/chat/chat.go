@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/weibaohui/htpl"
+	"github.com/weibaohui/k8m/pkg/ai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/constants"
@@ -62,7 +63,7 @@ type ResourceData struct {
 	Question string `form:"question"`
 }
 
-func handleRequest(c *gin.Context, promptFunc func(data any) string) {
+func handleRequest(c *gin.Context, feature constants.AIFeature, promptFunc func(data any) string) {
 	if !service.AIService().IsEnabled() {
 		amis.WriteJsonData(c, gin.H{
 			"result": "请先配置开启ChatGPT功能",
@@ -77,11 +78,11 @@ func handleRequest(c *gin.Context, promptFunc func(data any) string) {
 		return
 	}
 
-	ctxInst := amis.GetContextWithUser(c)
+	ctxInst := ai.WithCluster(amis.GetContextWithUser(c), c.Query("cluster"))
 
 	prompt := promptFunc(data)
 
-	stream, err := service.ChatService().GetChatStreamWithoutHistory(ctxInst, prompt)
+	stream, err := service.ChatService().GetChatStreamWithoutHistory(ctxInst, feature, prompt)
 	if err != nil {
 		klog.V(2).Infof("Error Stream chat request:%v\n\n", err)
 		return
@@ -128,7 +129,7 @@ func renderTemplate(templateStr string, data any, contextBuilder func(ResourceDa
 // @Router /ai/chat/event [get]
 func (cc *Controller) Event(c *gin.Context) {
 
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureEvent, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeEvent)
 
@@ -172,7 +173,7 @@ func (cc *Controller) Describe(c *gin.Context) {
 		Namespace(data.Namespace).
 		Describe(&describe)
 
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeDescribe)
 
@@ -194,7 +195,7 @@ func (cc *Controller) Describe(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/example [get]
 func (cc *Controller) Example(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeExample)
 
@@ -217,7 +218,7 @@ func (cc *Controller) Example(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/example/field [get]
 func (cc *Controller) FieldExample(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeFieldExample)
 
@@ -240,7 +241,7 @@ func (cc *Controller) FieldExample(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/resource [get]
 func (cc *Controller) Resource(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeResource)
 
@@ -263,7 +264,7 @@ func (cc *Controller) Resource(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/k8s_gpt/resource [get]
 func (cc *Controller) K8sGPTResource(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureK8sGPT, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeK8sGPTResource)
 
@@ -284,7 +285,7 @@ func (cc *Controller) K8sGPTResource(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/any_selection [get]
 func (cc *Controller) AnySelection(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeAnySelection)
 
@@ -305,7 +306,7 @@ func (cc *Controller) AnySelection(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/any_question [get]
 func (cc *Controller) AnyQuestion(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeAnyQuestion)
 
@@ -326,7 +327,7 @@ func (cc *Controller) AnyQuestion(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/cron [get]
 func (cc *Controller) Cron(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureChat, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeCron)
 
@@ -344,7 +345,7 @@ func (cc *Controller) Cron(c *gin.Context) {
 // @Success 200 {object} string
 // @Router /ai/chat/log [get]
 func (cc *Controller) Log(c *gin.Context) {
-	handleRequest(c, func(data any) string {
+	handleRequest(c, constants.AIFeatureLog, func(data any) string {
 		// 从数据库获取prompt模板
 		templateStr := getPromptWithFallback(c.Request.Context(), constants.AIPromptTypeLog)
 
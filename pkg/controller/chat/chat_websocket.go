@@ -2,12 +2,16 @@ package chat
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/weibaohui/k8m/pkg/ai"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/comm/xterm"
 	"github.com/weibaohui/k8m/pkg/service"
@@ -22,6 +26,20 @@ var WebsocketMessageType = map[int]string{
 	websocket.PongMessage:   "pong",
 }
 
+// toolApprovalTimeout 等待用户确认写操作的超时时间，超时视为拒绝
+const toolApprovalTimeout = 5 * time.Minute
+
+// toolApprovalMessageType 工具调用确认消息类型
+const toolApprovalMessageType = "tool_approval"
+
+// toolApprovalMessage 工具调用确认消息
+// 服务端发送 {"type":"tool_approval","data":{...}} 请求确认，
+// 客户端回复 {"type":"tool_approval","id":"...","approved":true,"reason":"..."}
+type toolApprovalMessage struct {
+	Type string `json:"type"`
+	service.ToolApprovalResponse
+}
+
 // @Summary 通过WebSocket提供GPT交互式对话终端
 // @Security BearerAuth
 // @Param cluster query string false "集群名称"
@@ -36,6 +54,7 @@ var WebsocketMessageType = map[int]string{
 // 该函数升级 HTTP 连接为 WebSocket，维持心跳检测，实现双向消息流转：
 // - 前端发送消息后，调用 ChatGPT 并动态集成可用工具，支持流式响应和工具调用结果返回；
 // - 后端将 AI 回复和工具执行结果实时推送给前端；
+// - 大模型调用写操作工具时，推送操作内容及 dry-run 差异，等待用户确认后执行；
 // - 自动处理连接异常、心跳超时和资源释放。
 //
 // 若 AI 服务未启用或参数绑定失败，将返回相应错误信息。
@@ -87,6 +106,49 @@ func (cc *Controller) GPTShell(c *gin.Context) {
 	}
 
 	var outBuffer xterm.SafeBuffer
+	// 输出缓冲区中的内容，与确认请求共用写锁，保证消息顺序
+	flushOutBuffer := func() error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		if outBuffer.Len() == 0 {
+			return nil
+		}
+		data := outBuffer.Bytes()
+		outBuffer.Reset()
+		klog.V(6).Infof("Received stdout (%d bytes): %q", len(data), string(data))
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	// 等待用户确认的工具调用
+	var pendingMutex sync.Mutex
+	pendingApprovals := map[string]chan service.ToolApprovalResponse{}
+	approver := func(ctx context.Context, req *service.ToolApprovalRequest) service.ToolApprovalResponse {
+		ch := make(chan service.ToolApprovalResponse, 1)
+		pendingMutex.Lock()
+		pendingApprovals[req.ID] = ch
+		pendingMutex.Unlock()
+		defer func() {
+			pendingMutex.Lock()
+			delete(pendingApprovals, req.ID)
+			pendingMutex.Unlock()
+		}()
+
+		if err := flushOutBuffer(); err != nil {
+			return service.ToolApprovalResponse{ID: req.ID, Reason: "会话已断开"}
+		}
+		msg := utils.ToJSON(gin.H{"type": toolApprovalMessageType, "data": req})
+		if err := safeWriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			return service.ToolApprovalResponse{ID: req.ID, Reason: "会话已断开"}
+		}
+		select {
+		case resp := <-ch:
+			return resp
+		case <-time.After(toolApprovalTimeout):
+			return service.ToolApprovalResponse{ID: req.ID, Reason: "等待确认超时"}
+		case <-ctx.Done():
+			return service.ToolApprovalResponse{ID: req.ID, Reason: "会话已结束"}
+		}
+	}
 	defer func() {
 		if err := conn.Close(); err != nil {
 			klog.V(6).Infof("failed to close webscoket connection: %s", err)
@@ -132,20 +194,10 @@ func (cc *Controller) GPTShell(c *gin.Context) {
 				break
 			}
 
-			if outBuffer.Len() > 0 {
-				data := outBuffer.Bytes()
-				outBuffer.Reset()
-				klog.V(6).Infof("Received stdout (%d bytes): %q", len(data), string(data))
-
-				if err := safeWriteMessage(websocket.TextMessage, data); err != nil {
-					klog.V(6).Infof("Failed to send stderr message   to xterm.js: %v", err)
-					errorCounter++
-					return
-				} else {
-					klog.V(6).Infof("Sent stdout (%d bytes) to xterm.js : %s", len(data), string(data))
-					errorCounter = 0
-				}
-
+			if err := flushOutBuffer(); err != nil {
+				klog.V(6).Infof("Failed to send stderr message   to xterm.js: %v", err)
+				errorCounter++
+				return
 			}
 
 			time.Sleep(100 * time.Millisecond)
@@ -153,9 +205,22 @@ func (cc *Controller) GPTShell(c *gin.Context) {
 		}
 	}()
 
+	// 对话按顺序执行，读取协程可在执行期间继续接收确认消息
+	prompts := make(chan string, 10)
+	go func() {
+		ctxInst := ai.WithCluster(amis.GetContextWithUser(c), c.Query("cluster"))
+		ctxInst = service.WithToolApprover(ctxInst, approver)
+		for prompt := range prompts {
+			klog.V(6).Infof("prompt: %s", prompt)
+			if err := service.ChatService().RunOneRound(ctxInst, prompt, &outBuffer); err != nil {
+				klog.V(6).Infof("failed to run chat round: %s", err)
+			}
+		}
+	}()
+
 	// chatgpt << ws
 	go func() {
-		ctxInst := amis.GetContextWithUser(c)
+		defer close(prompts)
 		for {
 			// data processing
 			messageType, data, err := conn.ReadMessage()
@@ -173,15 +238,28 @@ func (cc *Controller) GPTShell(c *gin.Context) {
 			}
 			klog.V(6).Infof("received %s (type: %v) message of size %v byte(s) from web ui with key sequence: %v  [%s]", dataType, messageType, dataLength, dataBuffer, string(dataBuffer))
 
-			klog.V(6).Infof("prompt: %s", string(data))
-
-			err = service.ChatService().RunOneRound(ctxInst, string(data), &outBuffer)
-
-			if err != nil {
-				klog.V(6).Infof("failed to write %v bytes to tty: %s", len(dataBuffer), err)
+			var approval toolApprovalMessage
+			if json.Unmarshal(dataBuffer, &approval) == nil && approval.Type == toolApprovalMessageType {
+				pendingMutex.Lock()
+				ch, ok := pendingApprovals[approval.ID]
+				pendingMutex.Unlock()
+				if ok {
+					select {
+					case ch <- approval.ToolApprovalResponse:
+					default:
+					}
+				} else {
+					klog.V(6).Infof("未找到待确认的工具调用: %s", approval.ID)
+				}
 				continue
 			}
 
+			// 不阻塞读取协程，保证对话执行期间仍能收到确认消息
+			select {
+			case prompts <- string(data):
+			default:
+				_, _ = outBuffer.Write([]byte("待处理的提问过多，请等待当前回答完成后再发送。\n"))
+			}
 		}
 
 	}()
@@ -663,7 +663,7 @@ func (nc *ActionController) BatchUpdateImages(c *gin.Context) {
 	} else if successCount == 0 {
 		// 全部失败
 		errorMsg := fmt.Sprintf("批量更新失败，共 %d 个错误：\n%s", len(errors), strings.Join(errors, "\n"))
-		amis.WriteJsonError(c, fmt.Errorf(errorMsg))
+		amis.WriteJsonError(c, fmt.Errorf("%s", errorMsg))
 	} else {
 		// 部分成功
 		resultMsg := fmt.Sprintf("批量更新完成：成功 %d 个，失败 %d 个。\n失败详情：\n%s", 
@@ -0,0 +1,156 @@
+package kubeproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/comm"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+type Controller struct{}
+
+// RegisterKubeProxyRoutes Kubernetes API 代理，kubectl、k9s 等客户端使用API密钥或下载的 kubeconfig 经 k8m 访问集群
+func RegisterKubeProxyRoutes(r *gin.RouterGroup) {
+	ctrl := &Controller{}
+	r.Any("/*path", ctrl.Proxy)
+}
+
+// Proxy 转发 Kubernetes API 请求
+// @Summary Kubernetes API 代理
+// @Description kubeconfig 中 server 设置为 https://<k8m地址>/k8s/proxy/<集群ID>，token 使用API密钥，也可在个人中心直接下载 kubeconfig。
+// @Description 每个请求按用户的集群授权及API密钥范围校验，使用 k8m 保存的集群凭据转发，支持 watch、exec、port-forward。变更类请求及 exec 记录操作日志
+// @Security BearerAuth
+// @Param cluster path string true "集群ID，URL安全的base64编码"
+// @Param path path string true "Kubernetes API 路径，如 /api/v1/namespaces/default/pods"
+// @Success 200 {object} string "集群 apiserver 的响应"
+// @Failure 401 {object} string "未使用API密钥或 kubeconfig"
+// @Failure 403 {object} string "无权限"
+// @Router /k8s/proxy/{cluster}/{path} [get]
+func (pc *Controller) Proxy(c *gin.Context) {
+	clusterIDByte, _ := utils.UrlSafeBase64Decode(c.Param("cluster"))
+	cluster := string(clusterIDByte)
+	username := amis.GetLoginUser(c)
+	apiPath := c.Param("path")
+	info := service.ParseKubeRequest(c.Request.Method, apiPath, c.Request.URL.Query())
+	gr := schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+
+	// 只接受API密钥及 k8m 生成的 kubeconfig，登录 Token 有效期短且与浏览器会话绑定
+	keyID, isApiKey := c.Get(constants.JwtApiKeyID)
+	kubeconfigID, isKubeconfig := c.Get(constants.JwtKubeconfigID)
+	if !isApiKey && !isKubeconfig {
+		writeStatus(c, apierrors.NewUnauthorized("请使用API密钥或在个人中心下载 kubeconfig 访问 Kubernetes API"))
+		return
+	}
+	if cluster == "" {
+		writeStatus(c, apierrors.NewBadRequest("未指定集群"))
+		return
+	}
+	var err error
+	if isApiKey {
+		err = service.ApiKeyService().CheckKubeScope(keyID.(uint), cluster, info)
+	} else {
+		err = service.UserKubeconfigService().CheckScope(kubeconfigID.(uint), cluster)
+	}
+	if err != nil {
+		audit(c, cluster, info, err.Error())
+		writeStatus(c, apierrors.NewForbidden(gr, info.Name, err))
+		return
+	}
+	var nsList []string
+	if info.Namespace != "" {
+		nsList = append(nsList, info.Namespace)
+	}
+	err = comm.CheckPermissionLogic(amis.GetContextWithUser(c), cluster, nsList, info.Namespace, info.Name, info.Action())
+	if err == nil {
+		err = service.KubeProxyService().CheckClusterWide(username, cluster, info)
+	}
+	if err != nil {
+		audit(c, cluster, info, err.Error())
+		writeStatus(c, apierrors.NewForbidden(gr, info.Name, err))
+		return
+	}
+
+	// 开启模拟用户的集群，转发时按请求上下文中的用户添加 Impersonate 请求头
+	c.Request = c.Request.WithContext(amis.GetContextWithUser(c))
+	handler, err := service.KubeProxyService().NewHandler(cluster, c.Request, apiPath, errorResponder{})
+	if err != nil {
+		writeStatus(c, apierrors.NewServiceUnavailable(err.Error()))
+		return
+	}
+	// exec、port-forward 会话可能持续很久，建立连接时即记录
+	if info.Action() == "exec" {
+		audit(c, cluster, info, "success")
+		handler.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+	handler.ServeHTTP(c.Writer, c.Request)
+
+	result := "success"
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		result = fmt.Sprintf("HTTP %d", status)
+	}
+	audit(c, cluster, info, result)
+}
+
+// audit 变更类请求及 exec 写入操作日志
+func audit(c *gin.Context, cluster string, info *service.KubeRequestInfo, result string) {
+	if !info.IsMutation() {
+		return
+	}
+	username := amis.GetLoginUser(c)
+	roles, err := service.UserService().GetRolesByUserName(username)
+	if err != nil {
+		klog.Errorf("get roles by username %s failed: %v", username, err)
+	}
+	kind := info.Resource
+	if info.Subresource != "" {
+		kind += "/" + info.Subresource
+	}
+	service.OperationLogService().Add(&models.OperationLog{
+		Action:       info.Action(),
+		Cluster:      cluster,
+		Kind:         kind,
+		Name:         info.Name,
+		Namespace:    info.Namespace,
+		UserName:     username,
+		Group:        info.APIGroup,
+		Role:         strings.Join(roles, ","),
+		ActionResult: result,
+	}, map[string]string{
+		"via":        "kube-proxy",
+		"verb":       info.Verb,
+		"path":       c.Param("path"),
+		"client_ip":  c.ClientIP(),
+		"user_agent": c.Request.UserAgent(),
+	})
+}
+
+// writeStatus 按 Kubernetes Status 格式返回错误，kubectl 可直接显示
+func writeStatus(c *gin.Context, err *apierrors.StatusError) {
+	status := err.Status()
+	status.Kind, status.APIVersion = "Status", "v1"
+	c.AbortWithStatusJSON(int(status.Code), status)
+}
+
+// errorResponder 连接集群失败时返回 Kubernetes Status 格式的错误
+type errorResponder struct{}
+
+func (errorResponder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	klog.Errorf("Kubernetes API 代理请求 %s 失败: %v", req.URL.Path, err)
+	status := apierrors.NewServiceUnavailable(err.Error()).Status()
+	status.Kind, status.APIVersion = "Status", "v1"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	_ = json.NewEncoder(w).Encode(status)
+}
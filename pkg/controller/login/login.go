@@ -1,10 +1,7 @@
 package login
 
 import (
-	"bytes"
-	"encoding/base64"
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
@@ -25,6 +22,14 @@ type Controller struct{}
 func RegisterLoginRoutes(auth *gin.RouterGroup) {
 	ctrl := &Controller{}
 	auth.POST("/login", ctrl.LoginByPassword)
+	auth.POST("/refresh", ctrl.Refresh)
+	auth.POST("/logout", ctrl.Logout)
+	// 2步验证
+	auth.POST("/mfa/totp", ctrl.VerifyTOTP)
+	auth.POST("/mfa/webauthn/begin", ctrl.BeginWebAuthn)
+	auth.POST("/mfa/webauthn/finish", ctrl.FinishWebAuthn)
+	// 登录时修改密码
+	auth.POST("/password/change", ctrl.ChangePassword)
 }
 
 // Request  用户结构体
@@ -45,7 +50,8 @@ type Request struct {
 // @Param loginType body int false "登录类型 0:普通 1:LDAP"
 // @Param code body string false "2FA验证码"
 // @Success 200 {object} string "登录成功，返回JWT Token"
-// @Failure 401 {object} string "登录失败"
+// @Failure 401 {object} string "登录失败，已绑定2步验证时返回 mfa_token 及可用的验证方式，须修改密码时返回 psw_token"
+// @Failure 429 {object} string "失败次数过多，需等待或已被锁定"
 // @Router /auth/login [post]
 func (lc *Controller) LoginByPassword(c *gin.Context) {
 	var req Request
@@ -56,6 +62,19 @@ func (lc *Controller) LoginByPassword(c *gin.Context) {
 		return
 	}
 
+	// 失败次数过多时直接拒绝，不再校验密码；校验前占用一次尝试，避免并发请求绕过失败次数限制
+	if err := service.LoginGuardService().Reserve(c, req.Username); err != nil {
+		var lockedErr *service.LoginLockedError
+		if !errors.As(err, &lockedErr) {
+			klog.Errorf("用户[%s]登录检查失败: %v", req.Username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "系统错误"})
+			return
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"message": err.Error()})
+		return
+	}
+	defer service.LoginGuardService().Release(c)
+
 	// 初始化配置
 	cfg := flag.Init()
 
@@ -63,15 +82,14 @@ func (lc *Controller) LoginByPassword(c *gin.Context) {
 	decrypt, err := utils.AesDecrypt(req.Password)
 	if err != nil {
 		klog.Errorf("LoginByPassword %v", err.Error())
-		c.JSON(http.StatusUnauthorized, errorInfo)
+		loginFailed(c, req.Username, service.LoginTypePassword, "密码解密失败", errorInfo)
 		return
 	}
 
-	// LDAP登录判断
+	// LDAP登录判断，失败时 handleLDAPLogin 已返回错误信息
 	if req.LoginType == 1 {
 		if err := handleLDAPLogin(c, req.Username, string(decrypt), req.Code, cfg); err != nil {
-			c.JSON(http.StatusUnauthorized, errorInfo)
-			return
+			klog.V(6).Infof("用户[%s]LDAP登录失败: %v", req.Username, err)
 		}
 		return
 	}
@@ -79,13 +97,11 @@ func (lc *Controller) LoginByPassword(c *gin.Context) {
 	if req.Username == cfg.AdminUserName && cfg.EnableTempAdmin {
 		// cfg 用户名密码
 		if string(decrypt) != cfg.AdminPassword {
-			// 前端处理登录状态码，不要修改
-			c.JSON(http.StatusUnauthorized, errorInfo)
+			loginFailed(c, req.Username, service.LoginTypePassword, "密码错误", errorInfo)
 			return
 		}
 		// Admin用户不需要2FA验证
-		token, _ := service.UserService().GenerateJWTTokenOnlyUserName(req.Username, time.Hour*24)
-		c.JSON(http.StatusOK, gin.H{"token": token})
+		writeLoginToken(c, req.Username, service.LoginTypePassword)
 		return
 	} else {
 		// DB 用户名密码
@@ -100,61 +116,58 @@ func (lc *Controller) LoginByPassword(c *gin.Context) {
 
 				if v.Disabled {
 					klog.Errorf("用户[%s]被禁用", v.Username)
-					c.JSON(http.StatusUnauthorized, errorInfo)
+					loginFailed(c, v.Username, service.LoginTypePassword, "用户被禁用", errorInfo)
 					return
 				}
 
-				// password base64解密
-				// 前端密码解密的值，加上盐值，重新计算
-				decryptPsw, err := utils.AesEncrypt([]byte(fmt.Sprintf("%s%s", string(decrypt), v.Salt)))
-				if err != nil {
-					klog.Errorf("LoginByPassword %v", err.Error())
-					c.JSON(http.StatusUnauthorized, errorInfo)
-					return
-				}
-				dbPsw, err := base64.StdEncoding.DecodeString(v.Password)
-				if err != nil {
-					klog.Errorf("LoginByPassword %v", err.Error())
-					c.JSON(http.StatusUnauthorized, errorInfo)
+				ok, needsRehash := service.PasswordService().Verify(v.Password, v.Salt, string(decrypt))
+				if !ok {
+					loginFailed(c, v.Username, service.LoginTypePassword, "密码错误", errorInfo)
 					return
 				}
-				if !bytes.Equal(dbPsw, decryptPsw) {
-					// 前端处理登录状态码，不要修改
-					c.JSON(http.StatusUnauthorized, errorInfo)
-					return
+				// 旧的加密格式在登录成功后转为 argon2id 存储
+				if needsRehash {
+					service.PasswordService().Rehash(v.Username, string(decrypt))
 				}
-
-				// 检查是否启用了2FA
-				if v.TwoFAEnabled {
-					// 如果启用了2FA但未提供验证码
-					if req.Code == "" {
-						c.JSON(http.StatusUnauthorized, gin.H{"message": "请输入2FA验证码"})
-						return
-					}
-					// 验证2FA代码
-					if !totp.ValidateCode(v.TwoFASecret, req.Code) {
-						c.JSON(http.StatusUnauthorized, gin.H{"message": "2FA验证码错误"})
+				// 管理员重置密码或密码过期，须先修改密码
+				if mustChange, reason := service.PasswordService().MustChange(v); mustChange {
+					token, err := service.PasswordService().StartChange(v.Username, service.LoginTypePassword)
+					if err != nil {
+						klog.Errorf("用户[%s]发起修改密码失败: %v", v.Username, err)
+						c.JSON(http.StatusInternalServerError, gin.H{"message": "系统错误"})
 						return
 					}
+					// 前端据 password_change_required 进入修改密码，不要修改
+					c.JSON(http.StatusUnauthorized, gin.H{
+						"message":                  reason,
+						"password_change_required": true,
+						"psw_token":                token,
+					})
+					return
 				}
 
-				token, _ := service.UserService().GenerateJWTTokenOnlyUserName(v.Username, 24*time.Hour)
-				c.JSON(http.StatusOK, gin.H{"token": token})
+				completeLogin(c, v, service.LoginTypePassword, req.Code)
 				return
 			}
 		}
 	}
 
-	c.JSON(http.StatusUnauthorized, errorInfo)
+	loginFailed(c, req.Username, service.LoginTypePassword, "用户不存在", errorInfo)
+}
+
+// loginFailed 记录失败的登录并返回错误信息，前端处理登录状态码，不要修改
+func loginFailed(c *gin.Context, username, loginType, reason string, body gin.H) {
+	service.LoginGuardService().Fail(c, username, loginType, reason)
+	c.JSON(http.StatusUnauthorized, body)
 }
 
 // handleLDAPLogin 处理LDAP登录流程
 func handleLDAPLogin(c *gin.Context, username, password, code string, cfg *flag.Config) error {
 	// 1. LDAP认证
-	_, err := service.UserService().LoginWithLdap(username, password, cfg)
+	entry, err := service.UserService().LoginWithLdap(username, password, cfg)
 	if err != nil {
 		klog.Errorf("LDAP登录失败: %v", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "LDAP登录验证失败"})
+		loginFailed(c, username, service.LoginTypeLDAP, "LDAP认证失败: "+err.Error(), gin.H{"message": "LDAP登录验证失败"})
 		return err
 	}
 
@@ -168,8 +181,10 @@ func handleLDAPLogin(c *gin.Context, username, password, code string, cfg *flag.
 
 	config, err := ldapConfig.GetOne(params, queryFunc)
 	var defaultGroup string
+	var ldapGroups []string
 	if err == nil && config != nil {
 		defaultGroup = config.DefaultGroup
+		ldapGroups = service.UserService().LdapGroups(entry, config)
 	}
 
 	// 2. 检查用户是否已存在
@@ -179,13 +194,13 @@ func handleLDAPLogin(c *gin.Context, username, password, code string, cfg *flag.
 		// 用户已存在，检查是否被禁用
 		if userModel.Disabled {
 			klog.Errorf("用户[%s]被禁用", username)
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户被禁用"})
+			loginFailed(c, username, service.LoginTypeLDAP, "用户被禁用", gin.H{"message": "用户被禁用"})
 			return errors.New("用户被禁用")
 		}
 		// 已存在直接走后续流程
 	} else if errors.Is(err, gorm.ErrRecordNotFound) {
 		// 用户不存在，插入
-		if err := service.UserService().CheckAndCreateUser(username, "ldap_config", defaultGroup); err != nil {
+		if err := service.UserService().CheckAndCreateUser(username, service.LdapUserSource, defaultGroup); err != nil {
 			klog.Errorf("创建/检查LDAP用户失败: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "系统错误"})
 			return err
@@ -197,6 +212,11 @@ func handleLDAPLogin(c *gin.Context, username, password, code string, cfg *flag.
 		return err
 	}
 
+	// 按映射规则更新用户组及集群授权，LDAP中移除的用户组同步移除
+	if err := service.GroupMappingService().Apply(username, service.LdapUserSource, ldapGroups); err != nil {
+		klog.Errorf("用户[%s]用户组映射失败: %v", username, err)
+	}
+
 	// 3. 获取用户信息
 	user, err := getUserInfo(username)
 	if err != nil {
@@ -205,15 +225,37 @@ func handleLDAPLogin(c *gin.Context, username, password, code string, cfg *flag.
 		return err
 	}
 
-	// 4. 验证2FA
-	if err := validateTwoFA(user, code, c); err != nil {
-		return err
+	// 4. 验证2FA并生成token
+	completeLogin(c, user, service.LoginTypeLDAP, code)
+	return nil
+}
+
+// writeLoginToken 创建登录会话，返回访问 Token 及刷新 Token
+func writeLoginToken(c *gin.Context, username, loginType string) {
+	pair, err := service.SessionService().Login(c, username, loginType)
+	if err != nil {
+		klog.Errorf("用户[%s]创建会话失败: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "系统错误"})
+		return
 	}
+	if expiresAt := passwordExpiresAt(username, loginType); expiresAt != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"token":               pair.Token,
+			"refresh_token":       pair.RefreshToken,
+			"expires_in":          pair.ExpiresIn,
+			"password_expires_at": expiresAt,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
 
-	// 5. 生成token
-	token, _ := service.UserService().GenerateJWTTokenOnlyUserName(username, 24*time.Hour)
-	c.JSON(http.StatusOK, gin.H{"token": token})
-	return nil
+// passwordExpiresAt 用户名密码登录且密码即将过期时返回过期时间，前端据此提醒用户修改密码
+func passwordExpiresAt(username, loginType string) *time.Time {
+	if loginType != service.LoginTypePassword {
+		return nil
+	}
+	return service.PasswordService().ExpiresSoon(username)
 }
 
 // getUserInfo 获取用户信息
@@ -226,17 +268,41 @@ func getUserInfo(username string) (*models.User, error) {
 	return user.GetOne(params, queryFunc)
 }
 
-// validateTwoFA 验证2FA
-func validateTwoFA(user *models.User, code string, c *gin.Context) error {
-	if user != nil && user.TwoFAEnabled {
-		if code == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "请输入2FA验证码"})
-			return errors.New("2FA验证码未提供")
-		}
+// completeLogin 密码校验通过后进行2步验证，并按2步验证策略签发 Token
+// 已绑定2步验证时，登录表单中填写了验证码则直接校验，否则返回 mfa_token 由客户端选择验证方式完成登录
+// 未绑定但被策略要求时，宽限期内正常登录并提示截止时间，超过宽限期仅签发用于绑定2步验证的受限 Token
+func completeLogin(c *gin.Context, user *models.User, loginType, code string) {
+	if code != "" && user.TwoFAEnabled {
 		if !totp.ValidateCode(user.TwoFASecret, code) {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "2FA验证码错误"})
-			return errors.New("2FA验证码错误")
+			loginFailed(c, user.Username, loginType, "2FA验证码错误", gin.H{"message": "2FA验证码错误"})
+			return
 		}
+		writeLoginToken(c, user.Username, loginType)
+		return
 	}
-	return nil
+
+	result, err := service.MFAService().BeginLogin(c, user, loginType)
+	if err != nil {
+		klog.Errorf("用户[%s]登录失败: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "系统错误"})
+		return
+	}
+	if result.MFAToken != "" {
+		// 前端据 mfa_required 进入2步验证，不要修改
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message":      "请完成2步验证",
+			"mfa_required": true,
+			"mfa_token":    result.MFAToken,
+			"methods":      result.Methods,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":               result.Pair.Token,
+		"refresh_token":       result.Pair.RefreshToken,
+		"expires_in":          result.Pair.ExpiresIn,
+		"mfa_enroll":          result.Status.Enforced,
+		"mfa_deadline":        result.Status.Deadline,
+		"password_expires_at": passwordExpiresAt(user.Username, loginType),
+	})
 }
@@ -0,0 +1,97 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/service"
+	"k8s.io/klog/v2"
+)
+
+type mfaRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code"`
+}
+
+// VerifyTOTP 使用验证器验证码完成2步验证
+// @Summary 验证码完成2步验证
+// @Description 使用登录时返回的 mfa_token 及验证器中的验证码完成登录
+// @Param mfa_token body string true "登录时返回的2步验证Token"
+// @Param code body string true "验证码"
+// @Success 200 {object} service.TokenPair "登录成功"
+// @Failure 401 {object} string "验证失败"
+// @Router /auth/mfa/totp [post]
+func (lc *Controller) VerifyTOTP(c *gin.Context) {
+	var req mfaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "2步验证已过期，请重新登录"})
+		return
+	}
+	pending, err := service.MFAService().VerifyTOTP(req.MFAToken, req.Code)
+	if err != nil {
+		mfaFailed(c, pending, err)
+		return
+	}
+	writeMFALoginToken(c, pending)
+}
+
+// BeginWebAuthn 发起 WebAuthn 验证
+// @Summary 发起WebAuthn验证
+// @Description 生成通行密钥、安全密钥的验证请求，返回值用于 navigator.credentials.get
+// @Param mfa_token body string true "登录时返回的2步验证Token"
+// @Success 200 {object} string "验证请求"
+// @Failure 401 {object} string "2步验证已过期"
+// @Router /auth/mfa/webauthn/begin [post]
+func (lc *Controller) BeginWebAuthn(c *gin.Context) {
+	var req mfaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "2步验证已过期，请重新登录"})
+		return
+	}
+	assertion, err := service.MFAService().BeginWebAuthnLogin(c, req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assertion)
+}
+
+// FinishWebAuthn 校验 WebAuthn 签名完成2步验证
+// @Summary 完成WebAuthn验证
+// @Description 请求体为 navigator.credentials.get 返回的凭据
+// @Param mfa_token query string true "登录时返回的2步验证Token"
+// @Success 200 {object} service.TokenPair "登录成功"
+// @Failure 401 {object} string "验证失败"
+// @Router /auth/mfa/webauthn/finish [post]
+func (lc *Controller) FinishWebAuthn(c *gin.Context) {
+	pending, err := service.MFAService().FinishWebAuthnLogin(c, c.Query("mfa_token"))
+	if err != nil {
+		mfaFailed(c, pending, err)
+		return
+	}
+	writeMFALoginToken(c, pending)
+}
+
+// mfaFailed 2步验证失败计入登录失败次数，防止在密码泄露后反复尝试验证码
+func mfaFailed(c *gin.Context, pending *service.MFAPending, err error) {
+	if pending != nil {
+		service.LoginGuardService().Fail(c, pending.Username, pending.LoginType, err.Error())
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+}
+
+// writeMFALoginToken 2步验证通过后签发 Token，等待验证期间被锁定或禁用的用户拒绝登录
+func writeMFALoginToken(c *gin.Context, pending *service.MFAPending) {
+	var lockedErr *service.LoginLockedError
+	if err := service.LoginGuardService().Check(c, pending.Username); errors.As(err, &lockedErr) && lockedErr.Locked {
+		c.JSON(http.StatusTooManyRequests, gin.H{"message": err.Error()})
+		return
+	}
+	if service.UserService().IsUserDisabled(pending.Username) {
+		klog.Errorf("用户[%s]被禁用", pending.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户名密码错误或用户被禁用"})
+		return
+	}
+	writeLoginToken(c, pending.Username, pending.LoginType)
+}
@@ -0,0 +1,68 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/service"
+	"k8s.io/klog/v2"
+)
+
+type passwordChangeRequest struct {
+	PswToken        string `json:"psw_token" binding:"required"`
+	Password        string `json:"password" binding:"required"`         // 新密码（加密后）
+	ConfirmPassword string `json:"confirm_password" binding:"required"` // 确认密码（加密后）
+}
+
+// ChangePassword 登录时修改密码
+// @Summary 登录时修改密码
+// @Description 管理员重置密码或密码过期后，使用登录时返回的 psw_token 修改密码，修改成功后继续完成登录
+// @Param psw_token body string true "登录时返回的修改密码Token"
+// @Param password body string true "新密码（加密）"
+// @Param confirm_password body string true "确认密码（加密）"
+// @Success 200 {object} service.TokenPair "登录成功"
+// @Failure 400 {object} string "密码不符合密码策略"
+// @Failure 401 {object} string "修改密码已超时，已绑定2步验证时返回 mfa_token"
+// @Router /auth/password/change [post]
+func (lc *Controller) ChangePassword(c *gin.Context) {
+	var req passwordChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请输入新密码"})
+		return
+	}
+	psw, err := utils.AesDecrypt(req.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "密码解密失败"})
+		return
+	}
+	confirmPsw, err := utils.AesDecrypt(req.ConfirmPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "密码解密失败"})
+		return
+	}
+	if string(psw) != string(confirmPsw) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "两次输入的密码不一致"})
+		return
+	}
+
+	pending, err := service.PasswordService().FinishChange(req.PswToken, string(psw))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrPasswordChangeExpired) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"message": err.Error()})
+		return
+	}
+	klog.V(2).Infof("用户[%s]登录时修改了密码", pending.Username)
+
+	user, err := getUserInfo(pending.Username)
+	if err != nil || user.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户名密码错误或用户被禁用"})
+		return
+	}
+	// 修改密码后继续2步验证及签发 Token
+	completeLogin(c, user, pending.LoginType, "")
+}
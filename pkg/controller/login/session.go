@@ -0,0 +1,54 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh 使用刷新Token换取新的访问Token
+// @Summary 刷新Token
+// @Description 使用刷新Token换取新的访问Token，刷新Token同时轮换，旧刷新Token失效
+// @Param refresh_token body string true "刷新Token"
+// @Success 200 {object} service.TokenPair "新的访问Token及刷新Token"
+// @Failure 401 {object} string "刷新Token无效或会话已注销"
+// @Failure 409 {object} string "刷新Token已被其他请求轮换"
+// @Router /auth/refresh [post]
+func (lc *Controller) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "未提供刷新Token"})
+		return
+	}
+	pair, err := service.SessionService().Refresh(c, req.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenRotated) {
+		// 前端据此状态码改用本地存储中最新的刷新Token
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 注销刷新Token所属的会话，该会话签发的访问Token立即失效
+// @Param refresh_token body string true "刷新Token"
+// @Success 200 {object} string "操作成功"
+// @Router /auth/logout [post]
+func (lc *Controller) Logout(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err == nil {
+		_ = service.SessionService().Logout(req.RefreshToken)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
@@ -16,12 +16,17 @@ import (
 // @Summary 集群选项列表
 // @Description 获取当前登录用户可选的集群列表（下拉选项）
 // @Security BearerAuth
+// @Param selector query string false "集群标签选择器，如 env=prod,group=payments"
 // @Success 200 {object} string
 // @Router /params/cluster/option_list [get]
 func (pc *Controller) ClusterOptionList(c *gin.Context) {
 	user := amis.GetLoginUser(c)
 
-	clusters := service.ClusterService().AllClusters()
+	clusters, err := service.ClusterTagService().Filter(service.ClusterService().AllClusters(), c.Query("selector"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 
 	if len(clusters) == 0 {
 		amis.WriteJsonData(c, gin.H{
@@ -64,12 +69,17 @@ func (pc *Controller) ClusterOptionList(c *gin.Context) {
 // @Summary 集群表格列表
 // @Description 获取当前登录用户可见的集群详细信息（表格）
 // @Security BearerAuth
+// @Param selector query string false "集群标签选择器，如 env=prod,group=payments"
 // @Success 200 {object} string
 // @Router /params/cluster/all [get]
 func (pc *Controller) ClusterTableList(c *gin.Context) {
 	user := amis.GetLoginUser(c)
 
-	clusters := service.ClusterService().AllClusters()
+	clusters, err := service.ClusterTagService().Filter(service.ClusterService().AllClusters(), c.Query("selector"))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 	if !service.UserService().IsUserPlatformAdmin(user) {
 		userCluster, err := service.UserService().GetClusterNames(user)
 		if err != nil {
@@ -82,6 +92,7 @@ func (pc *Controller) ClusterTableList(c *gin.Context) {
 			return slice.Contain(userCluster, cluster.GetClusterID())
 		})
 	}
+	service.ClusterTagService().Fill(clusters)
 	// 增加cluster.NotAfter
 	configs := service.ClusterService().ConnectedClusters() // 优化：移到循环外部
 	for _, cluster := range clusters {
@@ -102,3 +113,21 @@ func (pc *Controller) ClusterTableList(c *gin.Context) {
 	}
 	amis.WriteJsonData(c, clusters)
 }
+
+// @Summary 集群分组及标签选项列表
+// @Description 获取已使用的集群分组及标签，形如 group=payments、env=prod，用于按标签筛选集群
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /params/cluster/tag/option_list [get]
+func (pc *Controller) ClusterTagOptionList(c *gin.Context) {
+	options := make([]map[string]string, 0)
+	for _, tag := range service.ClusterTagService().Options() {
+		options = append(options, map[string]string{
+			"label": tag,
+			"value": tag,
+		})
+	}
+	amis.WriteJsonData(c, gin.H{
+		"options": options,
+	})
+}
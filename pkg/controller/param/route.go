@@ -17,6 +17,8 @@ func RegisterParamRoutes(params *gin.RouterGroup) {
 	params.GET("/cluster/option_list", ctrl.ClusterOptionList)
 	// 获取当前登录用户的集群列表,table列表
 	params.GET("/cluster/all", ctrl.ClusterTableList)
+	// 获取已使用的集群分组及标签,下拉列表
+	params.GET("/cluster/tag/option_list", ctrl.ClusterTagOptionList)
 	// 获取当前软件版本信息
 	params.GET("/version", ctrl.Version)
 	// 获取helm 仓库列表
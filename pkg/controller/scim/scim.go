@@ -0,0 +1,321 @@
+package scim
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/service"
+	"k8s.io/klog/v2"
+)
+
+const scimContentType = "application/scim+json"
+
+type Controller struct {
+}
+
+// RegisterScimRoutes 注册 SCIM 2.0 用户及用户组同步路由，供身份系统自动开通、禁用账号
+func RegisterScimRoutes(r *gin.RouterGroup) {
+	ctrl := &Controller{}
+	r.GET("/ServiceProviderConfig", ctrl.ServiceProviderConfig)
+	r.GET("/ResourceTypes", ctrl.ResourceTypes)
+
+	r.GET("/Users", ctrl.ListUsers)
+	r.POST("/Users", ctrl.CreateUser)
+	r.GET("/Users/:id", ctrl.GetUser)
+	r.PUT("/Users/:id", ctrl.ReplaceUser)
+	r.PATCH("/Users/:id", ctrl.PatchUser)
+	r.DELETE("/Users/:id", ctrl.DeleteUser)
+
+	r.GET("/Groups", ctrl.ListGroups)
+	r.POST("/Groups", ctrl.CreateGroup)
+	r.GET("/Groups/:id", ctrl.GetGroup)
+	r.PUT("/Groups/:id", ctrl.ReplaceGroup)
+	r.PATCH("/Groups/:id", ctrl.PatchGroup)
+	r.DELETE("/Groups/:id", ctrl.DeleteGroup)
+}
+
+// @Summary SCIM服务能力
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (s *Controller) ServiceProviderConfig(c *gin.Context) {
+	writeScim(c, http.StatusOK, gin.H{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": service.ScimMaxResults},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "在 k8m 管理后台创建的 SCIM Token",
+			"primary":     true,
+		}},
+	})
+}
+
+// @Summary SCIM资源类型
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /scim/v2/ResourceTypes [get]
+func (s *Controller) ResourceTypes(c *gin.Context) {
+	types := []gin.H{
+		{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   service.ScimSchemaUser,
+		},
+		{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   service.ScimSchemaGroup,
+		},
+	}
+	writeScimList(c, types, int64(len(types)), 1)
+}
+
+// @Summary SCIM查询用户
+// @Security BearerAuth
+// @Param filter query string false "过滤条件，如 userName eq \"alice\""
+// @Param startIndex query int false "起始位置，从1开始"
+// @Param count query int false "返回条数"
+// @Success 200 {object} string
+// @Router /scim/v2/Users [get]
+func (s *Controller) ListUsers(c *gin.Context) {
+	startIndex, count := pageParams(c)
+	users, total, err := service.ScimService().ListUsers(c.Query("filter"), startIndex, count)
+	if err != nil {
+		writeScimError(c, err)
+		return
+	}
+	writeScimList(c, users, total, startIndex)
+}
+
+// @Summary SCIM获取用户
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Success 200 {object} service.ScimUser
+// @Router /scim/v2/Users/{id} [get]
+func (s *Controller) GetUser(c *gin.Context) {
+	user, err := service.ScimService().GetUser(c.Param("id"))
+	writeScimResult(c, http.StatusOK, user, err)
+}
+
+// @Summary SCIM创建用户
+// @Security BearerAuth
+// @Param data body service.ScimUser true "用户"
+// @Success 201 {object} service.ScimUser
+// @Router /scim/v2/Users [post]
+func (s *Controller) CreateUser(c *gin.Context) {
+	var in service.ScimUser
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeScimError(c, invalidSyntax(err))
+		return
+	}
+	user, err := service.ScimService().CreateUser(&in)
+	writeScimResult(c, http.StatusCreated, user, err)
+}
+
+// @Summary SCIM更新用户
+// @Description 全量更新用户，active 为 false 时禁用用户并注销其登录会话
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Param data body service.ScimUser true "用户"
+// @Success 200 {object} service.ScimUser
+// @Router /scim/v2/Users/{id} [put]
+func (s *Controller) ReplaceUser(c *gin.Context) {
+	var in service.ScimUser
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeScimError(c, invalidSyntax(err))
+		return
+	}
+	user, err := service.ScimService().ReplaceUser(c.Param("id"), &in)
+	writeScimResult(c, http.StatusOK, user, err)
+}
+
+// @Summary SCIM部分更新用户
+// @Description 支持修改 active、externalId，active 为 false 时禁用用户并注销其登录会话
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Param data body service.ScimPatchRequest true "PatchOp"
+// @Success 200 {object} service.ScimUser
+// @Router /scim/v2/Users/{id} [patch]
+func (s *Controller) PatchUser(c *gin.Context) {
+	var in service.ScimPatchRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeScimError(c, invalidSyntax(err))
+		return
+	}
+	user, err := service.ScimService().PatchUser(c.Param("id"), in.Operations)
+	writeScimResult(c, http.StatusOK, user, err)
+}
+
+// @Summary SCIM删除用户
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Success 204 {object} string
+// @Router /scim/v2/Users/{id} [delete]
+func (s *Controller) DeleteUser(c *gin.Context) {
+	if err := service.ScimService().DeleteUser(c.Param("id")); err != nil {
+		writeScimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary SCIM查询用户组
+// @Security BearerAuth
+// @Param filter query string false "过滤条件，如 displayName eq \"dev\""
+// @Param startIndex query int false "起始位置，从1开始"
+// @Param count query int false "返回条数"
+// @Param excludedAttributes query string false "不返回的属性，支持 members"
+// @Success 200 {object} string
+// @Router /scim/v2/Groups [get]
+func (s *Controller) ListGroups(c *gin.Context) {
+	startIndex, count := pageParams(c)
+	excludeMembers := strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+	groups, total, err := service.ScimService().ListGroups(c.Query("filter"), startIndex, count, excludeMembers)
+	if err != nil {
+		writeScimError(c, err)
+		return
+	}
+	writeScimList(c, groups, total, startIndex)
+}
+
+// @Summary SCIM获取用户组
+// @Security BearerAuth
+// @Param id path string true "用户组ID"
+// @Success 200 {object} service.ScimGroup
+// @Router /scim/v2/Groups/{id} [get]
+func (s *Controller) GetGroup(c *gin.Context) {
+	group, err := service.ScimService().GetGroup(c.Param("id"))
+	writeScimResult(c, http.StatusOK, group, err)
+}
+
+// @Summary SCIM创建用户组
+// @Description 新建的用户组为普通用户角色，集群权限仍需管理员授权
+// @Security BearerAuth
+// @Param data body service.ScimGroup true "用户组"
+// @Success 201 {object} service.ScimGroup
+// @Router /scim/v2/Groups [post]
+func (s *Controller) CreateGroup(c *gin.Context) {
+	var in service.ScimGroup
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeScimError(c, invalidSyntax(err))
+		return
+	}
+	group, err := service.ScimService().CreateGroup(&in)
+	writeScimResult(c, http.StatusCreated, group, err)
+}
+
+// @Summary SCIM更新用户组
+// @Description 全量更新用户组名称及成员，成员通过用户的用户组字段维护
+// @Security BearerAuth
+// @Param id path string true "用户组ID"
+// @Param data body service.ScimGroup true "用户组"
+// @Success 200 {object} service.ScimGroup
+// @Router /scim/v2/Groups/{id} [put]
+func (s *Controller) ReplaceGroup(c *gin.Context) {
+	var in service.ScimGroup
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeScimError(c, invalidSyntax(err))
+		return
+	}
+	group, err := service.ScimService().ReplaceGroup(c.Param("id"), &in)
+	writeScimResult(c, http.StatusOK, group, err)
+}
+
+// @Summary SCIM部分更新用户组
+// @Description 支持修改 displayName 及增删成员
+// @Security BearerAuth
+// @Param id path string true "用户组ID"
+// @Param data body service.ScimPatchRequest true "PatchOp"
+// @Success 200 {object} service.ScimGroup
+// @Router /scim/v2/Groups/{id} [patch]
+func (s *Controller) PatchGroup(c *gin.Context) {
+	var in service.ScimPatchRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeScimError(c, invalidSyntax(err))
+		return
+	}
+	group, err := service.ScimService().PatchGroup(c.Param("id"), in.Operations)
+	writeScimResult(c, http.StatusOK, group, err)
+}
+
+// @Summary SCIM删除用户组
+// @Security BearerAuth
+// @Param id path string true "用户组ID"
+// @Success 204 {object} string
+// @Router /scim/v2/Groups/{id} [delete]
+func (s *Controller) DeleteGroup(c *gin.Context) {
+	if err := service.ScimService().DeleteGroup(c.Param("id")); err != nil {
+		writeScimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func pageParams(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(service.ScimMaxResults)))
+	if err != nil {
+		count = service.ScimMaxResults
+	}
+	return startIndex, count
+}
+
+func invalidSyntax(err error) error {
+	return &service.ScimError{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()}
+}
+
+func writeScim(c *gin.Context, status int, data any) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, data)
+}
+
+func writeScimList[T any](c *gin.Context, items []T, total int64, startIndex int) {
+	writeScim(c, http.StatusOK, gin.H{
+		"schemas":      []string{service.ScimSchemaListResponse},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(items),
+		"Resources":    items,
+	})
+}
+
+func writeScimResult(c *gin.Context, status int, data any, err error) {
+	if err != nil {
+		writeScimError(c, err)
+		return
+	}
+	writeScim(c, status, data)
+}
+
+func writeScimError(c *gin.Context, err error) {
+	var se *service.ScimError
+	if !errors.As(err, &se) {
+		klog.Errorf("SCIM %s %s 处理失败: %v", c.Request.Method, c.Request.URL.Path, err)
+		se = &service.ScimError{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	body := gin.H{
+		"schemas": []string{service.ScimSchemaError},
+		"status":  strconv.Itoa(se.Status),
+		"detail":  se.Detail,
+	}
+	if se.ScimType != "" {
+		body["scimType"] = se.ScimType
+	}
+	writeScim(c, se.Status, body)
+}
@@ -0,0 +1,279 @@
+package sso
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/models"
+	"golang.org/x/oauth2"
+	"k8s.io/klog/v2"
+)
+
+const (
+	oauth2StateCookie = "k8m_oauth2_state"
+	// maxUserInfoSize 用户信息响应的最大长度
+	maxUserInfoSize = 1 << 20
+)
+
+// OAuth2Client 不支持OIDC的OAuth2认证服务，如 GitHub、GitLab，通过用户信息接口获取用户
+type OAuth2Client struct {
+	OAuth2Config *oauth2.Config
+	UserInfoURL  string
+	DBConfig     *models.SSOConfig
+	github       bool   // GitHub 通过组织、团队接口获取用户组
+	groupsURL    string // GitLab 群组接口地址
+}
+
+// NewOAuth2Client 创建一个 OAuth2 客户端
+// 未配置授权、Token、用户信息地址时，按认证服务器地址推断：github.com 使用 GitHub 接口，其他使用 GitLab 接口
+func NewOAuth2Client(c *gin.Context, cfg *models.SSOConfig) (*OAuth2Client, error) {
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	authURL, tokenURL, userInfoURL := cfg.AuthURL, cfg.TokenURL, cfg.UserInfoURL
+	var defaultScopes []string
+	if isGitHub(issuer) {
+		authURL = cmp.Or(authURL, "https://github.com/login/oauth/authorize")
+		tokenURL = cmp.Or(tokenURL, "https://github.com/login/oauth/access_token")
+		userInfoURL = cmp.Or(userInfoURL, "https://api.github.com/user")
+		defaultScopes = []string{"read:user", "user:email"}
+		if cfg.AllowedGroups != "" {
+			defaultScopes = append(defaultScopes, "read:org")
+		}
+	} else if issuer != "" {
+		authURL = cmp.Or(authURL, issuer+"/oauth/authorize")
+		tokenURL = cmp.Or(tokenURL, issuer+"/oauth/token")
+		userInfoURL = cmp.Or(userInfoURL, issuer+"/api/v4/user")
+		defaultScopes = []string{"read_user"}
+		if cfg.AllowedGroups != "" {
+			defaultScopes = append(defaultScopes, "read_api")
+		}
+	}
+	if authURL == "" || tokenURL == "" || userInfoURL == "" {
+		return nil, fmt.Errorf("OAuth2配置[%s]缺少授权地址、Token地址或用户信息地址", cfg.Name)
+	}
+
+	scopes := utils.SplitAndTrim(cfg.Scopes, ",")
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	return &OAuth2Client{
+		OAuth2Config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  fmt.Sprintf("%s/auth/%s/%s/callback", externalURL(c), SSOTypeOAuth2, cfg.Name),
+			Endpoint:     oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL},
+			Scopes:       scopes,
+		},
+		UserInfoURL: userInfoURL,
+		DBConfig:    cfg,
+		github:      isGitHub(issuer),
+		groupsURL:   gitLabGroupsURL(issuer),
+	}, nil
+}
+
+// gitLabGroupsURL 按认证服务器地址推断 GitLab 群组接口，GitHub 及未配置地址时返回空
+func gitLabGroupsURL(issuer string) string {
+	if issuer == "" || isGitHub(issuer) {
+		return ""
+	}
+	return issuer + "/api/v4/groups?min_access_level=10&per_page=100"
+}
+
+// UserInfo 使用访问Token获取用户信息，嵌套字段展开为 a.b 形式，便于配置字段映射
+func (o *OAuth2Client) UserInfo(c *gin.Context, token *oauth2.Token) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, o.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.OAuth2Config.Client(c.Request.Context(), token).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUserInfoSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取用户信息失败: %s %s", resp.Status, string(body))
+	}
+	var info map[string]any
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("解析用户信息失败: %w", err)
+	}
+	claims := map[string]any{}
+	flattenClaims("", info, claims)
+	return claims, nil
+}
+
+// Groups 获取用户所属的组织及团队，GitHub 返回 组织名 及 组织/团队，GitLab 返回群组完整路径
+// 仅读取第一页（100条），用于校验允许登录的用户组
+func (o *OAuth2Client) Groups(c *gin.Context, token *oauth2.Token) ([]string, error) {
+	var groups []string
+	if o.github {
+		var orgs []struct {
+			Login string `json:"login"`
+		}
+		if err := o.getJSON(c, token, "https://api.github.com/user/orgs?per_page=100", &orgs); err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			groups = append(groups, org.Login)
+		}
+		var teams []struct {
+			Slug         string `json:"slug"`
+			Organization struct {
+				Login string `json:"login"`
+			} `json:"organization"`
+		}
+		if err := o.getJSON(c, token, "https://api.github.com/user/teams?per_page=100", &teams); err != nil {
+			return nil, err
+		}
+		for _, team := range teams {
+			groups = append(groups, team.Organization.Login+"/"+team.Slug)
+		}
+		return groups, nil
+	}
+	if o.groupsURL == "" {
+		return nil, nil
+	}
+	var list []struct {
+		FullPath string `json:"full_path"`
+	}
+	if err := o.getJSON(c, token, o.groupsURL, &list); err != nil {
+		return nil, err
+	}
+	for _, g := range list {
+		groups = append(groups, g.FullPath)
+	}
+	return groups, nil
+}
+
+// getJSON 使用访问Token请求接口并解析JSON响应
+func (o *OAuth2Client) getJSON(c *gin.Context, token *oauth2.Token, u string, v any) error {
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.OAuth2Config.Client(c.Request.Context(), token).Do(req)
+	if err != nil {
+		return fmt.Errorf("获取用户组失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUserInfoSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("获取用户组失败: %s %s", resp.Status, string(body))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("解析用户组失败: %w", err)
+	}
+	return nil
+}
+
+// @Summary 获取OAuth2认证URL
+// @Description 获取指定SSO名称的OAuth2认证跳转URL
+// @Param name path string true "SSO名称"
+// @Success 302 {string} string
+// @Router /auth/oauth2/{name}/sso [get]
+func (au *AuthController) GetOAuth2AuthCodeURL(c *gin.Context) {
+	name := c.Param("name")
+	cfg, err := getSSOConfig(name, SSOTypeOAuth2)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	client, err := NewOAuth2Client(c, cfg)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	state := utils.RandNLengthString(16)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauth2StateCookie, state, 600, "/auth/"+SSOTypeOAuth2+"/"+url.PathEscape(name), "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, client.OAuth2Config.AuthCodeURL(state))
+}
+
+// @Summary 处理OAuth2回调
+// @Description 处理OAuth2认证后的回调，通过用户信息接口获取用户并完成登录
+// @Param name path string true "SSO名称"
+// @Param code query string true "认证代码"
+// @Param state query string true "状态码"
+// @Success 200 {string} string
+// @Router /auth/oauth2/{name}/callback [get]
+func (au *AuthController) HandleOAuth2Callback(c *gin.Context) {
+	name := c.Param("name")
+	cfg, err := getSSOConfig(name, SSOTypeOAuth2)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	state, err := c.Cookie(oauth2StateCookie)
+	if err != nil || state == "" || state != c.Query("state") {
+		c.String(http.StatusBadRequest, "state 校验失败，请重新登录")
+		return
+	}
+	c.SetCookie(oauth2StateCookie, "", -1, "/auth/"+SSOTypeOAuth2+"/"+url.PathEscape(name), "", c.Request.TLS != nil, true)
+
+	client, err := NewOAuth2Client(c, cfg)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	token, err := client.OAuth2Config.Exchange(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Token exchange error: %v", err)
+		return
+	}
+	claims, err := client.UserInfo(c, token)
+	if err != nil {
+		klog.Errorf("OAuth2[%s] %v", name, err)
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	// 配置了允许登录的用户组时，从组织、团队接口获取用户组
+	if cfg.AllowedGroups != "" {
+		groups, err := client.Groups(c, token)
+		if err != nil {
+			klog.Errorf("OAuth2[%s] %v", name, err)
+			c.String(http.StatusInternalServerError, "%v", err)
+			return
+		}
+		if len(groups) > 0 {
+			claims[cmp.Or(cfg.GroupsKey, "groups")] = groups
+		}
+	}
+	// GitHub 使用 login，GitLab 使用 username 作为登录名
+	completeLogin(c, cfg, claims, "login", "username")
+}
+
+// flattenClaims 展开嵌套的用户信息，{"user":{"name":"a"}} 展开为 user.name
+func flattenClaims(prefix string, src map[string]any, dst map[string]any) {
+	for k, v := range src {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flattenClaims(key, nested, dst)
+			continue
+		}
+		dst[key] = v
+	}
+}
+
+func isGitHub(issuer string) bool {
+	u, err := url.Parse(issuer)
+	return err == nil && (u.Host == "github.com" || u.Host == "www.github.com")
+}
@@ -40,15 +40,8 @@ func NewOIDCClient(c *gin.Context, cfg *models.SSOConfig) (*Client, error) {
 	})
 
 	// 3. 构建 OAuth2 配置
-	// 从上下文中获取gin.Context
-	var redirectURL string
 	// 动态构建回调URL
-	scheme := "http"
-	if c.Request.TLS != nil {
-		scheme = "https"
-	}
-	host := c.Request.Host
-	redirectURL = fmt.Sprintf("%s://%s/auth/%s/%s/callback", scheme, host, cfg.Type, cfg.Name)
+	redirectURL := fmt.Sprintf("%s/auth/%s/%s/callback", externalURL(c), cfg.Type, cfg.Name)
 
 	oauth2Config := &oauth2.Config{
 		ClientID:     cfg.ClientID,
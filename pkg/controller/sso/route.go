@@ -5,6 +5,12 @@ import (
 	"github.com/weibaohui/k8m/pkg/controller/admin/config"
 )
 
+const (
+	SSOTypeOIDC   = "oidc"
+	SSOTypeOAuth2 = "oauth2"
+	SSOTypeSAML   = "saml"
+)
+
 type AuthController struct{}
 
 func RegisterAuthRoutes(auth *gin.RouterGroup) {
@@ -13,5 +19,10 @@ func RegisterAuthRoutes(auth *gin.RouterGroup) {
 	auth.GET("/sso/config", ctrl.GetSSOConfig)
 	auth.GET("/oidc/:name/sso", ctrl.GetAuthCodeURL)
 	auth.GET("/oidc/:name/callback", ctrl.HandleCallback)
+	auth.GET("/oauth2/:name/sso", ctrl.GetOAuth2AuthCodeURL)
+	auth.GET("/oauth2/:name/callback", ctrl.HandleOAuth2Callback)
+	auth.GET("/saml/:name/sso", ctrl.SAMLLogin)
+	auth.GET("/saml/:name/metadata", ctrl.SAMLMetadata)
+	auth.POST("/saml/:name/acs", ctrl.SAMLACS)
 	auth.GET("/ldap/config", ldap.GetLdapConfig)
 }
@@ -0,0 +1,292 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// samlRequestTTL 发起认证请求后等待 IdP 回调的最长时间
+	samlRequestTTL = 10 * time.Minute
+	// samlMetadataTTL IdP 元数据缓存时间
+	samlMetadataTTL     = time.Hour
+	samlSignatureMethod = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+)
+
+// samlKeyMu 串行化 SP 密钥生成，避免并发时生成多份证书
+var samlKeyMu sync.Mutex
+
+// @Summary 获取SAML SP元数据
+// @Description 获取k8m作为SAML服务提供方的元数据，导入到IdP中完成对接
+// @Param name path string true "SSO名称"
+// @Success 200 {string} string
+// @Router /auth/saml/{name}/metadata [get]
+func (au *AuthController) SAMLMetadata(c *gin.Context) {
+	// 未启用时也允许获取元数据，便于先在 IdP 中完成对接
+	cfg := &models.SSOConfig{}
+	err := dao.DB().Where("name = ? AND type = ?", c.Param("name"), SSOTypeSAML).First(cfg).Error
+	if err != nil {
+		c.String(http.StatusNotFound, "%v", err)
+		return
+	}
+	sp, err := newServiceProvider(c, cfg, false)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	buf, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", buf)
+}
+
+// @Summary 发起SAML认证
+// @Description 生成SAML认证请求，跳转到IdP登录
+// @Param name path string true "SSO名称"
+// @Success 302 {string} string
+// @Router /auth/saml/{name}/sso [get]
+func (au *AuthController) SAMLLogin(c *gin.Context) {
+	cfg, err := getSSOConfig(c.Param("name"), SSOTypeSAML)
+	if err != nil {
+		c.String(http.StatusNotFound, "%v", err)
+		return
+	}
+	sp, err := newServiceProvider(c, cfg, true)
+	if err != nil {
+		klog.Errorf("SAML[%s] %v", cfg.Name, err)
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	// 使用请求ID作为 RelayState，回调时据此找到对应的认证请求
+	redirectURL, err := req.Redirect(req.ID, sp)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	// 已发起的认证请求保存到数据库，回调时校验 InResponseTo 并一次性消费，防止断言重放
+	err = service.AuthChallengeService().Create(&models.AuthChallenge{ChallengeKey: samlChallengeKey(req.ID)}, samlRequestTTL)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+// @Summary SAML断言消费地址
+// @Description 接收IdP返回的签名断言，校验后完成用户登录
+// @Param name path string true "SSO名称"
+// @Success 200 {string} string
+// @Router /auth/saml/{name}/acs [post]
+func (au *AuthController) SAMLACS(c *gin.Context) {
+	cfg, err := getSSOConfig(c.Param("name"), SSOTypeSAML)
+	if err != nil {
+		c.String(http.StatusNotFound, "%v", err)
+		return
+	}
+	requestID := c.PostForm("RelayState")
+	if _, err := service.AuthChallengeService().Consume(samlChallengeKey(requestID)); err != nil {
+		c.String(http.StatusBadRequest, "认证请求不存在或已过期，请重新登录")
+		return
+	}
+	sp, err := newServiceProvider(c, cfg, true)
+	if err != nil {
+		klog.Errorf("SAML[%s] %v", cfg.Name, err)
+		c.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	assertion, err := sp.ParseResponse(c.Request, []string{requestID})
+	if err != nil {
+		var ire *saml.InvalidResponseError
+		if errors.As(err, &ire) {
+			klog.Errorf("SAML[%s] 断言校验失败: %v", cfg.Name, ire.PrivateErr)
+		} else {
+			klog.Errorf("SAML[%s] 断言校验失败: %v", cfg.Name, err)
+		}
+		c.String(http.StatusUnauthorized, "SAML断言校验失败")
+		return
+	}
+	completeLogin(c, cfg, assertionClaims(assertion), "uid", "username")
+}
+
+// newServiceProvider 构建SAML SP，withIdp 为 false 时不加载 IdP 元数据，仅用于输出 SP 元数据
+func newServiceProvider(c *gin.Context, cfg *models.SSOConfig, withIdp bool) (*saml.ServiceProvider, error) {
+	key, cert, err := ensureSPKeyPair(cfg)
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("%s/auth/%s/%s", externalURL(c), SSOTypeSAML, url.PathEscape(cfg.Name))
+	metadataURL, err := url.Parse(base + "/metadata")
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(base + "/acs")
+	if err != nil {
+		return nil, err
+	}
+	sp := &saml.ServiceProvider{
+		EntityID:        cfg.EntityID,
+		Key:             key,
+		Certificate:     cert,
+		MetadataURL:     *metadataURL,
+		AcsURL:          *acsURL,
+		SignatureMethod: samlSignatureMethod,
+	}
+	if withIdp {
+		sp.IDPMetadata, err = loadIdpMetadata(c.Request.Context(), cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sp, nil
+}
+
+// loadIdpMetadata 加载 IdP 元数据，优先使用配置的XML，否则从元数据地址获取并缓存
+func loadIdpMetadata(ctx context.Context, cfg *models.SSOConfig) (*saml.EntityDescriptor, error) {
+	if cfg.IdpMetadata != "" {
+		return samlsp.ParseMetadata([]byte(cfg.IdpMetadata))
+	}
+	if cfg.IdpMetadataURL == "" {
+		return nil, fmt.Errorf("SAML配置[%s]未设置IdP元数据", cfg.Name)
+	}
+	metadataURL, err := url.Parse(cfg.IdpMetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("IdP元数据地址错误: %w", err)
+	}
+	cacheKey := fmt.Sprintf("sso:saml:idp:%d:%d", cfg.ID, cfg.UpdatedAt.Unix())
+	return utils.GetOrSetCache(service.CacheService().CacheInstance(), cacheKey, samlMetadataTTL, func() (*saml.EntityDescriptor, error) {
+		client := &http.Client{Timeout: 10 * time.Second}
+		descriptor, err := samlsp.FetchMetadata(ctx, client, *metadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("获取IdP元数据失败: %w", err)
+		}
+		return descriptor, nil
+	})
+}
+
+// ensureSPKeyPair 获取 SP 签名密钥及证书，首次使用时生成自签名证书并保存
+func ensureSPKeyPair(cfg *models.SSOConfig) (*rsa.PrivateKey, *x509.Certificate, error) {
+	if cfg.SPPrivateKey == "" || cfg.SPCertificate == "" {
+		samlKeyMu.Lock()
+		defer samlKeyMu.Unlock()
+		// 其他请求可能已生成，重新读取
+		latest := &models.SSOConfig{}
+		if err := dao.DB().Where("id = ?", cfg.ID).First(latest).Error; err != nil {
+			return nil, nil, err
+		}
+		cfg.SPPrivateKey, cfg.SPCertificate = latest.SPPrivateKey, latest.SPCertificate
+		if cfg.SPPrivateKey == "" || cfg.SPCertificate == "" {
+			keyPEM, certPEM, err := generateSPKeyPair(cfg.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			cfg.SPPrivateKey, cfg.SPCertificate = keyPEM, certPEM
+			err = dao.DB().Model(cfg).Select("sp_private_key", "sp_certificate").Updates(cfg).Error
+			if err != nil {
+				return nil, nil, fmt.Errorf("保存SP证书失败: %w", err)
+			}
+			klog.Infof("已为SAML配置[%s]生成SP证书", cfg.Name)
+		}
+	}
+	return parseSPKeyPair(cfg.SPPrivateKey, cfg.SPCertificate)
+}
+
+// generateSPKeyPair 生成 RSA 私钥及有效期10年的自签名证书
+func generateSPKeyPair(name string) (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "k8m-saml-" + name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return string(keyPEM), string(certPEM), nil
+}
+
+func parseSPKeyPair(keyPEM, certPEM string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("SP私钥格式错误")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析SP私钥失败: %w", err)
+	}
+	cert, err := utils.ParseCertificate([]byte(certPEM))
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析SP证书失败: %w", err)
+	}
+	return key, cert, nil
+}
+
+// assertionClaims 将断言中的属性转换为用户信息，属性同时以 Name 及 FriendlyName 为键，NameID 写入 sub
+func assertionClaims(assertion *saml.Assertion) map[string]any {
+	claims := map[string]any{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		claims["sub"] = assertion.Subject.NameID.Value
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			values := make([]any, 0, len(attr.Values))
+			for _, v := range attr.Values {
+				values = append(values, v.Value)
+			}
+			var value any = values
+			if len(values) == 1 {
+				value = values[0]
+			}
+			for _, key := range []string{attr.Name, attr.FriendlyName} {
+				if key != "" {
+					claims[key] = value
+				}
+			}
+		}
+	}
+	return claims
+}
+
+func samlChallengeKey(requestID string) string {
+	return "saml:" + requestID
+}
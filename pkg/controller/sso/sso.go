@@ -1,10 +1,10 @@
 package sso
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/weibaohui/k8m/pkg/flag"
 
@@ -101,39 +101,127 @@ func (au *AuthController) HandleCallback(c *gin.Context) {
 	// test
 	// claims["groups"] = []string{"CRM开发组", "bdd", "c", "d"}
 
-	username := GetUsername(claims, strings.Split(client.DBConfig.PreferUserNameKeys, ","))
-	groups := GetUserGroups(claims)
-	_ = service.UserService().CheckAndCreateUser(username, name, groups)
-	userLoginToken, err := service.UserService().GenerateJWTTokenOnlyUserName(username, 24*time.Hour)
+	completeLogin(c, client.DBConfig, claims)
+}
+
+// completeLogin 根据认证服务返回的用户信息创建或更新用户，签发Token后写入 localStorage 并跳转首页
+// OIDC、OAuth2、SAML 共用该流程
+func completeLogin(c *gin.Context, cfg *models.SSOConfig, claims map[string]any, extraUserNameKeys ...string) {
+	preferKeys := append(strings.Split(cfg.PreferUserNameKeys, ","), extraUserNameKeys...)
+	username := GetUsername(claims, preferKeys)
+	externalGroups := getUserGroupList(claims, cfg.GroupsKey)
+	// 配置了映射规则时按规则分配用户组，否则直接使用外部用户组名称
+	groups := strings.Join(externalGroups, ",")
+	if service.GroupMappingService().HasRules(cfg.Name) {
+		groups = ""
+	}
+	if !groupAllowed(cfg.AllowedGroups, externalGroups) {
+		klog.Errorf("用户[%s]不属于SSO[%s]允许登录的用户组", username, cfg.Name)
+		c.String(http.StatusForbidden, "用户不属于允许登录的用户组")
+		return
+	}
+	if err := service.UserService().CheckAndCreateUser(username, cfg.Name, groups); err != nil {
+		klog.Errorf("SSO[%s]创建/检查用户[%s]失败: %v", cfg.Name, username, err)
+		if errors.Is(err, service.ErrUserSourceConflict) {
+			c.String(http.StatusForbidden, "用户名已被其他来源的用户使用，请联系管理员")
+			return
+		}
+		c.String(http.StatusInternalServerError, "系统错误")
+		return
+	}
+	if err := service.GroupMappingService().Apply(username, cfg.Name, externalGroups); err != nil {
+		klog.Errorf("用户[%s]用户组映射失败: %v", username, err)
+	}
+	if service.UserService().IsUserDisabled(username) {
+		klog.Errorf("用户[%s]被禁用", username)
+		c.String(http.StatusUnauthorized, "用户已被禁用")
+		return
+	}
+	user := &models.User{}
+	if err := dao.DB().Where("username = ? AND source = ?", username, cfg.Name).First(user).Error; err != nil {
+		klog.Errorf("获取用户[%s]失败: %v", username, err)
+		c.String(http.StatusInternalServerError, "系统错误")
+		return
+	}
+	// 与用户名密码登录相同，按2步验证策略继续登录
+	result, err := service.MFAService().BeginLogin(c, user, service.LoginTypeSSO)
 	if err != nil {
-		amis.WriteJsonError(c, err)
+		klog.Errorf("用户[%s]登录失败: %v", username, err)
+		c.String(http.StatusInternalServerError, "系统错误")
 		return
 	}
+	data := gin.H{
+		"mfa_required": true,
+		"mfa_token":    result.MFAToken,
+		"methods":      result.Methods,
+	}
+	if result.MFAToken == "" {
+		data = gin.H{
+			"token":         result.Pair.Token,
+			"refresh_token": result.Pair.RefreshToken,
+			"expires_in":    result.Pair.ExpiresIn,
+			"mfa_enroll":    result.Status.Enforced,
+			"mfa_deadline":  result.Status.Deadline,
+		}
+	}
 
-	// 返回 HTML + JS，用于写入 localStorage
+	// 返回 HTML + JS，将登录结果交给登录页处理 Token 或2步验证
 	html := fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
   <head><title>SSO Login Success</title></head>
   <body>
     <script>
-      localStorage.setItem("token", %q);
-      // 自动跳转回首页或 dashboard
-      window.location.href = "/#/";
+      sessionStorage.setItem("sso_login", %q);
+      window.location.href = "/#/login";
     </script>
-    <p>登录成功，正在跳转...</p>
+    <p>认证成功，正在跳转...</p>
   </body>
 </html>
-`, userLoginToken)
+`, utils.ToJSONCompact(data))
 
 	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
 }
 
+// groupAllowed 未配置允许登录的用户组时不限制，否则用户需属于其中任一用户组，不区分大小写
+func groupAllowed(allowed string, groups []string) bool {
+	list := utils.SplitAndTrim(allowed, ",")
+	if len(list) == 0 {
+		return true
+	}
+	for _, a := range list {
+		for _, g := range groups {
+			if strings.EqualFold(a, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getSSOConfig 获取指定名称及类型的已启用SSO配置
+func getSSOConfig(name, ssoType string) (*models.SSOConfig, error) {
+	var dbConfig *models.SSOConfig
+	err := dao.DB().Where("name = ? AND type = ? AND enabled = ?", name, ssoType, true).First(&dbConfig).Error
+	if err != nil {
+		return nil, err
+	}
+	return dbConfig, nil
+}
+
+// externalURL 根据请求构建外部访问地址，用于拼接回调地址
+func externalURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
+
 // 获取默认OIDC客户端配置
 func getDefaultOIDCClient(c *gin.Context, name string) (*Client, error) {
 	// 通过name 获取配置
-	var dbConfig *models.SSOConfig
-	err := dao.DB().Where("name = ?", name).First(&dbConfig).Error
+	dbConfig, err := getSSOConfig(name, SSOTypeOIDC)
 	if err != nil {
 		return nil, err
 	}
@@ -165,19 +253,27 @@ func GetUsername(claims map[string]any, preferKeys []string) string {
 
 // GetUserGroups 获取用户组
 func GetUserGroups(claims map[string]any) string {
+	return strings.Join(getUserGroupList(claims, "groups"), ",")
+}
+
+// getUserGroupList 从指定字段获取用户组，字段为空时使用 groups
+func getUserGroupList(claims map[string]any, key string) []string {
+	if key == "" {
+		key = "groups"
+	}
 	var groups []string
-	if v, ok := claims["groups"].([]any); ok {
+	if v, ok := claims[key].([]any); ok {
 		for _, g := range v {
 			if s, ok := g.(string); ok {
 				groups = append(groups, s)
 			}
 		}
-	} else if v, ok := claims["groups"].([]string); ok {
+	} else if v, ok := claims[key].([]string); ok {
 		groups = v
-	} else if v, ok := claims["groups"].(string); ok && v != "" {
+	} else if v, ok := claims[key].(string); ok && v != "" {
 		groups = append(groups, v)
 	}
-	return strings.Join(groups, ",")
+	return groups
 }
 
 // @Summary 获取LDAP开关状态
@@ -2,6 +2,8 @@ package apikey
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,14 +22,23 @@ func RegisterAPIKeysRoutes(mgm *gin.RouterGroup) {
 	ctrl := &Controller{}
 	mgm.GET("/user/profile/api_keys/list", ctrl.List)
 	mgm.POST("/user/profile/api_keys/create", ctrl.Create)
+	mgm.POST("/user/profile/api_keys/revoke/:id", ctrl.Revoke)
 	mgm.POST("/user/profile/api_keys/delete/:id", ctrl.Delete)
 }
 
+// maxExpireDays API密钥最长有效期（天）
+const maxExpireDays = 3650
+
 // Create 创建API密钥
 // @Summary 创建API密钥
-// @Description 为当前用户创建一个新的API密钥
+// @Description 为当前用户创建一个新的API密钥，可设置有效期、只读、集群/命名空间范围及来源IP白名单
 // @Security BearerAuth
 // @Param description body string false "密钥描述"
+// @Param expire_days body int true "有效期（天）"
+// @Param read_only body bool false "是否只读"
+// @Param clusters body string false "允许访问的集群，逗号分隔"
+// @Param namespaces body string false "允许访问的命名空间，逗号分隔"
+// @Param allowed_ips body string false "来源IP白名单，支持CIDR，逗号分隔"
 // @Success 200 {object} string "操作成功"
 // @Router /mgm/user/profile/api_keys/create [post]
 func (ac *Controller) Create(c *gin.Context) {
@@ -35,27 +46,68 @@ func (ac *Controller) Create(c *gin.Context) {
 
 	var req struct {
 		Description string `json:"description"`
+		ExpireDays  int    `json:"expire_days"`
+		ReadOnly    bool   `json:"read_only"`
+		Clusters    string `json:"clusters"`
+		Namespaces  string `json:"namespaces"`
+		AllowedIPs  string `json:"allowed_ips"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
+	if req.ExpireDays <= 0 || req.ExpireDays > maxExpireDays {
+		amis.WriteJsonError(c, fmt.Errorf("有效期应在 1 到 %d 天之间", maxExpireDays))
+		return
+	}
+	if err := service.ValidateIPList(req.AllowedIPs); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 
 	// 从JWT中获取用户信息
 	username := c.GetString(constants.JwtUserName)
 
-	// 生成API密钥
+	// 限制的集群须在用户已授权的集群范围内
+	clusters := splitList(req.Clusters)
+	if len(clusters) > 0 && !service.UserService().IsUserPlatformAdmin(username) {
+		allowed, err := service.UserService().GetClusterNames(username)
+		if err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+		for _, cluster := range clusters {
+			if !slices.Contains(allowed, cluster) {
+				amis.WriteJsonError(c, fmt.Errorf("无权访问集群: %s", cluster))
+				return
+			}
+		}
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, req.ExpireDays)
 	apiKey := &models.ApiKey{
 		Username:    username,
-		Key:         generateAPIKey(username),
 		Description: req.Description,
-		LastUsedAt:  time.Now(),
+		ExpiresAt:   &expiresAt,
+		ReadOnly:    req.ReadOnly,
+		Clusters:    strings.Join(clusters, ","),
+		Namespaces:  strings.Join(splitList(req.Namespaces), ","),
+		AllowedIPs:  strings.Join(splitList(req.AllowedIPs), ","),
+		CreatedBy:   username,
+	}
+	// 先保存获取ID，Token 中需携带密钥ID
+	if err := apiKey.Save(params); err != nil {
+		amis.WriteJsonError(c, err)
+		return
 	}
-	if apiKey.Key == "" {
+	token, err := service.ApiKeyService().GenerateToken(apiKey)
+	if err != nil {
+		klog.Errorf("generateAPIKey error: %v", err)
+		_ = apiKey.Delete(params, fmt.Sprintf("%d", apiKey.ID))
 		amis.WriteJsonError(c, fmt.Errorf("生成API密钥失败"))
 		return
 	}
-	// 保存到数据库
+	apiKey.Key = token
 	if err := apiKey.Save(params); err != nil {
 		amis.WriteJsonError(c, err)
 		return
@@ -63,16 +115,16 @@ func (ac *Controller) Create(c *gin.Context) {
 
 	amis.WriteJsonOK(c)
 }
-func generateAPIKey(username string) string {
-	// 查询用户对应的集群
-	// todo 有效期应该是一个可配置项
-	duration := time.Hour * 24 * 365
-	token, err := service.UserService().GenerateJWTTokenOnlyUserName(username, duration)
-	if err != nil {
-		klog.Errorf("generateAPIKey error: %v", err)
-		return ""
+
+// splitList 拆分逗号或换行分隔的列表
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
 	}
-	return token
+	return result
 }
 
 // List 获取API密钥列表
@@ -98,22 +150,62 @@ func (ac *Controller) List(c *gin.Context) {
 	amis.WriteJsonData(c, list)
 }
 
+// Revoke 吊销API密钥
+// @Summary 吊销API密钥
+// @Description 吊销指定ID的API密钥，立即生效，保留使用记录
+// @Security BearerAuth
+// @Param id path string true "API密钥ID"
+// @Success 200 {object} string "操作成功"
+// @Router /mgm/user/profile/api_keys/revoke/{id} [post]
+func (ac *Controller) Revoke(c *gin.Context) {
+	apiKey, err := ac.getOwnKey(c)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if apiKey.Revoked {
+		amis.WriteJsonOK(c)
+		return
+	}
+	if err := service.ApiKeyService().Revoke(apiKey, "用户吊销"); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOK(c)
+}
+
+// Delete 删除API密钥
 // @Summary 删除API密钥
-// @Description 删除指定ID的API密钥
+// @Description 删除指定ID的API密钥，删除前先吊销，确保已签发的密钥立即失效
 // @Security BearerAuth
 // @Param id path string true "API密钥ID"
 // @Success 200 {object} string "操作成功"
 // @Router /mgm/user/profile/api_keys/delete/{id} [post]
 func (ac *Controller) Delete(c *gin.Context) {
-	id := c.Param("id")
-	params := dao.BuildParams(c)
-
-	apiKey := &models.ApiKey{}
-
-	err := apiKey.Delete(params, id)
+	apiKey, err := ac.getOwnKey(c)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
+	if !apiKey.Revoked {
+		if err := service.ApiKeyService().Revoke(apiKey, "用户删除"); err != nil {
+			amis.WriteJsonError(c, err)
+			return
+		}
+	}
+	if err := apiKey.Delete(dao.BuildParams(c), c.Param("id")); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
 	amis.WriteJsonOK(c)
 }
+
+// getOwnKey 获取当前用户名下的API密钥
+func (ac *Controller) getOwnKey(c *gin.Context) (*models.ApiKey, error) {
+	username := c.GetString(constants.JwtUserName)
+	id := c.Param("id")
+	apiKey := &models.ApiKey{}
+	return apiKey.GetOne(dao.BuildParams(c), func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ? AND username = ?", id, username)
+	})
+}
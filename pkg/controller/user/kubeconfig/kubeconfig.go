@@ -0,0 +1,203 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+	"gorm.io/gorm"
+)
+
+type Controller struct{}
+
+const (
+	// maxTokenHours token 模式Token最长有效期（小时）
+	maxTokenHours = 7 * 24
+	// maxExecDays exec 模式凭据最长有效期（天）
+	maxExecDays = 365
+)
+
+// RegisterKubeconfigRoutes 个人中心下载 kubeconfig
+func RegisterKubeconfigRoutes(mgm *gin.RouterGroup) {
+	ctrl := &Controller{}
+	mgm.GET("/user/profile/kubeconfig/list", ctrl.List)
+	mgm.GET("/user/profile/kubeconfig/clusters", ctrl.ClusterOptions)
+	mgm.POST("/user/profile/kubeconfig/create", ctrl.Create)
+	mgm.POST("/user/profile/kubeconfig/revoke/:id", ctrl.Revoke)
+}
+
+// RegisterKubeconfigAuthRoutes exec 插件换取Token，使用 kubeconfig 中的凭据认证，无需登录
+func RegisterKubeconfigAuthRoutes(auth *gin.RouterGroup) {
+	ctrl := &Controller{}
+	auth.POST("/kubeconfig/exec_credential", ctrl.ExecCredential)
+}
+
+// Create 生成 kubeconfig
+// @Summary 生成 kubeconfig
+// @Description 为当前用户有权限的集群生成 kubeconfig，经 k8m 的 Kubernetes API 代理访问，权限与用户在 k8m 中的集群授权一致。
+// @Description token 模式写入短期Token，exec 模式通过 curl 向 k8m 换取每小时刷新的Token。kubeconfig 仅返回一次
+// @Security BearerAuth
+// @Param description body string false "描述"
+// @Param mode body string true "token 或 exec"
+// @Param expire_hours body int false "token 模式Token有效期（小时）"
+// @Param expire_days body int false "exec 模式凭据有效期（天）"
+// @Param clusters body string false "包含的集群，逗号分隔，为空包含全部有权限的集群"
+// @Success 200 {object} string "kubeconfig 内容"
+// @Router /mgm/user/profile/kubeconfig/create [post]
+func (kc *Controller) Create(c *gin.Context) {
+	var req struct {
+		Description string `json:"description"`
+		Mode        string `json:"mode"`
+		ExpireHours int    `json:"expire_hours"`
+		ExpireDays  int    `json:"expire_days"`
+		Clusters    string `json:"clusters"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	var ttl time.Duration
+	switch req.Mode {
+	case service.UserKubeconfigModeToken:
+		if req.ExpireHours <= 0 || req.ExpireHours > maxTokenHours {
+			amis.WriteJsonError(c, fmt.Errorf("Token有效期应在 1 到 %d 小时之间", maxTokenHours))
+			return
+		}
+		ttl = time.Duration(req.ExpireHours) * time.Hour
+	case service.UserKubeconfigModeExec:
+		if req.ExpireDays <= 0 || req.ExpireDays > maxExecDays {
+			amis.WriteJsonError(c, fmt.Errorf("凭据有效期应在 1 到 %d 天之间", maxExecDays))
+			return
+		}
+		ttl = time.Duration(req.ExpireDays) * 24 * time.Hour
+	default:
+		amis.WriteJsonError(c, fmt.Errorf("不支持的模式: %s", req.Mode))
+		return
+	}
+
+	username := amis.GetLoginUser(c)
+	data, item, err := service.UserKubeconfigService().Issue(&service.UserKubeconfigRequest{
+		Username:    username,
+		Description: req.Description,
+		Mode:        req.Mode,
+		Clusters:    splitList(req.Clusters),
+		TTL:         ttl,
+		Server:      serverURL(c),
+		ClientIP:    c.ClientIP(),
+	})
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"kubeconfig": string(data),
+		"filename":   fmt.Sprintf("k8m-%s.kubeconfig", username),
+		"expires_at": item.ExpiresAt,
+	})
+}
+
+// ClusterOptions 可写入 kubeconfig 的集群
+// @Summary 获取可写入 kubeconfig 的集群
+// @Description 当前用户有权限的集群，平台管理员为全部集群
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /mgm/user/profile/kubeconfig/clusters [get]
+func (kc *Controller) ClusterOptions(c *gin.Context) {
+	clusters, err := service.UserKubeconfigService().AuthorizedClusters(amis.GetLoginUser(c))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	var options []map[string]string
+	for _, cluster := range clusters {
+		options = append(options, map[string]string{"label": cluster, "value": cluster})
+	}
+	amis.WriteJsonData(c, gin.H{"options": options})
+}
+
+// List 获取已下载的 kubeconfig
+// @Summary 获取已下载的 kubeconfig 列表
+// @Description 获取当前用户下载过的 kubeconfig，不含 kubeconfig 内容
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /mgm/user/profile/kubeconfig/list [get]
+func (kc *Controller) List(c *gin.Context) {
+	username := c.GetString(constants.JwtUserName)
+	m := &models.UserKubeconfig{}
+	items, total, err := m.List(dao.BuildParams(c), func(db *gorm.DB) *gorm.DB {
+		return db.Where("username = ?", username)
+	})
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonListWithTotal(c, total, items)
+}
+
+// Revoke 吊销 kubeconfig
+// @Summary 吊销 kubeconfig
+// @Description 吊销当前用户下载的 kubeconfig，立即生效
+// @Security BearerAuth
+// @Param id path string true "kubeconfig ID"
+// @Success 200 {object} string "操作成功"
+// @Router /mgm/user/profile/kubeconfig/revoke/{id} [post]
+func (kc *Controller) Revoke(c *gin.Context) {
+	username := c.GetString(constants.JwtUserName)
+	m := &models.UserKubeconfig{}
+	item, err := m.GetOne(dao.BuildParams(c), func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ? AND username = ?", c.Param("id"), username)
+	})
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	if err := service.UserKubeconfigService().Revoke(item, username); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOK(c)
+}
+
+// ExecCredential exec 插件换取Token
+// @Summary kubeconfig exec 插件换取Token
+// @Description 使用 kubeconfig 中的凭据换取短期Token，返回 client.authentication.k8s.io/v1 ExecCredential
+// @Param Authorization header string true "Bearer kubeconfig 凭据"
+// @Success 200 {object} service.ExecCredential
+// @Failure 401 {object} string "凭据无效、已过期或已吊销"
+// @Router /auth/kubeconfig/exec_credential [post]
+func (kc *Controller) ExecCredential(c *gin.Context) {
+	secret := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	cred, err := service.UserKubeconfigService().ExecCredential(secret, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cred)
+}
+
+// serverURL k8m 的访问地址，经反向代理时根据 X-Forwarded-Proto 判断协议
+func serverURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// splitList 拆分逗号或换行分隔的列表
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
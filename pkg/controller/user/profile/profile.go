@@ -1,16 +1,17 @@
 package profile
 
 import (
-	"encoding/base64"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
 	"github.com/weibaohui/k8m/internal/dao"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/constants"
 	"github.com/weibaohui/k8m/pkg/models"
 	"github.com/weibaohui/k8m/pkg/service"
 	"gorm.io/gorm"
+	"k8s.io/klog/v2"
 )
 
 type Controller struct{}
@@ -20,10 +21,21 @@ func RegisterProfileRoutes(mgm *gin.RouterGroup) {
 	mgm.GET("/user/profile", ctrl.Profile)
 	mgm.GET("/user/profile/cluster/permissions/list", ctrl.ListUserPermissions)
 	mgm.POST("/user/profile/update_psw", ctrl.UpdatePsw)
+	mgm.GET("/user/profile/ai/usage", ctrl.AIUsage)
+	// 登录会话
+	mgm.GET("/user/profile/sessions/list", ctrl.ListSessions)
+	mgm.POST("/user/profile/sessions/revoke/:id", ctrl.RevokeSession)
+	mgm.POST("/user/profile/sessions/revoke_others", ctrl.RevokeOtherSessions)
 	// user profile 2FA 用户自助操作
 	mgm.POST("/user/profile/2fa/generate", ctrl.Generate2FASecret)
 	mgm.POST("/user/profile/2fa/disable", ctrl.Disable2FA)
 	mgm.POST("/user/profile/2fa/enable", ctrl.Enable2FA)
+	// 通行密钥及2步验证状态
+	mgm.GET("/user/profile/mfa/status", ctrl.MFAStatus)
+	mgm.GET("/user/profile/webauthn/list", ctrl.ListWebAuthn)
+	mgm.POST("/user/profile/webauthn/register/begin", ctrl.BeginWebAuthnRegister)
+	mgm.POST("/user/profile/webauthn/register/finish", ctrl.FinishWebAuthnRegister)
+	mgm.POST("/user/profile/webauthn/delete/:id", ctrl.DeleteWebAuthn)
 }
 
 // @Summary 获取用户信息
@@ -88,6 +100,13 @@ func (uc *Controller) UpdatePsw(c *gin.Context) {
 		return
 	}
 
+	// 原密码校验与登录共用失败次数限制，避免借修改密码接口绕过登录锁定猜测密码
+	if err := service.LoginGuardService().Reserve(c, params.UserName); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	defer service.LoginGuardService().Release(c)
+
 	// 获取用户当前密码信息进行验证
 	currentUser := models.User{}
 	err = dao.DB().Select([]string{"password", "salt"}).Where("username=?", params.UserName).First(&currentUser).Error
@@ -104,16 +123,12 @@ func (uc *Controller) UpdatePsw(c *gin.Context) {
 	}
 
 	// 验证原密码是否正确
-	oldPswWithSalt := fmt.Sprintf("%s%s", string(oldPswBytes), currentUser.Salt)
-	encryptedOldPsw, err := utils.AesEncrypt([]byte(oldPswWithSalt))
-	if err != nil {
-		amis.WriteJsonError(c, err)
-		return
-	}
-	if currentUser.Password != base64.StdEncoding.EncodeToString(encryptedOldPsw) {
+	if ok, _ := service.PasswordService().Verify(currentUser.Password, currentUser.Salt, string(oldPswBytes)); !ok {
+		service.LoginGuardService().Fail(c, params.UserName, service.LoginTypePassword, "修改密码时原密码错误")
 		amis.WriteJsonError(c, fmt.Errorf("原密码不正确"))
 		return
 	}
+	service.LoginGuardService().Succeed(params.UserName)
 
 	// 解密新密码进行比较
 	pswBytes, err := utils.AesDecrypt(req.Password)
@@ -133,24 +148,15 @@ func (uc *Controller) UpdatePsw(c *gin.Context) {
 		return
 	}
 
-	// 密码 + 盐重新计算
-	m := models.User{}
-	m.Salt = utils.RandNLengthString(8)
-	psw, err := utils.AesEncrypt([]byte(fmt.Sprintf("%s%s", string(pswBytes), m.Salt)))
+	// 用户名是从token中获取的，不能使用用户前端传递过来的用户名
+	err = service.PasswordService().Set(params.UserName, string(pswBytes), false, true)
 	if err != nil {
 		amis.WriteJsonError(c, err)
 		return
 	}
-
-	m.Password = base64.StdEncoding.EncodeToString(psw)
-	m.Username = params.UserName // 用户名是从token中获取的，不能使用用户前端传递过来的用户名
-	params.UserName = ""         // 避免增加CreatedBy字段,因为查询用户集群权限，是管理员授权的，所以不需要CreatedBy字段
-
-	err = dao.DB().Select([]string{"password", "salt"}).Where("username=?", m.Username).Updates(m).Error
-
-	if err != nil {
-		amis.WriteJsonError(c, err)
-		return
+	// 修改密码后注销其他会话，保留当前会话
+	if _, err := service.SessionService().RevokeAll(params.UserName, "用户修改了密码", c.GetString(constants.JwtSessionID)); err != nil {
+		klog.Errorf("注销用户[%s]其他会话失败: %v", params.UserName, err)
 	}
 	amis.WriteJsonOK(c)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
 	"github.com/weibaohui/k8m/pkg/comm/utils/totp"
 	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
 )
 
 // Disable2FA 禁用2FA
@@ -26,6 +27,11 @@ func (uc *Controller) Disable2FA(c *gin.Context) {
 	user.Username = params.UserName
 	params.UserName = "" // 避免增加CreatedBy字段,因为查询用户集群权限，是管理员授权的，所以不需要CreatedBy字段
 
+	if err := service.MFAService().CheckRemovable(user.Username, service.MFAMethodTOTP); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+
 	// 清除2FA相关信息
 	user.TwoFAEnabled = false
 	user.TwoFASecret = ""
@@ -169,5 +175,5 @@ func (uc *Controller) Enable2FA(c *gin.Context) {
 		return
 	}
 
-	amis.WriteJsonOK(c)
+	finishEnroll(c)
 }
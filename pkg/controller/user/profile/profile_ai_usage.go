@@ -0,0 +1,34 @@
+package profile
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// AIUsage 获取当前用户的AI用量及配额
+// @Summary 获取个人AI用量
+// @Description 获取当前登录用户今日、本月的Token用量及生效的配额
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /mgm/user/profile/ai/usage [get]
+func (uc *Controller) AIUsage(c *gin.Context) {
+	params := dao.BuildParams(c)
+
+	daily, monthly, err := service.AIUsageService().UserTokenSummary(params.UserName)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	quotas, err := service.AIUsageService().QuotaStatus(params.UserName)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, gin.H{
+		"daily_tokens":   daily,
+		"monthly_tokens": monthly,
+		"quotas":         quotas,
+	})
+}
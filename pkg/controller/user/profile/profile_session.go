@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// sessionItem 会话列表项，标记是否为当前会话
+type sessionItem struct {
+	ID         uint   `json:"id"`
+	LoginType  string `json:"login_type"`
+	ClientIP   string `json:"client_ip"`
+	UserAgent  string `json:"user_agent"`
+	LastSeenAt string `json:"last_seen_at"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"`
+}
+
+// ListSessions 获取当前用户的登录会话
+// @Summary 获取登录会话列表
+// @Description 获取当前用户所有有效的登录会话，包括登录方式、设备及来源IP
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /mgm/user/profile/sessions/list [get]
+func (uc *Controller) ListSessions(c *gin.Context) {
+	username := amis.GetLoginUser(c)
+	sessions, err := service.SessionService().ListActive(username)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	current := c.GetString(constants.JwtSessionID)
+	items := make([]*sessionItem, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, &sessionItem{
+			ID:         s.ID,
+			LoginType:  s.LoginType,
+			ClientIP:   s.ClientIP,
+			UserAgent:  s.UserAgent,
+			LastSeenAt: s.LastSeenAt.Format("2006-01-02 15:04:05"),
+			CreatedAt:  s.CreatedAt.Format("2006-01-02 15:04:05"),
+			ExpiresAt:  s.ExpiresAt.Format("2006-01-02 15:04:05"),
+			Current:    s.SessionID == current,
+		})
+	}
+	amis.WriteJsonData(c, items)
+}
+
+// RevokeSession 注销当前用户的指定会话
+// @Summary 注销登录会话
+// @Description 注销指定会话，该会话的Token立即失效
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} string "操作成功"
+// @Router /mgm/user/profile/sessions/revoke/{id} [post]
+func (uc *Controller) RevokeSession(c *gin.Context) {
+	username := amis.GetLoginUser(c)
+	err := service.SessionService().Revoke(utils.ToUInt(c.Param("id")), username, "用户注销")
+	amis.WriteJsonErrorOrOK(c, err)
+}
+
+// RevokeOtherSessions 注销当前用户除当前会话外的所有会话
+// @Summary 注销其他登录会话
+// @Description 注销除当前会话外的所有会话，用于设备丢失等场景
+// @Security BearerAuth
+// @Success 200 {object} string "操作成功"
+// @Router /mgm/user/profile/sessions/revoke_others [post]
+func (uc *Controller) RevokeOtherSessions(c *gin.Context) {
+	username := amis.GetLoginUser(c)
+	_, err := service.SessionService().RevokeAll(username, "用户注销其他会话", c.GetString(constants.JwtSessionID))
+	amis.WriteJsonErrorOrOK(c, err)
+}
@@ -0,0 +1,101 @@
+package profile
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// MFAStatus 获取当前用户的2步验证状态
+// @Summary 获取2步验证状态
+// @Description 获取已绑定的验证方式、策略是否要求绑定及宽限截止时间
+// @Security BearerAuth
+// @Success 200 {object} service.MFAStatus
+// @Router /mgm/user/profile/mfa/status [get]
+func (uc *Controller) MFAStatus(c *gin.Context) {
+	user := &models.User{}
+	if err := dao.DB().Where("username = ?", amis.GetLoginUser(c)).First(user).Error; err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	status, err := service.MFAService().Status(user)
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, status)
+}
+
+// ListWebAuthn 获取当前用户绑定的通行密钥
+// @Summary 获取通行密钥列表
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /mgm/user/profile/webauthn/list [get]
+func (uc *Controller) ListWebAuthn(c *gin.Context) {
+	items, err := service.MFAService().ListWebAuthn(amis.GetLoginUser(c))
+	amis.WriteJsonListWithError(c, items, err)
+}
+
+// BeginWebAuthnRegister 开始绑定通行密钥
+// @Summary 开始绑定通行密钥
+// @Description 返回浏览器 navigator.credentials.create 所需的参数
+// @Security BearerAuth
+// @Success 200 {object} string
+// @Router /mgm/user/profile/webauthn/register/begin [post]
+func (uc *Controller) BeginWebAuthnRegister(c *gin.Context) {
+	options, err := service.MFAService().BeginWebAuthnRegistration(c, amis.GetLoginUser(c))
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonData(c, options)
+}
+
+// FinishWebAuthnRegister 完成绑定通行密钥
+// @Summary 完成绑定通行密钥
+// @Description 请求体为浏览器返回的凭据
+// @Security BearerAuth
+// @Param name query string false "通行密钥名称"
+// @Success 200 {object} string "操作成功"
+// @Router /mgm/user/profile/webauthn/register/finish [post]
+func (uc *Controller) FinishWebAuthnRegister(c *gin.Context) {
+	if err := service.MFAService().FinishWebAuthnRegistration(c, amis.GetLoginUser(c), c.Query("name")); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	finishEnroll(c)
+}
+
+// DeleteWebAuthn 删除当前用户绑定的通行密钥
+// @Summary 删除通行密钥
+// @Security BearerAuth
+// @Param id path int true "通行密钥ID"
+// @Success 200 {object} string "操作成功"
+// @Router /mgm/user/profile/webauthn/delete/{id} [post]
+func (uc *Controller) DeleteWebAuthn(c *gin.Context) {
+	username := amis.GetLoginUser(c)
+	if err := service.MFAService().CheckRemovable(username, service.MFAMethodWebAuthn); err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	err := service.MFAService().DeleteWebAuthn(username, utils.ToUInt(c.Param("id")))
+	amis.WriteJsonErrorOrOK(c, err)
+}
+
+// finishEnroll 仅限绑定2步验证的会话在绑定完成后注销，要求用户重新登录
+func finishEnroll(c *gin.Context) {
+	if !c.GetBool(constants.JwtMFAEnroll) {
+		amis.WriteJsonOK(c)
+		return
+	}
+	_, err := service.SessionService().RevokeAll(amis.GetLoginUser(c), "已绑定2步验证，需重新登录", "")
+	if err != nil {
+		amis.WriteJsonError(c, err)
+		return
+	}
+	amis.WriteJsonOKMsg(c, "绑定成功，请重新登录")
+}
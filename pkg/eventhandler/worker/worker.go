@@ -10,7 +10,9 @@ import (
 	"time"
 
 	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
 	"github.com/weibaohui/k8m/pkg/eventhandler/config"
 	"github.com/weibaohui/k8m/pkg/models"
 	"github.com/weibaohui/k8m/pkg/service"
@@ -137,13 +139,10 @@ func (w *EventWorker) processBatch() error {
 
 	// 遍历每一条事件配置规则
 	for _, ec := range w.cfg.EventConfigs {
-		// 解析当前规则的集群列表与 webhook 列表
+		// 解析当前规则的集群列表与 webhook 列表，标签选择器按当前集群标签匹配
 		clusters := make(map[string]struct{})
-		for _, c := range strings.Split(ec.Clusters, ",") {
-			cc := strings.TrimSpace(c)
-			if cc != "" {
-				clusters[cc] = struct{}{}
-			}
+		for _, cc := range service.ClusterTagService().ResolveClusters(ec.Clusters, ec.ClusterSelector) {
+			clusters[cc] = struct{}{}
 		}
 		var webhookIDs []string
 		for _, wid := range strings.Split(ec.Webhooks, ",") {
@@ -292,7 +291,7 @@ func (w *EventWorker) pushWebhookBatchForIDs(cluster string, webhookIDs []string
         `
 			prompt = fmt.Sprintf(prompt, customTemplate, resultRaw)
 
-			aiSummary, err := service.ChatService().ChatWithCtxNoHistory(w.ctx, prompt)
+			aiSummary, err := service.ChatService().ChatWithCtxNoHistory(ai.WithCluster(w.ctx, cluster), constants.AIFeatureEvent, prompt)
 			if err != nil {
 				klog.Errorf("AI总结失败，回退到字符串拼接: %v", err)
 				summary = summary + "【AI总结失败】"
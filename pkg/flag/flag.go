@@ -18,6 +18,9 @@ import (
 var config *Config
 var once sync.Once
 
+// DefaultJwtTokenSecret 默认的 JWT Secret，公开可知，生产环境必须修改
+const DefaultJwtTokenSecret = "your-secret-key"
+
 type Config struct {
 	Port                 int     // gin 监听端口
 	Host                 string  // gin 监听地址
@@ -92,16 +95,41 @@ type Config struct {
 	HelmUpdateCron      string // Helm更新定时执行 cron 表达式
 
 	// 集群管理参数
-	HeartbeatIntervalSeconds    int // 心跳间隔时间（秒）
-	HeartbeatFailureThreshold   int // 心跳失败阈值
-	ReconnectMaxIntervalSeconds int // 重连最大间隔时间（秒）
-	MaxRetryAttempts            int // 最大重试次数，默认100次
+	HeartbeatIntervalSeconds    int    // 心跳间隔时间（秒）
+	HeartbeatFailureThreshold   int    // 心跳失败阈值
+	ReconnectMaxIntervalSeconds int    // 重连最大间隔时间（秒）
+	MaxRetryAttempts            int    // 最大重试次数，默认100次
+	ExecPluginAllowlist         string // 允许 kubeconfig 使用的 exec 凭据插件，逗号或换行分隔，为空时禁止使用
 
 	// Lease 同步参数
 	LeaseNamespace            string // Lease 所在命名空间，默认自动检测
 	LeaseDurationSeconds      int    // Lease 有效时长（秒），默认60
 	LeaseRenewIntervalSeconds int    // Lease 续约间隔（秒），默认20
 	HostClusterID             string // 宿主集群ID
+
+	// 集群自动发现参数，从宿主集群中的 Secret 发现集群
+	ArgoCDDiscovery bool   // 从 Argo CD 集群 Secret 自动发现集群
+	ArgoCDNamespace string // Argo CD 集群 Secret 所在命名空间，默认argocd
+	ArgoCDSelector  string // 额外的标签选择器，为空时发现全部集群
+	CAPIDiscovery   bool   // 从 Cluster API 的 <集群名>-kubeconfig Secret 自动发现集群
+	CAPINamespace   string // Cluster API 集群所在命名空间，默认default，* 表示全部命名空间
+	CAPISelector    string // 额外的标签选择器，为空时发现全部集群
+
+	// 数据加密参数
+	KMSProvider     string // KMS 类型，默认 local 使用本地主密钥
+	MasterKey       string `json:"-"` // 主密钥，格式为 <keyID>:<base64密钥>，多个用逗号分隔，第一个为当前密钥
+	MasterKeyFile   string // 主密钥文件路径，未设置 MASTER_KEY 时使用，不存在时自动生成
+	RotateMasterKey bool   // 生成新的主密钥并重新加密数据库中的数据密钥，执行后退出
+
+	// 登录会话参数
+	AccessTokenTTL  int    // 访问 Token 有效期（分钟），默认15
+	RefreshTokenTTL int    // 刷新 Token 有效期（小时），默认168，即7天
+	TrustedProxies  string // 可信的反向代理地址或网段，逗号分隔，仅信任其传递的 X-Forwarded-For，为空时使用连接的来源地址
+
+	// JWT 签名参数
+	JwtSigningAlg       string // JWT 签名算法，HS256（使用JwtTokenSecret）、RS256、ES256
+	JwtHMACAcceptBefore string // 启用 RS256/ES256 后，在该时间之前仍接受 HS256 签名的历史 Token，为空时立即拒绝
+	RotateJwtKey        bool   // 生成新的 JWT 签名密钥并设为当前密钥，执行后退出
 }
 
 func Init() *Config {
@@ -170,7 +198,7 @@ func (c *Config) InitFlags() {
 	defaultInCluster := getEnvAsBool("IN_CLUSTER", true)
 
 	// jwt token secret
-	defaultJwtTokenSecret := getEnv("JWT_TOKEN_SECRET", "your-secret-key")
+	defaultJwtTokenSecret := getEnv("JWT_TOKEN_SECRET", DefaultJwtTokenSecret)
 
 	// nodeShell 镜像
 	defaultNodeShellImage := getEnv("NODE_SHELL_IMAGE", "alpine:latest")
@@ -311,6 +339,7 @@ func (c *Config) InitFlags() {
 	pflag.IntVar(&c.HeartbeatFailureThreshold, "heartbeat-failure-threshold", getEnvAsInt("HEARTBEAT_FAILURE_THRESHOLD", 3), "心跳失败阈值，默认3次")
 	pflag.IntVar(&c.ReconnectMaxIntervalSeconds, "reconnect-max-interval", getEnvAsInt("RECONNECT_MAX_INTERVAL", 3600), "重连最大间隔时间（秒），默认3600秒")
 	pflag.IntVar(&c.MaxRetryAttempts, "max-retry-attempts", getEnvAsInt("MAX_RETRY_ATTEMPTS", 100), "最大重试次数，默认100次")
+	pflag.StringVar(&c.ExecPluginAllowlist, "exec-plugin-allowlist", getEnv("EXEC_PLUGIN_ALLOWLIST", ""), "允许 kubeconfig 使用的 exec 凭据插件，命令名或绝对路径，逗号分隔，如 kubelogin,aws。为空时禁止使用 exec 插件")
 
 	// Lease 同步参数
 	pflag.StringVar(&c.HostClusterID, "host-cluster-id", getEnv("HOST_CLUSTER_ID", ""), "为空会使用InCluster模式，否则会使用HostClusterID指定的集群。集群ID从k8m界面-多集群管理中复制")
@@ -318,6 +347,30 @@ func (c *Config) InitFlags() {
 	pflag.IntVar(&c.LeaseDurationSeconds, "lease-duration-seconds", getEnvAsInt("LEASE_DURATION_SECONDS", 60), "Lease 有效时长（秒），默认60")
 	pflag.IntVar(&c.LeaseRenewIntervalSeconds, "lease-renew-interval-seconds", getEnvAsInt("LEASE_RENEW_INTERVAL_SECONDS", 20), "Lease 续约间隔（秒），默认20")
 
+	// 集群自动发现参数
+	pflag.BoolVar(&c.ArgoCDDiscovery, "argocd-discovery", getEnvAsBool("ARGOCD_DISCOVERY", false), "是否从宿主集群中的 Argo CD 集群 Secret 自动发现集群，默认关闭")
+	pflag.StringVar(&c.ArgoCDNamespace, "argocd-namespace", getEnv("ARGOCD_NAMESPACE", "argocd"), "Argo CD 集群 Secret 所在命名空间，默认argocd")
+	pflag.StringVar(&c.ArgoCDSelector, "argocd-selector", getEnv("ARGOCD_SELECTOR", ""), "Argo CD 集群 Secret 额外的标签选择器，如 k8m.io/discover=true，为空时发现全部集群")
+	pflag.BoolVar(&c.CAPIDiscovery, "capi-discovery", getEnvAsBool("CAPI_DISCOVERY", false), "是否从宿主集群中的 Cluster API kubeconfig Secret 自动发现集群，默认关闭")
+	pflag.StringVar(&c.CAPINamespace, "capi-namespace", getEnv("CAPI_NAMESPACE", "default"), "Cluster API 集群所在命名空间，默认default，设置为 * 时发现全部命名空间")
+	pflag.StringVar(&c.CAPISelector, "capi-selector", getEnv("CAPI_SELECTOR", ""), "Cluster API kubeconfig Secret 额外的标签选择器，为空时发现全部集群")
+
+	// 数据加密参数，主密钥仅支持环境变量，避免出现在进程参数中
+	c.MasterKey = getEnv("MASTER_KEY", "")
+	pflag.StringVar(&c.KMSProvider, "kms-provider", getEnv("KMS_PROVIDER", "local"), "KMS类型，默认local使用本地主密钥加密数据库中的敏感数据")
+	pflag.StringVar(&c.MasterKeyFile, "master-key-file", getEnv("MASTER_KEY_FILE", "./data/master.key"), "主密钥文件路径，未设置环境变量MASTER_KEY时使用，不存在时自动生成，默认./data/master.key")
+	pflag.BoolVar(&c.RotateMasterKey, "rotate-master-key", false, "生成新的主密钥并重新加密数据库中的数据密钥，执行完成后退出")
+
+	// 登录会话参数
+	pflag.IntVar(&c.AccessTokenTTL, "access-token-ttl", getEnvAsInt("ACCESS_TOKEN_TTL", 15), "访问Token有效期（分钟），过期后使用刷新Token换取，默认15分钟")
+	pflag.IntVar(&c.RefreshTokenTTL, "refresh-token-ttl", getEnvAsInt("REFRESH_TOKEN_TTL", 168), "刷新Token有效期（小时），超过后需重新登录，默认168小时")
+	pflag.StringVar(&c.TrustedProxies, "trusted-proxies", getEnv("TRUSTED_PROXIES", ""), "可信的反向代理地址或网段，逗号分隔，如 10.0.0.0/8。仅信任这些代理传递的X-Forwarded-For作为来源IP，为空时使用连接的来源地址")
+
+	// JWT 签名参数
+	pflag.StringVar(&c.JwtSigningAlg, "jwt-signing-alg", getEnv("JWT_SIGNING_ALG", "HS256"), "JWT签名算法，HS256使用jwt-token-secret签名；RS256、ES256使用自动生成的密钥对签名，并通过/.well-known/jwks.json公开公钥")
+	pflag.StringVar(&c.JwtHMACAcceptBefore, "jwt-hmac-accept-before", getEnv("JWT_HMAC_ACCEPT_BEFORE", ""), "启用RS256/ES256后，在该时间之前仍接受jwt-token-secret签名的历史Token及API密钥，格式为2006-01-02或RFC3339，为空时立即拒绝")
+	pflag.BoolVar(&c.RotateJwtKey, "rotate-jwt-key", false, "生成新的JWT签名密钥并设为当前密钥，历史密钥签发的Token仍可验证，执行完成后退出")
+
 	// 其他配置-打印配置信息
 	pflag.BoolVar(&c.PrintConfig, "print-config", defaultPrintConfig, "是否打印配置信息，默认关闭")
 
@@ -0,0 +1,105 @@
+package knowledge
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Tokenize 分词：英文、数字按单词切分并转为小写，中文等其他文字按相邻两字切分
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch len(cjk) {
+		case 0:
+		case 1:
+			tokens = append(tokens, string(cjk))
+		default:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			flushCJK()
+			word = append(word, r)
+		case unicode.IsLetter(r):
+			flushWord()
+			cjk = append(cjk, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// BM25 基于 BM25 算法的关键词检索，在未配置向量模型时使用
+type BM25 struct {
+	docs   []map[string]int
+	lens   []int
+	avgLen float64
+	df     map[string]int
+}
+
+// NewBM25 使用已分词的文档构建索引
+func NewBM25(docs [][]string) *BM25 {
+	b := &BM25{df: map[string]int{}}
+	total := 0
+	for _, tokens := range docs {
+		tf := map[string]int{}
+		for _, t := range tokens {
+			tf[t]++
+		}
+		for t := range tf {
+			b.df[t]++
+		}
+		b.docs = append(b.docs, tf)
+		b.lens = append(b.lens, len(tokens))
+		total += len(tokens)
+	}
+	if len(docs) > 0 {
+		b.avgLen = float64(total) / float64(len(docs))
+	}
+	return b
+}
+
+// Score 计算查询与第 i 个文档的相关度
+func (b *BM25) Score(query []string, i int) float64 {
+	n := float64(len(b.docs))
+	var score float64
+	for _, t := range query {
+		tf := float64(b.docs[i][t])
+		if tf == 0 {
+			continue
+		}
+		df := float64(b.df[t])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := 1 - bm25B
+		if b.avgLen > 0 {
+			norm += bm25B * float64(b.lens[i]) / b.avgLen
+		}
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+	return score
+}
@@ -0,0 +1,95 @@
+package knowledge
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChunkSize 每个分片的最大字符数
+	DefaultChunkSize = 800
+	// DefaultChunkOverlap 相邻分片重叠的字符数，避免语义在边界处被截断
+	DefaultChunkOverlap = 100
+)
+
+// Split 将文档按 Markdown 标题与段落切分为分片
+// 每个分片带上所属的标题路径，便于检索时理解上下文
+func Split(content string, size, overlap int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	var headings []string
+	var buf strings.Builder
+
+	flush := func() {
+		text := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if text == "" {
+			return
+		}
+		prefix := ""
+		if len(headings) > 0 {
+			prefix = strings.Join(headings, " > ") + "\n"
+		}
+		for _, part := range splitBySize(text, size-utf8.RuneCountInString(prefix), overlap) {
+			chunks = append(chunks, prefix+part)
+		}
+	}
+
+	inCode := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		if !inCode && strings.HasPrefix(trimmed, "#") {
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			title := strings.TrimSpace(trimmed[level:])
+			if level <= 6 && title != "" {
+				flush()
+				if level <= len(headings) {
+					headings = headings[:level-1]
+				}
+				headings = append(headings, title)
+				continue
+			}
+		}
+		// 超出大小时在段落边界处切分
+		if !inCode && trimmed == "" && utf8.RuneCountInString(buf.String()) >= size/2 {
+			flush()
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	flush()
+	return chunks
+}
+
+// splitBySize 按字符数切分文本，相邻分片保留 overlap 个字符的重叠
+func splitBySize(text string, size, overlap int) []string {
+	if size < DefaultChunkOverlap {
+		size = DefaultChunkOverlap
+	}
+	if overlap >= size {
+		overlap = 0
+	}
+	runes := []rune(text)
+	if len(runes) <= size {
+		return []string{text}
+	}
+	var parts []string
+	for start := 0; start < len(runes); start += size - overlap {
+		end := min(start+size, len(runes))
+		parts = append(parts, strings.TrimSpace(string(runes[start:end])))
+		if end == len(runes) {
+			break
+		}
+	}
+	return parts
+}
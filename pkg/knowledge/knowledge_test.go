@@ -0,0 +1,80 @@
+package knowledge
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	content := "# 安装\n\n执行 helm install 安装。\n\n# 故障排查\n\nPod 处于 CrashLoopBackOff 时，先查看日志。\n" + strings.Repeat("重启后仍然失败需要检查探针配置。", 50)
+	chunks := Split(content, 200, 20)
+	if len(chunks) < 3 {
+		t.Fatalf("分片数量过少: %d", len(chunks))
+	}
+	if !strings.HasPrefix(chunks[0], "安装\n") {
+		t.Errorf("第一个分片应带有标题路径: %q", chunks[0])
+	}
+	for i, c := range chunks {
+		if utf8.RuneCountInString(c) > 200+50 {
+			t.Errorf("分片 %d 超出长度限制: %d", i, utf8.RuneCountInString(c))
+		}
+	}
+	if got := Split("  ", 200, 20); len(got) != 0 {
+		t.Errorf("空内容不应产生分片: %v", got)
+	}
+}
+
+func TestBM25(t *testing.T) {
+	docs := []string{
+		"Deployment 滚动更新策略 maxSurge maxUnavailable",
+		"Pod 处于 CrashLoopBackOff 时查看容器日志与探针",
+		"Service 通过 selector 选择后端 Pod",
+	}
+	var tokens [][]string
+	for _, d := range docs {
+		tokens = append(tokens, Tokenize(d))
+	}
+	bm := NewBM25(tokens)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"crashloopbackoff 怎么处理", 1},
+		{"滚动更新", 0},
+		{"selector 选择", 2},
+	}
+	for _, tt := range tests {
+		best, bestScore := -1, 0.0
+		q := Tokenize(tt.query)
+		for i := range docs {
+			if s := bm.Score(q, i); s > bestScore {
+				best, bestScore = i, s
+			}
+		}
+		if best != tt.want {
+			t.Errorf("查询 %q 期望命中 %d，实际 %d", tt.query, tt.want, best)
+		}
+	}
+}
+
+func TestVector(t *testing.T) {
+	v := []float32{0.1, -0.5, 2}
+	decoded := DecodeVector(EncodeVector(v))
+	if len(decoded) != len(v) {
+		t.Fatalf("解码长度错误: %v", decoded)
+	}
+	for i := range v {
+		if decoded[i] != v[i] {
+			t.Errorf("解码结果错误: %v", decoded)
+		}
+	}
+	if s := Cosine(v, v); math.Abs(s-1) > 1e-6 {
+		t.Errorf("相同向量相似度应为 1: %f", s)
+	}
+	if s := Cosine([]float32{1, 0}, []float32{0, 1}); math.Abs(s) > 1e-6 {
+		t.Errorf("正交向量相似度应为 0: %f", s)
+	}
+}
@@ -0,0 +1,44 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Cosine 计算两个向量的余弦相似度，维度不一致时返回 0
+func Cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// EncodeVector 将向量编码为 JSON 以便存储到数据库
+func EncodeVector(v []float32) string {
+	if len(v) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// DecodeVector 解析数据库中存储的向量
+func DecodeVector(s string) []float32 {
+	if s == "" {
+		return nil
+	}
+	var v []float32
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil
+	}
+	return v
+}
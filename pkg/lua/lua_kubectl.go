@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/service"
 	"github.com/weibaohui/kom/kom"
 	lua "github.com/yuin/gopher-lua"
 	v1 "k8s.io/api/core/v1"
@@ -111,7 +112,7 @@ func withCacheFunc(L *lua.LState) int {
 	timeSeconds := L.CheckNumber(2)
 	if timeSeconds > 0 {
 		dur := time.Duration(int64(timeSeconds)) * time.Second
-		obj.k = obj.k.WithCache(dur)
+		obj.k = obj.k.WithCache(service.ClusterService().CacheTTL(obj.k.ID, dur))
 	}
 	L.Push(ud)
 	L.Push(lua.LNil)
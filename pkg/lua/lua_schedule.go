@@ -11,6 +11,7 @@ import (
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/constants"
 	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/service"
 	"github.com/weibaohui/kom/kom"
 	"gorm.io/gorm"
 	"k8s.io/klog/v2"
@@ -331,13 +332,15 @@ func (s *ScheduleBackground) Add(scheduleID uint) {
 		// 这样确保每个定时任务都有自己独立的数据副本，不会被后续的Add调用影响
 		scheduleIDCopy := item.ID
 		clustersCopy := item.Clusters
+		selectorCopy := item.ClusterSelector
 		cronExpr := item.Cron
 
 		// 添加定时任务到TaskManager，而不是立即执行
 		addErr := localTaskManager.Add(fmt.Sprintf("%d", scheduleIDCopy), cronExpr, func(ctx context.Context) {
 			klog.V(6).Infof("定时巡检任务 [%s] 开始执行", item.Name)
 			// 执行巡检任务：逐项检查取消信号，并清洗 cluster 列表
-			clusters := strings.Split(clustersCopy, ",")
+			// 标签选择器在每次执行时匹配，新纳管的集群打上标签后自动纳入巡检
+			clusters := service.ClusterTagService().ResolveClusters(clustersCopy, selectorCopy)
 			for _, cluster := range clusters {
 				select {
 				case <-ctx.Done():
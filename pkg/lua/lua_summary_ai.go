@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/constants"
 	"github.com/weibaohui/k8m/pkg/models"
@@ -203,7 +204,7 @@ func (s *ScheduleBackground) generateAISummary(ctx context.Context, msg *Summary
 		`
 	prompt = fmt.Sprintf(prompt, customTemplate, utils.ToJSONCompact(msg))
 
-	summary, err := service.ChatService().ChatWithCtxNoHistory(ctx, prompt)
+	summary, err := service.ChatService().ChatWithCtxNoHistory(ai.WithCluster(ctx, msg.Cluster), constants.AIFeatureInspection, prompt)
 	if err != nil {
 		return "", fmt.Errorf("AI汇总请求失败: %v", err)
 	}
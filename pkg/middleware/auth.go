@@ -26,6 +26,9 @@ func AuthMiddleware() gin.HandlerFunc {
 			strings.HasPrefix(path, "/debug/") ||
 			strings.HasPrefix(path, "/mcp/") ||
 			strings.HasPrefix(path, "/auth/") ||
+			strings.HasPrefix(path, "/agent/") ||
+			strings.HasPrefix(path, "/.well-known/") ||
+			strings.HasPrefix(path, "/scim/") ||
 			strings.HasPrefix(path, "/assets/") ||
 			strings.HasPrefix(path, "/public/") {
 			c.Next()
@@ -41,6 +44,66 @@ func AuthMiddleware() gin.HandlerFunc {
 
 			return
 		}
+		if service.ApiKeyService().IsTokenRevoked(utils.GetJWTToken(c)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Token 已吊销"})
+			c.Abort()
+			return
+		}
+
+		// 被禁用的用户立即拒绝，包括其已签发的登录 Token 及API密钥
+		username, _ := claims[constants.JwtUserName].(string)
+		if service.UserService().IsUserDisabled(username) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户已被禁用"})
+			c.Abort()
+			return
+		}
+
+		// 登录 Token 需校验会话是否已注销。全局与路由组均注册了本中间件，只校验一次
+		if sessionID, ok := claims[constants.JwtSessionID].(string); ok && sessionID != "" {
+			if _, checked := c.Get(constants.JwtSessionID); !checked {
+				if err := service.SessionService().Validate(sessionID, username); err != nil {
+					c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+					c.Abort()
+					return
+				}
+				service.SessionService().Touch(sessionID, c.ClientIP())
+				c.Set(constants.JwtSessionID, sessionID)
+			}
+		}
+
+		// 未满足2步验证策略的会话仅允许绑定2步验证
+		if enroll, _ := claims[constants.JwtMFAEnroll].(bool); enroll {
+			if !isMFAEnrollPath(path) {
+				c.JSON(http.StatusForbidden, gin.H{"message": "请先绑定2步验证", "mfa_enroll": true})
+				c.Abort()
+				return
+			}
+			c.Set(constants.JwtMFAEnroll, true)
+		}
+
+		// API密钥需校验有效期及作用范围
+		if keyID, ok := claims[constants.JwtApiKeyID].(float64); ok {
+			if _, checked := c.Get(constants.JwtApiKeyID); !checked {
+				if err := service.ApiKeyService().Authorize(c, uint(keyID), username); err != nil {
+					c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+					c.Abort()
+					return
+				}
+				c.Set(constants.JwtApiKeyID, uint(keyID))
+			}
+		}
+
+		// 用户下载的 kubeconfig 仅可访问 Kubernetes API 代理，需校验是否已吊销
+		if kubeconfigID, ok := claims[constants.JwtKubeconfigID].(float64); ok {
+			if _, checked := c.Get(constants.JwtKubeconfigID); !checked {
+				if err := service.UserKubeconfigService().Authorize(c, uint(kubeconfigID), username); err != nil {
+					c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+					c.Abort()
+					return
+				}
+				c.Set(constants.JwtKubeconfigID, uint(kubeconfigID))
+			}
+		}
 
 		// 设置信息传递，后面才能从ctx中获取到用户信息
 		c.Set(constants.JwtUserName, claims[constants.JwtUserName])
@@ -83,3 +146,11 @@ func PlatformAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// isMFAEnrollPath 受限会话允许访问的接口：查看个人信息及绑定2步验证
+func isMFAEnrollPath(path string) bool {
+	return path == "/mgm/user/profile" ||
+		strings.HasPrefix(path, "/mgm/user/profile/2fa/") ||
+		strings.HasPrefix(path, "/mgm/user/profile/webauthn/") ||
+		strings.HasPrefix(path, "/mgm/user/profile/mfa/")
+}
@@ -45,11 +45,15 @@ func EnsureSelectedClusterMiddleware() gin.HandlerFunc {
 			strings.HasPrefix(path, "/debug/") ||
 			strings.HasPrefix(path, "/mcp/") ||
 			strings.HasPrefix(path, "/auth/") ||
+			strings.HasPrefix(path, "/agent/") || // 集群 Agent 使用 Agent Token 认证
+			strings.HasPrefix(path, "/.well-known/") ||
+			strings.HasPrefix(path, "/scim/") ||
 			strings.HasPrefix(path, "/assets/") ||
 			strings.HasPrefix(path, "/ai/") || // ai 聊天不带cluster
 			strings.HasPrefix(path, "/params/") || // 配置参数
 			strings.HasPrefix(path, "/mgm/") || // 个人中心
 			strings.HasPrefix(path, "/admin/") || // 管理后台
+			strings.HasPrefix(path, service.KubeProxyPathPrefix) || // Kubernetes API 代理自行校验集群权限
 			strings.HasPrefix(path, "/public/") {
 			c.Next()
 			return
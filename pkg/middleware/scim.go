@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/pkg/service"
+)
+
+// ScimAuthMiddleware SCIM 接口校验，仅接受专用的 SCIM Token，不接受用户登录 Token
+func ScimAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok {
+			if err := service.ScimService().Authenticate(strings.TrimSpace(token), c.ClientIP()); err == nil {
+				c.Next()
+				return
+			}
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="k8m-scim"`)
+		c.Header("Content-Type", "application/scim+json")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"schemas": []string{service.ScimSchemaError},
+			"status":  strconv.Itoa(http.StatusUnauthorized),
+			"detail":  "无效的SCIM Token",
+		})
+	}
+}
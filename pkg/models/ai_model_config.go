@@ -12,16 +12,22 @@ import (
 // 支持后续选择不同模型
 
 type AIModelConfig struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	ApiKey      string    `json:"api_key"`
-	ApiURL      string    `json:"api_url"`
-	ApiModel    string    `json:"api_model"`
-	Temperature float32   `json:"temperature"`
-	TopP        float32   `json:"top_p"`
-	Think       bool      `json:"think"` // 是否关闭思考模式
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"created_at,omitempty" gorm:"<-:create"`
-	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Provider        string    `gorm:"default:openai" json:"provider"` // 模型提供方：openai（OpenAI兼容）、azure、anthropic、ollama
+	ApiKey          string    `json:"api_key"`
+	ApiURL          string    `json:"api_url"`
+	ApiModel        string    `json:"api_model"`
+	ApiVersion      string    `json:"api_version,omitempty"` // Azure OpenAI api-version
+	Deployment      string    `json:"deployment,omitempty"`  // Azure OpenAI 部署名称，为空时使用模型名称
+	Temperature     float32   `json:"temperature"`
+	TopP            float32   `json:"top_p"`
+	MaxTokens       int       `json:"max_tokens"`        // 最大输出Token数，Anthropic 必填，为空时使用默认值
+	Timeout         int       `json:"timeout"`           // 请求超时时间（秒），0 表示不限制，超时后切换到备用模型
+	FallbackModelID uint      `json:"fallback_model_id"` // 备用模型ID，主模型超时或出错时使用
+	Think           bool      `json:"think"`             // 是否关闭思考模式
+	Description     string    `json:"description,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
 }
 
 func (c *AIModelConfig) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*AIModelConfig, int64, error) {
@@ -39,3 +45,18 @@ func (c *AIModelConfig) Delete(params *dao.Params, ids string, queryFuncs ...fun
 func (c *AIModelConfig) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*AIModelConfig, error) {
 	return dao.GenericGetOne(params, c, queryFuncs...)
 }
+
+// BeforeSave 在保存前加密敏感字段
+func (c *AIModelConfig) BeforeSave(tx *gorm.DB) error {
+	return encryptFields(&c.ApiKey)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (c *AIModelConfig) AfterSave(tx *gorm.DB) error {
+	return decryptFields(&c.ApiKey)
+}
+
+// AfterFind 在查询后解密敏感字段
+func (c *AIModelConfig) AfterFind(tx *gorm.DB) error {
+	return decryptFields(&c.ApiKey)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// AIUsageLog AI 调用用量记录，每次大模型请求一条
+type AIUsageLog struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username         string    `gorm:"index" json:"username,omitempty"`
+	GroupNames       string    `json:"group_names,omitempty"` // 请求时用户所在的用户组，逗号分隔
+	Cluster          string    `gorm:"index" json:"cluster,omitempty"`
+	Feature          string    `gorm:"index" json:"feature,omitempty"` // 功能场景，如 chat、log、inspection、event、k8sgpt
+	Provider         string    `json:"provider,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Estimated        bool      `json:"estimated"` // 用量是否为估算值
+	Success          bool      `json:"success"`
+	Error            string    `gorm:"type:text" json:"error,omitempty"`
+	Downgraded       bool      `json:"downgraded"` // 是否因超出配额而降级
+	CreatedAt        time.Time `gorm:"index;<-:create" json:"created_at,omitempty"`
+}
+
+func (c *AIUsageLog) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*AIUsageLog, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *AIUsageLog) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+// AIQuota AI 用量配额
+// TargetType 为 user 时按单个用户的用量计算，TargetName 为 * 表示对所有用户生效的默认配额；
+// TargetType 为 group 时按用户组内所有成员的合计用量计算
+type AIQuota struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	TargetType       string    `gorm:"index" json:"target_type,omitempty"` // user、group
+	TargetName       string    `gorm:"index" json:"target_name,omitempty"` // 用户名或用户组名
+	DailyTokens      int64     `json:"daily_tokens"`                       // 每日Token上限，0 表示不限制
+	MonthlyTokens    int64     `json:"monthly_tokens"`                     // 每月Token上限，0 表示不限制
+	Action           string    `json:"action,omitempty"`                   // 超出后的处理方式：reject 拒绝、downgrade 降级
+	DowngradeModelID uint      `json:"downgrade_model_id"`                 // 降级使用的模型ID
+	Enabled          bool      `json:"enabled"`
+	Description      string    `json:"description,omitempty"`
+	CreatedBy        string    `json:"created_by,omitempty"`
+	CreatedAt        time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+}
+
+func (c *AIQuota) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*AIQuota, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *AIQuota) Save(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericSave(params, c, queryFuncs...)
+}
+
+func (c *AIQuota) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *AIQuota) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*AIQuota, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
+
+// AIRedactionLog 发送给大模型前的脱敏记录，仅记录类别、占位符及原值的首尾字符，不保存原文
+type AIRedactionLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username  string    `gorm:"index" json:"username,omitempty"`
+	Cluster   string    `gorm:"index" json:"cluster,omitempty"`
+	Feature   string    `gorm:"index" json:"feature,omitempty"`
+	Kinds     string    `json:"kinds,omitempty"` // 各类别数量，如 IP:2,EMAIL:1
+	Count     int       `json:"count"`
+	Items     string    `gorm:"type:text" json:"items,omitempty"` // 脱敏明细 JSON
+	CreatedAt time.Time `gorm:"index;<-:create" json:"created_at,omitempty"`
+}
+
+func (c *AIRedactionLog) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*AIRedactionLog, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *AIRedactionLog) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
@@ -10,14 +10,23 @@ import (
 
 // ApiKey 用户API密钥
 type ApiKey struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
-	Username    string    `gorm:"index;not null" json:"username,omitempty"` // 所属用户
-	Key         string    `gorm:"type:text" json:"key,omitempty"`           // API密钥值
-	Description string    `json:"description,omitempty"`                    // 描述信息
-	CreatedAt   time.Time `json:"created_at,omitempty" gorm:"<-:create"`
-	UpdatedAt   time.Time `json:"updated_at,omitempty"`   // Automatically managed by GORM for update time
-	CreatedBy   string    `json:"created_by,omitempty"`   // 创建者
-	LastUsedAt  time.Time `json:"last_used_at,omitempty"` // 最后使用时间
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username    string     `gorm:"index;not null" json:"username,omitempty"` // 所属用户
+	Key         string     `gorm:"type:text" json:"key,omitempty"`           // API密钥值
+	Description string     `json:"description,omitempty"`                    // 描述信息
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`                     // 过期时间
+	ReadOnly    bool       `json:"read_only"`                                // 只读，仅允许查询类请求
+	Clusters    string     `gorm:"type:text" json:"clusters,omitempty"`      // 允许访问的集群，逗号分隔，为空不限制
+	Namespaces  string     `gorm:"type:text" json:"namespaces,omitempty"`    // 允许访问的命名空间，逗号分隔，为空不限制
+	AllowedIPs  string     `gorm:"type:text" json:"allowed_ips,omitempty"`   // 来源IP白名单，支持CIDR，逗号分隔，为空不限制
+	Revoked     bool       `json:"revoked"`                                  // 是否已吊销
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`                     // 吊销时间
+	UsageCount  int64      `json:"usage_count"`                              // 累计使用次数
+	LastUsedIP  string     `json:"last_used_ip,omitempty"`                   // 最后使用的来源IP
+	CreatedAt   time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty"`   // Automatically managed by GORM for update time
+	CreatedBy   string     `json:"created_by,omitempty"`   // 创建者
+	LastUsedAt  time.Time  `json:"last_used_at,omitempty"` // 最后使用时间
 }
 
 func (c *ApiKey) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ApiKey, int64, error) {
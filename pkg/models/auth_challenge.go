@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// AuthChallenge 一次性认证请求，如 SAML 认证请求ID、登录时修改密码的临时Token，存储在数据库中，多实例部署时任一实例均可完成校验
+type AuthChallenge struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	ChallengeKey string    `gorm:"uniqueIndex;size:128;not null" json:"-"` // 用途:标识，如 saml:请求ID、password:Token摘要
+	Username     string    `gorm:"index" json:"username,omitempty"`        // 所属用户，发起时未知则为空
+	LoginType    string    `json:"login_type,omitempty"`                   // 完成后继续登录使用的登录方式
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at,omitempty"`      // 过期时间
+	CreatedAt    time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// ClusterAgent 集群 Agent，运行在被纳管集群内，主动连接 k8m 建立反向隧道
+type ClusterAgent struct {
+	ID                 uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Name               string     `gorm:"uniqueIndex;size:128;not null" json:"name,omitempty"` // 名称，集群ID为 Agent/名称
+	Description        string     `json:"description,omitempty"`                               // 描述信息
+	TokenHash          string     `gorm:"index;size:64" json:"-"`                              // Agent Token 摘要
+	TokenPrefix        string     `gorm:"size:16" json:"token_prefix,omitempty"`               // Token 前缀，便于识别
+	AgentVersion       string     `json:"agent_version,omitempty"`                             // Agent 版本
+	APIServer          string     `json:"api_server,omitempty"`                                // Agent 上报的 apiserver 地址
+	LastConnectedAt    *time.Time `json:"last_connected_at,omitempty"`                         // 最后连接时间
+	LastDisconnectedAt *time.Time `json:"last_disconnected_at,omitempty"`                      // 最后断开时间
+	LastIP             string     `json:"last_ip,omitempty"`                                   // 最后连接的来源IP
+	CreatedBy          string     `json:"created_by,omitempty"`                                // 创建人
+	Connected          bool       `gorm:"-" json:"connected"`                                  // 隧道是否在线
+	CreatedAt          time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt          time.Time  `json:"updated_at,omitempty"`
+}
+
+func (c *ClusterAgent) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ClusterAgent, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *ClusterAgent) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *ClusterAgent) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*ClusterAgent, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
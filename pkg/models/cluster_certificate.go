@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"gorm.io/gorm"
+)
+
+// ClusterCertificate 集群中扫描到的证书及其过期时间
+// Kind 为 client_cert kubeconfig 客户端证书、tls_secret TLS Secret、webhook_ca Webhook caBundle，
+// UsedBy 为引用该证书的 Ingress、Gateway、Webhook 等，逗号分隔
+type ClusterCertificate struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Cluster      string     `gorm:"index;size:255" json:"cluster,omitempty"` // 集群ID
+	Kind         string     `gorm:"size:32" json:"kind,omitempty"`
+	Namespace    string     `gorm:"size:255" json:"namespace,omitempty"`
+	Name         string     `gorm:"size:512" json:"name,omitempty"`
+	Subject      string     `gorm:"type:text" json:"subject,omitempty"`
+	Issuer       string     `gorm:"type:text" json:"issuer,omitempty"`
+	DNSNames     string     `gorm:"type:text" json:"dns_names,omitempty"`
+	NotBefore    *time.Time `json:"not_before,omitempty"`
+	NotAfter     *time.Time `gorm:"index" json:"not_after,omitempty"`
+	UsedBy       string     `gorm:"type:text" json:"used_by,omitempty"`
+	Err          string     `gorm:"type:text" json:"err,omitempty"` // 证书缺失或解析失败的原因
+	NotifiedDays int        `json:"notified_days,omitempty"`        // 已发送过期提醒的阈值天数，-1 表示已发送过期通知
+	ScannedAt    time.Time  `json:"scanned_at,omitempty"`
+	DaysLeft     *int       `gorm:"-" json:"days_left,omitempty"` // 剩余天数，已过期时为负数
+	CreatedAt    time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+}
+
+func (c *ClusterCertificate) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ClusterCertificate, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"gorm.io/gorm"
+)
+
+// ClusterHeartbeat 集群心跳结果，用于计算延迟分位数并在重启后恢复心跳历史
+type ClusterHeartbeat struct {
+	ID        uint                       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Cluster   string                     `gorm:"index:idx_cluster_heartbeat_time,priority:1;size:255" json:"cluster,omitempty"` // 集群ID
+	Success   bool                       `json:"success"`
+	LatencyMs int64                      `json:"latency_ms"`                        // 读取集群版本耗时（毫秒），失败时为 0
+	ErrType   constants.ClusterErrorType `gorm:"size:32" json:"err_type,omitempty"` // 失败时的错误类型
+	Err       string                     `gorm:"type:text" json:"err,omitempty"`
+	CreatedAt time.Time                  `gorm:"index:idx_cluster_heartbeat_time,priority:2" json:"created_at,omitempty"`
+}
+
+func (c *ClusterHeartbeat) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ClusterHeartbeat, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+// ClusterStatusEvent 集群连接状态变化及自动重连尝试
+// Reason 为 connect 连接、heartbeat 心跳连续失败、manual 手动断开、agent Agent 断开、reconnect 自动重连，
+// 其中 reconnect 每次尝试均记录，Attempt 为第几次尝试
+type ClusterStatusEvent struct {
+	ID        uint                           `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Cluster   string                         `gorm:"index:idx_cluster_status_time,priority:1;size:255" json:"cluster,omitempty"` // 集群ID
+	Status    constants.ClusterConnectStatus `gorm:"size:32" json:"status,omitempty"`
+	Reason    string                         `gorm:"size:32" json:"reason,omitempty"`
+	Attempt   int                            `json:"attempt,omitempty"`
+	ErrType   constants.ClusterErrorType     `gorm:"size:32" json:"err_type,omitempty"`
+	Err       string                         `gorm:"type:text" json:"err,omitempty"`
+	CreatedAt time.Time                      `gorm:"index:idx_cluster_status_time,priority:2" json:"created_at,omitempty"`
+}
+
+func (c *ClusterStatusEvent) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ClusterStatusEvent, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// ClusterTag 集群分组及标签，按集群ID保存，适用于所有来源的集群
+// 标签以 key=value 逗号分隔保存，如 env=prod,region=cn-east,team=payments
+type ClusterTag struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Cluster   string    `gorm:"uniqueIndex;size:255" json:"cluster,omitempty"` // 集群ID
+	Group     string    `gorm:"index;size:128" json:"group,omitempty"`         // 所属分组
+	Tags      string    `gorm:"type:text" json:"tags,omitempty"`               // 标签列表
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+func (c *ClusterTag) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ClusterTag, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *ClusterTag) Save(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericSave(params, c, queryFuncs...)
+}
+
+func (c *ClusterTag) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *ClusterTag) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*ClusterTag, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
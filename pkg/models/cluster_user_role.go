@@ -12,14 +12,17 @@ import (
 // ClusterUserRole 集群用户权限表
 // AuthorizationType有两种类型（user、user_group），如果是用户，那么代表这个人有哪些权限
 // 如果是Group，那么代表这个组有哪些权限，这个组可能会有多个用户，那么这多个用户都有相关的权限
+// ClusterSelector 不为空时按集群标签授权，Cluster 为空，匹配标签的集群（包括后续新增的集群）自动获得该权限
 type ClusterUserRole struct {
 	ID                  uint                               `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
-	Cluster             string                             `gorm:"index" json:"cluster,omitempty"`  // 集群名称
-	Username            string                             `gorm:"index" json:"username,omitempty"` // 用户名
-	Role                string                             `gorm:"index" json:"role,omitempty"`     // 角色类型：只读、读写、Exec
-	Namespaces          string                             `json:"namespaces,omitempty"`            // Namespaces列表，逗号分割 ，该用户可以访问的Ns
-	BlacklistNamespaces string                             `json:"blacklist_namespaces,omitempty"`  // 黑名单Namespaces列表，逗号分割，禁止访问的Ns
-	AuthorizationType   constants.ClusterAuthorizationType `json:"authorization_type,omitempty"`    // 用户类型。User\Group两种，默认为User，空为User。Group指用户组
+	Cluster             string                             `gorm:"index" json:"cluster,omitempty"`    // 集群名称
+	ClusterSelector     string                             `json:"cluster_selector,omitempty"`        // 集群标签选择器，如 env=prod,region in (cn-east,cn-north)
+	Username            string                             `gorm:"index" json:"username,omitempty"`   // 用户名
+	Role                string                             `gorm:"index" json:"role,omitempty"`       // 角色类型：只读、读写、Exec
+	Namespaces          string                             `json:"namespaces,omitempty"`              // Namespaces列表，逗号分割 ，该用户可以访问的Ns
+	BlacklistNamespaces string                             `json:"blacklist_namespaces,omitempty"`    // 黑名单Namespaces列表，逗号分割，禁止访问的Ns
+	AuthorizationType   constants.ClusterAuthorizationType `json:"authorization_type,omitempty"`      // 用户类型。User\Group两种，默认为User，空为User。Group指用户组
+	ManagedBy           string                             `gorm:"index" json:"managed_by,omitempty"` // 自动维护的来源，如外部用户组映射，为空表示手动授权
 	CreatedAt           time.Time                          `json:"created_at,omitempty" gorm:"<-:create"`
 	UpdatedAt           time.Time                          `json:"updated_at,omitempty"`
 }
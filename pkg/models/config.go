@@ -5,6 +5,7 @@ import (
 
 	"github.com/weibaohui/k8m/internal/dao"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
 	"gorm.io/gorm"
 )
 
@@ -28,9 +29,54 @@ type Config struct {
 	HeartbeatFailureThreshold   int       `gorm:"default:3" json:"heartbeat_failure_threshold,omitempty"`       // 心跳失败阈值
 	ReconnectMaxIntervalSeconds int       `gorm:"default:3600" json:"reconnect_max_interval_seconds,omitempty"` // 重连最大间隔时间（秒）
 	MaxRetryAttempts            int       `gorm:"default:100" json:"max_retry_attempts,omitempty"`              // 最大重试次数，默认100次
+	ExecPluginAllowlist         string    `gorm:"type:text" json:"exec_plugin_allowlist"`                       // 允许 kubeconfig 使用的 exec 凭据插件，每行一个命令名或绝对路径
 	ModelID                     uint      `json:"model_id"`
+	ChatModelID                 uint      `json:"chat_model_id"`                           // 对话使用的模型ID，0 表示使用默认模型
+	LogModelID                  uint      `json:"log_model_id"`                            // 日志分析使用的模型ID
+	InspectionModelID           uint      `json:"inspection_model_id"`                     // 巡检汇总使用的模型ID
+	EventModelID                uint      `json:"event_model_id"`                          // 事件分析、汇总使用的模型ID
+	K8sGPTModelID               uint      `json:"k8sgpt_model_id"`                         // k8sgpt 解释使用的模型ID
+	AIQuotaFailClosed           bool      `json:"ai_quota_fail_closed"`                    // 查询AI配额失败时拒绝请求，默认放行
+	AIRedactEnabled             bool      `gorm:"default:true" json:"ai_redact_enabled"`   // 发送给大模型前是否脱敏，默认开启
+	AIRedactEnvPatterns         string    `gorm:"type:text" json:"ai_redact_env_patterns"` // 需要脱敏的环境变量名正则，每行一个，为空时使用默认规则
+	KnowledgeEnabled            bool      `gorm:"default:true" json:"knowledge_enabled"`   // 是否在对话中检索知识库
+	KnowledgeTopK               int       `gorm:"default:3" json:"knowledge_top_k"`        // 检索知识库返回的分片数
+	EmbeddingModelID            uint      `json:"embedding_model_id"`                      // 生成向量使用的模型ID，0 表示使用 BM25 关键词检索
+	MFAEnforcePlatformAdmin     bool      `json:"mfa_enforce_platform_admin"`              // 平台管理员必须启用2步验证
+	MFAEnforceClusters          string    `gorm:"type:text" json:"mfa_enforce_clusters"`   // 生产集群，逗号分隔，* 表示全部集群，在其上拥有集群管理员或Exec权限的用户必须启用2步验证
+	MFAGraceDays                int       `gorm:"default:7" json:"mfa_grace_days"`         // 宽限天数，超过后登录仅允许绑定2步验证
+	LoginMaxFailures            int       `gorm:"default:5" json:"login_max_failures"`     // 同一用户名连续登录失败次数上限，超过后锁定，0 表示不锁定
+	LoginIPMaxFailures          int       `gorm:"default:20" json:"login_ip_max_failures"` // 同一来源IP连续登录失败次数上限，超过后锁定，0 表示不锁定
+	LoginLockMinutes            int       `gorm:"default:15" json:"login_lock_minutes"`    // 首次锁定时长（分钟），连续锁定时翻倍，最长24小时
+	SMTPHost                    string    `json:"smtp_host"`                               // 邮件服务器地址，为空时不发送邮件通知
+	SMTPPort                    int       `gorm:"default:25" json:"smtp_port"`             // 邮件服务器端口
+	SMTPUsername                string    `json:"smtp_username"`                           // 邮件服务器用户名
+	SMTPPassword                string    `json:"smtp_password"`                           // 邮件服务器密码，加密存储
+	SMTPFrom                    string    `json:"smtp_from"`                               // 发件人地址
+	SMTPTLS                     bool      `json:"smtp_tls"`                                // 是否使用 SSL/TLS 连接，如 465 端口，否则在服务器支持时使用 STARTTLS
 	CreatedAt                   time.Time `json:"created_at,omitempty" gorm:"<-:create"`
 	UpdatedAt                   time.Time `json:"updated_at,omitempty"` // Automatically managed by GORM for update time
+
+	// 本地用户密码策略
+	PasswordMinLength      int `gorm:"default:8" json:"password_min_length"`       // 密码最小长度
+	PasswordMinClasses     int `gorm:"default:3" json:"password_min_classes"`      // 密码至少包含大写字母、小写字母、数字、特殊字符中的几类
+	PasswordHistoryCount   int `gorm:"default:5" json:"password_history_count"`    // 不允许重复使用最近几次的密码，0 表示不限制
+	PasswordExpireDays     int `json:"password_expire_days"`                       // 密码有效天数，过期后登录须先修改密码，0 表示不过期
+	PasswordExpireWarnDays int `gorm:"default:7" json:"password_expire_warn_days"` // 密码过期前几天开始提醒
+
+	// 集群健康历史
+	ClusterHealthRetentionDays int    `gorm:"default:30" json:"cluster_health_retention_days"` // 心跳及状态变化记录保留天数
+	ClusterHealthWebhooks      string `json:"cluster_health_webhooks"`                         // 集群断开、恢复时通知的 webhook ID，逗号分隔
+	ClusterHealthEmails        string `gorm:"type:text" json:"cluster_health_emails"`          // 集群断开、恢复时通知的邮箱，逗号分隔
+
+	// 证书过期检查
+	CertScanIntervalHours int    `gorm:"default:24" json:"cert_scan_interval_hours"`      // 扫描集群证书的间隔（小时），0 表示不定期扫描
+	CertExpiryThresholds  string `gorm:"default:30,14,7,1" json:"cert_expiry_thresholds"` // 剩余天数达到这些阈值时发送提醒，逗号分隔
+	CertExpiryWebhooks    string `json:"cert_expiry_webhooks"`                            // 证书即将过期时通知的 webhook ID，逗号分隔
+	CertExpiryEmails      string `gorm:"type:text" json:"cert_expiry_emails"`             // 证书即将过期时通知的邮箱，逗号分隔
+
+	// 知识库
+	KnowledgeMaxChunks int `gorm:"default:5000" json:"knowledge_max_chunks"` // 知识库分片总数上限，检索时逐条计算相关度，过多会拖慢对话
 }
 
 func (c *Config) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*Config, int64, error) {
@@ -45,6 +91,39 @@ func (c *Config) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm
 	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
 }
 
+// FeatureModelID 获取指定功能场景配置的模型ID，未单独配置时返回 0
+func (c *Config) FeatureModelID(feature constants.AIFeature) uint {
+	switch feature {
+	case constants.AIFeatureChat:
+		return c.ChatModelID
+	case constants.AIFeatureLog:
+		return c.LogModelID
+	case constants.AIFeatureInspection:
+		return c.InspectionModelID
+	case constants.AIFeatureEvent:
+		return c.EventModelID
+	case constants.AIFeatureK8sGPT:
+		return c.K8sGPTModelID
+	default:
+		return 0
+	}
+}
+
 func (c *Config) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*Config, error) {
 	return dao.GenericGetOne(params, c, queryFuncs...)
 }
+
+// BeforeSave 在保存前加密敏感字段
+func (c *Config) BeforeSave(tx *gorm.DB) error {
+	return encryptFields(&c.SMTPPassword)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (c *Config) AfterSave(tx *gorm.DB) error {
+	return decryptFields(&c.SMTPPassword)
+}
+
+// AfterFind 在查询后解密敏感字段
+func (c *Config) AfterFind(tx *gorm.DB) error {
+	return decryptFields(&c.SMTPPassword)
+}
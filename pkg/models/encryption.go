@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/kms"
+	"k8s.io/klog/v2"
+)
+
+// encryptedTable 需要加密存储的表及字段
+type encryptedTable struct {
+	model   any
+	columns []string
+	// legacy 历史版本使用固定密钥 AES 加密的字段，迁移时先解密
+	legacy map[string]bool
+}
+
+// encryptedTables 所有加密存储的敏感字段，新增敏感字段时需在此登记，并在模型中实现加解密钩子
+var encryptedTables = []encryptedTable{
+	{model: &KubeConfig{}, columns: []string{"content", "token", "ca_cert", "access_key", "secret_access_key", "proxy_password", "ssh_private_key", "ssh_passphrase"}, legacy: map[string]bool{"access_key": true, "secret_access_key": true}},
+	{model: &SSOConfig{}, columns: []string{"client_secret", "sp_private_key"}},
+	{model: &LDAPConfig{}, columns: []string{"bind_password"}},
+	{model: &AIModelConfig{}, columns: []string{"api_key"}},
+	{model: &WebhookReceiver{}, columns: []string{"sign_secret"}},
+	{model: &JwtSigningKey{}, columns: []string{"private_key"}},
+	{model: &Config{}, columns: []string{"smtp_password"}},
+}
+
+// encryptFields 保存前加密字段
+func encryptFields(fields ...*string) error {
+	for _, f := range fields {
+		encrypted, err := kms.Encrypt(*f)
+		if err != nil {
+			return err
+		}
+		*f = encrypted
+	}
+	return nil
+}
+
+// decryptFields 查询后解密字段，未加密的历史数据原样保留
+func decryptFields(fields ...*string) error {
+	for _, f := range fields {
+		decrypted, err := kms.Decrypt(*f)
+		if err != nil {
+			return err
+		}
+		*f = decrypted
+	}
+	return nil
+}
+
+// EncryptSensitiveColumns 将历史明文数据加密，已加密的数据跳过
+func EncryptSensitiveColumns() error {
+	return forEachEncryptedColumn(func(t encryptedTable, column, value string) (string, bool, error) {
+		if value == "" || kms.IsEncrypted(value) {
+			return value, false, nil
+		}
+		if t.legacy[column] {
+			plain, err := decryptField(value)
+			if err != nil {
+				return "", false, err
+			}
+			value = plain
+		}
+		encrypted, err := kms.Encrypt(value)
+		return encrypted, err == nil, err
+	})
+}
+
+// RotateMasterKey 生成新的主密钥，并使用新密钥重新加密所有数据密钥
+// 主密钥不支持自动生成时（如来自环境变量），直接使用当前主密钥重新加密
+// 有字段重新加密失败时返回错误，这些字段仍依赖历史主密钥，不能删除历史主密钥
+func RotateMasterKey() (string, int, error) {
+	k, err := kms.Default()
+	if err != nil {
+		return "", 0, err
+	}
+	if rotator, ok := k.(kms.Rotator); ok {
+		if _, err := rotator.Rotate(); err != nil {
+			klog.Warningf("生成新主密钥失败，使用当前主密钥 [%s] 重新加密: %v", k.ActiveKeyID(), err)
+		}
+	}
+	count, err := RewrapSensitiveColumns()
+	return k.ActiveKeyID(), count, err
+}
+
+// RewrapSensitiveColumns 使用当前主密钥重新加密所有数据密钥，返回更新的字段数
+func RewrapSensitiveColumns() (int, error) {
+	count := 0
+	err := forEachEncryptedColumn(func(t encryptedTable, column, value string) (string, bool, error) {
+		newValue, changed, err := kms.Rewrap(value)
+		if changed {
+			count++
+		}
+		return newValue, changed, err
+	})
+	return count, err
+}
+
+// forEachEncryptedColumn 读取原始字段值（不经过模型钩子），按需更新
+// 单个字段处理失败时继续处理其余字段，最后返回错误，调用方不得因此认为已全部完成
+func forEachEncryptedColumn(fn func(t encryptedTable, column, value string) (string, bool, error)) error {
+	failed := 0
+	for _, t := range encryptedTables {
+		var rows []map[string]any
+		columns := append([]string{"id"}, t.columns...)
+		if err := dao.DB().Model(t.model).Select(columns).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			updates := map[string]any{}
+			for _, column := range t.columns {
+				newValue, changed, err := fn(t, column, rawString(row[column]))
+				if err != nil {
+					klog.Errorf("处理加密字段失败 [%T id=%v %s]: %v", t.model, row["id"], column, err)
+					failed++
+					continue
+				}
+				if changed {
+					updates[column] = newValue
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			// UpdateColumns 不触发钩子，也不更新 updated_at
+			if err := dao.DB().Model(t.model).Where("id = ?", row["id"]).UpdateColumns(updates).Error; err != nil {
+				return fmt.Errorf("更新加密字段失败 [%T id=%v]: %w", t.model, row["id"], err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个加密字段处理失败，详见日志", failed)
+	}
+	return nil
+}
+
+func rawString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", s)
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// GroupMappingRule 外部用户组映射规则，将 OIDC groups、LDAP memberOf 等外部用户组映射为 k8m 用户组及集群授权
+// 每次登录重新计算，外部用户组移除后对应的用户组及授权随之移除
+type GroupMappingRule struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Name          string    `json:"name,omitempty"`                            // 规则名称
+	Source        string    `gorm:"index" json:"source,omitempty"`             // 用户来源，SSO配置名称或 ldap_config，为空匹配全部来源
+	MatchType     string    `json:"match_type,omitempty"`                      // 匹配方式：exact 精确匹配（忽略大小写）、regex 正则匹配
+	ExternalGroup string    `gorm:"type:text" json:"external_group,omitempty"` // 外部用户组名称或正则，LDAP的DN同时以首个RDN的值匹配
+	UserGroups    string    `json:"user_groups,omitempty"`                     // 映射到的 k8m 用户组，逗号分隔
+	Cluster       string    `json:"cluster,omitempty"`                         // 授权集群，为空表示不授权
+	ClusterRole   string    `json:"cluster_role,omitempty"`                    // 集群角色
+	Namespaces    string    `json:"namespaces,omitempty"`                      // 授权的命名空间，逗号分隔，为空表示全部
+	Enabled       bool      `gorm:"default:true" json:"enabled"`               // 是否启用
+	Description   string    `json:"description,omitempty"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
+}
+
+func (c *GroupMappingRule) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*GroupMappingRule, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *GroupMappingRule) Save(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericSave(params, c, queryFuncs...)
+}
+
+func (c *GroupMappingRule) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *GroupMappingRule) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*GroupMappingRule, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JwtSigningKey JWT 签名密钥，私钥加密存储，公钥通过 JWKS 公开
+type JwtSigningKey struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Kid        string    `gorm:"uniqueIndex;size:64;not null" json:"kid,omitempty"` // 密钥ID，写入 Token 头部
+	Algorithm  string    `gorm:"size:16;not null" json:"algorithm,omitempty"`       // 签名算法 RS256、ES256
+	PrivateKey string    `gorm:"type:text" json:"-"`                                // PEM 格式私钥
+	PublicKey  string    `gorm:"type:text" json:"public_key,omitempty"`             // PEM 格式公钥
+	Active     bool      `json:"active"`                                            // 是否为当前签名密钥，其余仅用于验证
+	CreatedAt  time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"` // Automatically managed by GORM for update time
+}
+
+// BeforeSave 在保存前加密私钥
+func (k *JwtSigningKey) BeforeSave(tx *gorm.DB) error {
+	return encryptFields(&k.PrivateKey)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (k *JwtSigningKey) AfterSave(tx *gorm.DB) error {
+	return decryptFields(&k.PrivateKey)
+}
+
+// AfterFind 在查询后解密私钥
+func (k *JwtSigningKey) AfterFind(tx *gorm.DB) error {
+	return decryptFields(&k.PrivateKey)
+}
@@ -14,6 +14,7 @@ type K8sEventConfig struct {
 	Name             string `json:"name"`                                // 事件转发配置名称
 	Description      string `json:"description"`                         // 事件转发配置描述
 	Clusters         string `json:"clusters"`                            // 目标集群列表
+	ClusterSelector  string `json:"cluster_selector"`                    // 目标集群标签选择器，与目标集群列表合并
 	Webhooks         string `json:"webhooks"`                            // webhook列表
 	WebhookNames     string `json:"webhook_names"`                       // webhook 名称列表
 	Enabled          bool   `json:"enabled"`                             // 是否启用该任务
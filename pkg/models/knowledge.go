@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// KnowledgeDoc 知识库文档
+type KnowledgeDoc struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Source     string    `gorm:"index" json:"source,omitempty"`     // 来源：runbook 运维手册、openapi 资源字段文档、inspection 巡检总结、incident 故障复盘
+	SourceRef  string    `gorm:"index" json:"source_ref,omitempty"` // 来源标识，用于导入时去重，如 inspection:12
+	Content    string    `gorm:"type:text" json:"content,omitempty"`
+	Enabled    bool      `gorm:"default:true" json:"enabled"`
+	ChunkCount int       `json:"chunk_count"`
+	IndexMode  string    `json:"index_mode,omitempty"` // 索引方式：vector 向量、bm25 关键词
+	IndexError string    `json:"index_error,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+func (c *KnowledgeDoc) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*KnowledgeDoc, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *KnowledgeDoc) Save(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericSave(params, c, queryFuncs...)
+}
+
+func (c *KnowledgeDoc) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *KnowledgeDoc) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*KnowledgeDoc, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
+
+// KnowledgeChunk 知识库文档分片及其向量
+type KnowledgeChunk struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	DocID          uint      `gorm:"index" json:"doc_id,omitempty"`
+	Seq            int       `json:"seq"`
+	Content        string    `gorm:"type:text" json:"content,omitempty"`
+	Embedding      string    `gorm:"type:text" json:"-"` // 向量，JSON 数组
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	CreatedAt      time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+}
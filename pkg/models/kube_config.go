@@ -1,10 +1,10 @@
 package models
 
 import (
-	"encoding/base64"
 	"time"
 
 	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/kms"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"gorm.io/gorm"
 )
@@ -37,6 +37,20 @@ type KubeConfig struct {
 	QPS float32 `gorm:"default:200" json:"qps,omitempty"`
 	// Burst 设置突发请求数限制，默认为 2000
 	Burst int `gorm:"default:2000" json:"burst,omitempty"`
+	// Impersonate 以 k8m 用户身份模拟访问集群，集群 RBAC 及审计日志作用于实际用户，关闭时使用注册的凭据访问
+	Impersonate bool `json:"impersonate,omitempty"`
+	// ImpersonatePrefix 模拟的用户名及用户组名前缀，如 k8m:，便于在 RBAC 中区分
+	ImpersonatePrefix string `gorm:"type:varchar(64)" json:"impersonate_prefix,omitempty"`
+	// ProxyUsername、ProxyPassword 代理认证信息，ProxyURL 支持 http、https、socks5
+	ProxyUsername string `gorm:"type:varchar(128)" json:"proxy_username,omitempty"`
+	ProxyPassword string `gorm:"type:text" json:"-"`
+	// SSH 跳板机，配置后经跳板机转发访问 apiserver，与代理不能同时使用
+	SSHHost       string `gorm:"type:varchar(255)" json:"ssh_host,omitempty"`       // 跳板机地址，host:port
+	SSHUser       string `gorm:"type:varchar(128)" json:"ssh_user,omitempty"`       // 登录用户
+	SSHAuthMethod string `gorm:"type:varchar(16)" json:"ssh_auth_method,omitempty"` // key：私钥；agent：k8m 所在主机的 ssh-agent
+	SSHPrivateKey string `gorm:"type:text" json:"-"`                                // PEM 格式私钥
+	SSHPassphrase string `gorm:"type:text" json:"-"`                                // 私钥密码
+	SSHKnownHosts string `gorm:"type:text" json:"ssh_known_hosts,omitempty"`        // known_hosts 格式的跳板机公钥，用于校验跳板机身份
 
 	CreatedAt time.Time `json:"created_at,omitempty" gorm:"<-:create"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"` // Automatically managed by GORM for update time
@@ -60,55 +74,30 @@ func (c *KubeConfig) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *go
 
 // BeforeSave 在保存前加密敏感字段
 func (c *KubeConfig) BeforeSave(tx *gorm.DB) error {
-	if c.AccessKey != "" {
-		encrypted, err := encryptField(c.AccessKey)
-		if err != nil {
-			return err
-		}
-		c.AccessKey = encrypted
-	}
-	if c.SecretAccessKey != "" {
-		encrypted, err := encryptField(c.SecretAccessKey)
-		if err != nil {
-			return err
-		}
-		c.SecretAccessKey = encrypted
-	}
-	return nil
+	return encryptFields(&c.Content, &c.Token, &c.CACert, &c.AccessKey, &c.SecretAccessKey, &c.ProxyPassword, &c.SSHPrivateKey, &c.SSHPassphrase)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (c *KubeConfig) AfterSave(tx *gorm.DB) error {
+	return c.AfterFind(tx)
 }
 
 // AfterFind 在查询后解密敏感字段
 func (c *KubeConfig) AfterFind(tx *gorm.DB) error {
-	if c.AccessKey != "" {
-		decrypted, err := decryptField(c.AccessKey)
-		if err != nil {
-			return err
-		}
-		c.AccessKey = decrypted
-	}
-	if c.SecretAccessKey != "" {
-		decrypted, err := decryptField(c.SecretAccessKey)
-		if err != nil {
-			return err
+	// 兼容未迁移的历史数据：AWS 密钥曾使用固定密钥加密
+	for _, f := range []*string{&c.AccessKey, &c.SecretAccessKey} {
+		if *f != "" && !kms.IsEncrypted(*f) {
+			decrypted, err := decryptField(*f)
+			if err != nil {
+				return err
+			}
+			*f = decrypted
 		}
-		c.SecretAccessKey = decrypted
-	}
-	return nil
-}
-
-// encryptField 加密字段
-func encryptField(plaintext string) (string, error) {
-	if plaintext == "" {
-		return "", nil
-	}
-	encrypted, err := utils.AesEncrypt([]byte(plaintext))
-	if err != nil {
-		return "", err
 	}
-	return base64.StdEncoding.EncodeToString(encrypted), nil
+	return decryptFields(&c.Content, &c.Token, &c.CACert, &c.AccessKey, &c.SecretAccessKey, &c.ProxyPassword, &c.SSHPrivateKey, &c.SSHPassphrase)
 }
 
-// decryptField 解密字段
+// decryptField 解密使用固定密钥加密的历史字段
 func decryptField(ciphertext string) (string, error) {
 	if ciphertext == "" {
 		return "", nil
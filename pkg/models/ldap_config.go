@@ -22,6 +22,8 @@ type LDAPConfig struct {
 	UserFilter      string    `gorm:"size:255" json:"user_filter"`             // 用户过滤器
 	LOGIN2AUTHCLOSE bool      `gorm:"default:true" json:"login2_auth_close"`   // 登录后开启认证
 	DefaultGroup    string    `gorm:"size:50" json:"default_group"`            // 默认用户组
+	GroupAttribute  string    `gorm:"size:50" json:"group_attribute"`          // 用户组属性，默认 memberOf
+	SyncInterval    int       `json:"sync_interval"`                           // 定期同步用户组的间隔（分钟），0 表示不同步
 	Enabled         bool      `gorm:"default:true" json:"enabled"`             // 启用状态
 	CreatedAt       time.Time `json:"created_at,omitempty" gorm:"<-:create"`
 	UpdatedAt       time.Time `json:"updated_at"`
@@ -46,3 +48,18 @@ func (l *LDAPConfig) Delete(params *dao.Params, ids string, queryFuncs ...func(*
 func (l *LDAPConfig) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*LDAPConfig, error) {
 	return dao.GenericGetOne(params, l, queryFuncs...)
 }
+
+// BeforeSave 在保存前加密敏感字段
+func (l *LDAPConfig) BeforeSave(tx *gorm.DB) error {
+	return encryptFields(&l.BindPassword)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (l *LDAPConfig) AfterSave(tx *gorm.DB) error {
+	return decryptFields(&l.BindPassword)
+}
+
+// AfterFind 在查询后解密敏感字段
+func (l *LDAPConfig) AfterFind(tx *gorm.DB) error {
+	return decryptFields(&l.BindPassword)
+}
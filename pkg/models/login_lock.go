@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// LoginLock 登录失败计数及锁定状态，分别按用户名、来源IP记录，多副本共享
+type LoginLock struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Kind         string     `gorm:"uniqueIndex:idx_login_lock_key;size:16;not null" json:"kind,omitempty"`   // 类型：user、ip
+	Value        string     `gorm:"uniqueIndex:idx_login_lock_key;size:255;not null" json:"value,omitempty"` // 用户名或IP
+	Failures     int        `json:"failures"`                                                                // 连续失败次数，锁定后清零
+	Locks        int        `json:"locks"`                                                                   // 连续锁定次数，锁定时长随之翻倍
+	LastFailedAt *time.Time `json:"last_failed_at,omitempty"`                                                // 最后一次失败时间
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`                                                  // 锁定截止时间
+	Pending      int        `json:"pending"`                                                                 // 进行中的登录尝试数，校验密码前占用
+	ReservedAt   *time.Time `json:"reserved_at,omitempty"`                                                   // 最近一次占用尝试的时间，超时未释放视为失效
+	Locked       bool       `gorm:"-" json:"locked"`                                                         // 是否处于锁定中，查询时计算
+	CreatedAt    time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+}
+
+func (c *LoginLock) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*LoginLock, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *LoginLock) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
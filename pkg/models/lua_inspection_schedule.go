@@ -20,6 +20,7 @@ type InspectionSchedule struct {
 	Name                string       `json:"name"`                                // 巡检任务名称
 	Description         string       `json:"description"`                         // 巡检任务描述
 	Clusters            string       `json:"clusters"`                            // 目标集群列表
+	ClusterSelector     string       `json:"cluster_selector"`                    // 目标集群标签选择器，与目标集群列表合并
 	Webhooks            string       `json:"webhooks"`                            // webhook列表
 	WebhookNames        string       `json:"webhook_names"`                       // webhook 名称列表
 	Cron                string       `json:"cron"`                                // cron表达式，定时周期
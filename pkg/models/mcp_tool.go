@@ -16,6 +16,7 @@ type MCPTool struct {
 	Description string    `gorm:"type:text" json:"description,omitempty"`                       // 工具描述
 	InputSchema string    `gorm:"type:text" json:"input_schema,omitempty"`                      // 输入模式，JSON格式
 	Enabled     bool      `gorm:"default:true" json:"enabled,omitempty"`                        // 是否启用
+	AccessMode  string    `json:"access_mode,omitempty"`                                        // 读写类型：read 只读、write 写入，为空时根据工具名称自动判断
 	CreatedAt   time.Time `json:"created_at,omitempty" gorm:"<-:create"`                        // 创建时间
 	UpdatedAt   time.Time `json:"updated_at,omitempty"`                                         // 更新时间
 }
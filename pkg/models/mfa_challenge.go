@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// MFAChallenge 等待完成的2步验证登录或 WebAuthn 绑定请求，存储在数据库中，多实例部署时任一实例均可完成验证
+type MFAChallenge struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	ChallengeKey string    `gorm:"uniqueIndex;size:128;not null" json:"-"` // 登录为 login:Token摘要，绑定为 register:用户名
+	Username     string    `gorm:"index" json:"username,omitempty"`        // 所属用户
+	LoginType    string    `json:"login_type,omitempty"`                   // 登录方式：password、ldap、sso
+	Attempts     int       `json:"attempts"`                               // 验证失败次数
+	Session      string    `gorm:"type:text" json:"-"`                     // WebAuthn 会话数据，JSON 格式
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at,omitempty"`      // 过期时间
+	CreatedAt    time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
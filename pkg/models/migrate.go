@@ -30,6 +30,9 @@ func init() {
 	_ = MigrateAIModel()
 	_ = AddBuiltinLuaScripts()
 	_ = InitBuiltinAIPrompts()
+	if err := EncryptSensitiveColumns(); err != nil {
+		klog.Errorf("加密历史敏感数据失败: %v", err)
+	}
 }
 func AutoMigrate() error {
 
@@ -141,6 +144,86 @@ func AutoMigrate() error {
 	if err := dao.DB().AutoMigrate(&K8sEvent{}); err != nil {
 		errs = append(errs, err)
 	}
+	// AI 用量与配额
+	if err := dao.DB().AutoMigrate(&AIUsageLog{}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := dao.DB().AutoMigrate(&AIQuota{}); err != nil {
+		errs = append(errs, err)
+	}
+	// AI 脱敏记录
+	if err := dao.DB().AutoMigrate(&AIRedactionLog{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 知识库
+	if err := dao.DB().AutoMigrate(&KnowledgeDoc{}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := dao.DB().AutoMigrate(&KnowledgeChunk{}); err != nil {
+		errs = append(errs, err)
+	}
+	// Token 吊销列表
+	if err := dao.DB().AutoMigrate(&RevokedToken{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 登录会话
+	if err := dao.DB().AutoMigrate(&UserSession{}); err != nil {
+		errs = append(errs, err)
+	}
+	// JWT 签名密钥
+	if err := dao.DB().AutoMigrate(&JwtSigningKey{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 外部用户组映射规则
+	if err := dao.DB().AutoMigrate(&GroupMappingRule{}); err != nil {
+		errs = append(errs, err)
+	}
+	// SCIM 访问Token
+	if err := dao.DB().AutoMigrate(&ScimToken{}); err != nil {
+		errs = append(errs, err)
+	}
+	// WebAuthn 凭据
+	if err := dao.DB().AutoMigrate(&WebAuthnCredential{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 等待完成的2步验证
+	if err := dao.DB().AutoMigrate(&MFAChallenge{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 一次性认证请求
+	if err := dao.DB().AutoMigrate(&AuthChallenge{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 登录失败计数及锁定
+	if err := dao.DB().AutoMigrate(&LoginLock{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 密码历史
+	if err := dao.DB().AutoMigrate(&PasswordHistory{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 用户下载的 kubeconfig
+	if err := dao.DB().AutoMigrate(&UserKubeconfig{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 集群 Agent
+	if err := dao.DB().AutoMigrate(&ClusterAgent{}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := dao.DB().AutoMigrate(&ClusterTag{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 集群健康历史
+	if err := dao.DB().AutoMigrate(&ClusterHeartbeat{}); err != nil {
+		errs = append(errs, err)
+	}
+	if err := dao.DB().AutoMigrate(&ClusterStatusEvent{}); err != nil {
+		errs = append(errs, err)
+	}
+	// 集群证书过期检查
+	if err := dao.DB().AutoMigrate(&ClusterCertificate{}); err != nil {
+		errs = append(errs, err)
+	}
 	// 删除 user 表 name 字段，已弃用
 	if dao.DB().Migrator().HasColumn(&User{}, "Role") {
 		if err := dao.DB().Migrator().DropColumn(&User{}, "Role"); err != nil {
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory 用户使用过的密码摘要，用于防止重复使用近期密码
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username  string    `gorm:"index;not null" json:"username,omitempty"`
+	Password  string    `gorm:"not null" json:"-"` // 密码摘要，历史数据可能为旧的加密格式
+	Salt      string    `json:"-"`                 // 旧加密格式使用的盐值
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+}
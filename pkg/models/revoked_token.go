@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken 已吊销的 Token，在过期前拒绝使用
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	TokenHash string    `gorm:"uniqueIndex;size:64;not null" json:"token_hash,omitempty"` // Token 的 SHA256 摘要
+	Username  string    `gorm:"index" json:"username,omitempty"`                          // 所属用户
+	Reason    string    `json:"reason,omitempty"`                                         // 吊销原因
+	ExpiresAt time.Time `gorm:"index" json:"expires_at,omitempty"`                        // Token 原过期时间，过期后可清理
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"<-:create"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// ScimToken SCIM 接口专用的访问Token，仅保存摘要，明文只在创建时返回一次
+type ScimToken struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Name        string     `json:"name,omitempty"`                        // 名称，如对接的身份系统
+	TokenHash   string     `gorm:"uniqueIndex;size:64;not null" json:"-"` // Token 的 SHA256 摘要
+	TokenPrefix string     `gorm:"size:16" json:"token_prefix,omitempty"` // Token 前缀，便于识别
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`                  // 过期时间，为空不过期
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`                // 最后使用时间
+	LastUsedIP  string     `json:"last_used_ip,omitempty"`                // 最后使用的来源IP
+	CreatedBy   string     `json:"created_by,omitempty"`                  // 创建者
+	CreatedAt   time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty"`
+}
+
+func (c *ScimToken) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*ScimToken, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *ScimToken) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
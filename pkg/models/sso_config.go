@@ -19,6 +19,16 @@ type SSOConfig struct {
 	Enabled            bool      `gorm:"default:false" json:"enabled,omitempty"`            // 是否启用SSO
 	PreferUserNameKeys string    `gorm:"type:text;" json:"prefer_user_name_keys,omitempty"` // 用户自定义获取用户名的字段顺序，适用于如果用户名字段不在默认字段中情况
 	Scopes             string    `gorm:"type:text;" json:"scopes,omitempty"`                // 授权范围
+	GroupsKey          string    `json:"groups_key,omitempty"`                              // 用户组字段，默认 groups
+	AllowedGroups      string    `gorm:"type:text;" json:"allowed_groups,omitempty"`        // 允许登录的组织、团队或用户组，逗号分隔，为空时不限制
+	AuthURL            string    `gorm:"type:text;" json:"auth_url,omitempty"`              // OAuth2授权地址
+	TokenURL           string    `gorm:"type:text;" json:"token_url,omitempty"`             // OAuth2获取Token地址
+	UserInfoURL        string    `gorm:"type:text;" json:"user_info_url,omitempty"`         // OAuth2获取用户信息地址
+	IdpMetadataURL     string    `gorm:"type:text;" json:"idp_metadata_url,omitempty"`      // SAML IdP元数据地址
+	IdpMetadata        string    `gorm:"type:text;" json:"idp_metadata,omitempty"`          // SAML IdP元数据XML，无法访问元数据地址时使用
+	EntityID           string    `gorm:"type:text;" json:"entity_id,omitempty"`             // SAML SP EntityID，默认使用元数据地址
+	SPCertificate      string    `gorm:"type:text;" json:"sp_certificate,omitempty"`        // SAML SP证书，首次使用时自动生成
+	SPPrivateKey       string    `gorm:"type:text;" json:"-"`                               // SAML SP私钥，用于签名请求及解密断言
 	CreatedAt          time.Time `json:"created_at,omitempty" gorm:"<-:create"`
 	UpdatedAt          time.Time `json:"updated_at,omitempty"` // 更新时间
 }
@@ -42,3 +52,18 @@ func (s *SSOConfig) Delete(params *dao.Params, ids string, queryFuncs ...func(*g
 func (s *SSOConfig) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*SSOConfig, error) {
 	return dao.GenericGetOne(params, s, queryFuncs...)
 }
+
+// BeforeSave 在保存前加密敏感字段
+func (s *SSOConfig) BeforeSave(tx *gorm.DB) error {
+	return encryptFields(&s.ClientSecret, &s.SPPrivateKey)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (s *SSOConfig) AfterSave(tx *gorm.DB) error {
+	return decryptFields(&s.ClientSecret, &s.SPPrivateKey)
+}
+
+// AfterFind 在查询后解密敏感字段
+func (s *SSOConfig) AfterFind(tx *gorm.DB) error {
+	return decryptFields(&s.ClientSecret, &s.SPPrivateKey)
+}
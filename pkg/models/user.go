@@ -10,20 +10,27 @@ import (
 
 // User 用户导入User
 type User struct {
-	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
-	Username         string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"username,omitempty"`
-	Salt             string    `gorm:"not null" json:"salt,omitempty"`
-	Password         string    `gorm:"not null" json:"password,omitempty"`
-	GroupNames       string    `json:"group_names,omitempty"`
-	Source           string    `json:"source,omitempty"` // 来源，如：db, ldap_config.json, oauth
-	CreatedAt        time.Time `json:"created_at,omitempty" gorm:"<-:create"`
-	UpdatedAt        time.Time `json:"updated_at,omitempty"`                          // Automatically managed by GORM for update time
-	TwoFAEnabled     bool      `gorm:"default:false" json:"two_fa_enabled,omitempty"` // 是否启用2FA
-	TwoFAType        string    `gorm:"size:20" json:"two_fa_type,omitempty"`          // 2FA类型：如 'totp', 'sms', 'email'
-	TwoFASecret      string    `gorm:"size:100" json:"two_fa_secret,omitempty"`       // 2FA密钥
-	TwoFABackupCodes string    `gorm:"size:500" json:"two_fa_backup_codes,omitempty"` // 备用恢复码，逗号分隔
-	TwoFAAppName     string    `gorm:"size:100" json:"two_fa_app_name,omitempty"`     // 2FA应用名称，用于提醒用户使用的是哪个软件
-	Disabled         bool      `gorm:"default:false" json:"disabled,omitempty"`       // 是否启用
+	ID                 uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username           string     `gorm:"type:varchar(255);uniqueIndex;not null" json:"username,omitempty"`
+	Salt               string     `gorm:"not null" json:"salt,omitempty"`
+	Password           string     `gorm:"not null" json:"password,omitempty"`
+	GroupNames         string     `json:"group_names,omitempty"`
+	MappedGroupNames   string     `json:"mapped_group_names,omitempty"`       // 由外部用户组映射规则分配的用户组，登录时重新计算
+	Source             string     `json:"source,omitempty"`                   // 来源，如：db, ldap_config.json, oauth
+	ExternalID         string     `gorm:"index" json:"external_id,omitempty"` // SCIM 身份系统中的用户标识
+	CreatedAt          time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt          time.Time  `json:"updated_at,omitempty"`                                // Automatically managed by GORM for update time
+	TwoFAEnabled       bool       `gorm:"default:false" json:"two_fa_enabled,omitempty"`       // 是否启用2FA
+	TwoFAType          string     `gorm:"size:20" json:"two_fa_type,omitempty"`                // 2FA类型：如 'totp', 'sms', 'email'
+	TwoFASecret        string     `gorm:"size:100" json:"two_fa_secret,omitempty"`             // 2FA密钥
+	TwoFABackupCodes   string     `gorm:"size:500" json:"two_fa_backup_codes,omitempty"`       // 备用恢复码，逗号分隔
+	TwoFAAppName       string     `gorm:"size:100" json:"two_fa_app_name,omitempty"`           // 2FA应用名称，用于提醒用户使用的是哪个软件
+	Disabled           bool       `gorm:"default:false" json:"disabled,omitempty"`             // 是否启用
+	MFARequiredAt      *time.Time `json:"mfa_required_at,omitempty"`                           // 首次被2步验证策略要求绑定的时间，宽限期由此起算
+	Email              string     `json:"email,omitempty"`                                     // 邮箱，用于接收账号安全通知
+	NotifyWebhooks     string     `json:"notify_webhooks,omitempty"`                           // 接收账号安全通知的 webhook ID，逗号分隔
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`                       // 最近修改密码时间，密码有效期由此起算
+	PasswordMustChange bool       `gorm:"default:false" json:"password_must_change,omitempty"` // 管理员重置密码后，下次登录须先修改密码
 }
 
 func (c *User) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*User, int64, error) {
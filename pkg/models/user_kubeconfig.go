@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// UserKubeconfig 用户下载的 kubeconfig，经 Kubernetes API 代理以 k8m 用户身份访问集群
+type UserKubeconfig struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username    string     `gorm:"index;not null" json:"username,omitempty"` // 所属用户
+	Description string     `json:"description,omitempty"`                    // 描述信息
+	Mode        string     `gorm:"size:16" json:"mode,omitempty"`            // token：内置短期Token；exec：通过 exec 插件向 k8m 换取Token
+	Clusters    string     `gorm:"type:text" json:"clusters,omitempty"`      // 包含的集群，逗号分隔
+	SecretHash  string     `gorm:"index;size:64" json:"-"`                   // exec 模式换取Token的凭据摘要
+	ExpiresAt   time.Time  `json:"expires_at"`                               // 过期时间，exec 模式为凭据的过期时间
+	Revoked     bool       `json:"revoked"`                                  // 是否已吊销
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`                     // 吊销时间
+	RevokedBy   string     `json:"revoked_by,omitempty"`                     // 吊销人
+	CreatedIP   string     `json:"created_ip,omitempty"`                     // 下载时的来源IP
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`                   // 最后使用时间
+	LastUsedIP  string     `json:"last_used_ip,omitempty"`                   // 最后使用的来源IP
+	CreatedAt   time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty"`
+}
+
+func (c *UserKubeconfig) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*UserKubeconfig, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *UserKubeconfig) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *UserKubeconfig) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*UserKubeconfig, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// UserSession 用户登录会话，每次登录创建一个会话，通过刷新 Token 续期
+type UserSession struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	SessionID        string     `gorm:"uniqueIndex;size:64;not null" json:"session_id,omitempty"` // 会话ID，写入访问 Token
+	Username         string     `gorm:"index;not null" json:"username,omitempty"`                 // 所属用户
+	LoginType        string     `json:"login_type,omitempty"`                                     // 登录方式：password、ldap、sso
+	RefreshTokenHash string     `gorm:"index;size:64" json:"-"`                                   // 当前刷新 Token 的 SHA256 摘要
+	PrevTokenHash    string     `gorm:"index;size:64" json:"-"`                                   // 上一个刷新 Token 的摘要，用于发现 Token 被盗用
+	ClientIP         string     `json:"client_ip,omitempty"`                                      // 最近访问的来源IP
+	UserAgent        string     `gorm:"type:text" json:"user_agent,omitempty"`                    // 设备信息
+	LastSeenAt       time.Time  `json:"last_seen_at,omitempty"`                                   // 最近活跃时间
+	ExpiresAt        time.Time  `gorm:"index" json:"expires_at,omitempty"`                        // 刷新 Token 过期时间
+	Revoked          bool       `gorm:"index" json:"revoked"`                                     // 是否已注销
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`                                     // 注销时间
+	RevokedReason    string     `json:"revoked_reason,omitempty"`                                 // 注销原因
+	MFAEnroll        bool       `json:"mfa_enroll"`                                               // 未满足2步验证策略，仅允许绑定2步验证
+	CreatedAt        time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt        time.Time  `json:"updated_at,omitempty"` // Automatically managed by GORM for update time
+}
+
+func (c *UserSession) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*UserSession, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *UserSession) Save(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericSave(params, c, queryFuncs...)
+}
+
+func (c *UserSession) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
+
+func (c *UserSession) GetOne(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) (*UserSession, error) {
+	return dao.GenericGetOne(params, c, queryFuncs...)
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential 用户绑定的 WebAuthn 凭据（通行密钥、安全密钥），作为2步验证方式之一
+type WebAuthnCredential struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id,omitempty"`
+	Username     string     `gorm:"index;not null" json:"username,omitempty"`                     // 所属用户
+	Name         string     `json:"name,omitempty"`                                               // 名称，便于用户区分不同设备
+	CredentialID string     `gorm:"uniqueIndex;size:255;not null" json:"credential_id,omitempty"` // 凭据ID，base64url 编码
+	Credential   string     `gorm:"type:text" json:"-"`                                           // 凭据公钥等信息，JSON 格式
+	SignCount    uint32     `json:"sign_count"`                                                   // 签名计数器，用于发现克隆的认证器
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`                                       // 最后使用时间
+	CreatedAt    time.Time  `json:"created_at,omitempty" gorm:"<-:create"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+}
+
+func (c *WebAuthnCredential) List(params *dao.Params, queryFuncs ...func(*gorm.DB) *gorm.DB) ([]*WebAuthnCredential, int64, error) {
+	return dao.GenericQuery(params, c, queryFuncs...)
+}
+
+func (c *WebAuthnCredential) Delete(params *dao.Params, ids string, queryFuncs ...func(*gorm.DB) *gorm.DB) error {
+	return dao.GenericDelete(params, c, utils.ToInt64Slice(ids), queryFuncs...)
+}
@@ -83,3 +83,18 @@ func (c *WebhookReceiver) GetNamesByIds(ids string) ([]string, error) {
 	}
 	return names, nil
 }
+
+// BeforeSave 在保存前加密敏感字段
+func (c *WebhookReceiver) BeforeSave(tx *gorm.DB) error {
+	return encryptFields(&c.SignSecret)
+}
+
+// AfterSave 保存后还原为明文，便于调用方继续使用
+func (c *WebhookReceiver) AfterSave(tx *gorm.DB) error {
+	return decryptFields(&c.SignSecret)
+}
+
+// AfterFind 在查询后解密敏感字段
+func (c *WebhookReceiver) AfterFind(tx *gorm.DB) error {
+	return decryptFields(&c.SignSecret)
+}
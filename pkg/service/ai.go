@@ -2,10 +2,14 @@ package service
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/weibaohui/k8m/pkg/ai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
 	"github.com/weibaohui/k8m/pkg/flag"
+	"github.com/weibaohui/k8m/pkg/models"
 	"k8s.io/klog/v2"
 )
 
@@ -13,6 +17,10 @@ type aiService struct {
 	innerModel  string
 	innerApiKey string
 	innerApiUrl string
+
+	mu           sync.Mutex
+	clients      map[constants.AIFeature]ai.IAI // 各功能场景的客户端缓存
+	modelClients map[uint]ai.IAI                // 按模型ID缓存的客户端，用于配额降级等场景
 }
 
 func (c *aiService) SetVars(apikey, apiUrl, model string) {
@@ -21,35 +29,156 @@ func (c *aiService) SetVars(apikey, apiUrl, model string) {
 	c.innerApiKey = apikey
 }
 
-var local ai.IAI
-
+// DefaultClient 获取对话场景使用的客户端
 func (c *aiService) DefaultClient() (ai.IAI, error) {
+	return c.ClientForFeature(constants.AIFeatureChat)
+}
+
+// ClientForFeature 获取指定功能场景使用的客户端
+// 功能场景单独配置了模型时使用该模型，否则使用默认模型
+func (c *aiService) ClientForFeature(feature constants.AIFeature) (ai.IAI, error) {
 	enable := c.IsEnabled()
 	if !enable {
 		return nil, fmt.Errorf("ChatGPT功能未开启")
 	}
 
-	if local != nil {
-		return local, nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[feature]; ok {
+		return client, nil
 	}
 
-	if client, err := c.openAIClient(); err == nil {
-		local = client
+	client, err := c.newFeatureClient(feature)
+	if err != nil {
+		return nil, err
+	}
+	if c.clients == nil {
+		c.clients = make(map[constants.AIFeature]ai.IAI)
 	}
-	return local, nil
+	c.clients[feature] = client
+	return client, nil
 }
 
-// ResetDefaultClient 重置 local ，适用于切换
+// ClientForModel 获取指定模型配置的客户端
+func (c *aiService) ClientForModel(id uint) (ai.IAI, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.modelClients[id]; ok {
+		return client, nil
+	}
+	client, err := c.modelClient(id, map[uint]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if c.modelClients == nil {
+		c.modelClients = make(map[uint]ai.IAI)
+	}
+	c.modelClients[id] = client
+	return client, nil
+}
+
+// ResetDefaultClient 重置各场景客户端缓存，适用于切换
 func (c *aiService) ResetDefaultClient() error {
 	enable := c.IsEnabled()
 	if !enable {
 		return fmt.Errorf("ChatGPT功能未开启")
 	}
-	local = nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, client := range c.clients {
+		client.Close()
+	}
+	for _, client := range c.modelClients {
+		client.Close()
+	}
+	c.clients = nil
+	c.modelClients = nil
 	klog.V(6).Infof("AI DefaultClient Reset ")
 	return nil
 }
 
+func (c *aiService) newFeatureClient(feature constants.AIFeature) (ai.IAI, error) {
+	var modelID uint
+	if m, err := ConfigService().GetConfig(); err == nil {
+		modelID = m.FeatureModelID(feature)
+		if modelID == 0 && !m.UseBuiltInModel {
+			modelID = m.ModelID
+		}
+	}
+	if modelID == 0 {
+		return c.openAIClient()
+	}
+	return c.modelClient(modelID, map[uint]bool{})
+}
+
+// modelClient 根据数据库中的模型配置创建客户端，配置了备用模型或超时时间时包装为 FallbackClient
+// visited 用于避免备用模型配置成环
+func (c *aiService) modelClient(id uint, visited map[uint]bool) (ai.IAI, error) {
+	visited[id] = true
+	mc := &models.AIModelConfig{ID: id}
+	mc, err := mc.GetOne(nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取AI模型配置[%d]失败: %w", id, err)
+	}
+	client, err := c.newClientFromModel(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback ai.IAI
+	if mc.FallbackModelID > 0 && !visited[mc.FallbackModelID] {
+		fallback, err = c.modelClient(mc.FallbackModelID, visited)
+		if err != nil {
+			klog.Warningf("AI模型[%d]的备用模型[%d]不可用: %v", id, mc.FallbackModelID, err)
+			fallback = nil
+		}
+	}
+	if fallback == nil && mc.Timeout <= 0 {
+		return client, nil
+	}
+	return ai.NewFallbackClient(client, fallback, time.Duration(mc.Timeout)*time.Second), nil
+}
+
+func (c *aiService) newClientFromModel(mc *models.AIModelConfig) (ai.IAI, error) {
+	cfg := flag.Init()
+	aiProvider := ai.Provider{
+		Name:        mc.Provider,
+		Model:       mc.ApiModel,
+		Password:    mc.ApiKey,
+		BaseURL:     mc.ApiURL,
+		ApiVersion:  mc.ApiVersion,
+		Engine:      mc.Deployment,
+		Temperature: 0.7,
+		TopP:        1,
+		MaxHistory:  10,
+		MaxTokens:   mc.MaxTokens,
+	}
+	if aiProvider.Name == "" {
+		aiProvider.Name = "openai"
+	}
+	if mc.Temperature > 0 {
+		aiProvider.Temperature = mc.Temperature
+	}
+	if mc.TopP > 0 {
+		aiProvider.TopP = mc.TopP
+	}
+	if cfg.MaxHistory > 0 {
+		aiProvider.MaxHistory = cfg.MaxHistory
+	}
+	if cfg.Debug {
+		klog.V(4).Infof("ai Provider: %v\n", aiProvider.Name)
+		klog.V(4).Infof("ai BaseURL: %v\n", aiProvider.BaseURL)
+		klog.V(4).Infof("ai Model : %v\n", aiProvider.Model)
+		klog.V(4).Infof("ai Key: %v\n", utils.MaskString(aiProvider.Password, 5))
+	}
+
+	aiClient := ai.NewClient(aiProvider.Name)
+	if err := aiClient.Configure(&aiProvider); err != nil {
+		return nil, err
+	}
+	return aiClient, nil
+}
+
 func (c *aiService) openAIClient() (ai.IAI, error) {
 	cfg := flag.Init()
 
@@ -103,18 +232,8 @@ func (c *aiService) IsEnabled() bool {
 	return enable
 }
 
-func (c *aiService) TestClient(url string, key string, model string) (ai.IAI, error) {
-	klog.V(6).Infof("TestClient url:%v key:%v model:%v\n", url, utils.MaskString(key, 5), model)
-	aiProvider := ai.Provider{
-		Name:     "test",
-		Model:    model,
-		Password: key,
-		BaseURL:  url,
-	}
-
-	aiClient := ai.NewClient(aiProvider.Name)
-	if err := aiClient.Configure(&aiProvider); err != nil {
-		return nil, err
-	}
-	return aiClient, nil
+// TestClient 按模型配置创建一个独立的客户端，用于测试连通性
+func (c *aiService) TestClient(mc *models.AIModelConfig) (ai.IAI, error) {
+	klog.V(6).Infof("TestClient provider:%v url:%v key:%v model:%v\n", mc.Provider, mc.ApiURL, utils.MaskString(mc.ApiKey, 5), mc.ApiModel)
+	return c.newClientFromModel(mc)
 }
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"k8s.io/klog/v2"
+)
+
+type aiRedactionService struct{}
+
+// Record 记录一次发送给大模型前的脱敏，作为 ai.RedactionRecorder 注册
+func (s *aiRedactionService) Record(ctx context.Context, items []ai.RedactedItem) {
+	username, _ := ctx.Value(constants.JwtUserName).(string)
+
+	counts := map[string]int{}
+	var kinds []string
+	for _, item := range items {
+		if counts[item.Kind] == 0 {
+			kinds = append(kinds, item.Kind)
+		}
+		counts[item.Kind]++
+	}
+	for i, k := range kinds {
+		kinds[i] = fmt.Sprintf("%s:%d", k, counts[k])
+	}
+
+	log := &models.AIRedactionLog{
+		Username: username,
+		Cluster:  ai.ClusterFromContext(ctx),
+		Feature:  string(ai.FeatureFromContext(ctx)),
+		Kinds:    strings.Join(kinds, ","),
+		Count:    len(items),
+		Items:    utils.ToJSON(items),
+	}
+	go func() {
+		if err := dao.DB().Create(log).Error; err != nil {
+			klog.Errorf("保存AI脱敏记录失败: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"k8s.io/klog/v2"
+)
+
+const (
+	AIQuotaTargetUser  = "user"
+	AIQuotaTargetGroup = "group"
+
+	AIQuotaActionReject    = "reject"
+	AIQuotaActionDowngrade = "downgrade"
+
+	// AIQuotaAllUsers 对所有用户生效的默认配额
+	AIQuotaAllUsers = "*"
+)
+
+type aiUsageService struct{}
+
+type downgradedKey struct{}
+
+// QuotaStatus 配额及其当前使用情况
+type QuotaStatus struct {
+	Quota       *models.AIQuota `json:"quota"`
+	DailyUsed   int64           `json:"daily_used"`
+	MonthlyUsed int64           `json:"monthly_used"`
+	Exceeded    bool            `json:"exceeded"`
+}
+
+// UsageStat 按维度聚合后的用量
+type UsageStat struct {
+	Key              string `json:"key"`
+	Requests         int64  `json:"requests"`
+	Failures         int64  `json:"failures"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	AvgLatencyMs     int64  `json:"avg_latency_ms"`
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Record 记录一次大模型请求的用量，作为 ai.UsageRecorder 注册
+func (s *aiUsageService) Record(ctx context.Context, u *ai.Usage) {
+	log := &models.AIUsageLog{
+		Username:         u.Username,
+		Cluster:          u.Cluster,
+		Feature:          string(u.Feature),
+		Provider:         u.Provider,
+		Model:            u.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		LatencyMs:        u.Latency.Milliseconds(),
+		Estimated:        u.Estimated,
+		Success:          u.Err == nil,
+	}
+	if u.Err != nil {
+		log.Error = u.Err.Error()
+	}
+	if downgraded, ok := ctx.Value(downgradedKey{}).(bool); ok {
+		log.Downgraded = downgraded
+	}
+	go func() {
+		if groups, err := UserService().GetGroupNames(log.Username); err == nil {
+			log.GroupNames = strings.Join(groups, ",")
+		}
+		if err := dao.DB().Create(log).Error; err != nil {
+			klog.Errorf("保存AI用量记录失败: %v", err)
+		}
+	}()
+}
+
+// UserTokens 统计用户自 since 起的 Token 用量
+func (s *aiUsageService) UserTokens(username string, since time.Time) (int64, error) {
+	var total int64
+	err := dao.DB().Model(&models.AIUsageLog{}).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Where("username = ? AND created_at >= ?", username, since).
+		Scan(&total).Error
+	return total, err
+}
+
+// UserTokenSummary 统计用户今日、本月的 Token 用量
+func (s *aiUsageService) UserTokenSummary(username string) (daily int64, monthly int64, err error) {
+	now := time.Now()
+	if daily, err = s.UserTokens(username, startOfDay(now)); err != nil {
+		return 0, 0, err
+	}
+	if monthly, err = s.UserTokens(username, startOfMonth(now)); err != nil {
+		return 0, 0, err
+	}
+	return daily, monthly, nil
+}
+
+// GroupTokens 统计用户组自 since 起所有成员的 Token 合计用量
+func (s *aiUsageService) GroupTokens(group string, since time.Time) (int64, error) {
+	var rows []struct {
+		GroupNames string
+		Total      int64
+	}
+	err := dao.DB().Model(&models.AIUsageLog{}).
+		Select("group_names, COALESCE(SUM(total_tokens), 0) AS total").
+		Where("created_at >= ? AND group_names LIKE ?", since, "%"+group+"%").
+		Group("group_names").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, r := range rows {
+		if slices.Contains(strings.Split(r.GroupNames, ","), group) {
+			total += r.Total
+		}
+	}
+	return total, nil
+}
+
+// applicableQuotas 获取对用户生效的配额：用户专属配额优先于 * 默认配额，另加所在用户组的配额
+func (s *aiUsageService) applicableQuotas(username string) ([]*models.AIQuota, error) {
+	groups, err := UserService().GetGroupNames(username)
+	if err != nil {
+		groups = nil
+	}
+	var quotas []*models.AIQuota
+	err = dao.DB().Where("enabled = ?", true).
+		Where("(target_type = ? AND target_name IN ?) OR (target_type = ? AND target_name IN ?)",
+			AIQuotaTargetUser, []string{username, AIQuotaAllUsers},
+			AIQuotaTargetGroup, append(groups, "")).
+		Find(&quotas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hasUserQuota := slices.ContainsFunc(quotas, func(q *models.AIQuota) bool {
+		return q.TargetType == AIQuotaTargetUser && q.TargetName == username
+	})
+	if hasUserQuota {
+		quotas = slices.DeleteFunc(quotas, func(q *models.AIQuota) bool {
+			return q.TargetType == AIQuotaTargetUser && q.TargetName == AIQuotaAllUsers
+		})
+	}
+	return quotas, nil
+}
+
+// QuotaStatus 获取用户各项生效配额的使用情况
+func (s *aiUsageService) QuotaStatus(username string) ([]*QuotaStatus, error) {
+	quotas, err := s.applicableQuotas(username)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var result []*QuotaStatus
+	for _, q := range quotas {
+		st := &QuotaStatus{Quota: q}
+		if q.TargetType == AIQuotaTargetGroup {
+			st.DailyUsed, err = s.GroupTokens(q.TargetName, startOfDay(now))
+			if err == nil {
+				st.MonthlyUsed, err = s.GroupTokens(q.TargetName, startOfMonth(now))
+			}
+		} else {
+			st.DailyUsed, err = s.UserTokens(username, startOfDay(now))
+			if err == nil {
+				st.MonthlyUsed, err = s.UserTokens(username, startOfMonth(now))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		st.Exceeded = (q.DailyTokens > 0 && st.DailyUsed >= q.DailyTokens) ||
+			(q.MonthlyTokens > 0 && st.MonthlyUsed >= q.MonthlyTokens)
+		result = append(result, st)
+	}
+	return result, nil
+}
+
+// CheckQuota 检查用户是否超出配额
+// 返回降级模型ID（0 表示不降级）；超出配额且处理方式为拒绝时返回错误
+func (s *aiUsageService) CheckQuota(username string) (uint, error) {
+	if username == "" {
+		return 0, nil
+	}
+	statuses, err := s.QuotaStatus(username)
+	if err != nil {
+		klog.Errorf("查询用户[%s]AI配额失败: %v", username, err)
+		// 默认放行，配置为拒绝时返回错误
+		if cfg, cfgErr := ConfigService().GetConfig(); cfgErr != nil || cfg.AIQuotaFailClosed {
+			return 0, fmt.Errorf("查询AI配额失败，请稍后重试")
+		}
+		return 0, nil
+	}
+	var downgradeModelID uint
+	for _, st := range statuses {
+		if !st.Exceeded {
+			continue
+		}
+		if st.Quota.Action == AIQuotaActionDowngrade && st.Quota.DowngradeModelID > 0 {
+			downgradeModelID = st.Quota.DowngradeModelID
+			continue
+		}
+		return 0, fmt.Errorf("AI用量已超出配额[%s:%s]，今日已用 %d，本月已用 %d Token",
+			st.Quota.TargetType, st.Quota.TargetName, st.DailyUsed, st.MonthlyUsed)
+	}
+	return downgradeModelID, nil
+}
+
+// Stats 按维度聚合 [start, end) 区间内的用量
+// dimension 可选 user、group、cluster、feature、model
+func (s *aiUsageService) Stats(dimension string, start, end time.Time) ([]*UsageStat, error) {
+	column := map[string]string{
+		"user":    "username",
+		"group":   "group_names",
+		"cluster": "cluster",
+		"feature": "feature",
+		"model":   "model",
+	}[dimension]
+	if column == "" {
+		return nil, fmt.Errorf("不支持的统计维度: %s", dimension)
+	}
+
+	var rows []struct {
+		StatKey          string
+		Requests         int64
+		Failures         int64
+		PromptTokens     int64
+		CompletionTokens int64
+		TotalTokens      int64
+		LatencyMs        int64
+	}
+	err := dao.DB().Model(&models.AIUsageLog{}).
+		Select(column+" AS stat_key, COUNT(*) AS requests, "+
+			"SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failures, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, "+
+			"COALESCE(SUM(total_tokens), 0) AS total_tokens, "+
+			"COALESCE(SUM(latency_ms), 0) AS latency_ms").
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]*UsageStat{}
+	for _, r := range rows {
+		keys := []string{r.StatKey}
+		// 用户组按成员所在的每个组分别累计
+		if dimension == "group" {
+			keys = strings.Split(r.StatKey, ",")
+		}
+		for _, k := range keys {
+			st, ok := stats[k]
+			if !ok {
+				st = &UsageStat{Key: k}
+				stats[k] = st
+			}
+			st.Requests += r.Requests
+			st.Failures += r.Failures
+			st.PromptTokens += r.PromptTokens
+			st.CompletionTokens += r.CompletionTokens
+			st.TotalTokens += r.TotalTokens
+			st.AvgLatencyMs += r.LatencyMs
+		}
+	}
+
+	result := make([]*UsageStat, 0, len(stats))
+	for _, st := range stats {
+		if st.Requests > 0 {
+			st.AvgLatencyMs = st.AvgLatencyMs / st.Requests
+		}
+		result = append(result, st)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TotalTokens > result[j].TotalTokens
+	})
+	return result, nil
+}
+
+// withUsageContext 标记功能场景，并根据配额决定使用的客户端
+func (s *aiUsageService) withUsageContext(ctx context.Context, feature constants.AIFeature) (context.Context, ai.IAI, error) {
+	ctx = ai.WithFeature(ctx, feature)
+	username, _ := ctx.Value(constants.JwtUserName).(string)
+	downgradeModelID, err := s.CheckQuota(username)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if downgradeModelID > 0 {
+		client, err := AIService().ClientForModel(downgradeModelID)
+		if err == nil {
+			klog.V(6).Infof("用户[%s]AI用量超出配额，降级使用模型[%d]", username, downgradeModelID)
+			return context.WithValue(ctx, downgradedKey{}, true), client, nil
+		}
+		klog.Errorf("获取降级模型[%d]失败，使用原模型: %v", downgradeModelID, err)
+	}
+	client, err := AIService().ClientForFeature(feature)
+	return ctx, client, err
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// apiKeyUsageFlushInterval 使用次数批量写库的间隔
+const apiKeyUsageFlushInterval = 30 * time.Second
+
+// apiKeyCacheTTL API密钥及吊销状态的缓存时间，多实例部署时吊销在该时间内于其他实例生效
+const apiKeyCacheTTL = 10 * time.Second
+
+// revokedCleanupInterval 两次清理过期吊销记录的最小间隔
+const revokedCleanupInterval = time.Hour
+
+// KubeProxyPathPrefix Kubernetes API 代理路径，只读及作用范围由代理按解析出的请求通过 CheckKubeScope 校验
+const KubeProxyPathPrefix = "/k8s/proxy/"
+
+type apiKeyService struct {
+	revokedMu      sync.Mutex
+	revokedCleaned time.Time
+
+	usageOnce sync.Once
+	usageMu   sync.Mutex
+	usage     map[uint]*apiKeyUsage
+}
+
+type apiKeyUsage struct {
+	count  int64
+	lastAt time.Time
+	lastIP string
+}
+
+// GenerateToken 为API密钥生成 Token，Token 中携带密钥ID，每次请求据此校验作用范围
+func (s *apiKeyService) GenerateToken(key *models.ApiKey) (string, error) {
+	if key.Username == "" || key.ID == 0 {
+		return "", fmt.Errorf("API密钥信息不完整")
+	}
+	claims := jwt.MapClaims{
+		constants.JwtUserName: key.Username,
+		constants.JwtApiKeyID: key.ID,
+		"isPlatformAdmin":     UserService().IsUserPlatformAdmin(key.Username),
+		"iat":                 time.Now().Unix(),
+	}
+	if key.ExpiresAt != nil {
+		claims["exp"] = key.ExpiresAt.Unix()
+	}
+	return JwtKeyService().Sign(claims)
+}
+
+// Revoke 吊销API密钥，立即生效
+func (s *apiKeyService) Revoke(key *models.ApiKey, reason string) error {
+	now := time.Now()
+	err := dao.DB().Model(&models.ApiKey{}).Where("id = ?", key.ID).
+		UpdateColumns(map[string]any{"revoked": true, "revoked_at": now}).Error
+	if err != nil {
+		return err
+	}
+	key.Revoked, key.RevokedAt = true, &now
+	utils.ClearCacheByKey(CacheService().CacheInstance(), s.cacheKey(key.ID))
+
+	// 旧版密钥 Token 中没有密钥ID，只能通过吊销列表拒绝，旧版密钥有效期为一年
+	expiresAt := key.CreatedAt.AddDate(1, 0, 0)
+	if key.ExpiresAt != nil {
+		expiresAt = *key.ExpiresAt
+	}
+	return s.RevokeToken(key.Key, key.Username, reason, expiresAt)
+}
+
+// RevokeToken 将 Token 加入吊销列表，在原过期时间之前均拒绝使用
+func (s *apiKeyService) RevokeToken(token, username, reason string, expiresAt time.Time) error {
+	if token == "" || !expiresAt.After(time.Now()) {
+		return nil
+	}
+	hash := tokenHash(token)
+	item := &models.RevokedToken{TokenHash: hash, Username: username, Reason: reason, ExpiresAt: expiresAt}
+	if err := dao.DB().Where("token_hash = ?", hash).FirstOrCreate(item).Error; err != nil {
+		return err
+	}
+	utils.ClearCacheByKey(CacheService().CacheInstance(), s.revokedCacheKey(hash))
+	return nil
+}
+
+// IsTokenRevoked 判断 Token 是否已被吊销
+// 吊销记录保存在数据库中，查询结果短暂缓存，其他实例吊销的 Token 在缓存过期后即被拒绝
+func (s *apiKeyService) IsTokenRevoked(token string) bool {
+	if token == "" {
+		return false
+	}
+	s.cleanupRevoked()
+	hash := tokenHash(token)
+	revoked, err := utils.GetOrSetCache(CacheService().CacheInstance(), s.revokedCacheKey(hash), apiKeyCacheTTL, func() (bool, error) {
+		var count int64
+		err := dao.DB().Model(&models.RevokedToken{}).
+			Where("token_hash = ? AND expires_at >= ?", hash, time.Now()).
+			Count(&count).Error
+		return count > 0, err
+	})
+	if err != nil {
+		// 无法确认时拒绝使用
+		klog.Errorf("查询Token吊销状态失败: %v", err)
+		return true
+	}
+	return revoked
+}
+
+func (s *apiKeyService) revokedCacheKey(hash string) string {
+	return "apikey:revoked:" + hash
+}
+
+// cleanupRevoked 定期清理已过期的吊销记录，过期的 Token 本身已无法通过校验
+func (s *apiKeyService) cleanupRevoked() {
+	s.revokedMu.Lock()
+	if time.Since(s.revokedCleaned) < revokedCleanupInterval {
+		s.revokedMu.Unlock()
+		return
+	}
+	s.revokedCleaned = time.Now()
+	s.revokedMu.Unlock()
+	if err := dao.DB().Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		klog.Warningf("清理过期吊销记录失败: %v", err)
+	}
+}
+
+// Authorize 校验API密钥的有效期、来源IP、只读及集群/命名空间范围，并记录使用次数
+func (s *apiKeyService) Authorize(c *gin.Context, keyID uint, username string) error {
+	key, err := s.getKey(keyID)
+	if err != nil {
+		return fmt.Errorf("API密钥不存在或已删除")
+	}
+	if key.Username != username {
+		return fmt.Errorf("API密钥与用户不匹配")
+	}
+	if key.Revoked {
+		return fmt.Errorf("API密钥已吊销")
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("API密钥已过期")
+	}
+	ip := c.ClientIP()
+	if !ipAllowed(ip, splitList(key.AllowedIPs)) {
+		return fmt.Errorf("来源IP %s 不在API密钥白名单内", ip)
+	}
+	// API密钥不能用于管理API密钥及下载 kubeconfig，避免低权限密钥自行签发新凭据
+	if strings.HasPrefix(c.Request.URL.Path, "/mgm/user/profile/api_keys") {
+		return fmt.Errorf("不允许使用API密钥管理API密钥")
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/mgm/user/profile/kubeconfig") {
+		return fmt.Errorf("不允许使用API密钥下载 kubeconfig")
+	}
+	if strings.HasPrefix(c.FullPath(), KubeProxyPathPrefix) {
+		s.recordUsage(key.ID, ip)
+		return nil
+	}
+	if key.ReadOnly && !isReadOnlyRequest(c.Request.Method, c.FullPath()) {
+		return fmt.Errorf("只读API密钥不允许执行该操作")
+	}
+	if err := checkClusterScope(c, splitList(key.Clusters), splitList(key.Namespaces)); err != nil {
+		return err
+	}
+	s.recordUsage(key.ID, ip)
+	return nil
+}
+
+// CheckKubeScope 校验经 Kubernetes API 代理的请求是否在API密钥的只读及集群、命名空间范围内
+// 限制了命名空间时，除 /api、/version 等发现类请求外，只能访问允许的命名空间内的资源
+func (s *apiKeyService) CheckKubeScope(keyID uint, cluster string, info *KubeRequestInfo) error {
+	key, err := s.getKey(keyID)
+	if err != nil {
+		return fmt.Errorf("API密钥不存在或已删除")
+	}
+	if key.ReadOnly && !info.IsReadOnly() {
+		return fmt.Errorf("只读API密钥不允许执行该操作")
+	}
+	if clusters := splitList(key.Clusters); len(clusters) > 0 && !slices.Contains(clusters, cluster) {
+		return fmt.Errorf("API密钥无权访问集群: %s", cluster)
+	}
+	if namespaces := splitList(key.Namespaces); len(namespaces) > 0 && info.IsResourceRequest {
+		if info.Namespace == "" || !slices.Contains(namespaces, info.Namespace) {
+			return fmt.Errorf("API密钥仅允许访问命名空间: %s", strings.Join(namespaces, ","))
+		}
+	}
+	return nil
+}
+
+func (s *apiKeyService) cacheKey(id uint) string {
+	return fmt.Sprintf("apikey:id:%d", id)
+}
+
+func (s *apiKeyService) getKey(id uint) (*models.ApiKey, error) {
+	return utils.GetOrSetCache(CacheService().CacheInstance(), s.cacheKey(id), apiKeyCacheTTL, func() (*models.ApiKey, error) {
+		key := &models.ApiKey{}
+		return key.GetOne(&dao.Params{}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ?", id)
+		})
+	})
+}
+
+// recordUsage 在内存中累计使用次数，定期批量写库
+func (s *apiKeyService) recordUsage(id uint, ip string) {
+	s.usageOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(apiKeyUsageFlushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				s.FlushUsage()
+			}
+		}()
+	})
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	if s.usage == nil {
+		s.usage = map[uint]*apiKeyUsage{}
+	}
+	u, ok := s.usage[id]
+	if !ok {
+		u = &apiKeyUsage{}
+		s.usage[id] = u
+	}
+	u.count++
+	u.lastAt = time.Now()
+	u.lastIP = ip
+}
+
+// FlushUsage 将内存中累计的使用次数写入数据库
+func (s *apiKeyService) FlushUsage() {
+	s.usageMu.Lock()
+	pending := s.usage
+	s.usage = nil
+	s.usageMu.Unlock()
+
+	for id, u := range pending {
+		err := dao.DB().Model(&models.ApiKey{}).Where("id = ?", id).UpdateColumns(map[string]any{
+			"usage_count":  gorm.Expr("usage_count + ?", u.count),
+			"last_used_at": u.lastAt,
+			"last_used_ip": u.lastIP,
+		}).Error
+		if err != nil {
+			klog.Warningf("更新API密钥[%d]使用次数失败: %v", id, err)
+		}
+	}
+}
+
+// readOnlyPostActions 以 POST 方式提交的查询类接口，路由中包含这些路径段时视为只读
+var readOnlyPostActions = []string{"list", "describe"}
+
+// readOnlyDeniedGets 以 GET 方式建立但可执行写操作的交互式接口
+var readOnlyDeniedGets = []string{"xterm", "ws_chatgpt"}
+
+// isReadOnlyRequest 根据请求方法及路由模板判断是否为只读请求
+func isReadOnlyRequest(method, route string) bool {
+	segments := strings.Split(route, "/")
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		for _, seg := range readOnlyDeniedGets {
+			if slices.Contains(segments, seg) {
+				return false
+			}
+		}
+		return true
+	case http.MethodPost:
+		for _, seg := range readOnlyPostActions {
+			if slices.Contains(segments, seg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkClusterScope 校验请求的集群及命名空间是否在允许范围内
+// 限制了命名空间时，集群接口必须指定允许的命名空间，不能跨命名空间或访问集群级资源
+func checkClusterScope(c *gin.Context, clusters, namespaces []string) error {
+	if len(clusters) == 0 && len(namespaces) == 0 {
+		return nil
+	}
+	path := c.FullPath()
+	if !strings.HasPrefix(path, "/k8s/cluster/") {
+		// 管理后台及AI对话可跨集群操作，限定范围的密钥不允许访问
+		if strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/ai/") {
+			return fmt.Errorf("限定集群或命名空间的API密钥不允许访问该接口")
+		}
+		return nil
+	}
+	clusterIDByte, _ := utils.UrlSafeBase64Decode(c.Param("cluster"))
+	clusterID := string(clusterIDByte)
+	if len(clusters) > 0 && !slices.Contains(clusters, clusterID) {
+		return fmt.Errorf("API密钥无权访问集群: %s", clusterID)
+	}
+	if len(namespaces) > 0 {
+		ns := c.Param("ns")
+		if ns == "" || !slices.Contains(namespaces, ns) {
+			return fmt.Errorf("API密钥仅允许访问命名空间: %s", strings.Join(namespaces, ","))
+		}
+	}
+	return nil
+}
+
+// ipAllowed 判断来源IP是否在白名单内，白名单为空时不限制，支持单个IP及CIDR
+func ipAllowed(ip string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, item := range allowed {
+		if strings.Contains(item, "/") {
+			if _, cidr, err := net.ParseCIDR(item); err == nil && cidr.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(item); allowedIP != nil && allowedIP.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateIPList 校验IP白名单格式
+func ValidateIPList(value string) error {
+	for _, item := range splitList(value) {
+		if strings.Contains(item, "/") {
+			if _, _, err := net.ParseCIDR(item); err != nil {
+				return fmt.Errorf("CIDR格式错误: %s", item)
+			}
+			continue
+		}
+		if net.ParseIP(item) == nil {
+			return fmt.Errorf("IP格式错误: %s", item)
+		}
+	}
+	return nil
+}
+
+// splitList 拆分逗号或换行分隔的列表，去除空项
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestIsReadOnlyRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		route  string
+		want   bool
+	}{
+		{"GET", "/k8s/cluster/:cluster/deploy/ns/:ns/name/:name/rollout/history", true},
+		{"POST", "/k8s/cluster/:cluster/:kind/group/:group/version/:version/list/ns/:ns", true},
+		{"POST", "/k8s/cluster/:cluster/:kind/group/:group/version/:version/describe/ns/:ns/name/:name", true},
+		{"POST", "/k8s/cluster/:cluster/:kind/group/:group/version/:version/remove/ns/:ns/name/:name", false},
+		{"POST", "/k8s/cluster/:cluster/deploy/ns/:ns/name/:name/restart", false},
+		{"GET", "/k8s/cluster/:cluster/pod/xterm/ns/:ns/pod_name/:pod_name", false},
+		{"GET", "/ai/chat/ws_chatgpt", false},
+		{"DELETE", "/k8s/cluster/:cluster/file/list", false},
+	}
+	for _, tt := range tests {
+		if got := isReadOnlyRequest(tt.method, tt.route); got != tt.want {
+			t.Errorf("%s %s 期望 %v，实际 %v", tt.method, tt.route, tt.want, got)
+		}
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      string
+		allowed string
+		want    bool
+	}{
+		{"未配置白名单", "1.2.3.4", "", true},
+		{"单个IP匹配", "10.0.0.8", "10.0.0.8", true},
+		{"CIDR匹配", "10.0.3.8", "192.168.0.1, 10.0.0.0/16", true},
+		{"不在白名单", "10.1.0.8", "10.0.0.0/16", false},
+		{"IPv6", "fd00::1", "fd00::/8", true},
+		{"非法来源IP", "unknown", "10.0.0.0/8", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ipAllowed(tt.ip, splitList(tt.allowed)); got != tt.want {
+				t.Errorf("期望 %v，实际 %v", tt.want, got)
+			}
+		})
+	}
+	if err := ValidateIPList("10.0.0.1,10.0.0.0/33"); err == nil {
+		t.Errorf("非法CIDR应报错")
+	}
+}
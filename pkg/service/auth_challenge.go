@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/models"
+	"k8s.io/klog/v2"
+)
+
+// ErrAuthChallengeInvalid 认证请求不存在、已使用或已过期
+var ErrAuthChallengeInvalid = errors.New("认证请求不存在或已过期")
+
+// authChallengeService 保存一次性认证请求，多实例部署时发起与回调可落在不同实例
+type authChallengeService struct{}
+
+// Create 保存认证请求，ttl 后过期
+func (s *authChallengeService) Create(challenge *models.AuthChallenge, ttl time.Duration) error {
+	s.cleanup()
+	challenge.ExpiresAt = time.Now().Add(ttl)
+	return dao.DB().Create(challenge).Error
+}
+
+// Consume 一次性消费认证请求，条件删除保证并发时只有一个请求成功
+func (s *authChallengeService) Consume(key string) (*models.AuthChallenge, error) {
+	challenge := &models.AuthChallenge{}
+	if err := dao.DB().Where("challenge_key = ?", key).First(challenge).Error; err != nil {
+		return nil, ErrAuthChallengeInvalid
+	}
+	result := dao.DB().Where("id = ? AND expires_at > ?", challenge.ID, time.Now()).Delete(&models.AuthChallenge{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrAuthChallengeInvalid
+	}
+	return challenge, nil
+}
+
+func (s *authChallengeService) cleanup() {
+	err := dao.DB().Where("expires_at < ?", time.Now()).Delete(&models.AuthChallenge{}).Error
+	if err != nil {
+		klog.V(6).Infof("清理过期的认证请求失败: %v", err)
+	}
+}
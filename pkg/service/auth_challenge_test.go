@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestAuthChallengeConsume(t *testing.T) {
+	s := AuthChallengeService()
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	if err := s.Create(&models.AuthChallenge{ChallengeKey: key, Username: "alice"}, time.Minute); err != nil {
+		t.Fatalf("保存认证请求失败: %v", err)
+	}
+	challenge, err := s.Consume(key)
+	if err != nil || challenge.Username != "alice" {
+		t.Fatalf("首次消费应成功，实际 %v %v", challenge, err)
+	}
+	if _, err := s.Consume(key); !errors.Is(err, ErrAuthChallengeInvalid) {
+		t.Errorf("重复消费应失败，实际 %v", err)
+	}
+
+	expired := key + ":expired"
+	if err := s.Create(&models.AuthChallenge{ChallengeKey: expired}, -time.Second); err != nil {
+		t.Fatalf("保存认证请求失败: %v", err)
+	}
+	if _, err := s.Consume(expired); !errors.Is(err, ErrAuthChallengeInvalid) {
+		t.Errorf("过期的认证请求不应被消费，实际 %v", err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/sashabaranov/go-openai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/comm/utils/amis"
+	"github.com/weibaohui/k8m/pkg/constants"
 	"github.com/weibaohui/k8m/pkg/flag"
 	"k8s.io/klog/v2"
 )
@@ -19,9 +20,9 @@ import (
 type chatService struct{}
 
 // getChatStreamBase 是 GetChatStream 和 GetChatStreamWithoutHistory 的通用实现，支持可选的历史清理
-// 参数 clearHistory 表示是否在请求前后都清空历史
-func (c *chatService) getChatStreamBase(ctx context.Context, chat string, clearHistory bool) (*openai.ChatCompletionStream, error) {
-	client, err := AIService().DefaultClient()
+// 参数 feature 表示功能场景，用于选择对应的模型；clearHistory 表示是否在请求前后都清空历史
+func (c *chatService) getChatStreamBase(ctx context.Context, feature constants.AIFeature, chat string, clearHistory bool) (*openai.ChatCompletionStream, error) {
+	ctx, client, err := AIUsageService().withUsageContext(ctx, feature)
 	if err != nil {
 		klog.V(6).Infof("获取AI服务错误 : %v\n", err)
 		return nil, fmt.Errorf("获取AI服务错误 : %v", err)
@@ -45,6 +46,7 @@ func (c *chatService) getChatStreamBase(ctx context.Context, chat string, clearH
 	tools := McpService().GetAllEnabledTools()
 	klog.V(6).Infof("GPTShell 对话携带tools %d", len(tools))
 	client.SetTools(tools)
+	chat = KnowledgeService().AugmentPrompt(ctx, chat)
 	stream, err := client.GetStreamCompletionWithTools(ctx, chat)
 	if err != nil {
 		klog.V(6).Infof("ChatCompletion error: %v\n", err)
@@ -52,18 +54,18 @@ func (c *chatService) getChatStreamBase(ctx context.Context, chat string, clearH
 	}
 	return stream, nil
 }
-func (c *chatService) GetChatStream(ctx context.Context, chat string) (*openai.ChatCompletionStream, error) {
+func (c *chatService) GetChatStream(ctx context.Context, feature constants.AIFeature, chat string) (*openai.ChatCompletionStream, error) {
 	// 仅复用基础方法，不清理历史
-	return c.getChatStreamBase(ctx, chat, false)
+	return c.getChatStreamBase(ctx, feature, chat, false)
 }
-func (c *chatService) GetChatStreamWithoutHistory(ctx context.Context, chat string) (*openai.ChatCompletionStream, error) {
+func (c *chatService) GetChatStreamWithoutHistory(ctx context.Context, feature constants.AIFeature, chat string) (*openai.ChatCompletionStream, error) {
 	// 复用基础方法，前后都清理历史
-	return c.getChatStreamBase(ctx, chat, true)
+	return c.getChatStreamBase(ctx, feature, chat, true)
 }
 func (c *chatService) RunOneRound(ctx context.Context, chat string, writer io.Writer) error {
 	cfg := flag.Init()
 
-	client, err := AIService().DefaultClient()
+	ctx, client, err := AIUsageService().withUsageContext(ctx, constants.AIFeatureChat)
 
 	if err != nil {
 		klog.V(6).Infof("获取AI服务错误 : %v\n", err)
@@ -79,7 +81,7 @@ func (c *chatService) RunOneRound(ctx context.Context, chat string, writer io.Wr
 	var currChatContent []any
 
 	// Set the initial message to start the conversation
-	currChatContent = append(currChatContent, chat)
+	currChatContent = append(currChatContent, KnowledgeService().AugmentPrompt(ctx, chat))
 
 	currentIteration := int32(0)
 	maxIterations := cfg.MaxIterations
@@ -147,6 +149,10 @@ func (c *chatService) RunOneRound(ctx context.Context, chat string, writer io.Wr
 
 			}
 
+			// 开启用量统计后，最后一个 chunk 只包含用量信息
+			if len(response.Choices) == 0 {
+				continue
+			}
 			// 发送数据给客户端
 			// 写入outBuffer
 			content := response.Choices[0].Delta.Content
@@ -176,15 +182,15 @@ func (c *chatService) RunOneRound(ctx context.Context, chat string, writer io.Wr
 }
 func (c *chatService) Chat(ctx *gin.Context, chat string) string {
 	ctxInst := amis.GetContextWithUser(ctx)
-	result, err := c.ChatWithCtx(ctxInst, chat)
+	result, err := c.ChatWithCtx(ctxInst, constants.AIFeatureChat, chat)
 	if err != nil {
 		klog.V(2).Infof("ChatWithCtx error: %v\n", err)
 		return ""
 	}
 	return result
 }
-func (c *chatService) ChatWithCtxNoHistory(ctx context.Context, chat string) (string, error) {
-	client, err := AIService().DefaultClient()
+func (c *chatService) ChatWithCtxNoHistory(ctx context.Context, feature constants.AIFeature, chat string) (string, error) {
+	ctx, client, err := AIUsageService().withUsageContext(ctx, feature)
 
 	if err != nil {
 		klog.V(2).Infof("获取AI服务错误 : %v\n", err)
@@ -201,8 +207,8 @@ func (c *chatService) ChatWithCtxNoHistory(ctx context.Context, chat string) (st
 	}
 	return result, nil
 }
-func (c *chatService) ChatWithCtx(ctx context.Context, chat string) (string, error) {
-	client, err := AIService().DefaultClient()
+func (c *chatService) ChatWithCtx(ctx context.Context, feature constants.AIFeature, chat string) (string, error) {
+	ctx, client, err := AIUsageService().withUsageContext(ctx, feature)
 
 	if err != nil {
 		klog.V(2).Infof("获取AI服务错误 : %v\n", err)
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/agent"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/flag"
+	"github.com/weibaohui/k8m/pkg/models"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// clusterAgentTokenPrefix Agent Token 前缀，便于识别
+	clusterAgentTokenPrefix = "k8ma_"
+	// clusterAgentImage Agent 使用 k8m 镜像中的 /app/k8m-agent
+	clusterAgentImage = "registry.cn-hangzhou.aliyuncs.com/minik8m/k8m"
+)
+
+// clusterAgentNamePattern Agent 名称，同时作为集群ID的一部分
+var clusterAgentNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,61}[a-z0-9])?$`)
+
+// ClusterConfigSourceAgent 通过 Agent 反向隧道接入的集群，集群ID为 Agent/名称
+var ClusterConfigSourceAgent ClusterConfigSource = "Agent"
+
+// clusterAgentService 管理 Agent 反向隧道。
+// 隧道只存在于接受 Agent 连接的实例进程内，其他实例无法经该隧道访问集群，
+// 因此启用 Agent 接入时须单副本部署，或通过会话亲和将 /agent/ 与集群请求固定到同一实例。
+type clusterAgentService struct {
+	mu      sync.Mutex
+	tunnels sync.Map // Agent ID -> *agentTunnel，仅本实例持有
+}
+
+// agentTunnel k8m 侧的隧道入口，在本机回环地址监听，每个连接经 yamux 打开一个流转发给 Agent
+// 监听地址及密钥在进程内保持不变，Agent 重连后集群的 rest.Config 仍然有效
+type agentTunnel struct {
+	listener net.Listener
+	secret   string
+	mu       sync.Mutex
+	session  *yamux.Session
+}
+
+// CreateAgent 创建 Agent，Token 仅在创建时返回一次
+func (s *clusterAgentService) CreateAgent(name, description, createdBy string) (*models.ClusterAgent, string, error) {
+	if !clusterAgentNamePattern.MatchString(name) {
+		return nil, "", fmt.Errorf("名称只能包含小写字母、数字、- 和 .，且以字母或数字开头结尾")
+	}
+	random, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	token := clusterAgentTokenPrefix + random
+	item := &models.ClusterAgent{
+		Name:        name,
+		Description: description,
+		TokenHash:   tokenHash(token),
+		TokenPrefix: token[:len(clusterAgentTokenPrefix)+4],
+		CreatedBy:   createdBy,
+	}
+	if err := dao.DB().Create(item).Error; err != nil {
+		return nil, "", fmt.Errorf("创建 Agent 失败，名称可能已存在: %w", err)
+	}
+	ClusterService().AddToClusterList(s.clusterConfig(item))
+	return item, token, nil
+}
+
+// Authenticate 校验 Agent Token
+func (s *clusterAgentService) Authenticate(token string) (*models.ClusterAgent, error) {
+	if !strings.HasPrefix(token, clusterAgentTokenPrefix) {
+		return nil, fmt.Errorf("无效的 Agent Token")
+	}
+	item := &models.ClusterAgent{}
+	if err := dao.DB().Where("token_hash = ?", tokenHash(token)).First(item).Error; err != nil {
+		return nil, fmt.Errorf("无效的 Agent Token")
+	}
+	return item, nil
+}
+
+// TunnelSecret 获取 Agent 的隧道密钥，握手时下发给 Agent
+func (s *clusterAgentService) TunnelSecret(item *models.ClusterAgent) (string, error) {
+	t, err := s.tunnel(item.ID)
+	if err != nil {
+		return "", err
+	}
+	return t.secret, nil
+}
+
+// Attach 使用 Agent 的 websocket 连接建立隧道并连接集群，阻塞至隧道断开
+// 同一 Agent 重复连接时以最新的连接为准
+func (s *clusterAgentService) Attach(item *models.ClusterAgent, ws *websocket.Conn, clientIP, version, apiServer string) error {
+	t, err := s.tunnel(item.ID)
+	if err != nil {
+		return err
+	}
+	session, err := yamux.Client(agent.NewConn(ws), nil)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	old := t.session
+	t.session = session
+	t.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+
+	now := time.Now()
+	err = dao.DB().Model(&models.ClusterAgent{}).Where("id = ?", item.ID).UpdateColumns(map[string]any{
+		"last_connected_at": now,
+		"last_ip":           clientIP,
+		"agent_version":     version,
+		"api_server":        apiServer,
+	}).Error
+	if err != nil {
+		klog.Warningf("更新 Agent[%s]连接信息失败: %v", item.Name, err)
+	}
+	cc := s.clusterConfig(item)
+	ClusterService().AddToClusterList(cc)
+	clusterID := cc.GetClusterID()
+	if existing := ClusterService().GetClusterByID(clusterID); existing != nil {
+		existing.Server = apiServer
+	}
+	klog.V(2).Infof("Agent[%s]已连接，来源IP %s，版本 %s", item.Name, clientIP, version)
+	ClusterService().Connect(clusterID)
+
+	<-session.CloseChan()
+
+	t.mu.Lock()
+	replaced := t.session != session
+	if !replaced {
+		t.session = nil
+	}
+	t.mu.Unlock()
+	if replaced {
+		return nil
+	}
+	klog.V(2).Infof("Agent[%s]已断开", item.Name)
+	_ = dao.DB().Model(&models.ClusterAgent{}).Where("id = ?", item.ID).
+		UpdateColumn("last_disconnected_at", time.Now()).Error
+	ClusterService().disconnect(clusterID, ClusterHealthReasonAgent)
+	return nil
+}
+
+// IsConnected Agent 隧道是否在线
+func (s *clusterAgentService) IsConnected(id uint) bool {
+	v, ok := s.tunnels.Load(id)
+	if !ok {
+		return false
+	}
+	t := v.(*agentTunnel)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.session != nil && !t.session.IsClosed()
+}
+
+// Delete 删除 Agent，断开隧道并从集群列表移除
+func (s *clusterAgentService) Delete(item *models.ClusterAgent) error {
+	if err := dao.DB().Delete(item).Error; err != nil {
+		return err
+	}
+	if v, ok := s.tunnels.LoadAndDelete(item.ID); ok {
+		t := v.(*agentTunnel)
+		t.mu.Lock()
+		if t.session != nil {
+			_ = t.session.Close()
+		}
+		t.mu.Unlock()
+		_ = t.listener.Close()
+	}
+	s.ScanClusters()
+	return nil
+}
+
+// ScanClusters 将数据库中的 Agent 加入集群列表，已删除的 Agent 断开并从集群列表移除
+func (s *clusterAgentService) ScanClusters() {
+	var list []*models.ClusterAgent
+	if err := dao.DB().Find(&list).Error; err != nil {
+		klog.Errorf("查询集群 Agent 失败: %v", err)
+		return
+	}
+	ids := make(map[uint]bool, len(list))
+	for _, item := range list {
+		ids[item.ID] = true
+		ClusterService().AddToClusterList(s.clusterConfig(item))
+	}
+	removed := ClusterService().RemoveFromClusterList(func(cc *ClusterConfig) bool {
+		return cc.Source == ClusterConfigSourceAgent && !ids[cc.AgentID]
+	})
+	for _, cc := range removed {
+		ClusterService().Disconnect(cc.GetClusterID())
+	}
+}
+
+// Manifest 生成在被纳管集群中部署 Agent 的 YAML
+func (s *clusterAgentService) Manifest(server, token string) string {
+	tag := flag.Init().Version
+	if tag == "" || tag == "dev" {
+		tag = "latest"
+	}
+	return fmt.Sprintf(clusterAgentManifest, token, clusterAgentImage+":"+tag, strings.TrimRight(server, "/"))
+}
+
+// restConfig Agent 集群经本机隧道入口访问，隧道密钥作为 BearerToken 由 Agent 校验
+func (s *clusterAgentService) restConfig(cc *ClusterConfig) (*rest.Config, error) {
+	if !s.IsConnected(cc.AgentID) {
+		return nil, fmt.Errorf("Agent[%s]未连接", cc.ContextName)
+	}
+	v, _ := s.tunnels.Load(cc.AgentID)
+	t := v.(*agentTunnel)
+	return &rest.Config{
+		Host:        "http://" + t.listener.Addr().String(),
+		BearerToken: t.secret,
+	}, nil
+}
+
+func (s *clusterAgentService) clusterConfig(item *models.ClusterAgent) *ClusterConfig {
+	return &ClusterConfig{
+		FileName:             string(ClusterConfigSourceAgent),
+		ContextName:          item.Name,
+		ClusterName:          item.Name,
+		Server:               item.APIServer,
+		ClusterConnectStatus: constants.ClusterConnectStatusDisconnected,
+		Source:               ClusterConfigSourceAgent,
+		AgentID:              item.ID,
+	}
+}
+
+// tunnel 获取 Agent 的隧道入口，不存在时创建
+func (s *clusterAgentService) tunnel(id uint) (*agentTunnel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.tunnels.Load(id); ok {
+		return v.(*agentTunnel), nil
+	}
+	secret, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("创建隧道入口失败: %w", err)
+	}
+	t := &agentTunnel{listener: listener, secret: secret}
+	s.tunnels.Store(id, t)
+	go t.serve()
+	return t, nil
+}
+
+func (t *agentTunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.pipe(conn)
+	}
+}
+
+// pipe 为每个连接打开一个 yamux 流，双向转发直到任一方向结束
+func (t *agentTunnel) pipe(conn net.Conn) {
+	defer conn.Close()
+	t.mu.Lock()
+	session := t.session
+	t.mu.Unlock()
+	if session == nil || session.IsClosed() {
+		return
+	}
+	stream, err := session.Open()
+	if err != nil {
+		klog.V(4).Infof("打开隧道流失败: %v", err)
+		return
+	}
+	defer stream.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(stream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, stream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// clusterAgentManifest Agent 部署文件，与 deploy/k8m-agent.yaml 保持一致
+// Agent 使用 cluster-admin 权限，实际访问权限由 k8m 的集群授权控制
+const clusterAgentManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: k8m-agent
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: k8m-agent
+  namespace: k8m-agent
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: k8m-agent
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+  - kind: ServiceAccount
+    name: k8m-agent
+    namespace: k8m-agent
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: k8m-agent
+  namespace: k8m-agent
+type: Opaque
+stringData:
+  token: "%s"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: k8m-agent
+  namespace: k8m-agent
+  labels:
+    app: k8m-agent
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: k8m-agent
+  template:
+    metadata:
+      labels:
+        app: k8m-agent
+    spec:
+      serviceAccountName: k8m-agent
+      containers:
+        - name: k8m-agent
+          image: %s
+          command: ["/app/k8m-agent"]
+          env:
+            - name: K8M_SERVER
+              value: "%s"
+            - name: K8M_AGENT_TOKEN
+              valueFrom:
+                secretKeyRef:
+                  name: k8m-agent
+                  key: token
+          resources:
+            requests:
+              cpu: 50m
+              memory: 64Mi
+            limits:
+              memory: 256Mi
+`
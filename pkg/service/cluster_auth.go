@@ -0,0 +1,352 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/flag"
+	"github.com/weibaohui/k8m/pkg/models"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	// kubeconfig 中 auth-provider 为 oidc 的用户，由 client-go 使用 refresh-token 刷新 id-token
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+)
+
+// errExecPluginNotAllowed kubeconfig 使用了未在白名单中的 exec 凭据插件
+var errExecPluginNotAllowed = errors.New("exec 凭据插件未在白名单中")
+
+// clusterAuthErrorMarkers client-go 凭据相关错误信息中的关键字，用于区分认证失败与网络故障
+var clusterAuthErrorMarkers = []string{
+	"getting credentials",
+	"exec plugin",
+	"failed to refresh token",
+	"tls: bad certificate",
+	"tls: expired certificate",
+	"tls: unknown certificate",
+	"tls: certificate required",
+	"Unauthorized",
+}
+
+// clusterCredentials 集群 kubeconfig 中内嵌的 Token 及客户端证书，轮换后原地更新，无需重新连接集群
+// Token 经 WrapTransport 在每个请求中读取；客户端证书写入私有临时目录，client-go 在握手时检测到文件变化后重新加载
+type clusterCredentials struct {
+	mu    sync.RWMutex
+	token string
+	dir   string // 客户端证书所在目录，未使用内嵌证书时为空
+}
+
+// clusterAuthPersister oidc 刷新 Token 后回写 kubeconfig，数据库中的集群同时保存到数据库，重启后仍可使用新的 refresh-token
+type clusterAuthPersister struct {
+	cluster *ClusterConfig
+}
+
+// ValidateKubeconfigAuth 校验 kubeconfig 中用户的认证方式，exec 插件须在白名单中，auth-provider 仅支持 oidc
+func ValidateKubeconfigAuth(config *clientcmdapi.Config) error {
+	for name, auth := range config.AuthInfos {
+		if auth.Exec != nil {
+			if err := execPluginAllowed(auth.Exec.Command); err != nil {
+				return fmt.Errorf("用户 %s: %w", name, err)
+			}
+		}
+		if auth.AuthProvider != nil && auth.AuthProvider.Name != "oidc" {
+			return fmt.Errorf("用户 %s: 不支持 auth-provider %s，仅支持 oidc", name, auth.AuthProvider.Name)
+		}
+	}
+	return nil
+}
+
+// execPluginAllowed 校验 exec 凭据插件是否在白名单中
+// 白名单中的命令名仅匹配在 PATH 中查找的同名命令，绝对路径匹配解析后的实际路径
+func execPluginAllowed(command string) error {
+	var allowlist []string
+	for _, item := range strings.FieldsFunc(flag.Init().ExecPluginAllowlist, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			allowlist = append(allowlist, item)
+		}
+	}
+	resolved := command
+	if path, err := exec.LookPath(command); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			resolved = abs
+		}
+	}
+	for _, item := range allowlist {
+		if strings.Contains(item, "/") {
+			if filepath.Clean(item) == resolved {
+				return nil
+			}
+		} else if item == command {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s，请在 平台设置-参数设置-集群配置 中添加", errExecPluginNotAllowed, command)
+}
+
+// classifyClusterError 区分认证失败与网络故障，便于在心跳状态中给出不同的处理建议
+func classifyClusterError(err error) constants.ClusterErrorType {
+	if err == nil {
+		return ""
+	}
+	if apierrors.IsUnauthorized(err) || apierrors.IsForbidden(err) || errors.Is(err, errExecPluginNotAllowed) {
+		return constants.ClusterErrorTypeAuth
+	}
+	msg := err.Error()
+	for _, marker := range clusterAuthErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return constants.ClusterErrorTypeAuth
+		}
+	}
+	return constants.ClusterErrorTypeNetwork
+}
+
+// kubeconfigRestConfig 按 context 名称生成 rest.Config
+func kubeconfigRestConfig(content []byte, contextName string) (*rest.Config, error) {
+	config, err := clientcmd.Load(content)
+	if err != nil {
+		return nil, err
+	}
+	return clientcmd.NewNonInteractiveClientConfig(*config, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+}
+
+// applyClusterAuth 校验 exec 插件白名单、设置 oidc 刷新回写，并将内嵌的静态凭据改为可原地更新
+func (c *clusterService) applyClusterAuth(cc *ClusterConfig, restConfig *rest.Config) error {
+	if restConfig.ExecProvider != nil {
+		if err := execPluginAllowed(restConfig.ExecProvider.Command); err != nil {
+			return err
+		}
+		// k8m 在后台运行，插件不能等待用户输入
+		restConfig.ExecProvider.InteractiveMode = clientcmdapi.NeverExecInteractiveMode
+	}
+	if restConfig.AuthProvider != nil {
+		restConfig.AuthConfigPersister = &clusterAuthPersister{cluster: cc}
+	}
+
+	cc.closeCredentials()
+	cred := &clusterCredentials{}
+	if len(restConfig.CertData) > 0 && len(restConfig.KeyData) > 0 && restConfig.CertFile == "" && restConfig.KeyFile == "" {
+		dir, err := os.MkdirTemp("", "k8m-cluster-cert-*")
+		if err != nil {
+			return fmt.Errorf("创建客户端证书目录失败: %w", err)
+		}
+		cred.dir = dir
+		if err := cred.writeCert(restConfig.CertData, restConfig.KeyData); err != nil {
+			_ = os.RemoveAll(dir)
+			return err
+		}
+		restConfig.CertFile, restConfig.KeyFile = cred.certFile(), cred.keyFile()
+		restConfig.CertData, restConfig.KeyData = nil, nil
+	}
+	if restConfig.BearerTokenFile == "" {
+		cred.token = restConfig.BearerToken
+		restConfig.BearerToken = ""
+		restConfig.Wrap(cred.wrap)
+	}
+	cc.credentials = cred
+	return nil
+}
+
+// reloadKubeconfig kubeconfig 内容变化后，仅 Token、客户端证书变化时原地更新，其他配置变化时重新连接
+func (c *clusterService) reloadKubeconfig(cc *ClusterConfig, content []byte) {
+	old, changed := cc.setKubeConfig(content)
+	if !changed {
+		return
+	}
+	clusterID := cc.GetClusterID()
+	if cc.ClusterConnectStatus != constants.ClusterConnectStatusConnected {
+		return
+	}
+	if cc.credentials != nil {
+		oldConfig, oldErr := kubeconfigRestConfig(old, cc.ContextName)
+		newConfig, newErr := kubeconfigRestConfig(content, cc.ContextName)
+		if oldErr == nil && newErr == nil && onlyCredentialsChanged(oldConfig, newConfig) && cc.credentials.canUpdate(newConfig) {
+			if err := cc.credentials.update(newConfig); err == nil {
+				klog.V(2).Infof("集群 %s 凭据已轮换，原地更新", clusterID)
+				return
+			} else {
+				klog.Warningf("集群 %s 更新凭据失败，重新连接: %v", clusterID, err)
+			}
+		}
+	}
+	klog.V(2).Infof("集群 %s kubeconfig 已变化，重新连接", clusterID)
+	go func() {
+		// Connect 跳过已连接的集群，先清理连接资源
+		c.disconnectWithOption(clusterID, false)
+		c.Connect(clusterID)
+	}()
+}
+
+// reloadKubeconfigFile 文件来源的集群，kubeconfig 文件修改后重新加载
+func (c *clusterService) reloadKubeconfigFile(cc *ClusterConfig) {
+	if cc.Source != ClusterConfigSourceFile || cc.sourcePath == "" {
+		return
+	}
+	info, err := os.Stat(cc.sourcePath)
+	if err != nil || info.ModTime().Equal(cc.sourceModTime) {
+		return
+	}
+	content, err := os.ReadFile(cc.sourcePath)
+	if err != nil {
+		klog.V(4).Infof("读取集群 %s 的 kubeconfig 文件失败: %v", cc.GetClusterID(), err)
+		return
+	}
+	cc.sourceModTime = info.ModTime()
+	c.reloadKubeconfig(cc, content)
+}
+
+// onlyCredentialsChanged 除 Token、客户端证书外的连接配置均未变化
+func onlyCredentialsChanged(a, b *rest.Config) bool {
+	return a.Host == b.Host &&
+		a.APIPath == b.APIPath &&
+		a.Insecure == b.Insecure &&
+		a.ServerName == b.ServerName &&
+		bytes.Equal(a.CAData, b.CAData) &&
+		a.CAFile == b.CAFile &&
+		a.CertFile == b.CertFile &&
+		a.KeyFile == b.KeyFile &&
+		a.BearerTokenFile == b.BearerTokenFile &&
+		a.Username == b.Username &&
+		a.Password == b.Password &&
+		reflect.DeepEqual(a.Impersonate, b.Impersonate) &&
+		reflect.DeepEqual(a.ExecProvider, b.ExecProvider) &&
+		reflect.DeepEqual(a.AuthProvider, b.AuthProvider)
+}
+
+// closeCredentials 清理集群的客户端证书临时目录
+func (cc *ClusterConfig) closeCredentials() {
+	if cc.credentials == nil {
+		return
+	}
+	if cc.credentials.dir != "" {
+		_ = os.RemoveAll(cc.credentials.dir)
+	}
+	cc.credentials = nil
+}
+
+// canUpdate 新旧凭据类型一致时才能原地更新，如由 Token 改为客户端证书则需要重新连接
+func (cred *clusterCredentials) canUpdate(config *rest.Config) bool {
+	hasCert := len(config.CertData) > 0 && len(config.KeyData) > 0 && config.CertFile == ""
+	return hasCert == (cred.dir != "") && config.BearerTokenFile == ""
+}
+
+func (cred *clusterCredentials) update(config *rest.Config) error {
+	if cred.dir != "" {
+		if err := cred.writeCert(config.CertData, config.KeyData); err != nil {
+			return err
+		}
+	}
+	cred.mu.Lock()
+	cred.token = config.BearerToken
+	cred.mu.Unlock()
+	return nil
+}
+
+func (cred *clusterCredentials) certFile() string { return filepath.Join(cred.dir, "client.crt") }
+func (cred *clusterCredentials) keyFile() string  { return filepath.Join(cred.dir, "client.key") }
+
+// writeCert 先写临时文件再重命名，避免 client-go 读取到写了一半的证书
+func (cred *clusterCredentials) writeCert(cert, key []byte) error {
+	for path, data := range map[string][]byte{cred.certFile(): cert, cred.keyFile(): key} {
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o600); err != nil {
+			return fmt.Errorf("写入客户端证书失败: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("写入客户端证书失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (cred *clusterCredentials) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &credentialRoundTripper{cred: cred, rt: rt}
+}
+
+type credentialRoundTripper struct {
+	cred *clusterCredentials
+	rt   http.RoundTripper
+}
+
+func (r *credentialRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.cred.mu.RLock()
+	token := r.cred.token
+	r.cred.mu.RUnlock()
+	if token == "" || req.Header.Get("Authorization") != "" {
+		return r.rt.RoundTrip(req)
+	}
+	req = utilnet.CloneRequest(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return r.rt.RoundTrip(req)
+}
+
+func (r *credentialRoundTripper) WrappedRoundTripper() http.RoundTripper { return r.rt }
+
+// Persist 保存 oidc 刷新后的 id-token、refresh-token
+func (p *clusterAuthPersister) Persist(config map[string]string) error {
+	return ClusterService().persistAuthProviderConfig(p.cluster, config)
+}
+
+// persistAuthProviderConfig 将刷新后的 auth-provider 配置写回集群的 kubeconfig
+func (c *clusterService) persistAuthProviderConfig(cc *ClusterConfig, authConfig map[string]string) error {
+	// 读取、修改、写回须在同一把锁内完成，避免并发刷新时丢失更新
+	cc.kubeConfigMu.Lock()
+	content, err := updateAuthProviderConfig(cc.kubeConfig, cc.ContextName, authConfig)
+	if err == nil {
+		cc.kubeConfig = content
+	}
+	cc.kubeConfigMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if cc.Source != ClusterConfigSourceDB || cc.DBID == 0 {
+		return nil
+	}
+	item := &models.KubeConfig{}
+	if err := dao.DB().First(item, cc.DBID).Error; err != nil {
+		return err
+	}
+	// 数据库中保存的是上传的完整 kubeconfig，仅更新当前集群用户的 auth-provider
+	item.Content = string(content)
+	if err := dao.DB().Save(item).Error; err != nil {
+		return fmt.Errorf("保存集群 %s 刷新后的 Token 失败: %w", cc.GetClusterID(), err)
+	}
+	klog.V(4).Infof("集群 %s 的 oidc Token 已刷新并保存", cc.GetClusterID())
+	return nil
+}
+
+// updateAuthProviderConfig 更新 kubeconfig 中 context 对应用户的 auth-provider 配置
+func updateAuthProviderConfig(content []byte, contextName string, authConfig map[string]string) ([]byte, error) {
+	config, err := clientcmd.Load(content)
+	if err != nil {
+		return nil, err
+	}
+	ctx := config.Contexts[contextName]
+	if ctx == nil {
+		return nil, fmt.Errorf("kubeconfig 中不存在 context %s", contextName)
+	}
+	auth := config.AuthInfos[ctx.AuthInfo]
+	if auth == nil || auth.AuthProvider == nil {
+		return nil, fmt.Errorf("kubeconfig 中用户 %s 未配置 auth-provider", ctx.AuthInfo)
+	}
+	auth.AuthProvider.Config = make(map[string]string, len(authConfig))
+	for k, v := range authConfig {
+		auth.AuthProvider.Config[k] = v
+	}
+	return clientcmd.Write(*config)
+}
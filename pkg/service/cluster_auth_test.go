@@ -0,0 +1,232 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/flag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestExecPluginAllowed(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("未找到 sh")
+	}
+	sh, _ = filepath.Abs(sh)
+	cfg := flag.Init()
+	defer func(old string) { cfg.ExecPluginAllowlist = old }(cfg.ExecPluginAllowlist)
+
+	tests := []struct {
+		name      string
+		allowlist string
+		command   string
+		want      bool
+	}{
+		{"未配置白名单", "", "kubelogin", false},
+		{"命令名匹配", "kubelogin\naws", "kubelogin", true},
+		{"逗号分隔", "aws, kubelogin", "kubelogin", true},
+		{"命令名不匹配相对路径", "kubelogin", "./kubelogin", false},
+		{"命令名不匹配绝对路径", "kubelogin", "/tmp/kubelogin", false},
+		{"绝对路径匹配PATH中的命令", sh, "sh", true},
+		{"绝对路径匹配", sh, sh, true},
+		{"不在白名单", "aws", "kubelogin", false},
+	}
+	for _, tt := range tests {
+		cfg.ExecPluginAllowlist = tt.allowlist
+		err := execPluginAllowed(tt.command)
+		if (err == nil) != tt.want {
+			t.Errorf("%s: 期望允许 %v，实际 %v", tt.name, tt.want, err)
+		}
+		if err != nil && !errors.Is(err, errExecPluginNotAllowed) {
+			t.Errorf("%s: 错误类型不正确: %v", tt.name, err)
+		}
+	}
+}
+
+func TestClassifyClusterError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want constants.ClusterErrorType
+	}{
+		{"无错误", nil, ""},
+		{"401", apierrors.NewUnauthorized("token expired"), constants.ClusterErrorTypeAuth},
+		{"exec插件失败", fmt.Errorf(`Get "https://10.0.0.1/version": getting credentials: exec: executable kubelogin not found`), constants.ClusterErrorTypeAuth},
+		{"exec插件不在白名单", fmt.Errorf("%w: kubelogin", errExecPluginNotAllowed), constants.ClusterErrorTypeAuth},
+		{"客户端证书过期", fmt.Errorf("remote error: tls: expired certificate"), constants.ClusterErrorTypeAuth},
+		{"连接超时", fmt.Errorf("dial tcp 10.0.0.1:6443: i/o timeout"), constants.ClusterErrorTypeNetwork},
+	}
+	for _, tt := range tests {
+		if got := classifyClusterError(tt.err); got != tt.want {
+			t.Errorf("%s: 期望 %q，实际 %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestReloadCredentialsInPlace(t *testing.T) {
+	var got string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"major":"1","minor":"34","gitVersion":"v1.34.0"}`))
+	}))
+	defer srv.Close()
+
+	kubeconfig := func(token string) []byte {
+		return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: demo
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+users:
+- name: admin
+  user:
+    token: %s
+contexts:
+- name: demo
+  context:
+    cluster: demo
+    user: admin
+current-context: demo
+`, srv.URL, token))
+	}
+
+	cc := &ClusterConfig{ContextName: "demo", kubeConfig: kubeconfig("old")}
+	restConfig, err := kubeconfigRestConfig(cc.getKubeConfig(), cc.ContextName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ClusterService().applyClusterAuth(cc, restConfig); err != nil {
+		t.Fatal(err)
+	}
+	defer cc.closeCredentials()
+	if restConfig.BearerToken != "" {
+		t.Fatalf("内嵌 Token 应改为经 WrapTransport 设置")
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.Discovery().ServerVersion(); err != nil || got != "Bearer old" {
+		t.Fatalf("期望使用原 Token，实际 %q（%v）", got, err)
+	}
+
+	// 仅 Token 变化时原地更新，已创建的 clientset 使用新 Token
+	oldConfig, _ := kubeconfigRestConfig(cc.getKubeConfig(), "demo")
+	newConfig, _ := kubeconfigRestConfig(kubeconfig("new"), "demo")
+	if !onlyCredentialsChanged(oldConfig, newConfig) || !cc.credentials.canUpdate(newConfig) {
+		t.Fatalf("仅 Token 变化时应可原地更新")
+	}
+	if err := cc.credentials.update(newConfig); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.Discovery().ServerVersion(); err != nil || got != "Bearer new" {
+		t.Fatalf("期望使用新 Token，实际 %q（%v）", got, err)
+	}
+
+	newConfig.Host = "https://10.0.0.2"
+	if onlyCredentialsChanged(oldConfig, newConfig) {
+		t.Errorf("集群地址变化时应重新连接")
+	}
+}
+
+func TestClusterCredentialsCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	cred := &clusterCredentials{dir: dir}
+	if err := cred.writeCert([]byte("cert-1"), []byte("key-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cred.writeCert([]byte("cert-2"), []byte("key-2")); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := os.ReadFile(cred.certFile())
+	key, _ := os.ReadFile(cred.keyFile())
+	if string(cert) != "cert-2" || string(key) != "key-2" {
+		t.Errorf("证书文件未更新: %s %s", cert, key)
+	}
+	if info, err := os.Stat(cred.keyFile()); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("私钥文件权限应为 0600: %v", err)
+	}
+	cc := &ClusterConfig{credentials: cred}
+	cc.closeCredentials()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("断开连接后应删除证书目录")
+	}
+}
+
+func TestUpdateAuthProviderConfig(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: demo
+  cluster:
+    server: https://10.0.0.1
+users:
+- name: alice
+  user:
+    auth-provider:
+      name: oidc
+      config:
+        idp-issuer-url: https://issuer
+        client-id: k8s
+        id-token: old
+        refresh-token: r1
+contexts:
+- name: demo
+  context:
+    cluster: demo
+    user: alice
+current-context: demo
+`)
+	updated, err := updateAuthProviderConfig(content, "demo", map[string]string{
+		"idp-issuer-url": "https://issuer",
+		"client-id":      "k8s",
+		"id-token":       "new",
+		"refresh-token":  "r2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := clientcmd.Load(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := config.AuthInfos["alice"].AuthProvider
+	if ap.Config["id-token"] != "new" || ap.Config["refresh-token"] != "r2" {
+		t.Errorf("auth-provider 配置未更新: %v", ap.Config)
+	}
+	if _, err := updateAuthProviderConfig(content, "missing", nil); err == nil {
+		t.Errorf("context 不存在时应返回错误")
+	}
+
+	// 请求过程中并发刷新及读取 kubeconfig，配合 go test -race 检查
+	cc := &ClusterConfig{ContextName: "demo", kubeConfig: content}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := ClusterService().persistAuthProviderConfig(cc, map[string]string{"id-token": fmt.Sprintf("t%d", i)}); err != nil {
+				t.Errorf("保存 auth-provider 配置失败: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_ = cc.GetKubeconfig()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/webhook"
+	"github.com/weibaohui/kom/kom"
+	"gorm.io/gorm"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// 证书来源
+const (
+	CertificateKindClientCert = "client_cert" // kubeconfig 客户端证书
+	CertificateKindTLSSecret  = "tls_secret"  // TLS Secret，含 Ingress、Gateway 引用的 Secret
+	CertificateKindWebhookCA  = "webhook_ca"  // ValidatingWebhook、MutatingWebhook 的 caBundle
+)
+
+// certificateExpiredNotified 已发送过期通知
+const certificateExpiredNotified = -1
+
+// clusterCertificateService 扫描各集群的证书，记录过期时间及使用者，按阈值发送过期提醒
+type clusterCertificateService struct {
+	mu       sync.Mutex
+	lastScan time.Time
+}
+
+// Start 定期扫描全部已连接集群的证书，仅在 Leader 上运行
+func (s *clusterCertificateService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cfg, err := ConfigService().GetConfig()
+			if err != nil || cfg.CertScanIntervalHours <= 0 {
+				continue
+			}
+			s.mu.Lock()
+			due := time.Since(s.lastScan) >= time.Duration(cfg.CertScanIntervalHours)*time.Hour
+			s.mu.Unlock()
+			if due {
+				s.ScanAll()
+			}
+		}
+	}()
+}
+
+// ScanAll 扫描全部已连接集群的证书
+func (s *clusterCertificateService) ScanAll() {
+	s.mu.Lock()
+	s.lastScan = time.Now()
+	s.mu.Unlock()
+	for _, cc := range ClusterService().ConnectedClusters() {
+		count, err := s.ScanCluster(cc.GetClusterID())
+		if err != nil {
+			klog.Errorf("扫描集群 %s 证书失败: %v", cc.GetClusterID(), err)
+			continue
+		}
+		klog.V(4).Infof("扫描集群 %s 证书完成，共 %d 个", cc.GetClusterID(), count)
+	}
+}
+
+// ScanCluster 扫描单个集群的客户端证书、TLS Secret 及 Webhook caBundle，保存结果并发送过期提醒
+func (s *clusterCertificateService) ScanCluster(clusterID string) (int, error) {
+	cc := ClusterService().GetClusterByID(clusterID)
+	if cc == nil {
+		return 0, fmt.Errorf("集群 %s 不存在", clusterID)
+	}
+	if !ClusterService().IsConnected(clusterID) {
+		return 0, fmt.Errorf("集群 %s 未连接", clusterID)
+	}
+	certs, err := s.collect(cc)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.save(clusterID, certs); err != nil {
+		return 0, err
+	}
+	s.notify(clusterID, certs)
+	return len(certs), nil
+}
+
+// collect 读取集群中的证书
+func (s *clusterCertificateService) collect(cc *ClusterConfig) ([]*models.ClusterCertificate, error) {
+	clusterID := cc.GetClusterID()
+	ctx := utils.GetContextWithAdmin()
+	var result []*models.ClusterCertificate
+
+	if cert, user := cc.clientCertificate(); cert != nil {
+		item := newClusterCertificate(CertificateKindClientCert, "", user, cert)
+		item.UsedBy = "k8m"
+		result = append(result, item)
+	}
+
+	// 只列出 kubernetes.io/tls 类型的 Secret，避免读取集群中全部 Secret 的内容
+	var secrets []corev1.Secret
+	err := kom.Cluster(clusterID).WithContext(ctx).Resource(&corev1.Secret{}).AllNamespace().
+		WithFieldSelector("type=" + string(corev1.SecretTypeTLS)).List(&secrets).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取 Secret 失败: %w", err)
+	}
+	var ingresses []networkingv1.Ingress
+	if err := kom.Cluster(clusterID).WithContext(ctx).Resource(&networkingv1.Ingress{}).AllNamespace().List(&ingresses).Error; err != nil {
+		klog.V(6).Infof("集群 %s 读取 Ingress 失败: %v", clusterID, err)
+	}
+	var gateways []gatewayapiv1.Gateway
+	if kom.Cluster(clusterID).Status().IsGatewayAPISupported() {
+		err := kom.Cluster(clusterID).WithContext(ctx).CRD("gateway.networking.k8s.io", "v1", "Gateway").
+			Resource(&gatewayapiv1.Gateway{}).AllNamespace().List(&gateways).Error
+		if err != nil {
+			klog.V(6).Infof("集群 %s 读取 Gateway 失败: %v", clusterID, err)
+		}
+	}
+	users := tlsSecretUsers(ingresses, gateways)
+	referenced, errs := s.referencedSecrets(ctx, clusterID, secrets, users)
+	result = append(result, secretCertificates(append(secrets, referenced...), users, errs)...)
+
+	var validating []admissionregistrationv1.ValidatingWebhookConfiguration
+	if err := kom.Cluster(clusterID).WithContext(ctx).Resource(&admissionregistrationv1.ValidatingWebhookConfiguration{}).List(&validating).Error; err != nil {
+		klog.V(6).Infof("集群 %s 读取 ValidatingWebhookConfiguration 失败: %v", clusterID, err)
+	}
+	var mutating []admissionregistrationv1.MutatingWebhookConfiguration
+	if err := kom.Cluster(clusterID).WithContext(ctx).Resource(&admissionregistrationv1.MutatingWebhookConfiguration{}).List(&mutating).Error; err != nil {
+		klog.V(6).Infof("集群 %s 读取 MutatingWebhookConfiguration 失败: %v", clusterID, err)
+	}
+	result = append(result, webhookCertificates(validating, mutating)...)
+
+	now := time.Now()
+	for _, item := range result {
+		item.Cluster = clusterID
+		item.ScannedAt = now
+	}
+	return result, nil
+}
+
+// save 替换集群的证书记录，证书未更换时保留已发送提醒的阈值
+func (s *clusterCertificateService) save(clusterID string, certs []*models.ClusterCertificate) error {
+	var existing []*models.ClusterCertificate
+	if err := dao.DB().Where("cluster = ?", clusterID).Find(&existing).Error; err != nil {
+		return err
+	}
+	notified := map[string]*models.ClusterCertificate{}
+	for _, item := range existing {
+		notified[certificateKey(item)] = item
+	}
+	for _, item := range certs {
+		if old, ok := notified[certificateKey(item)]; ok && sameTime(old.NotAfter, item.NotAfter) {
+			item.NotifiedDays = old.NotifiedDays
+		}
+	}
+	return dao.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cluster = ?", clusterID).Delete(&models.ClusterCertificate{}).Error; err != nil {
+			return err
+		}
+		if len(certs) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(certs, 100).Error
+	})
+}
+
+// notify 按配置的阈值发送证书过期提醒，每个阈值仅提醒一次
+func (s *clusterCertificateService) notify(clusterID string, certs []*models.ClusterCertificate) {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil {
+		return
+	}
+	thresholds := ParseCertExpiryThresholds(cfg.CertExpiryThresholds)
+	now := time.Now()
+	var lines []string
+	for _, item := range certs {
+		if item.NotAfter == nil {
+			continue
+		}
+		days, ok := certificateNotifyDays(*item.NotAfter, now, thresholds, item.NotifiedDays)
+		if !ok {
+			continue
+		}
+		item.NotifiedDays = days
+		if err := dao.DB().Model(item).UpdateColumn("notified_days", days).Error; err != nil {
+			klog.Errorf("更新证书 %s 提醒状态失败: %v", item.Name, err)
+		}
+		lines = append(lines, certificateNotifyLine(item, now))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	msg := fmt.Sprintf("k8m 证书过期提醒：集群[%s]有 %d 个证书即将过期或已过期：\n%s", clusterID, len(lines), strings.Join(lines, "\n"))
+	if ids := utils.SplitAndTrim(cfg.CertExpiryWebhooks, ","); len(ids) > 0 {
+		var receivers []*models.WebhookReceiver
+		if err := dao.DB().Where("id in ?", ids).Find(&receivers).Error; err != nil {
+			klog.Errorf("查询证书过期通知webhook失败: %v", err)
+		}
+		webhook.PushMsgToAllTargets(msg, "", receivers)
+	}
+	if emails := utils.SplitAndTrim(cfg.CertExpiryEmails, ","); len(emails) > 0 {
+		err := MailService().Send(emails, "k8m 证书过期提醒："+clusterID, msg)
+		if err != nil && !errors.Is(err, ErrMailNotConfigured) {
+			klog.Errorf("发送证书过期通知邮件失败: %v", err)
+		}
+	}
+}
+
+// ParseCertExpiryThresholds 解析逗号分隔的提醒阈值天数，忽略非正数，按从大到小排序
+func ParseCertExpiryThresholds(s string) []int {
+	var result []int
+	for _, v := range utils.SplitAndTrim(s, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || slices.Contains(result, n) {
+			continue
+		}
+		result = append(result, n)
+	}
+	slices.Sort(result)
+	slices.Reverse(result)
+	return result
+}
+
+// certificateNotifyDays 计算本次应提醒的阈值，剩余天数不超过某阈值且该阈值及更小的阈值均未提醒过时提醒；
+// 已过期时返回 -1，仅提醒一次
+func certificateNotifyDays(notAfter, now time.Time, thresholds []int, notified int) (int, bool) {
+	if notified == certificateExpiredNotified {
+		return 0, false
+	}
+	if !now.Before(notAfter) {
+		return certificateExpiredNotified, true
+	}
+	left := int(notAfter.Sub(now).Hours() / 24)
+	matched := 0
+	for _, t := range thresholds {
+		if left < t {
+			matched = t
+		}
+	}
+	if matched == 0 || (notified > 0 && notified <= matched) {
+		return 0, false
+	}
+	return matched, true
+}
+
+func certificateNotifyLine(item *models.ClusterCertificate, now time.Time) string {
+	name := item.Name
+	if item.Namespace != "" {
+		name = item.Namespace + "/" + item.Name
+	}
+	line := fmt.Sprintf("- %s %s", certificateKindText(item.Kind), name)
+	if item.NotAfter.Before(now) {
+		line += fmt.Sprintf(" 已于 %s 过期", item.NotAfter.Local().Format(time.DateTime))
+	} else {
+		line += fmt.Sprintf(" 将于 %s 过期，剩余 %d 天", item.NotAfter.Local().Format(time.DateTime), int(item.NotAfter.Sub(now).Hours()/24))
+	}
+	if item.UsedBy != "" {
+		line += "，使用者：" + item.UsedBy
+	}
+	return line
+}
+
+func certificateKindText(kind string) string {
+	switch kind {
+	case CertificateKindClientCert:
+		return "客户端证书"
+	case CertificateKindTLSSecret:
+		return "TLS Secret"
+	case CertificateKindWebhookCA:
+		return "Webhook caBundle"
+	default:
+		return kind
+	}
+}
+
+// referencedSecrets 逐个读取被 Ingress、Gateway 引用但不是 kubernetes.io/tls 类型的 Secret，
+// 返回读取到的 Secret 及读取失败（不存在除外）的错误，namespace/name -> 错误
+func (s *clusterCertificateService) referencedSecrets(ctx context.Context, clusterID string, listed []corev1.Secret, users map[string][]string) ([]corev1.Secret, map[string]error) {
+	found := map[string]bool{}
+	for _, secret := range listed {
+		found[secret.Namespace+"/"+secret.Name] = true
+	}
+	var result []corev1.Secret
+	errs := map[string]error{}
+	for key := range users {
+		if found[key] {
+			continue
+		}
+		ns, name, _ := strings.Cut(key, "/")
+		var secret corev1.Secret
+		err := kom.Cluster(clusterID).WithContext(ctx).Resource(&secret).Namespace(ns).Name(name).Get(&secret).Error
+		switch {
+		case err == nil:
+			result = append(result, secret)
+		case !apierrors.IsNotFound(err):
+			errs[key] = err
+		}
+	}
+	return result, errs
+}
+
+// tlsSecretUsers 统计 Ingress、Gateway 引用的 TLS Secret，namespace/name -> 使用者列表
+func tlsSecretUsers(ingresses []networkingv1.Ingress, gateways []gatewayapiv1.Gateway) map[string][]string {
+	users := map[string][]string{}
+	add := func(key, user string) {
+		if !slices.Contains(users[key], user) {
+			users[key] = append(users[key], user)
+		}
+	}
+	for _, ing := range ingresses {
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName != "" {
+				add(ing.Namespace+"/"+tls.SecretName, "Ingress "+ing.Namespace+"/"+ing.Name)
+			}
+		}
+	}
+	for _, gw := range gateways {
+		for _, l := range gw.Spec.Listeners {
+			if l.TLS == nil {
+				continue
+			}
+			for _, ref := range l.TLS.CertificateRefs {
+				if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Secret") {
+					continue
+				}
+				ns := gw.Namespace
+				if ref.Namespace != nil && *ref.Namespace != "" {
+					ns = string(*ref.Namespace)
+				}
+				add(ns+"/"+string(ref.Name), "Gateway "+gw.Namespace+"/"+gw.Name)
+			}
+		}
+	}
+	return users
+}
+
+// secretCertificates 解析 kubernetes.io/tls 类型及被 Ingress、Gateway 引用的 Secret 中的证书，
+// 被引用但不存在、读取失败或不含证书的 Secret 同样记录，便于发现配置错误，errs 为读取失败的错误
+func secretCertificates(secrets []corev1.Secret, users map[string][]string, errs map[string]error) []*models.ClusterCertificate {
+	var result []*models.ClusterCertificate
+	found := map[string]bool{}
+	for _, secret := range secrets {
+		key := secret.Namespace + "/" + secret.Name
+		used := users[key]
+		if secret.Type != corev1.SecretTypeTLS && len(used) == 0 {
+			continue
+		}
+		found[key] = true
+		var item *models.ClusterCertificate
+		certs, err := parseCertificates(secret.Data[corev1.TLSCertKey])
+		if err != nil {
+			item = &models.ClusterCertificate{Kind: CertificateKindTLSSecret, Namespace: secret.Namespace, Name: secret.Name, Err: err.Error()}
+		} else {
+			// 证书链中第一个为服务端证书
+			item = newClusterCertificate(CertificateKindTLSSecret, secret.Namespace, secret.Name, certs[0])
+		}
+		item.UsedBy = strings.Join(used, ",")
+		result = append(result, item)
+	}
+	keys := make([]string, 0, len(users))
+	for key := range users {
+		if !found[key] {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		ns, name, _ := strings.Cut(key, "/")
+		msg := "Secret 不存在"
+		if err := errs[key]; err != nil {
+			msg = "读取 Secret 失败: " + err.Error()
+		}
+		result = append(result, &models.ClusterCertificate{
+			Kind:      CertificateKindTLSSecret,
+			Namespace: ns,
+			Name:      name,
+			UsedBy:    strings.Join(users[key], ","),
+			Err:       msg,
+		})
+	}
+	return result
+}
+
+// webhookCertificates 解析 Webhook 的 caBundle，取其中最早过期的证书，使用者为对应的 Service 或 URL
+func webhookCertificates(validating []admissionregistrationv1.ValidatingWebhookConfiguration, mutating []admissionregistrationv1.MutatingWebhookConfiguration) []*models.ClusterCertificate {
+	var result []*models.ClusterCertificate
+	add := func(kind, config, name string, client admissionregistrationv1.WebhookClientConfig) {
+		if len(client.CABundle) == 0 {
+			return
+		}
+		certName := kind + "/" + config + "/" + name
+		var item *models.ClusterCertificate
+		certs, err := parseCertificates(client.CABundle)
+		if err != nil {
+			item = &models.ClusterCertificate{Kind: CertificateKindWebhookCA, Name: certName, Err: err.Error()}
+		} else {
+			earliest := slices.MinFunc(certs, func(a, b *x509.Certificate) int { return a.NotAfter.Compare(b.NotAfter) })
+			item = newClusterCertificate(CertificateKindWebhookCA, "", certName, earliest)
+		}
+		switch {
+		case client.Service != nil:
+			item.UsedBy = "Service " + client.Service.Namespace + "/" + client.Service.Name
+		case client.URL != nil:
+			item.UsedBy = *client.URL
+		}
+		result = append(result, item)
+	}
+	for _, cfg := range validating {
+		for _, w := range cfg.Webhooks {
+			add("ValidatingWebhookConfiguration", cfg.Name, w.Name, w.ClientConfig)
+		}
+	}
+	for _, cfg := range mutating {
+		for _, w := range cfg.Webhooks {
+			add("MutatingWebhookConfiguration", cfg.Name, w.Name, w.ClientConfig)
+		}
+	}
+	return result
+}
+
+// parseCertificates 解析 PEM 中的全部证书
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if len(data) == 0 {
+		return nil, errors.New("证书为空")
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书失败: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("未找到 PEM 格式的证书")
+	}
+	return certs, nil
+}
+
+func newClusterCertificate(kind, namespace, name string, cert *x509.Certificate) *models.ClusterCertificate {
+	notBefore := cert.NotBefore.Local()
+	notAfter := cert.NotAfter.Local()
+	return &models.ClusterCertificate{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		DNSNames:  strings.Join(cert.DNSNames, ","),
+		NotBefore: &notBefore,
+		NotAfter:  &notAfter,
+	}
+}
+
+func certificateKey(item *models.ClusterCertificate) string {
+	return item.Kind + "|" + item.Namespace + "|" + item.Name
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func testCertPEM(t *testing.T, cn string, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSecretCertificates(t *testing.T) {
+	expiry := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	ns := gatewayapiv1.Namespace("certs")
+	ingresses := []networkingv1.Ingress{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "shop"},
+		Spec:       networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{{SecretName: "shop-tls"}, {SecretName: "missing-tls"}, {SecretName: "forbidden-tls"}}},
+	}}
+	gateways := []gatewayapiv1.Gateway{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gw", Name: "public"},
+		Spec: gatewayapiv1.GatewaySpec{Listeners: []gatewayapiv1.Listener{{
+			TLS: &gatewayapiv1.ListenerTLSConfig{CertificateRefs: []gatewayapiv1.SecretObjectReference{{Name: "wildcard", Namespace: &ns}}},
+		}}},
+	}}
+	secrets := []corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "shop-tls"}, Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{corev1.TLSCertKey: testCertPEM(t, "shop.example.com", expiry)}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "certs", Name: "wildcard"}, Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{corev1.TLSCertKey: testCertPEM(t, "*.example.com", expiry)}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "broken"}, Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{corev1.TLSCertKey: []byte("not a cert")}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "db-password"}, Type: corev1.SecretTypeOpaque},
+	}
+
+	errs := map[string]error{"web/forbidden-tls": errors.New("secrets is forbidden")}
+	certs := secretCertificates(secrets, tlsSecretUsers(ingresses, gateways), errs)
+	got := map[string]string{}
+	for _, c := range certs {
+		got[c.Namespace+"/"+c.Name] = c.UsedBy
+	}
+	want := map[string]string{
+		"web/shop-tls":      "Ingress web/shop",
+		"certs/wildcard":    "Gateway gw/public",
+		"web/broken":        "",
+		"web/missing-tls":   "Ingress web/shop",
+		"web/forbidden-tls": "Ingress web/shop",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("期望 %d 个证书，实际 %v", len(want), got)
+	}
+	for k, v := range want {
+		if u, ok := got[k]; !ok || u != v {
+			t.Errorf("%s: 期望使用者 %q，实际 %q（存在 %v）", k, v, u, ok)
+		}
+	}
+	for _, c := range certs {
+		switch c.Name {
+		case "shop-tls":
+			if c.NotAfter == nil || !c.NotAfter.Equal(expiry) || c.DNSNames != "shop.example.com" {
+				t.Errorf("证书信息不正确: %+v", c)
+			}
+		case "broken", "missing-tls":
+			if c.Err == "" || c.NotAfter != nil {
+				t.Errorf("%s: 应记录错误", c.Name)
+			}
+		case "forbidden-tls":
+			if c.Err != "读取 Secret 失败: secrets is forbidden" {
+				t.Errorf("%s: 应记录读取失败，实际 %q", c.Name, c.Err)
+			}
+		}
+	}
+}
+
+func TestWebhookCertificates(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	later := time.Now().Add(365 * 24 * time.Hour).Truncate(time.Second)
+	bundle := append(testCertPEM(t, "ca-new", later), testCertPEM(t, "ca-old", soon)...)
+	validating := []admissionregistrationv1.ValidatingWebhookConfiguration{{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "validate.policy.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				CABundle: bundle,
+				Service:  &admissionregistrationv1.ServiceReference{Namespace: "policy", Name: "webhook"},
+			}},
+			{Name: "no-ca.policy.io"},
+		},
+	}}
+	certs := webhookCertificates(validating, nil)
+	if len(certs) != 1 {
+		t.Fatalf("期望 1 个证书，实际 %d", len(certs))
+	}
+	c := certs[0]
+	if c.Name != "ValidatingWebhookConfiguration/policy/validate.policy.io" || c.UsedBy != "Service policy/webhook" {
+		t.Errorf("证书信息不正确: %+v", c)
+	}
+	if c.NotAfter == nil || !c.NotAfter.Equal(soon) {
+		t.Errorf("应取 caBundle 中最早过期的证书，实际 %v", c.NotAfter)
+	}
+}
+
+func TestCertificateNotifyDays(t *testing.T) {
+	now := time.Now()
+	thresholds := ParseCertExpiryThresholds("7, 30,1,14,abc,0,7")
+	if !slices.Equal(thresholds, []int{30, 14, 7, 1}) {
+		t.Fatalf("阈值解析不正确: %v", thresholds)
+	}
+	days := func(d float64) time.Time { return now.Add(time.Duration(d * 24 * float64(time.Hour))) }
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		notified int
+		want     int
+		ok       bool
+	}{
+		{"未到阈值", days(45), 0, 0, false},
+		{"首次达到30天", days(29.5), 0, 30, true},
+		{"30天已提醒", days(20), 30, 0, false},
+		{"达到14天", days(13), 30, 14, true},
+		{"首次扫描时已不足7天", days(5), 0, 7, true},
+		{"最后一天", days(0.5), 7, 1, true},
+		{"1天已提醒", days(0.5), 1, 0, false},
+		{"已过期", days(-1), 1, certificateExpiredNotified, true},
+		{"过期已提醒", days(-2), certificateExpiredNotified, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := certificateNotifyDays(tt.notAfter, now, thresholds, tt.notified)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: 期望 %d（%v），实际 %d（%v）", tt.name, tt.want, tt.ok, got, ok)
+		}
+	}
+}
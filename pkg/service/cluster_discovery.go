@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/flag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+)
+
+// ClusterConfigSourceArgoCD 从 Argo CD 集群 Secret 发现的集群，集群ID为 ArgoCD/集群名称
+var ClusterConfigSourceArgoCD ClusterConfigSource = "ArgoCD"
+
+// ClusterConfigSourceCAPI 从 Cluster API kubeconfig Secret 发现的集群，集群ID为 ClusterAPI/命名空间/集群名称
+var ClusterConfigSourceCAPI ClusterConfigSource = "ClusterAPI"
+
+const (
+	// argoCDSecretTypeLabel Argo CD 通过该标签识别集群 Secret
+	argoCDSecretTypeLabel = "argocd.argoproj.io/secret-type=cluster"
+	// argoCDInClusterServer Argo CD 所在集群，k8m 通过 InCluster 或宿主集群纳管，不重复发现
+	argoCDInClusterServer = "https://kubernetes.default.svc"
+	// capiClusterNameLabel Cluster API 为集群的 kubeconfig Secret 设置该标签
+	capiClusterNameLabel = "cluster.x-k8s.io/cluster-name"
+
+	clusterDiscoveryRetryInterval = 30 * time.Second
+	clusterDiscoveryResyncPeriod  = 10 * time.Minute
+)
+
+type clusterDiscoveryService struct {
+	mu       sync.Mutex
+	clusters map[string]string // 来源/Secret命名空间/名称 -> 集群ID
+}
+
+// clusterDiscoveryProvider 从宿主集群中的一类 Secret 发现集群
+type clusterDiscoveryProvider struct {
+	source    ClusterConfigSource
+	namespace string
+	selector  string
+	convert   func(secret *corev1.Secret) (*ClusterConfig, error) // 返回 nil 表示该 Secret 不对应集群
+}
+
+// Start 按启动参数启动集群自动发现，宿主集群不可用时定期重试，直到 ctx 结束
+func (s *clusterDiscoveryService) Start(ctx context.Context) {
+	providers, err := discoveryProviders(flag.Init())
+	if err != nil {
+		klog.Errorf("集群自动发现参数错误: %v", err)
+		return
+	}
+	if len(providers) == 0 {
+		return
+	}
+	go func() {
+		for {
+			cs, hasCluster, err := utils.GetClientSet(flag.Init().HostClusterID)
+			if err == nil && hasCluster {
+				for _, p := range providers {
+					s.watch(ctx, cs, p)
+				}
+				return
+			}
+			klog.V(4).Infof("集群自动发现等待宿主集群可用，%s 后重试", clusterDiscoveryRetryInterval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(clusterDiscoveryRetryInterval):
+			}
+		}
+	}()
+}
+
+// discoveryProviders 根据启动参数生成启用的发现来源，标签选择器在此校验
+func discoveryProviders(cfg *flag.Config) ([]*clusterDiscoveryProvider, error) {
+	var providers []*clusterDiscoveryProvider
+	if cfg.ArgoCDDiscovery {
+		selector, err := discoverySelector(argoCDSecretTypeLabel, cfg.ArgoCDSelector)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, &clusterDiscoveryProvider{
+			source:    ClusterConfigSourceArgoCD,
+			namespace: cfg.ArgoCDNamespace,
+			selector:  selector,
+			convert:   argoCDClusterConfig,
+		})
+	}
+	if cfg.CAPIDiscovery {
+		selector, err := discoverySelector(capiClusterNameLabel, cfg.CAPISelector)
+		if err != nil {
+			return nil, err
+		}
+		// Cluster API kubeconfig 为集群管理员凭据，默认只发现指定命名空间，* 才发现全部命名空间
+		namespace := cfg.CAPINamespace
+		switch namespace {
+		case "":
+			return nil, fmt.Errorf("未配置 Cluster API 集群所在命名空间")
+		case "*":
+			namespace = metav1.NamespaceAll
+		}
+		providers = append(providers, &clusterDiscoveryProvider{
+			source:    ClusterConfigSourceCAPI,
+			namespace: namespace,
+			selector:  selector,
+			convert:   capiClusterConfig,
+		})
+	}
+	return providers, nil
+}
+
+// discoverySelector 合并来源固定的标签及用户配置的标签选择器
+func discoverySelector(base, extra string) (string, error) {
+	selector := base
+	if strings.TrimSpace(extra) != "" {
+		selector += "," + strings.TrimSpace(extra)
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return "", fmt.Errorf("标签选择器 %s 格式错误: %w", extra, err)
+	}
+	return selector, nil
+}
+
+// watch 监听宿主集群中的 Secret，新增、更新时注册或更新集群，删除时移除集群
+func (s *clusterDiscoveryService) watch(ctx context.Context, cs *kubernetes.Clientset, p *clusterDiscoveryProvider) {
+	factory := informers.NewSharedInformerFactoryWithOptions(cs, clusterDiscoveryResyncPeriod,
+		informers.WithNamespace(p.namespace), informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = p.selector
+		}))
+	informer := factory.Core().V1().Secrets().Informer()
+	_, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				s.sync(p, secret)
+			}
+		},
+		UpdateFunc: func(_, obj any) {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				s.sync(p, secret)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if dfo, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = dfo.Obj
+			}
+			if secret, ok := obj.(*corev1.Secret); ok {
+				s.remove(p, secret)
+			}
+		},
+	})
+	if err != nil {
+		klog.Errorf("启动 %s 集群自动发现失败: %v", p.source, err)
+		return
+	}
+	factory.Start(ctx.Done())
+	klog.V(2).Infof("已启动 %s 集群自动发现，命名空间：%s，标签选择器：%s", p.source, p.namespace, p.selector)
+}
+
+// sync 注册或更新 Secret 对应的集群
+func (s *clusterDiscoveryService) sync(p *clusterDiscoveryProvider, secret *corev1.Secret) {
+	key := discoveryKey(p.source, secret)
+	cc, err := p.convert(secret)
+	if err != nil {
+		klog.Warningf("%s 集群 Secret %s/%s 解析失败: %v", p.source, secret.Namespace, secret.Name, err)
+		return
+	}
+	if cc == nil {
+		s.remove(p, secret)
+		return
+	}
+	clusterID := cc.GetClusterID()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clusters == nil {
+		s.clusters = map[string]string{}
+	}
+	// 集群名称变化时移除原集群
+	if old, ok := s.clusters[key]; ok && old != clusterID {
+		ClusterService().removeCluster(old)
+	}
+	cs := ClusterService()
+	existing := cs.GetClusterByID(clusterID)
+	if existing == nil {
+		s.clusters[key] = clusterID
+		cs.AddToClusterList(cc)
+		klog.V(2).Infof("发现集群 %s [%s]", clusterID, cc.Server)
+		if flag.Init().ConnectCluster {
+			go cs.Connect(clusterID)
+		}
+		return
+	}
+	if existing.Source != p.source {
+		klog.Warningf("%s 集群 Secret %s/%s 对应的集群ID %s 已被其他来源使用，忽略", p.source, secret.Namespace, secret.Name, clusterID)
+		return
+	}
+	s.clusters[key] = clusterID
+	existing.Server = cc.Server
+	if existing.ProxyURL == cc.ProxyURL {
+		// 仅 Token、客户端证书轮换时原地更新，其他配置变化时重新连接
+		cs.reloadKubeconfig(existing, cc.kubeConfig)
+		return
+	}
+	klog.V(2).Infof("集群 %s 代理已变化，更新集群", clusterID)
+	existing.setKubeConfig(cc.kubeConfig)
+	existing.ProxyURL = cc.ProxyURL
+	if existing.ClusterConnectStatus == constants.ClusterConnectStatusConnected {
+		go func() {
+			// Connect 跳过已连接的集群，先清理连接资源
+			cs.disconnectWithOption(clusterID, false)
+			cs.Connect(clusterID)
+		}()
+	}
+}
+
+// remove Secret 删除或不再匹配时，断开并移除对应的集群
+func (s *clusterDiscoveryService) remove(p *clusterDiscoveryProvider, secret *corev1.Secret) {
+	key := discoveryKey(p.source, secret)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clusterID, ok := s.clusters[key]
+	if !ok {
+		return
+	}
+	delete(s.clusters, key)
+	klog.V(2).Infof("%s 集群 Secret %s/%s 已删除，移除集群 %s", p.source, secret.Namespace, secret.Name, clusterID)
+	ClusterService().removeCluster(clusterID)
+}
+
+func discoveryKey(source ClusterConfigSource, secret *corev1.Secret) string {
+	return fmt.Sprintf("%s/%s/%s", source, secret.Namespace, secret.Name)
+}
+
+// removeCluster 断开集群并从集群列表移除
+func (c *clusterService) removeCluster(clusterID string) {
+	c.Disconnect(clusterID)
+	c.RemoveFromClusterList(func(item *ClusterConfig) bool {
+		return item.GetClusterID() == clusterID
+	})
+}
+
+// argoCDClusterSecretConfig Argo CD 集群 Secret 中 config 字段的内容
+type argoCDClusterSecretConfig struct {
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	BearerToken     string `json:"bearerToken,omitempty"`
+	ProxyURL        string `json:"proxyUrl,omitempty"`
+	TLSClientConfig struct {
+		Insecure   bool   `json:"insecure"`
+		ServerName string `json:"serverName,omitempty"`
+		CertData   []byte `json:"certData,omitempty"`
+		KeyData    []byte `json:"keyData,omitempty"`
+		CAData     []byte `json:"caData,omitempty"`
+	} `json:"tlsClientConfig"`
+	ExecProviderConfig *struct {
+		Command     string            `json:"command,omitempty"`
+		Args        []string          `json:"args,omitempty"`
+		Env         map[string]string `json:"env,omitempty"`
+		APIVersion  string            `json:"apiVersion,omitempty"`
+		InstallHint string            `json:"installHint,omitempty"`
+	} `json:"execProviderConfig,omitempty"`
+	AWSAuthConfig *struct {
+		ClusterName string `json:"clusterName,omitempty"`
+	} `json:"awsAuthConfig,omitempty"`
+}
+
+// argoCDClusterConfig 将 Argo CD 集群 Secret 转换为集群配置，Argo CD 所在集群不重复发现
+func argoCDClusterConfig(secret *corev1.Secret) (*ClusterConfig, error) {
+	server := strings.TrimRight(string(secret.Data["server"]), "/")
+	if server == "" {
+		return nil, fmt.Errorf("未配置集群地址 server")
+	}
+	if server == argoCDInClusterServer {
+		return nil, nil
+	}
+	name := string(secret.Data["name"])
+	if name == "" {
+		name = secret.Name
+	}
+	var c argoCDClusterSecretConfig
+	if err := json.Unmarshal(secret.Data["config"], &c); err != nil {
+		return nil, fmt.Errorf("解析 config 失败: %w", err)
+	}
+	if c.AWSAuthConfig != nil {
+		return nil, fmt.Errorf("不支持 awsAuthConfig 认证，请在 k8m 中以 AWS EKS 方式添加该集群")
+	}
+	if c.ProxyURL != "" {
+		if _, err := url.Parse(c.ProxyURL); err != nil {
+			return nil, fmt.Errorf("代理地址格式错误: %w", err)
+		}
+	}
+
+	cluster := &clientcmdapi.Cluster{
+		Server:                   server,
+		InsecureSkipTLSVerify:    c.TLSClientConfig.Insecure,
+		TLSServerName:            c.TLSClientConfig.ServerName,
+		CertificateAuthorityData: c.TLSClientConfig.CAData,
+	}
+	auth := &clientcmdapi.AuthInfo{
+		Token:                 c.BearerToken,
+		Username:              c.Username,
+		Password:              c.Password,
+		ClientCertificateData: c.TLSClientConfig.CertData,
+		ClientKeyData:         c.TLSClientConfig.KeyData,
+	}
+	if e := c.ExecProviderConfig; e != nil {
+		auth.Exec = &clientcmdapi.ExecConfig{
+			Command:         e.Command,
+			Args:            e.Args,
+			APIVersion:      e.APIVersion,
+			InstallHint:     e.InstallHint,
+			InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+		}
+		for k, v := range e.Env {
+			auth.Exec.Env = append(auth.Exec.Env, clientcmdapi.ExecEnvVar{Name: k, Value: v})
+		}
+	}
+	cc, err := discoveredClusterConfig(ClusterConfigSourceArgoCD, name, cluster, auth, "")
+	if err != nil {
+		return nil, err
+	}
+	cc.ProxyURL = c.ProxyURL
+	return cc, nil
+}
+
+// capiClusterConfig 将 Cluster API 生成的 <集群名>-kubeconfig Secret 转换为集群配置
+func capiClusterConfig(secret *corev1.Secret) (*ClusterConfig, error) {
+	clusterName := secret.Labels[capiClusterNameLabel]
+	// 同一集群还可能有 <集群名>-user-kubeconfig 等 Secret，仅使用管理员 kubeconfig
+	if clusterName == "" || secret.Name != clusterName+"-kubeconfig" {
+		return nil, nil
+	}
+	content := secret.Data["value"]
+	if len(content) == 0 {
+		return nil, fmt.Errorf("未包含 kubeconfig")
+	}
+	config, err := clientcmd.Load(content)
+	if err != nil {
+		return nil, fmt.Errorf("解析 kubeconfig 失败: %w", err)
+	}
+	ctx := config.Contexts[config.CurrentContext]
+	if ctx == nil {
+		return nil, fmt.Errorf("kubeconfig 未设置 current-context")
+	}
+	cluster := config.Clusters[ctx.Cluster]
+	auth := config.AuthInfos[ctx.AuthInfo]
+	if cluster == nil || auth == nil {
+		return nil, fmt.Errorf("kubeconfig 中 context %s 对应的集群或用户不存在", config.CurrentContext)
+	}
+	return discoveredClusterConfig(ClusterConfigSourceCAPI, secret.Namespace+"/"+clusterName, cluster, auth, ctx.Namespace)
+}
+
+// discoveredClusterConfig 生成只包含一个 context 的 kubeconfig，context 名称与集群ID中的名称一致
+func discoveredClusterConfig(source ClusterConfigSource, name string, cluster *clientcmdapi.Cluster, auth *clientcmdapi.AuthInfo, namespace string) (*ClusterConfig, error) {
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = cluster
+	config.AuthInfos[name] = auth
+	config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name, Namespace: namespace}
+	config.CurrentContext = name
+	// Secret 中的 exec 插件会在 k8m 进程中执行，发现时即按白名单校验，未允许的集群不加入
+	if err := ValidateKubeconfigAuth(config); err != nil {
+		return nil, err
+	}
+	content, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, fmt.Errorf("生成 kubeconfig 失败: %w", err)
+	}
+	return &ClusterConfig{
+		FileName:             string(source),
+		ContextName:          name,
+		ClusterName:          name,
+		UserName:             name,
+		Namespace:            namespace,
+		Server:               cluster.Server,
+		kubeConfig:           content,
+		ClusterConnectStatus: constants.ClusterConnectStatusDisconnected,
+		Source:               source,
+	}, nil
+}
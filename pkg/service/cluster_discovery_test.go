@@ -0,0 +1,220 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/flag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestArgoCDClusterConfig(t *testing.T) {
+	cfg := flag.Init()
+	defer func(old string) { cfg.ExecPluginAllowlist = old }(cfg.ExecPluginAllowlist)
+	cfg.ExecPluginAllowlist = "gke-gcloud-auth-plugin"
+
+	tests := []struct {
+		name       string
+		data       map[string]string
+		wantID     string
+		wantToken  string
+		wantProxy  string
+		wantInsec  bool
+		wantNil    bool
+		wantErr    bool
+		wantExec   bool
+		secretName string
+	}{
+		{
+			name:      "Token认证",
+			data:      map[string]string{"name": "prod", "server": "https://10.0.0.1:6443", "config": `{"bearerToken":"abc","tlsClientConfig":{"insecure":true}}`},
+			wantID:    "ArgoCD/prod",
+			wantToken: "abc",
+			wantInsec: true,
+		},
+		{
+			name:       "未设置名称时使用Secret名称",
+			secretName: "cluster-10.0.0.2",
+			data:       map[string]string{"server": "https://10.0.0.2:6443", "config": `{"bearerToken":"abc","proxyUrl":"socks5://proxy:1080"}`},
+			wantID:     "ArgoCD/cluster-10.0.0.2",
+			wantToken:  "abc",
+			wantProxy:  "socks5://proxy:1080",
+		},
+		{
+			name:     "exec认证",
+			data:     map[string]string{"name": "gke", "server": "https://10.0.0.3", "config": `{"execProviderConfig":{"command":"gke-gcloud-auth-plugin","apiVersion":"client.authentication.k8s.io/v1beta1"}}`},
+			wantID:   "ArgoCD/gke",
+			wantExec: true,
+		},
+		{
+			name:    "exec插件不在白名单",
+			data:    map[string]string{"name": "evil", "server": "https://10.0.0.4", "config": `{"execProviderConfig":{"command":"sh","args":["-c","id"]}}`},
+			wantErr: true,
+		},
+		{
+			name:    "Argo CD所在集群",
+			data:    map[string]string{"name": "in-cluster", "server": "https://kubernetes.default.svc", "config": `{}`},
+			wantNil: true,
+		},
+		{
+			name:    "不支持AWS认证",
+			data:    map[string]string{"name": "eks", "server": "https://eks", "config": `{"awsAuthConfig":{"clusterName":"eks"}}`},
+			wantErr: true,
+		},
+		{
+			name:    "缺少集群地址",
+			data:    map[string]string{"name": "x", "config": `{}`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: tt.secretName, Namespace: "argocd"}, Data: map[string][]byte{}}
+		for k, v := range tt.data {
+			secret.Data[k] = []byte(v)
+		}
+		cc, err := argoCDClusterConfig(secret)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: 期望错误 %v，实际 %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if tt.wantNil {
+			if cc != nil {
+				t.Errorf("%s: 期望忽略，实际 %s", tt.name, cc.GetClusterID())
+			}
+			continue
+		}
+		if cc.GetClusterID() != tt.wantID {
+			t.Errorf("%s: 期望集群ID %s，实际 %s", tt.name, tt.wantID, cc.GetClusterID())
+		}
+		if cc.ProxyURL != tt.wantProxy {
+			t.Errorf("%s: 期望代理 %s，实际 %s", tt.name, tt.wantProxy, cc.ProxyURL)
+		}
+		config, err := clientcmd.Load(cc.kubeConfig)
+		if err != nil {
+			t.Errorf("%s: 生成的 kubeconfig 无法解析: %v", tt.name, err)
+			continue
+		}
+		if config.CurrentContext != cc.ContextName {
+			t.Errorf("%s: current-context 应为 %s，实际 %s", tt.name, cc.ContextName, config.CurrentContext)
+		}
+		auth := config.AuthInfos[cc.ContextName]
+		if auth.Token != tt.wantToken {
+			t.Errorf("%s: 期望Token %s，实际 %s", tt.name, tt.wantToken, auth.Token)
+		}
+		if (auth.Exec != nil) != tt.wantExec {
+			t.Errorf("%s: 期望exec %v，实际 %v", tt.name, tt.wantExec, auth.Exec)
+		}
+		if config.Clusters[cc.ContextName].InsecureSkipTLSVerify != tt.wantInsec {
+			t.Errorf("%s: 期望跳过证书校验 %v", tt.name, tt.wantInsec)
+		}
+	}
+}
+
+func TestCAPIClusterConfig(t *testing.T) {
+	kubeconfig := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: demo
+  cluster:
+    server: https://10.0.1.1:6443
+users:
+- name: demo-admin
+  user:
+    token: xyz
+contexts:
+- name: demo-admin@demo
+  context:
+    cluster: demo
+    user: demo-admin
+current-context: demo-admin@demo
+`)
+	tests := []struct {
+		name       string
+		secretName string
+		label      string
+		value      []byte
+		wantID     string
+		wantNil    bool
+		wantErr    bool
+	}{
+		{"管理员kubeconfig", "demo-kubeconfig", "demo", kubeconfig, "ClusterAPI/capi/demo", false, false},
+		{"用户kubeconfig", "demo-user-kubeconfig", "demo", kubeconfig, "", true, false},
+		{"缺少标签", "demo-kubeconfig", "", kubeconfig, "", true, false},
+		{"内容为空", "demo-kubeconfig", "demo", nil, "", false, true},
+		{"内容格式错误", "demo-kubeconfig", "demo", []byte("not: [yaml"), "", false, true},
+	}
+	for _, tt := range tests {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: tt.secretName, Namespace: "capi", Labels: map[string]string{}},
+			Data:       map[string][]byte{"value": tt.value},
+		}
+		if tt.label != "" {
+			secret.Labels[capiClusterNameLabel] = tt.label
+		}
+		cc, err := capiClusterConfig(secret)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: 期望错误 %v，实际 %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if tt.wantNil {
+			if cc != nil {
+				t.Errorf("%s: 期望忽略，实际 %s", tt.name, cc.GetClusterID())
+			}
+			continue
+		}
+		if cc.GetClusterID() != tt.wantID || cc.Server != "https://10.0.1.1:6443" {
+			t.Errorf("%s: 期望集群ID %s，实际 %s [%s]", tt.name, tt.wantID, cc.GetClusterID(), cc.Server)
+		}
+		if _, err := clientcmd.RESTConfigFromKubeConfig(cc.kubeConfig); err != nil {
+			t.Errorf("%s: 生成的 kubeconfig 无法使用: %v", tt.name, err)
+		}
+	}
+}
+
+func TestDiscoverySelector(t *testing.T) {
+	tests := []struct {
+		extra   string
+		want    string
+		wantErr bool
+	}{
+		{"", argoCDSecretTypeLabel, false},
+		{" env=prod ", argoCDSecretTypeLabel + ",env=prod", false},
+		{"env in (prod,staging)", argoCDSecretTypeLabel + ",env in (prod,staging)", false},
+		{"env in (", "", true},
+	}
+	for _, tt := range tests {
+		got, err := discoverySelector(argoCDSecretTypeLabel, tt.extra)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q: 期望 %q（错误 %v），实际 %q（%v）", tt.extra, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
+
+func TestDiscoveryProvidersCAPINamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		want      string
+		wantErr   bool
+	}{
+		{"default", "default", false},
+		{"*", metav1.NamespaceAll, false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		providers, err := discoveryProviders(&flag.Config{CAPIDiscovery: true, CAPINamespace: tt.namespace})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: 期望错误 %v，实际 %v", tt.namespace, tt.wantErr, err)
+			continue
+		}
+		if !tt.wantErr && providers[0].namespace != tt.want {
+			t.Errorf("%q: 期望命名空间 %q，实际 %q", tt.namespace, tt.want, providers[0].namespace)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/webhook"
+	"k8s.io/klog/v2"
+)
+
+// 集群状态变化的原因
+const (
+	ClusterHealthReasonConnect   = "connect"   // 连接或重新连接
+	ClusterHealthReasonHeartbeat = "heartbeat" // 心跳连续失败达到阈值
+	ClusterHealthReasonManual    = "manual"    // 手动断开、移除集群，不计入可用率
+	ClusterHealthReasonAgent     = "agent"     // Agent 反向隧道断开
+	ClusterHealthReasonReconnect = "reconnect" // 自动重连尝试
+)
+
+// 可用率统计中的集群状态
+const (
+	clusterHealthUp          = "up"
+	clusterHealthDown        = "down"
+	clusterHealthUnmonitored = "unmonitored"
+)
+
+// clusterHealthCleanupInterval 两次清理过期记录的最小间隔
+const clusterHealthCleanupInterval = time.Hour
+
+// clusterHealthMaxGapIntervals 可用期间超过该倍数的心跳间隔没有心跳时，视为未监测，如 k8m 停止运行期间
+const clusterHealthMaxGapIntervals = 2
+
+// ClusterOutage 集群中断时段
+type ClusterOutage struct {
+	Start           time.Time                  `json:"start"`
+	End             time.Time                  `json:"end"`
+	Ongoing         bool                       `json:"ongoing"` // 截至统计结束时仍未恢复
+	DurationSeconds int64                      `json:"duration_seconds"`
+	Reason          string                     `json:"reason,omitempty"`
+	ErrType         constants.ClusterErrorType `json:"err_type,omitempty"`
+	Err             string                     `json:"err,omitempty"`
+}
+
+// ClusterHealthReport 集群在统计时段内的可用率报告
+// 手动断开期间不计入可用率，Availability 为 nil 表示时段内没有监测数据
+type ClusterHealthReport struct {
+	Cluster            string           `json:"cluster"`
+	From               time.Time        `json:"from"`
+	To                 time.Time        `json:"to"`
+	Status             string           `json:"status"` // 统计结束时的状态：up、down、unmonitored
+	Availability       *float64         `json:"availability"`
+	UpSeconds          int64            `json:"up_seconds"`
+	DownSeconds        int64            `json:"down_seconds"`
+	UnmonitoredSeconds int64            `json:"unmonitored_seconds"`
+	OutageCount        int              `json:"outage_count"`
+	Outages            []*ClusterOutage `json:"outages"`
+	ReconnectAttempts  int              `json:"reconnect_attempts"`
+	Heartbeats         int              `json:"heartbeats"`
+	HeartbeatFailures  int              `json:"heartbeat_failures"`
+	LatencyAvgMs       int64            `json:"latency_avg_ms"`
+	LatencyP50Ms       int64            `json:"latency_p50_ms"`
+	LatencyP95Ms       int64            `json:"latency_p95_ms"`
+	LatencyP99Ms       int64            `json:"latency_p99_ms"`
+}
+
+// clusterHealthState 集群最近一次的状态及开始时间，用于识别断开、恢复
+type clusterHealthState struct {
+	state string
+	since time.Time
+}
+
+// clusterHealthService 持久化集群心跳、连接状态变化及自动重连尝试，统计可用率并在断开、恢复时通知
+// 多实例部署时每个实例都会连接集群，仅 Leader 持久化及通知，避免重复记录、重复告警
+type clusterHealthService struct {
+	mu          sync.Mutex
+	states      map[string]*clusterHealthState
+	lastCleanup time.Time
+	leading     atomic.Bool
+}
+
+// Start 成为 Leader 后开始持久化集群健康数据，ctx 结束（失去 Leader）后停止
+func (s *clusterHealthService) Start(ctx context.Context) {
+	s.mu.Lock()
+	// 非 Leader 期间的状态变化未记录，清空缓存后从数据库重新恢复
+	s.states = nil
+	s.mu.Unlock()
+	s.leading.Store(true)
+	klog.V(2).Infof("开始记录集群健康数据")
+	// 成为 Leader 前已连接的集群补记一次状态
+	for _, cc := range ClusterService().AllClusters() {
+		if cc.ClusterConnectStatus == constants.ClusterConnectStatusConnected {
+			s.RecordStatus(cc, ClusterHealthReasonConnect)
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		s.leading.Store(false)
+		klog.V(2).Infof("停止记录集群健康数据")
+	}()
+}
+
+// clusterHealthStateOf 将连接状态映射为可用率统计中的状态，手动断开不计入可用率
+func clusterHealthStateOf(status constants.ClusterConnectStatus, reason string) string {
+	switch {
+	case status == constants.ClusterConnectStatusConnected:
+		return clusterHealthUp
+	case reason == ClusterHealthReasonManual:
+		return clusterHealthUnmonitored
+	default:
+		return clusterHealthDown
+	}
+}
+
+// RecordHeartbeat 记录一次心跳结果
+func (s *clusterHealthService) RecordHeartbeat(cluster *ClusterConfig, ts time.Time, latency time.Duration, err error) {
+	if !s.leading.Load() {
+		return
+	}
+	item := &models.ClusterHeartbeat{
+		Cluster:   cluster.GetClusterID(),
+		Success:   err == nil,
+		CreatedAt: ts,
+	}
+	if err == nil {
+		item.LatencyMs = latency.Milliseconds()
+	} else {
+		item.ErrType = cluster.ErrType
+		item.Err = err.Error()
+	}
+	if err := dao.DB().Create(item).Error; err != nil {
+		klog.V(6).Infof("记录集群 %s 心跳失败: %v", item.Cluster, err)
+	}
+	s.cleanup()
+}
+
+// RecentHeartbeats 返回集群最近的心跳记录，按时间正序，用于重启后恢复心跳历史
+func (s *clusterHealthService) RecentHeartbeats(clusterID string, limit int) []*models.ClusterHeartbeat {
+	var items []*models.ClusterHeartbeat
+	err := dao.DB().Where("cluster = ?", clusterID).Order("created_at desc").Limit(limit).Find(&items).Error
+	if err != nil {
+		klog.V(6).Infof("读取集群 %s 心跳历史失败: %v", clusterID, err)
+		return nil
+	}
+	slices.Reverse(items)
+	return items
+}
+
+// RecordStatus 记录集群连接状态变化，状态未变化时不记录；断开及恢复时发送通知
+func (s *clusterHealthService) RecordStatus(cluster *ClusterConfig, reason string) {
+	if !s.leading.Load() || cluster == nil || cluster.ClusterConnectStatus == constants.ClusterConnectStatusConnecting {
+		return
+	}
+	clusterID := cluster.GetClusterID()
+	state := clusterHealthStateOf(cluster.ClusterConnectStatus, reason)
+	now := time.Now()
+
+	s.mu.Lock()
+	prev := s.state(clusterID)
+	if prev != nil && prev.state == state {
+		s.mu.Unlock()
+		return
+	}
+	s.states[clusterID] = &clusterHealthState{state: state, since: now}
+	s.mu.Unlock()
+
+	event := &models.ClusterStatusEvent{
+		Cluster:   clusterID,
+		Status:    cluster.ClusterConnectStatus,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	if state == clusterHealthDown {
+		event.ErrType = cluster.ErrType
+		event.Err = cluster.Err
+	}
+	if err := dao.DB().Create(event).Error; err != nil {
+		klog.Errorf("记录集群 %s 状态变化失败: %v", clusterID, err)
+	}
+	klog.V(4).Infof("集群 %s 状态变化为 %s[%s]", clusterID, cluster.ClusterConnectStatus, reason)
+
+	switch {
+	case state == clusterHealthDown:
+		msg := fmt.Sprintf("k8m 集群告警：集群[%s]%s，时间 %s。", clusterID, clusterHealthReasonText(reason), now.Format(time.DateTime))
+		if event.Err != "" {
+			msg += "错误信息：" + event.Err
+		}
+		go s.notify("k8m 集群已断开："+clusterID, msg)
+	case state == clusterHealthUp && prev != nil && prev.state == clusterHealthDown:
+		msg := fmt.Sprintf("k8m 集群恢复：集群[%s]已恢复连接，中断时长 %s（%s 至 %s）。", clusterID,
+			now.Sub(prev.since).Round(time.Second), prev.since.Format(time.DateTime), now.Format(time.DateTime))
+		go s.notify("k8m 集群已恢复："+clusterID, msg)
+	}
+}
+
+// RecordReconnect 记录一次自动重连尝试及结果
+func (s *clusterHealthService) RecordReconnect(cluster *ClusterConfig, attempt int) {
+	if !s.leading.Load() || cluster == nil {
+		return
+	}
+	event := &models.ClusterStatusEvent{
+		Cluster: cluster.GetClusterID(),
+		Status:  cluster.ClusterConnectStatus,
+		Reason:  ClusterHealthReasonReconnect,
+		Attempt: attempt,
+	}
+	if cluster.ClusterConnectStatus != constants.ClusterConnectStatusConnected {
+		event.ErrType = cluster.ErrType
+		event.Err = cluster.Err
+	}
+	if err := dao.DB().Create(event).Error; err != nil {
+		klog.V(6).Infof("记录集群 %s 自动重连失败: %v", event.Cluster, err)
+	}
+}
+
+// state 返回集群最近一次的状态，首次使用时从数据库中恢复，调用方需持有锁
+func (s *clusterHealthService) state(clusterID string) *clusterHealthState {
+	if s.states == nil {
+		s.states = map[string]*clusterHealthState{}
+	}
+	if st, ok := s.states[clusterID]; ok {
+		return st
+	}
+	event := &models.ClusterStatusEvent{}
+	err := dao.DB().Where("cluster = ? AND reason <> ?", clusterID, ClusterHealthReasonReconnect).
+		Order("created_at desc").First(event).Error
+	if err != nil {
+		return nil
+	}
+	st := &clusterHealthState{state: clusterHealthStateOf(event.Status, event.Reason), since: event.CreatedAt}
+	s.states[clusterID] = st
+	return st
+}
+
+// Report 统计集群在指定时段内的可用率、心跳延迟分位数及中断时段
+func (s *clusterHealthService) Report(clusterID string, from, to time.Time) (*ClusterHealthReport, error) {
+	var initial *models.ClusterStatusEvent
+	var before []*models.ClusterStatusEvent
+	err := dao.DB().Where("cluster = ? AND created_at < ? AND reason <> ?", clusterID, from, ClusterHealthReasonReconnect).
+		Order("created_at desc").Limit(1).Find(&before).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(before) > 0 {
+		initial = before[0]
+	}
+	var events []*models.ClusterStatusEvent
+	err = dao.DB().Where("cluster = ? AND created_at >= ? AND created_at < ?", clusterID, from, to).
+		Order("created_at asc").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	var heartbeats []*models.ClusterHeartbeat
+	err = dao.DB().Select("success", "latency_ms", "created_at").
+		Where("cluster = ? AND created_at >= ? AND created_at < ?", clusterID, from, to).
+		Order("created_at asc").Find(&heartbeats).Error
+	if err != nil {
+		return nil, err
+	}
+	interval := time.Duration(ClusterService().HeartbeatIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	report := buildClusterHealthReport(initial, events, heartbeats, from, to, interval)
+	report.Cluster = clusterID
+	return report, nil
+}
+
+// buildClusterHealthReport 按状态变化时间线计算各状态时长、中断时段，并按成功的心跳计算延迟分位数
+// initial 为统计开始前最近一次的状态变化，events、heartbeats 按时间正序
+// 可用期间超过 clusterHealthMaxGapIntervals 个心跳间隔没有心跳的时段计为未监测，interval 为 0 时不拆分
+func buildClusterHealthReport(initial *models.ClusterStatusEvent, events []*models.ClusterStatusEvent, heartbeats []*models.ClusterHeartbeat, from, to time.Time, interval time.Duration) *ClusterHealthReport {
+	report := &ClusterHealthReport{From: from, To: to, Outages: []*ClusterOutage{}}
+
+	state := clusterHealthUnmonitored
+	var outage *ClusterOutage
+	if initial != nil {
+		state = clusterHealthStateOf(initial.Status, initial.Reason)
+		if state == clusterHealthDown {
+			outage = &ClusterOutage{Start: initial.CreatedAt, Reason: initial.Reason, ErrType: initial.ErrType, Err: initial.Err}
+		}
+	}
+	durations := map[string]time.Duration{}
+	maxGap := interval * clusterHealthMaxGapIntervals
+	hbIdx := 0 // 下一条未处理的心跳
+	// addDuration 累加 [start, end) 的状态时长，返回结束时是否仍在监测
+	addDuration := func(state string, start, end time.Time) bool {
+		if state != clusterHealthUp || maxGap <= 0 {
+			durations[state] += end.Sub(start)
+			return true
+		}
+		prev, monitored := start, true
+		addGap := func(at time.Time) {
+			gap := at.Sub(prev)
+			if monitored = gap <= maxGap; monitored {
+				durations[clusterHealthUp] += gap
+			} else {
+				durations[clusterHealthUnmonitored] += gap
+			}
+			prev = at
+		}
+		for ; hbIdx < len(heartbeats) && heartbeats[hbIdx].CreatedAt.Before(end); hbIdx++ {
+			if at := heartbeats[hbIdx].CreatedAt; at.After(prev) {
+				addGap(at)
+			}
+		}
+		addGap(end)
+		return monitored
+	}
+	last := from
+	for _, event := range events {
+		if event.Reason == ClusterHealthReasonReconnect {
+			report.ReconnectAttempts++
+			// 重连成功前状态已为断开，重连结果不改变状态
+			if event.Status != constants.ClusterConnectStatusConnected {
+				continue
+			}
+		}
+		next := clusterHealthStateOf(event.Status, event.Reason)
+		if next == state {
+			continue
+		}
+		addDuration(state, last, event.CreatedAt)
+		last = event.CreatedAt
+		if state == clusterHealthDown && outage != nil {
+			outage.End = event.CreatedAt
+			outage.DurationSeconds = int64(outage.End.Sub(outage.Start).Seconds())
+			report.Outages = append(report.Outages, outage)
+			outage = nil
+		}
+		if next == clusterHealthDown {
+			outage = &ClusterOutage{Start: event.CreatedAt, Reason: event.Reason, ErrType: event.ErrType, Err: event.Err}
+		}
+		state = next
+	}
+	monitored := addDuration(state, last, to)
+	if outage != nil {
+		outage.End = to
+		outage.Ongoing = true
+		outage.DurationSeconds = int64(to.Sub(outage.Start).Seconds())
+		report.Outages = append(report.Outages, outage)
+	}
+
+	report.Status = state
+	if !monitored {
+		report.Status = clusterHealthUnmonitored
+	}
+	report.OutageCount = len(report.Outages)
+	report.UpSeconds = int64(durations[clusterHealthUp].Seconds())
+	report.DownSeconds = int64(durations[clusterHealthDown].Seconds())
+	report.UnmonitoredSeconds = int64(durations[clusterHealthUnmonitored].Seconds())
+	if monitored := durations[clusterHealthUp] + durations[clusterHealthDown]; monitored > 0 {
+		availability := math.Round(float64(durations[clusterHealthUp])/float64(monitored)*100000) / 1000
+		report.Availability = &availability
+	}
+
+	var latencies []int64
+	var sum int64
+	for _, hb := range heartbeats {
+		report.Heartbeats++
+		if !hb.Success {
+			report.HeartbeatFailures++
+			continue
+		}
+		latencies = append(latencies, hb.LatencyMs)
+		sum += hb.LatencyMs
+	}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		report.LatencyAvgMs = sum / int64(len(latencies))
+		report.LatencyP50Ms = latencyPercentile(latencies, 50)
+		report.LatencyP95Ms = latencyPercentile(latencies, 95)
+		report.LatencyP99Ms = latencyPercentile(latencies, 99)
+	}
+	return report
+}
+
+// latencyPercentile 按最近秩法计算已排序延迟的分位数
+func latencyPercentile(sorted []int64, p float64) int64 {
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(idx, 0)]
+}
+
+// ParseClusterHealthPeriod 解析统计时段，支持 7d 形式的天数及 Go 时长格式，如 1h、24h
+func ParseClusterHealthPeriod(period string) (time.Duration, error) {
+	period = strings.TrimSpace(period)
+	if period == "" {
+		return 24 * time.Hour, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(period, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("统计时段格式错误: %s", period)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(period); err != nil {
+			return 0, fmt.Errorf("统计时段格式错误: %s", period)
+		}
+	}
+	if d <= 0 {
+		return 0, errors.New("统计时段必须大于 0")
+	}
+	return d, nil
+}
+
+// notify 通过系统配置的 webhook 及邮箱发送集群断开、恢复通知
+func (s *clusterHealthService) notify(subject, msg string) {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil {
+		return
+	}
+	if ids := utils.SplitAndTrim(cfg.ClusterHealthWebhooks, ","); len(ids) > 0 {
+		var receivers []*models.WebhookReceiver
+		if err := dao.DB().Where("id in ?", ids).Find(&receivers).Error; err != nil {
+			klog.Errorf("查询集群健康通知webhook失败: %v", err)
+		}
+		webhook.PushMsgToAllTargets(msg, "", receivers)
+	}
+	if emails := utils.SplitAndTrim(cfg.ClusterHealthEmails, ","); len(emails) > 0 {
+		err := MailService().Send(emails, subject, msg)
+		if err != nil && !errors.Is(err, ErrMailNotConfigured) {
+			klog.Errorf("发送集群健康通知邮件失败: %v", err)
+		}
+	}
+}
+
+// cleanup 定期删除超过保留天数的心跳及状态变化记录
+func (s *clusterHealthService) cleanup() {
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < clusterHealthCleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	days := 30
+	if cfg, err := ConfigService().GetConfig(); err == nil && cfg.ClusterHealthRetentionDays > 0 {
+		days = cfg.ClusterHealthRetentionDays
+	}
+	threshold := time.Now().AddDate(0, 0, -days)
+	if err := dao.DB().Where("created_at < ?", threshold).Delete(&models.ClusterHeartbeat{}).Error; err != nil {
+		klog.V(6).Infof("清理集群心跳记录失败: %v", err)
+	}
+	// 保留每个集群最近一次的状态变化，作为后续统计的起始状态
+	var latest []uint
+	err := dao.DB().Model(&models.ClusterStatusEvent{}).
+		Where("reason <> ?", ClusterHealthReasonReconnect).Group("cluster").Pluck("max(id)", &latest).Error
+	if err != nil {
+		klog.V(6).Infof("查询集群最近状态变化失败: %v", err)
+		return
+	}
+	query := dao.DB().Where("created_at < ?", threshold)
+	if len(latest) > 0 {
+		query = query.Where("id NOT IN ?", latest)
+	}
+	if err := query.Delete(&models.ClusterStatusEvent{}).Error; err != nil {
+		klog.V(6).Infof("清理集群状态变化记录失败: %v", err)
+	}
+}
+
+func clusterHealthReasonText(reason string) string {
+	switch reason {
+	case ClusterHealthReasonHeartbeat:
+		return "心跳连续失败，已断开"
+	case ClusterHealthReasonAgent:
+		return "Agent 已断开"
+	default:
+		return "连接失败"
+	}
+}
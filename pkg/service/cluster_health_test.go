@@ -0,0 +1,138 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestBuildClusterHealthReport(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	to := from.Add(10 * time.Hour)
+	at := func(h float64) time.Time { return from.Add(time.Duration(h * float64(time.Hour))) }
+	event := func(h float64, status constants.ClusterConnectStatus, reason string) *models.ClusterStatusEvent {
+		return &models.ClusterStatusEvent{Status: status, Reason: reason, CreatedAt: at(h)}
+	}
+	connected := constants.ClusterConnectStatusConnected
+	disconnected := constants.ClusterConnectStatusDisconnected
+	failed := constants.ClusterConnectStatusFailed
+
+	tests := []struct {
+		name         string
+		initial      *models.ClusterStatusEvent
+		events       []*models.ClusterStatusEvent
+		availability float64 // -1 表示没有监测数据
+		outages      int
+		ongoing      bool
+		status       string
+		reconnects   int
+	}{
+		{"没有记录", nil, nil, -1, 0, false, clusterHealthUnmonitored, 0},
+		{"全程可用", event(-1, connected, ClusterHealthReasonConnect), nil, 100, 0, false, clusterHealthUp, 0},
+		{"心跳失败后自动重连恢复", event(-1, connected, ClusterHealthReasonConnect), []*models.ClusterStatusEvent{
+			event(2, disconnected, ClusterHealthReasonHeartbeat),
+			event(2.5, failed, ClusterHealthReasonReconnect),
+			event(3, connected, ClusterHealthReasonConnect),
+			event(3, connected, ClusterHealthReasonReconnect),
+		}, 90, 1, false, clusterHealthUp, 2},
+		{"统计开始时已断开", event(-2, failed, ClusterHealthReasonConnect), []*models.ClusterStatusEvent{
+			event(5, connected, ClusterHealthReasonConnect),
+		}, 50, 1, false, clusterHealthUp, 0},
+		{"手动断开不计入", event(-1, connected, ClusterHealthReasonConnect), []*models.ClusterStatusEvent{
+			event(5, disconnected, ClusterHealthReasonManual),
+		}, 100, 0, false, clusterHealthUnmonitored, 0},
+		{"截至结束仍未恢复", nil, []*models.ClusterStatusEvent{
+			event(2, connected, ClusterHealthReasonConnect),
+			event(6, disconnected, ClusterHealthReasonAgent),
+		}, 50, 1, true, clusterHealthDown, 0},
+	}
+	for _, tt := range tests {
+		report := buildClusterHealthReport(tt.initial, tt.events, nil, from, to, 0)
+		if tt.availability < 0 {
+			if report.Availability != nil {
+				t.Errorf("%s: 期望没有可用率，实际 %v", tt.name, *report.Availability)
+			}
+		} else if report.Availability == nil || *report.Availability != tt.availability {
+			t.Errorf("%s: 期望可用率 %v，实际 %v", tt.name, tt.availability, report.Availability)
+		}
+		if report.OutageCount != tt.outages {
+			t.Errorf("%s: 期望中断 %d 次，实际 %d", tt.name, tt.outages, report.OutageCount)
+		}
+		if tt.outages > 0 && report.Outages[len(report.Outages)-1].Ongoing != tt.ongoing {
+			t.Errorf("%s: 期望最后一次中断未恢复 %v", tt.name, tt.ongoing)
+		}
+		if report.Status != tt.status {
+			t.Errorf("%s: 期望状态 %s，实际 %s", tt.name, tt.status, report.Status)
+		}
+		if report.ReconnectAttempts != tt.reconnects {
+			t.Errorf("%s: 期望重连 %d 次，实际 %d", tt.name, tt.reconnects, report.ReconnectAttempts)
+		}
+	}
+
+	// 开始前已断开的中断时长从实际断开时间算起
+	report := buildClusterHealthReport(event(-2, failed, ClusterHealthReasonConnect),
+		[]*models.ClusterStatusEvent{event(5, connected, ClusterHealthReasonConnect)}, nil, from, to, 0)
+	if got := report.Outages[0].DurationSeconds; got != 7*3600 {
+		t.Errorf("期望中断 7 小时，实际 %d 秒", got)
+	}
+	if report.DownSeconds != 5*3600 || report.UpSeconds != 5*3600 {
+		t.Errorf("统计时长不正确: 可用 %d 秒，中断 %d 秒", report.UpSeconds, report.DownSeconds)
+	}
+
+	// 可用期间每半小时一次心跳，2~5 小时之间没有心跳（如 k8m 停止运行）计为未监测
+	var heartbeats []*models.ClusterHeartbeat
+	for h := 0.5; h < 10; h += 0.5 {
+		if h <= 2 || h >= 5 {
+			heartbeats = append(heartbeats, &models.ClusterHeartbeat{Success: true, CreatedAt: at(h)})
+		}
+	}
+	report = buildClusterHealthReport(event(-1, connected, ClusterHealthReasonConnect), nil, heartbeats, from, to, 30*time.Minute)
+	if report.UpSeconds != 7*3600 || report.UnmonitoredSeconds != 3*3600 || report.Status != clusterHealthUp {
+		t.Errorf("心跳间断统计不正确: 可用 %d 秒，未监测 %d 秒，状态 %s", report.UpSeconds, report.UnmonitoredSeconds, report.Status)
+	}
+	// 最后一次心跳之后长时间没有心跳，当前状态为未监测
+	report = buildClusterHealthReport(event(-1, connected, ClusterHealthReasonConnect), nil, heartbeats[:4], from, to, 30*time.Minute)
+	if report.UpSeconds != 2*3600 || report.Status != clusterHealthUnmonitored {
+		t.Errorf("心跳停止后统计不正确: 可用 %d 秒，状态 %s", report.UpSeconds, report.Status)
+	}
+}
+
+func TestClusterHealthLatency(t *testing.T) {
+	var heartbeats []*models.ClusterHeartbeat
+	for i := 100; i >= 1; i-- {
+		heartbeats = append(heartbeats, &models.ClusterHeartbeat{Success: true, LatencyMs: int64(i)})
+	}
+	heartbeats = append(heartbeats, &models.ClusterHeartbeat{Success: false})
+	now := time.Now()
+	report := buildClusterHealthReport(nil, nil, heartbeats, now.Add(-time.Hour), now, 0)
+	if report.Heartbeats != 101 || report.HeartbeatFailures != 1 {
+		t.Errorf("心跳次数不正确: %d/%d", report.Heartbeats, report.HeartbeatFailures)
+	}
+	if report.LatencyP50Ms != 50 || report.LatencyP95Ms != 95 || report.LatencyP99Ms != 99 || report.LatencyAvgMs != 50 {
+		t.Errorf("延迟分位数不正确: p50=%d p95=%d p99=%d avg=%d", report.LatencyP50Ms, report.LatencyP95Ms, report.LatencyP99Ms, report.LatencyAvgMs)
+	}
+}
+
+func TestParseClusterHealthPeriod(t *testing.T) {
+	tests := []struct {
+		period  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 24 * time.Hour, false},
+		{"1h", time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseClusterHealthPeriod(tt.period)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q: 期望 %v（错误 %v），实际 %v（%v）", tt.period, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/constants"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/transport"
+)
+
+// impersonateReservedPrefix Kubernetes 内置用户及用户组前缀，如 system:masters，不允许模拟
+const impersonateReservedPrefix = "system:"
+
+// ErrImpersonateUnsupported 集群内模式及 AWS EKS 集群由 kom 使用独立的凭据注册，无法添加模拟请求头
+var ErrImpersonateUnsupported = errors.New("集群内模式及 AWS EKS 集群不支持模拟用户，请关闭模拟用户后重新连接")
+
+// validateImpersonate 开启模拟但集群不支持时拒绝连接，避免静默以 k8m 自身的身份访问集群
+func validateImpersonate(cc *ClusterConfig) error {
+	if cc.Impersonate && (cc.IsInCluster || cc.IsAWSEKS) {
+		return ErrImpersonateUnsupported
+	}
+	return nil
+}
+
+// CacheTTL 返回 kom 查询使用的缓存时间，开启模拟用户的集群返回 0 不使用缓存
+// kom 的查询缓存按集群共享，不区分用户，模拟用户时会读到其他用户的查询结果
+func (c *clusterService) CacheTTL(clusterID string, ttl time.Duration) time.Duration {
+	if cc := c.GetClusterByID(clusterID); cc != nil && cc.Impersonate {
+		return 0
+	}
+	return ttl
+}
+
+// impersonateWrapper 开启模拟的集群，按请求上下文中的 k8m 用户添加 Impersonate-User、Impersonate-Group 请求头
+// 集群注册的凭据需有 impersonate 权限，集群 RBAC 及 apiserver 审计日志作用于实际用户
+func impersonateWrapper(cc *ClusterConfig) transport.WrapperFunc {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &impersonateRoundTripper{cluster: cc, rt: rt}
+	}
+}
+
+type impersonateRoundTripper struct {
+	cluster *ClusterConfig
+	rt      http.RoundTripper
+}
+
+func (r *impersonateRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	username, _ := req.Context().Value(constants.JwtUserName).(string)
+	if username == "" {
+		// 心跳、巡检、事件监听等后台任务没有用户上下文，使用集群注册的凭据
+		return r.rt.RoundTrip(req)
+	}
+	user, groups, err := ImpersonateIdentity(r.cluster.ImpersonatePrefix, username)
+	if err != nil {
+		return nil, err
+	}
+	req = utilnet.CloneRequest(req)
+	req.Header.Set(transport.ImpersonateUserHeader, user)
+	req.Header.Del(transport.ImpersonateGroupHeader)
+	for _, group := range groups {
+		req.Header.Add(transport.ImpersonateGroupHeader, group)
+	}
+	return r.rt.RoundTrip(req)
+}
+
+func (r *impersonateRoundTripper) WrappedRoundTripper() http.RoundTripper { return r.rt }
+
+// ImpersonateIdentity 将 k8m 用户及其用户组转换为模拟的 Kubernetes 用户及用户组，统一加上前缀
+// apiserver 会为模拟的用户自动加入 system:authenticated 用户组
+func ImpersonateIdentity(prefix, username string) (string, []string, error) {
+	user := prefix + username
+	if strings.HasPrefix(user, impersonateReservedPrefix) {
+		return "", nil, fmt.Errorf("不允许模拟 Kubernetes 内置用户: %s", user)
+	}
+	groupNames, err := UserService().GetGroupNames(username)
+	if err != nil {
+		// 临时管理员等不在数据库中的用户没有用户组
+		groupNames = nil
+	}
+	var groups []string
+	for _, name := range groupNames {
+		group := prefix + name
+		if strings.HasPrefix(group, impersonateReservedPrefix) {
+			return "", nil, fmt.Errorf("不允许模拟 Kubernetes 内置用户组: %s", group)
+		}
+		groups = append(groups, group)
+	}
+	return user, groups, nil
+}
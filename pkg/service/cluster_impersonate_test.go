@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/constants"
+	"k8s.io/client-go/transport"
+)
+
+func TestImpersonateIdentityReserved(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		username string
+	}{
+		{"无前缀的内置用户", "", "system:admin"},
+		{"前缀本身为内置前缀", "system:", "alice"},
+		{"拼接后为内置用户", "system", ":kube-scheduler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ImpersonateIdentity(tt.prefix, tt.username); err == nil {
+				t.Errorf("前缀 %q 用户 %q 应拒绝模拟", tt.prefix, tt.username)
+			}
+		})
+	}
+}
+
+// captureRoundTripper 记录转发的请求
+type captureRoundTripper struct {
+	req *http.Request
+}
+
+func (c *captureRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+}
+
+func TestImpersonateRoundTripper(t *testing.T) {
+	// 预先写入用户组缓存，避免访问数据库
+	CacheService().CacheInstance().SetWithTTL(UserService().formatCacheKey("user:groupnames:%s", "alice"), []string{"dev", "ops"}, 100, time.Minute)
+	CacheService().CacheInstance().Wait()
+
+	tests := []struct {
+		name     string
+		prefix   string
+		username string
+		user     string
+		groups   []string
+		wantErr  bool
+	}{
+		{"模拟用户及用户组", "k8m:", "alice", "k8m:alice", []string{"k8m:dev", "k8m:ops"}, false},
+		{"没有用户上下文", "k8m:", "", "", nil, false},
+		{"内置用户", "", "system:admin", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := &captureRoundTripper{}
+			rt := impersonateWrapper(&ClusterConfig{ImpersonatePrefix: tt.prefix})(capture)
+			ctx := context.Background()
+			if tt.username != "" {
+				ctx = context.WithValue(ctx, constants.JwtUserName, tt.username)
+			}
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://kubernetes.default/api/v1/pods", nil)
+			req.Header.Add(transport.ImpersonateGroupHeader, "system:masters")
+
+			_, err := rt.RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("期望错误 %v，实际 %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				if capture.req != nil {
+					t.Errorf("拒绝模拟时不应转发请求")
+				}
+				return
+			}
+			if tt.username == "" {
+				if capture.req != req {
+					t.Errorf("没有用户上下文时应原样转发请求")
+				}
+				if capture.req.Header.Get(transport.ImpersonateUserHeader) != "" {
+					t.Errorf("没有用户上下文时不应添加 Impersonate-User")
+				}
+				return
+			}
+			if got := capture.req.Header.Get(transport.ImpersonateUserHeader); got != tt.user {
+				t.Errorf("Impersonate-User 期望 %s，实际 %s", tt.user, got)
+			}
+			if got := capture.req.Header.Values(transport.ImpersonateGroupHeader); !slices.Equal(got, tt.groups) {
+				t.Errorf("Impersonate-Group 期望 %v，实际 %v", tt.groups, got)
+			}
+			if got := req.Header.Values(transport.ImpersonateGroupHeader); !slices.Equal(got, []string{"system:masters"}) {
+				t.Errorf("不应修改原请求的请求头: %v", got)
+			}
+		})
+	}
+}
+
+func TestImpersonateUnsupportedCluster(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster *ClusterConfig
+	}{
+		{"集群内模式", &ClusterConfig{IsInCluster: true, Impersonate: true}},
+		{"AWS EKS 集群", &ClusterConfig{FileName: "eks", ContextName: "eks", IsAWSEKS: true, Impersonate: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ClusterService().RegisterCluster(tt.cluster); !errors.Is(err, ErrImpersonateUnsupported) {
+				t.Errorf("开启模拟应拒绝注册，实际 %v", err)
+			}
+			if tt.cluster.ClusterConnectStatus != constants.ClusterConnectStatusFailed {
+				t.Errorf("状态应为连接失败，实际 %s", tt.cluster.ClusterConnectStatus)
+			}
+		})
+	}
+}
+
+func TestClusterCacheTTL(t *testing.T) {
+	cc := &ClusterConfig{FileName: "impersonate-cache-test", ContextName: "ctx", Impersonate: true}
+	ClusterService().AddToClusterList(cc)
+	t.Cleanup(func() {
+		ClusterService().RemoveFromClusterList(func(item *ClusterConfig) bool { return item == cc })
+	})
+
+	if ttl := ClusterService().CacheTTL(cc.GetClusterID(), time.Minute); ttl != 0 {
+		t.Errorf("开启模拟的集群不应使用缓存，实际 %s", ttl)
+	}
+	if ttl := ClusterService().CacheTTL("not-exist/ctx", time.Minute); ttl != time.Minute {
+		t.Errorf("未开启模拟的集群应保留缓存时间，实际 %s", ttl)
+	}
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ClusterSSHAuthKey 使用私钥登录跳板机
+	ClusterSSHAuthKey = "key"
+	// ClusterSSHAuthAgent 使用 k8m 所在主机的 ssh-agent 登录跳板机，通过 SSH_AUTH_SOCK 连接
+	ClusterSSHAuthAgent = "agent"
+
+	sshDialTimeout = 15 * time.Second
+)
+
+// ClusterSSHConfig SSH 跳板机配置，私钥等敏感信息不输出到前端
+type ClusterSSHConfig struct {
+	Host       string `json:"host,omitempty"`        // 跳板机地址，host:port
+	User       string `json:"user,omitempty"`        // 登录用户
+	AuthMethod string `json:"auth_method,omitempty"` // key、agent
+	PrivateKey string `json:"-"`
+	Passphrase string `json:"-"`
+	KnownHosts string `json:"-"`
+}
+
+// clusterSSHTunnel 经 SSH 跳板机访问 apiserver 的本机转发入口
+// client-go 的 exec、port-forward 等协议升级请求不使用 rest.Config.Dial，
+// 因此在本机回环地址监听，将 rest.Config 的地址指向该入口，所有请求均经跳板机转发
+type clusterSSHTunnel struct {
+	cfg      *ClusterSSHConfig
+	target   string // apiserver 地址，host:port
+	listener net.Listener
+	mu       sync.Mutex
+	client   *ssh.Client
+}
+
+// ClusterSSHConfigFromModel 数据库中的跳板机配置，未配置时返回 nil
+func ClusterSSHConfigFromModel(item *models.KubeConfig) *ClusterSSHConfig {
+	if item.SSHHost == "" {
+		return nil
+	}
+	return &ClusterSSHConfig{
+		Host:       item.SSHHost,
+		User:       item.SSHUser,
+		AuthMethod: item.SSHAuthMethod,
+		PrivateKey: item.SSHPrivateKey,
+		Passphrase: item.SSHPassphrase,
+		KnownHosts: item.SSHKnownHosts,
+	}
+}
+
+// ValidateClusterNetwork 校验集群的代理及 SSH 跳板机配置
+func ValidateClusterNetwork(proxyURL string, sshCfg *ClusterSSHConfig) error {
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("代理地址格式错误: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("代理地址仅支持 http://、https://、socks5:// 开头")
+		}
+	}
+	if sshCfg == nil || sshCfg.Host == "" {
+		return nil
+	}
+	if proxyURL != "" {
+		return fmt.Errorf("SSH 跳板机与代理不能同时配置")
+	}
+	if _, _, err := net.SplitHostPort(sshCfg.Host); err != nil {
+		return fmt.Errorf("跳板机地址应为 host:port 格式: %w", err)
+	}
+	if sshCfg.User == "" {
+		return fmt.Errorf("跳板机登录用户不能为空")
+	}
+	_, err := sshCfg.clientConfig()
+	return err
+}
+
+// clientConfig 根据认证方式生成 SSH 客户端配置，必须校验跳板机公钥
+func (s *ClusterSSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	if strings.TrimSpace(s.KnownHosts) == "" {
+		return nil, fmt.Errorf("未配置跳板机公钥（known_hosts），可通过 ssh-keyscan -p <端口> <地址> 获取")
+	}
+	hostKeyCallback, err := knownHostsCallback(s.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	var auth ssh.AuthMethod
+	switch s.AuthMethod {
+	case ClusterSSHAuthKey, "":
+		if s.PrivateKey == "" {
+			return nil, fmt.Errorf("未配置跳板机登录私钥")
+		}
+		var signer ssh.Signer
+		if s.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(s.PrivateKey), []byte(s.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(s.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析跳板机登录私钥失败: %w", err)
+		}
+		auth = ssh.PublicKeys(signer)
+	case ClusterSSHAuthAgent:
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, fmt.Errorf("k8m 所在主机未设置 SSH_AUTH_SOCK，无法使用 ssh-agent")
+		}
+		auth = ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				return nil, fmt.Errorf("连接 ssh-agent 失败: %w", err)
+			}
+			defer conn.Close()
+			return agent.NewClient(conn).Signers()
+		})
+	default:
+		return nil, fmt.Errorf("不支持的跳板机认证方式: %s", s.AuthMethod)
+	}
+	return &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, nil
+}
+
+// knownHostsCallback 使用配置的 known_hosts 内容校验跳板机公钥
+func knownHostsCallback(content string) (ssh.HostKeyCallback, error) {
+	f, err := os.CreateTemp("", "k8m-known-hosts-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("解析跳板机公钥失败: %w", err)
+	}
+	return callback, nil
+}
+
+// applyClusterNetwork 将代理或 SSH 跳板机应用到集群的 rest.Config
+func (c *clusterService) applyClusterNetwork(cc *ClusterConfig, restConfig *rest.Config) error {
+	clusterID := cc.GetClusterID()
+	if cc.SSH != nil && cc.SSH.Host != "" {
+		return c.applySSHTunnel(clusterID, cc.SSH, restConfig)
+	}
+	c.closeSSHTunnel(clusterID)
+	if cc.ProxyURL != "" {
+		u, err := cc.proxyURL()
+		if err != nil {
+			return err
+		}
+		restConfig.Proxy = http.ProxyURL(u)
+	}
+	return nil
+}
+
+// proxyURL 代理地址，认证信息单独加密保存，使用时合并
+func (cc *ClusterConfig) proxyURL() (*url.URL, error) {
+	u, err := url.Parse(cc.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址格式错误: %w", err)
+	}
+	if cc.ProxyUsername != "" {
+		u.User = url.UserPassword(cc.ProxyUsername, cc.proxyPassword)
+	}
+	return u, nil
+}
+
+// applySSHTunnel 创建或复用集群的 SSH 转发入口，rest.Config 改为访问本机入口，TLS 仍按原地址校验证书
+func (c *clusterService) applySSHTunnel(clusterID string, cfg *ClusterSSHConfig, restConfig *rest.Config) error {
+	server, err := url.Parse(restConfig.Host)
+	if err != nil {
+		return fmt.Errorf("集群地址格式错误: %w", err)
+	}
+	port := server.Port()
+	if port == "" {
+		port = "443"
+		if server.Scheme == "http" {
+			port = "80"
+		}
+	}
+	target := net.JoinHostPort(server.Hostname(), port)
+
+	var tunnel *clusterSSHTunnel
+	if v, ok := c.sshTunnels.Load(clusterID); ok {
+		existing := v.(*clusterSSHTunnel)
+		if existing.target == target && *existing.cfg == *cfg {
+			tunnel = existing
+		} else {
+			c.closeSSHTunnel(clusterID)
+		}
+	}
+	if tunnel == nil {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("创建 SSH 转发入口失败: %w", err)
+		}
+		copied := *cfg
+		tunnel = &clusterSSHTunnel{cfg: &copied, target: target, listener: listener}
+		c.sshTunnels.Store(clusterID, tunnel)
+		go tunnel.serve()
+	}
+	// 注册前先确认跳板机可连接，便于给出明确的错误信息
+	if _, err := tunnel.sshClient(); err != nil {
+		return err
+	}
+
+	if restConfig.TLSClientConfig.ServerName == "" {
+		restConfig.TLSClientConfig.ServerName = server.Hostname()
+	}
+	server.Host = tunnel.listener.Addr().String()
+	restConfig.Host = server.String()
+	return nil
+}
+
+// checkSSHTunnel 心跳时检测跳板机连接，未使用跳板机时返回 nil
+func (c *clusterService) checkSSHTunnel(clusterID string) error {
+	v, ok := c.sshTunnels.Load(clusterID)
+	if !ok {
+		return nil
+	}
+	client, err := v.(*clusterSSHTunnel).sshClient()
+	if err != nil {
+		return err
+	}
+	if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		v.(*clusterSSHTunnel).reset(client)
+		return fmt.Errorf("SSH 跳板机连接已断开: %w", err)
+	}
+	return nil
+}
+
+// closeSSHTunnel 关闭集群的 SSH 转发入口及跳板机连接
+func (c *clusterService) closeSSHTunnel(clusterID string) {
+	v, ok := c.sshTunnels.LoadAndDelete(clusterID)
+	if !ok {
+		return
+	}
+	t := v.(*clusterSSHTunnel)
+	_ = t.listener.Close()
+	t.mu.Lock()
+	if t.client != nil {
+		_ = t.client.Close()
+		t.client = nil
+	}
+	t.mu.Unlock()
+}
+
+// sshClient 获取跳板机连接，断开后重新连接
+func (t *clusterSSHTunnel) sshClient() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	config, err := t.cfg.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", t.cfg.Host, config)
+	if err != nil {
+		return nil, fmt.Errorf("连接 SSH 跳板机 %s 失败: %w", t.cfg.Host, err)
+	}
+	t.client = client
+	go func() {
+		_ = client.Wait()
+		t.reset(client)
+	}()
+	return client, nil
+}
+
+// reset 跳板机连接断开后清理，下次使用时重新连接
+func (t *clusterSSHTunnel) reset(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == client {
+		_ = client.Close()
+		t.client = nil
+	}
+}
+
+func (t *clusterSSHTunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.pipe(conn)
+	}
+}
+
+// pipe 经跳板机连接 apiserver，双向转发直到任一方向结束
+func (t *clusterSSHTunnel) pipe(conn net.Conn) {
+	defer conn.Close()
+	client, err := t.sshClient()
+	if err != nil {
+		klog.V(4).Infof("%v", err)
+		return
+	}
+	remote, err := client.Dial("tcp", t.target)
+	if err != nil {
+		klog.V(4).Infof("经 SSH 跳板机 %s 连接 %s 失败: %v", t.cfg.Host, t.target, err)
+		return
+	}
+	defer remote.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
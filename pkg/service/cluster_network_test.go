@@ -0,0 +1,65 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestValidateClusterNetwork(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(block))
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := "[bastion.example.com]:2222 " + string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+
+	valid := func() *ClusterSSHConfig {
+		return &ClusterSSHConfig{
+			Host:       "bastion.example.com:2222",
+			User:       "ops",
+			AuthMethod: ClusterSSHAuthKey,
+			PrivateKey: privateKey,
+			KnownHosts: knownHosts,
+		}
+	}
+	tests := []struct {
+		name    string
+		proxy   string
+		ssh     func() *ClusterSSHConfig
+		wantErr bool
+	}{
+		{"未配置", "", nil, false},
+		{"http代理", "http://proxy:8080", nil, false},
+		{"socks5代理", "socks5://proxy:1080", nil, false},
+		{"不支持的代理协议", "ftp://proxy:21", nil, true},
+		{"跳板机", "", valid, false},
+		{"跳板机与代理同时配置", "socks5://proxy:1080", valid, true},
+		{"跳板机缺少端口", "", func() *ClusterSSHConfig { c := valid(); c.Host = "bastion.example.com"; return c }, true},
+		{"跳板机缺少用户", "", func() *ClusterSSHConfig { c := valid(); c.User = ""; return c }, true},
+		{"未配置公钥", "", func() *ClusterSSHConfig { c := valid(); c.KnownHosts = ""; return c }, true},
+		{"私钥格式错误", "", func() *ClusterSSHConfig { c := valid(); c.PrivateKey = "invalid"; return c }, true},
+		{"不支持的认证方式", "", func() *ClusterSSHConfig { c := valid(); c.AuthMethod = "password"; return c }, true},
+	}
+	for _, tt := range tests {
+		var cfg *ClusterSSHConfig
+		if tt.ssh != nil {
+			cfg = tt.ssh()
+		}
+		err := ValidateClusterNetwork(tt.proxy, cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: 期望错误 %v，实际 %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// ClusterGroupTagKey 分组在标签选择器中的键名，如 group=payments，不能用作普通标签
+const ClusterGroupTagKey = "group"
+
+const clusterTagCacheKey = "cluster:tags"
+
+// clusterTagService 集群分组及标签，按标签选择器筛选集群、授权及指定巡检、事件转发的目标集群
+type clusterTagService struct{}
+
+// ValidateClusterTags 校验集群标签，键、值需符合 Kubernetes 标签规范，分组不能作为普通标签设置
+func ValidateClusterTags(tags map[string]string) error {
+	for k, v := range tags {
+		if k == ClusterGroupTagKey {
+			return fmt.Errorf("标签键 %s 为分组保留，请设置集群分组", ClusterGroupTagKey)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("标签键[%s]不合法: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("标签[%s]的值[%s]不合法: %s", k, v, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// ParseClusterSelector 解析集群标签选择器，语法同 Kubernetes 标签选择器，分组可用 group=xxx 匹配
+func ParseClusterSelector(selector string) (labels.Selector, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return nil, errors.New("集群标签选择器不能为空")
+	}
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("集群标签选择器格式错误: %w", err)
+	}
+	return s, nil
+}
+
+// tags 读取全部集群的分组及标签，集群ID -> 标签（含分组）
+func (s *clusterTagService) tags() map[string]labels.Set {
+	result, err := utils.GetOrSetCache(CacheService().CacheInstance(), clusterTagCacheKey, 5*time.Minute, func() (map[string]labels.Set, error) {
+		var items []*models.ClusterTag
+		if err := dao.DB().Find(&items).Error; err != nil {
+			return nil, err
+		}
+		m := make(map[string]labels.Set, len(items))
+		for _, item := range items {
+			set, err := labels.ConvertSelectorToLabelsMap(item.Tags)
+			if err != nil {
+				klog.Warningf("集群[%s]标签格式错误，已忽略: %v", item.Cluster, err)
+				set = labels.Set{}
+			}
+			if item.Group != "" {
+				set[ClusterGroupTagKey] = item.Group
+			}
+			m[item.Cluster] = set
+		}
+		return m, nil
+	})
+	if err != nil {
+		klog.Errorf("读取集群标签失败: %v", err)
+		return map[string]labels.Set{}
+	}
+	return result
+}
+
+// Labels 返回集群的标签，分组以 group 键给出
+func (s *clusterTagService) Labels(clusterID string) labels.Set {
+	if set, ok := s.tags()[clusterID]; ok {
+		return set
+	}
+	return labels.Set{}
+}
+
+// Save 保存集群的分组及标签，匹配该集群的标签授权随即生效
+func (s *clusterTagService) Save(clusterID, group string, tags map[string]string) error {
+	if clusterID == "" {
+		return errors.New("集群ID不能为空")
+	}
+	group = strings.TrimSpace(group)
+	if errs := validation.IsValidLabelValue(group); len(errs) > 0 {
+		return fmt.Errorf("分组名称不合法: %s", strings.Join(errs, "; "))
+	}
+	if err := ValidateClusterTags(tags); err != nil {
+		return err
+	}
+	item := &models.ClusterTag{}
+	err := dao.DB().Where("cluster = ?", clusterID).First(item).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	item.Cluster = clusterID
+	item.Group = group
+	item.Tags = labels.Set(tags).String()
+	if err := dao.DB().Save(item).Error; err != nil {
+		return err
+	}
+	s.ClearCache()
+	return nil
+}
+
+// ClearCache 清除集群标签缓存
+func (s *clusterTagService) ClearCache() {
+	utils.ClearCacheByKey(CacheService().CacheInstance(), clusterTagCacheKey)
+}
+
+// Fill 填充集群列表的分组及标签，用于前端展示
+func (s *clusterTagService) Fill(clusters []*ClusterConfig) {
+	all := s.tags()
+	for _, cc := range clusters {
+		set := all[cc.GetClusterID()]
+		cc.Group = set[ClusterGroupTagKey]
+		cc.Tags = nil
+		for k, v := range set {
+			if k == ClusterGroupTagKey {
+				continue
+			}
+			if cc.Tags == nil {
+				cc.Tags = map[string]string{}
+			}
+			cc.Tags[k] = v
+		}
+	}
+}
+
+// Filter 按标签选择器筛选集群，选择器为空时不筛选
+func (s *clusterTagService) Filter(clusters []*ClusterConfig, selector string) ([]*ClusterConfig, error) {
+	if strings.TrimSpace(selector) == "" {
+		return clusters, nil
+	}
+	sel, err := ParseClusterSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	all := s.tags()
+	var result []*ClusterConfig
+	for _, cc := range clusters {
+		if sel.Matches(all[cc.GetClusterID()]) {
+			result = append(result, cc)
+		}
+	}
+	return result, nil
+}
+
+// MatchClusters 返回当前已纳管集群中匹配标签选择器的集群ID
+func (s *clusterTagService) MatchClusters(selector string) ([]string, error) {
+	matched, err := s.Filter(ClusterService().AllClusters(), selector)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(matched))
+	for _, cc := range matched {
+		ids = append(ids, cc.GetClusterID())
+	}
+	return ids, nil
+}
+
+// ResolveClusters 合并逗号分隔的集群列表与标签选择器匹配的集群，用于巡检计划、事件转发等按集群执行的任务
+func (s *clusterTagService) ResolveClusters(clusters string, selector string) []string {
+	var result []string
+	for _, c := range strings.Split(clusters, ",") {
+		if c = strings.TrimSpace(c); c != "" && !slices.Contains(result, c) {
+			result = append(result, c)
+		}
+	}
+	if strings.TrimSpace(selector) == "" {
+		return result
+	}
+	matched, err := s.MatchClusters(selector)
+	if err != nil {
+		klog.Warningf("解析集群标签选择器[%s]失败: %v", selector, err)
+		return result
+	}
+	for _, c := range matched {
+		if !slices.Contains(result, c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// ExpandRoles 将按标签授权的条目展开为匹配集群的授权，新纳管的集群打上标签后自动继承
+// 展开后的条目保留原ID及选择器，便于识别授权来源
+func (s *clusterTagService) ExpandRoles(roles []*models.ClusterUserRole) []*models.ClusterUserRole {
+	if !slices.ContainsFunc(roles, func(role *models.ClusterUserRole) bool { return role.ClusterSelector != "" }) {
+		return roles
+	}
+	return expandClusterRoles(roles, ClusterService().AllClusters(), s.tags())
+}
+
+func expandClusterRoles(roles []*models.ClusterUserRole, clusters []*ClusterConfig, tags map[string]labels.Set) []*models.ClusterUserRole {
+	result := make([]*models.ClusterUserRole, 0, len(roles))
+	for _, role := range roles {
+		if role.ClusterSelector == "" {
+			result = append(result, role)
+			continue
+		}
+		sel, err := ParseClusterSelector(role.ClusterSelector)
+		if err != nil {
+			klog.Warningf("集群授权[id=%d]标签选择器错误，已忽略: %v", role.ID, err)
+			continue
+		}
+		for _, cc := range clusters {
+			if !sel.Matches(tags[cc.GetClusterID()]) {
+				continue
+			}
+			item := *role
+			item.Cluster = cc.GetClusterID()
+			result = append(result, &item)
+		}
+	}
+	return result
+}
+
+// Options 返回已使用的分组及标签，形如 group=payments、env=prod，用于前端筛选下拉
+func (s *clusterTagService) Options() []string {
+	var options []string
+	for _, set := range s.tags() {
+		for k, v := range set {
+			option := k + "=" + v
+			if !slices.Contains(options, option) {
+				options = append(options, option)
+			}
+		}
+	}
+	slices.Sort(options)
+	return options
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/models"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestValidateClusterTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    map[string]string
+		wantErr bool
+	}{
+		{"未设置标签", nil, false},
+		{"普通标签", map[string]string{"env": "prod", "region": "cn-east", "team": "payments"}, false},
+		{"带前缀的键", map[string]string{"example.com/tier": "gold"}, false},
+		{"空值", map[string]string{"canary": ""}, false},
+		{"分组为保留键", map[string]string{"group": "payments"}, true},
+		{"键不合法", map[string]string{"env prod": "x"}, true},
+		{"值不合法", map[string]string{"env": "生产"}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateClusterTags(tt.tags)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: 期望错误 %v，实际 %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestExpandClusterRoles(t *testing.T) {
+	clusters := []*ClusterConfig{
+		{FileName: "prod", ContextName: "east"},
+		{FileName: "prod", ContextName: "north"},
+		{FileName: "dev", ContextName: "east"},
+		{FileName: "new", ContextName: "untagged"},
+	}
+	tags := map[string]labels.Set{
+		"prod/east":  {"env": "prod", "region": "cn-east", "group": "payments"},
+		"prod/north": {"env": "prod", "region": "cn-north"},
+		"dev/east":   {"env": "dev", "region": "cn-east", "group": "payments"},
+	}
+	tests := []struct {
+		name     string
+		selector string
+		want     []string
+	}{
+		{"按环境", "env=prod", []string{"prod/east", "prod/north"}},
+		{"多个条件", "env=prod,region=cn-east", []string{"prod/east"}},
+		{"集合", "env in (prod,dev),region!=cn-north", []string{"prod/east", "dev/east"}},
+		{"按分组", "group=payments", []string{"prod/east", "dev/east"}},
+		{"存在标签", "env", []string{"prod/east", "prod/north", "dev/east"}},
+		{"无匹配", "env=staging", nil},
+		{"选择器错误", "env in (", nil},
+	}
+	for _, tt := range tests {
+		roles := []*models.ClusterUserRole{
+			{ID: 1, Cluster: "manual/cluster", Username: "alice", Role: "cluster_readonly"},
+			{ID: 2, ClusterSelector: tt.selector, Username: "alice", Role: "cluster_admin"},
+		}
+		result := expandClusterRoles(roles, clusters, tags)
+		if result[0] != roles[0] {
+			t.Errorf("%s: 直接授权的条目应保留", tt.name)
+		}
+		var got []string
+		for _, r := range result[1:] {
+			if r.ID != 2 || r.Role != "cluster_admin" || r.ClusterSelector != tt.selector {
+				t.Errorf("%s: 展开的条目应保留原授权信息: %+v", tt.name, r)
+			}
+			got = append(got, r.Cluster)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: 期望匹配 %v，实际 %v", tt.name, tt.want, got)
+		}
+		if roles[1].Cluster != "" {
+			t.Errorf("%s: 不应修改原授权条目", tt.name)
+		}
+	}
+}
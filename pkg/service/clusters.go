@@ -1,11 +1,16 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +33,7 @@ import (
 )
 
 type clusterService struct {
+	clusterMu             sync.RWMutex                        // 保护 clusterConfigs，增删集群须通过 AddToClusterList、RemoveFromClusterList
 	clusterConfigs        []*ClusterConfig                    // 文件名+context名称 -> 集群配置
 	AggregateDelaySeconds int                                 // 聚合延迟时间
 	callbackRegisterFunc  func(cluster *ClusterConfig) func() // 用来注册回调参数的回调方法
@@ -41,6 +47,8 @@ type clusterService struct {
 	ReconnectMaxIntervalSeconds int      // 自动重连最大退避秒数，默认3600
 	MaxRetryAttempts            int      // 最大重试次数，默认100次
 
+	sshTunnels sync.Map // 集群ID -> *clusterSSHTunnel，经 SSH 跳板机访问的集群
+
 }
 
 func newClusterService() *clusterService {
@@ -86,6 +94,7 @@ type ClusterConfig struct {
 	UserName                string                         `json:"userName,omitempty"`                // 用户名
 	Namespace               string                         `json:"namespace,omitempty"`               // kubeconfig 限制Namespace
 	Err                     string                         `json:"err,omitempty"`                     // 连接错误信息
+	ErrType                 constants.ClusterErrorType     `json:"err_type,omitempty"`                // 连接错误类型，认证失败或网络故障
 	NodeStatusAggregated    bool                           `json:"nodeStatusAggregated,omitempty"`    // 是否已聚合节点状态
 	PodStatusAggregated     bool                           `json:"podStatusAggregated,omitempty"`     // 是否已聚合容器组状态
 	PVCStatusAggregated     bool                           `json:"pvcStatusAggregated,omitempty"`     // 是否已聚合pcv状态
@@ -95,7 +104,8 @@ type ClusterConfig struct {
 	IsInCluster             bool                           `json:"isInCluster,omitempty"`             // 是否为集群内运行获取到的配置
 	watchStatus             sync.Map                       // watch 类型为key，比如pod,deploy,node,pvc,sc
 	restConfig              *rest.Config                   // 直连rest.Config
-	kubeConfig              []byte                         // 集群配置.kubeconfig原始文件内容
+	kubeConfig              []byte                         // 集群配置.kubeconfig原始文件内容，加入集群列表后通过 getKubeConfig、setKubeConfig 读写
+	kubeConfigMu            sync.RWMutex                   // 保护 kubeConfig，Token 轮换及 oidc 刷新会在请求过程中更新
 	Source                  ClusterConfigSource            `json:"source,omitempty"`                 // 配置文件来源
 	K8sGPTProblemsCount     int                            `json:"k8s_gpt_problems_count,omitempty"` // k8sGPT 扫描结果
 	K8sGPTProblemsResult    *analysis.ResultWithStatus     `json:"k8s_gpt_problems,omitempty"`       // k8sGPT 扫描结果
@@ -105,10 +115,26 @@ type ClusterConfig struct {
 
 	// kom 集群注册配置项
 	DBID     uint    `json:"id,omitempty"`        // 数据库ID
-	ProxyURL string  `json:"proxy_url,omitempty"` // HTTP 代理，例如 http://127.0.0.1:7890
+	ProxyURL string  `json:"proxy_url,omitempty"` // 代理，例如 http://127.0.0.1:7890、socks5://127.0.0.1:1080
 	Timeout  int     `json:"timeout,omitempty"`   // 请求超时时间，单位为秒，默认为 30 秒
 	QPS      float32 `json:"qps,omitempty"`       // 每秒查询数限制，默认为 200
 	Burst    int     `json:"burst,omitempty"`     // 突发请求数限制，默认为 2000
+
+	Impersonate       bool   `json:"impersonate,omitempty"`        // 以 k8m 用户身份模拟访问集群
+	ImpersonatePrefix string `json:"impersonate_prefix,omitempty"` // 模拟的用户名及用户组名前缀
+
+	AgentID uint `json:"agent_id,omitempty"` // 经 Agent 反向隧道接入的集群对应的 Agent ID
+
+	Group string            `json:"group,omitempty"` // 集群分组，由 ClusterTagService 填充
+	Tags  map[string]string `json:"tags,omitempty"`  // 集群标签，由 ClusterTagService 填充
+
+	ProxyUsername string            `json:"proxy_username,omitempty"` // 代理认证用户名
+	proxyPassword string            // 代理认证密码
+	SSH           *ClusterSSHConfig `json:"ssh,omitempty"` // SSH 跳板机
+
+	credentials   *clusterCredentials // 内嵌的 Token、客户端证书，轮换后原地更新
+	sourcePath    string              // 文件来源的 kubeconfig 路径
+	sourceModTime time.Time           // 文件来源的 kubeconfig 最后加载的修改时间
 }
 type ClusterConfigSource string
 
@@ -129,31 +155,59 @@ type clusterWatchStatus struct {
 // HeartbeatRecord 心跳结果记录条目
 // 中文说明：index 为在当前窗口中的位置（1..N），success 表示本次心跳是否成功，time 为发生时间（本地时区）
 type HeartbeatRecord struct {
-	Index   int    `json:"index"`
-	Success bool   `json:"success"`
-	Time    string `json:"time"`
+	Index   int                        `json:"index"`
+	Success bool                       `json:"success"`
+	Time    string                     `json:"time"`
+	ErrType constants.ClusterErrorType `json:"err_type,omitempty"` // 失败时的错误类型
 }
 
 // appendHeartbeatRecord 追加一条心跳记录，并裁剪为阈值长度
-// 中文函数注释：将成功/失败结果与本地时间写入 HeartbeatHistory，保持长度不超过阈值
-func (c *clusterService) appendHeartbeatRecord(cluster *ClusterConfig, success bool, ts time.Time) {
+// 中文函数注释：将成功/失败结果与本地时间写入 HeartbeatHistory，保持长度不超过阈值；同时持久化，用于可用率统计及重启后恢复
+func (c *clusterService) appendHeartbeatRecord(cluster *ClusterConfig, ts time.Time, latency time.Duration, err error) {
 	if cluster == nil {
 		return
 	}
+	ClusterHealthService().RecordHeartbeat(cluster, ts, latency, err)
 	if cluster.HeartbeatHistory == nil {
 		cluster.HeartbeatHistory = make([]HeartbeatRecord, 0)
 	}
 	// 追加一条记录
 	rec := HeartbeatRecord{
-		Success: success,
+		Success: err == nil,
 		Time:    ts.Local().Format("2006-01-02 15:04:05"),
 	}
+	if err != nil {
+		rec.ErrType = cluster.ErrType
+	}
 	cluster.HeartbeatHistory = append(cluster.HeartbeatHistory, rec)
-	// 裁剪为最近阈值条目
+	c.trimHeartbeatHistory(cluster)
+}
+
+// restoreHeartbeatHistory 从数据库恢复最近的心跳历史，避免重启后丢失
+func (c *clusterService) restoreHeartbeatHistory(cluster *ClusterConfig) {
+	records := ClusterHealthService().RecentHeartbeats(cluster.GetClusterID(), c.heartbeatHistoryLimit())
+	cluster.HeartbeatHistory = make([]HeartbeatRecord, 0, len(records))
+	for _, r := range records {
+		cluster.HeartbeatHistory = append(cluster.HeartbeatHistory, HeartbeatRecord{
+			Success: r.Success,
+			Time:    r.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			ErrType: r.ErrType,
+		})
+	}
+	c.trimHeartbeatHistory(cluster)
+}
+
+func (c *clusterService) heartbeatHistoryLimit() int {
 	threshold := c.HeartbeatFailureThreshold
 	if threshold <= 0 {
 		threshold = 3 // 兜底：默认 3 次
 	}
+	return threshold
+}
+
+// trimHeartbeatHistory 裁剪为最近阈值条目，并重新标注序号
+func (c *clusterService) trimHeartbeatHistory(cluster *ClusterConfig) {
+	threshold := c.heartbeatHistoryLimit()
 	if len(cluster.HeartbeatHistory) > threshold {
 		cluster.HeartbeatHistory = cluster.HeartbeatHistory[len(cluster.HeartbeatHistory)-threshold:]
 	}
@@ -183,7 +237,25 @@ func (c *ClusterConfig) GetClusterWatchStatus(watchType string) bool {
 	return false
 }
 func (c *ClusterConfig) GetKubeconfig() string {
-	return string(c.kubeConfig)
+	return string(c.getKubeConfig())
+}
+
+func (c *ClusterConfig) getKubeConfig() []byte {
+	c.kubeConfigMu.RLock()
+	defer c.kubeConfigMu.RUnlock()
+	return c.kubeConfig
+}
+
+// setKubeConfig 更新 kubeconfig 内容，返回原内容及是否有变化
+func (c *ClusterConfig) setKubeConfig(content []byte) ([]byte, bool) {
+	c.kubeConfigMu.Lock()
+	defer c.kubeConfigMu.Unlock()
+	old := c.kubeConfig
+	if bytes.Equal(old, content) {
+		return old, false
+	}
+	c.kubeConfig = content
+	return old, true
 }
 
 // GetClusterID 根据ClusterConfig，按照 文件名+context名称 获取clusterID
@@ -213,6 +285,12 @@ func (c *clusterService) ClusterID(clusterConfig *ClusterConfig) string {
 
 // GetClusterByID 获取ClusterConfig
 func (c *clusterService) GetClusterByID(id string) *ClusterConfig {
+	c.clusterMu.RLock()
+	defer c.clusterMu.RUnlock()
+	return c.getClusterByID(id)
+}
+
+func (c *clusterService) getClusterByID(id string) *ClusterConfig {
 	if id == "" {
 		return nil
 	}
@@ -244,82 +322,92 @@ func (c *clusterService) GetClusterByID(id string) *ClusterConfig {
 
 // GetCertificateExpiry 获取集群证书的过期时间
 func (c *ClusterConfig) GetCertificateExpiry() time.Time {
+	cert, _ := c.clientCertificate()
+	if cert == nil {
+		return time.Time{}
+	}
+	return cert.NotAfter.Local()
+}
+
+// clientCertificate 解析 kubeconfig 中的客户端证书，同时返回对应的用户名
+func (c *ClusterConfig) clientCertificate() (*x509.Certificate, string) {
 	// 检查 kubeConfig 是否为空
-	if len(c.kubeConfig) == 0 {
+	kubeConfig := c.getKubeConfig()
+	if len(kubeConfig) == 0 {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] kubeConfig为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
-	config, err := clientcmd.Load(c.kubeConfig)
+	config, err := clientcmd.Load(kubeConfig)
 	if err != nil {
 		klog.V(8).Infof("设置NotAfter, 解析文件[%s]失败: %v", c.ClusterID, err)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查 config 是否为空
 	if config == nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] config为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查 CurrentContext 是否为空
 	if config.CurrentContext == "" {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] CurrentContext为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查 Contexts 是否为空
 	if config.Contexts == nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] Contexts为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查当前 context 是否存在
 	currentContext, contextExists := config.Contexts[config.CurrentContext]
 	if !contextExists || currentContext == nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] 当前context[%s]不存在", c.ClusterID, config.CurrentContext)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查 AuthInfos 是否为空
 	if config.AuthInfos == nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] AuthInfos为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查 AuthInfo 名称是否为空
 	if currentContext.AuthInfo == "" {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] AuthInfo名称为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 获取 authInfo
 	authInfo, exists := config.AuthInfos[currentContext.AuthInfo]
 	if !exists || authInfo == nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] authInfo[%s]不存在", c.ClusterID, currentContext.AuthInfo)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查证书数据是否为空
 	if len(authInfo.ClientCertificateData) == 0 {
 		klog.V(8).Infof("设置NotAfter, 集群[%s] ClientCertificateData为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 解析证书
 	cert, err := utils.ParseCertificate(authInfo.ClientCertificateData)
 	if err != nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s]解析证书失败: %v", c.ClusterID, err)
-		return time.Time{}
+		return nil, ""
 	}
 
 	// 检查证书是否为空
 	if cert == nil {
 		klog.V(8).Infof("设置NotAfter, 集群[%s]解析出的证书为空", c.ClusterID)
-		return time.Time{}
+		return nil, ""
 	}
 
-	return cert.NotAfter.Local()
+	return cert, currentContext.AuthInfo
 }
 
 // IsConnected 判断集群是否连接
@@ -381,6 +469,7 @@ func (c *clusterService) Connect(clusterID string) {
 			if cc.ClusterConnectStatus == constants.ClusterConnectStatusConnecting {
 				cc.ClusterConnectStatus = constants.ClusterConnectStatusFailed
 			}
+			ClusterHealthService().RecordStatus(cc, ClusterHealthReasonConnect)
 		} else {
 			klog.V(4).Infof("集群[%s] 连接成功", clusterID)
 		}
@@ -411,6 +500,7 @@ func (c *clusterService) disconnectWithOption(clusterID string, stopReconnect bo
 	cc.ServerVersion = ""
 	cc.restConfig = nil
 	cc.Err = ""
+	cc.ErrType = ""
 	cc.ClusterConnectStatus = constants.ClusterConnectStatusDisconnected
 	cc.watchStatus.Range(func(key, value interface{}) bool {
 		if v, ok := value.(*clusterWatchStatus); ok {
@@ -423,13 +513,23 @@ func (c *clusterService) disconnectWithOption(clusterID string, stopReconnect bo
 	})
 	// 从kom解除
 	kom.Clusters().RemoveClusterById(clusterID)
+	// 关闭 SSH 跳板机连接
+	c.closeSSHTunnel(clusterID)
+	// 清理客户端证书临时文件
+	cc.closeCredentials()
 	klog.V(6).Infof("Disconnect 完成清理集群 %s", clusterID)
 }
 
 // Disconnect 断开连接
 // 中文函数注释：幂等清理指定集群的连接状态与资源，并停止自动重连；用于外部显式断开场景。
 func (c *clusterService) Disconnect(clusterID string) {
+	c.disconnect(clusterID, ClusterHealthReasonManual)
+}
+
+// disconnect 断开连接并停止自动重连，按断开原因记录状态变化
+func (c *clusterService) disconnect(clusterID string, reason string) {
 	c.disconnectWithOption(clusterID, true)
+	ClusterHealthService().RecordStatus(c.GetClusterByID(clusterID), reason)
 	// 集成 Lease（断开后删除租约，仅责任者删除）
 	_ = LeaseManager().EnsureOnDisconnect(context.Background(), clusterID)
 }
@@ -442,9 +542,11 @@ func (c *clusterService) Scan() {
 	c.ScanClustersInDB()
 }
 
-// AllClusters 获取所有集群
+// AllClusters 获取所有集群，返回列表的副本，遍历期间增删集群不影响调用方
 func (c *clusterService) AllClusters() []*ClusterConfig {
-	return c.clusterConfigs
+	c.clusterMu.RLock()
+	defer c.clusterMu.RUnlock()
+	return slices.Clone(c.clusterConfigs)
 }
 
 // ConnectedClusters 获取已连接的集群
@@ -469,7 +571,7 @@ func (c *clusterService) FirstClusterID() string {
 // RegisterClustersByPath 根据kubeconfig地址注册集群
 func (c *clusterService) RegisterClustersByPath(filePath string) {
 	// 如果c.clusterConfigs为空，则返回
-	if len(c.clusterConfigs) == 0 {
+	if len(c.AllClusters()) == 0 {
 		klog.V(6).Infof("clusterConfigs为空，不进行注册")
 		return
 	}
@@ -549,6 +651,10 @@ func (c *clusterService) ScanClustersInDir(path string) {
 				kubeConfig:           content,
 				ClusterConnectStatus: constants.ClusterConnectStatusDisconnected,
 				Source:               ClusterConfigSourceFile,
+				sourcePath:           filePath,
+			}
+			if info, err := file.Info(); err == nil {
+				clusterConfig.sourceModTime = info.ModTime()
 			}
 			clusterConfig.Server = cluster.Server
 			c.AddToClusterList(clusterConfig)
@@ -565,23 +671,18 @@ func (c *clusterService) ScanClustersInDB() {
 		return
 	}
 
-	for i, cc := range c.clusterConfigs {
-		if cc.Source == ClusterConfigSourceDB || cc.Source == ClusterConfigSourceAWS {
-			// 查一下list中是否存在
-			filter := slice.Filter(list, func(index int, item *models.KubeConfig) bool {
-				if item.Server == cc.Server && item.User == cc.UserName && item.Cluster == cc.ClusterName {
-					return true
-				}
-				return false
-			})
-			if len(filter) == 0 {
-				// 在数据库中也不存在
-				// 从list中删除
-				// 删除前先断开连接，避免watcher泄露
-				c.Disconnect(cc.ClusterID)
-				c.clusterConfigs = slice.DeleteAt(c.clusterConfigs, i)
-			}
+	removed := c.RemoveFromClusterList(func(cc *ClusterConfig) bool {
+		if cc.Source != ClusterConfigSourceDB && cc.Source != ClusterConfigSourceAWS {
+			return false
 		}
+		// 查一下list中是否存在
+		return !slices.ContainsFunc(list, func(item *models.KubeConfig) bool {
+			return item.Server == cc.Server && item.User == cc.UserName && item.Cluster == cc.ClusterName
+		})
+	})
+	// 在数据库中已不存在，断开连接，避免watcher泄露
+	for _, cc := range removed {
+		c.Disconnect(cc.ClusterID)
 	}
 
 	// 2. 处理数据库中的配置
@@ -600,7 +701,7 @@ func (c *clusterService) ScanClustersInDB() {
 			if context.AuthInfo == item.User {
 				// 检查是否已存在该配置
 				exists := false
-				for _, cc := range c.clusterConfigs {
+				for _, cc := range c.AllClusters() {
 					if (cc.FileName == string(ClusterConfigSourceAWS)) && cc.Server == cluster.Server && cc.ContextName == contextName {
 						exists = true
 						break
@@ -624,6 +725,13 @@ func (c *clusterService) ScanClustersInDB() {
 						QPS:      item.QPS,
 						Burst:    item.Burst,
 						DBID:     item.ID,
+						// 模拟用户配置
+						Impersonate:       item.Impersonate,
+						ImpersonatePrefix: item.ImpersonatePrefix,
+						// 代理认证及 SSH 跳板机
+						ProxyUsername: item.ProxyUsername,
+						proxyPassword: item.ProxyPassword,
+						SSH:           ClusterSSHConfigFromModel(item),
 					}
 					if item.DisplayName != "" {
 						clusterConfig.FileName = item.DisplayName
@@ -645,6 +753,12 @@ func (c *clusterService) ScanClustersInDB() {
 						clusterConfig.FileName = string(ClusterConfigSourceAWS)
 					}
 					clusterConfig.Server = cluster.Server
+					// 重新上传的 kubeconfig，仅凭据变化时原地更新
+					if existing := c.GetClusterByID(clusterConfig.GetClusterID()); existing != nil && existing.Source == ClusterConfigSourceDB {
+						existing.DBID = item.ID
+						c.reloadKubeconfig(existing, clusterConfig.kubeConfig)
+						continue
+					}
 					c.AddToClusterList(clusterConfig)
 				}
 
@@ -652,16 +766,37 @@ func (c *clusterService) ScanClustersInDB() {
 
 		}
 	}
+
+	// 3. 处理 Agent 接入的集群
+	ClusterAgentService().ScanClusters()
 }
 
 func (c *clusterService) AddToClusterList(clusterConfig *ClusterConfig) {
+	c.clusterMu.Lock()
+	defer c.clusterMu.Unlock()
 	// 判断是否已经存在
-	if c.GetClusterByID(clusterConfig.GetClusterID()) != nil {
+	if c.getClusterByID(clusterConfig.GetClusterID()) != nil {
 		return
 	}
 	c.clusterConfigs = append(c.clusterConfigs, clusterConfig)
 }
 
+// RemoveFromClusterList 从集群列表移除满足条件的集群并返回，由调用方断开连接
+func (c *clusterService) RemoveFromClusterList(match func(cc *ClusterConfig) bool) []*ClusterConfig {
+	c.clusterMu.Lock()
+	defer c.clusterMu.Unlock()
+	var kept, removed []*ClusterConfig
+	for _, cc := range c.clusterConfigs {
+		if match(cc) {
+			removed = append(removed, cc)
+			continue
+		}
+		kept = append(kept, cc)
+	}
+	c.clusterConfigs = kept
+	return removed
+}
+
 // Deprecated
 // RegisterClustersInDir 注册集群,扫描文件夹下的kubeconfig文件，注册集群
 func (c *clusterService) RegisterClustersInDir(path string) {
@@ -714,12 +849,12 @@ func (c *clusterService) RegisterClustersInDir(path string) {
 	}
 
 	// 注册
-	for _, clusterConfig := range c.clusterConfigs {
+	for _, clusterConfig := range c.AllClusters() {
 		// 改为只注册CurrentContext的这个
 		_, _ = c.RegisterCluster(clusterConfig)
 	}
 	// 打印serverVersion
-	for _, clusterConfig := range c.clusterConfigs {
+	for _, clusterConfig := range c.AllClusters() {
 		klog.V(6).Infof("ServerVersion: %s/%s: %s[%s] using user: %s", clusterConfig.FileName, clusterConfig.ContextName, clusterConfig.ServerVersion, clusterConfig.Server, clusterConfig.UserName)
 	}
 }
@@ -736,6 +871,14 @@ func (c *clusterService) RegisterCluster(clusterConfig *ClusterConfig) (bool, er
 	clusterID := clusterConfig.GetClusterID()
 	klog.V(6).Infof("开始注册集群 %s [来源：%s]", clusterID, clusterConfig.Source)
 
+	// 不支持模拟用户的集群开启了模拟，属于配置错误
+	if err := validateImpersonate(clusterConfig); err != nil {
+		klog.V(4).Infof("注册集群[%s]失败: %v", clusterID, err)
+		clusterConfig.ClusterConnectStatus = constants.ClusterConnectStatusFailed
+		clusterConfig.Err = err.Error()
+		return false, err
+	}
+
 	// AWS EKS 集群处理
 	if clusterConfig.IsAWSEKS {
 		if clusterConfig.AWSConfig == nil {
@@ -788,6 +931,8 @@ func (c *clusterService) RegisterCluster(clusterConfig *ClusterConfig) (bool, er
 	klog.V(4).Infof("成功注册集群: %s [%s]", clusterID, clusterConfig.Server)
 	clusterConfig.ClusterConnectStatus = constants.ClusterConnectStatusConnected
 	clusterConfig.Err = "" // 清除错误信息
+	clusterConfig.ErrType = ""
+	ClusterHealthService().RecordStatus(clusterConfig, ClusterHealthReasonConnect)
 
 	// 启动心跳监测
 	c.StartHeartbeat(clusterID)
@@ -816,19 +961,47 @@ func (c *clusterService) LoadRestConfig(config *ClusterConfig) error {
 			config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
 			return err
 		}
+	} else if config.Source == ClusterConfigSourceAgent {
+		// Agent 模式，经本机隧道入口访问
+		restConfig, err = ClusterAgentService().restConfig(config)
+		if err != nil {
+			config.Err = err.Error()
+			config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
+			return err
+		}
 	} else {
 		// 集群外模式
-		lines := strings.Split(string(config.kubeConfig), "\n")
-		for i, line := range lines {
-			if strings.HasPrefix(line, "current-context:") {
-				lines[i] = "current-context: " + config.ContextName
+		restConfig, err = kubeconfigRestConfig(config.getKubeConfig(), config.ContextName)
+		if err != nil {
+			klog.V(6).Infof("加载集群配置失败 %s: %v", config.GetClusterID(), err)
+			config.Err = err.Error()
+			config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
+			return err
+		}
+		// exec 插件、oidc 及内嵌凭据，AWS EKS 由 kom 使用独立的凭据注册
+		if !config.IsAWSEKS {
+			if err := c.applyClusterAuth(config, restConfig); err != nil {
+				klog.V(6).Infof("设置集群认证失败 %s: %v", config.GetClusterID(), err)
+				config.Err = err.Error()
+				config.ErrType = classifyClusterError(err)
+				config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
+				return err
 			}
 		}
-		bytes := []byte(strings.Join(lines, "\n"))
-		restConfig, err = clientcmd.RESTConfigFromKubeConfig(bytes)
-
+	}
+	// 代理及 SSH 跳板机，集群内及 Agent 模式无需设置
+	if restConfig != nil && !config.IsInCluster && config.Source != ClusterConfigSourceAgent {
+		if err := c.applyClusterNetwork(config, restConfig); err != nil {
+			klog.V(6).Infof("设置集群网络失败 %s: %v", config.GetClusterID(), err)
+			config.Err = err.Error()
+			config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
+			return err
+		}
 	}
 	config.restConfig = restConfig
+	if restConfig != nil && config.Impersonate {
+		restConfig.Wrap(impersonateWrapper(config))
+	}
 
 	if config.IsAWSEKS {
 		theaws := kom.Clusters().GetClusterById(config.ClusterID)
@@ -849,6 +1022,7 @@ func (c *clusterService) LoadRestConfig(config *ClusterConfig) error {
 	if err != nil {
 		klog.V(6).Infof("创建clientset失败 %s: %v", config.GetClusterID(), err)
 		config.Err = err.Error()
+		config.ErrType = classifyClusterError(err)
 		config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
 		return err
 	}
@@ -858,6 +1032,7 @@ func (c *clusterService) LoadRestConfig(config *ClusterConfig) error {
 	if err != nil {
 		klog.V(6).Infof("连接集群失败 %s: %v", config.GetClusterID(), err)
 		config.Err = err.Error()
+		config.ErrType = classifyClusterError(err)
 		config.ClusterConnectStatus = constants.ClusterConnectStatusFailed
 		return err
 	}
@@ -1017,10 +1192,16 @@ func (c *clusterService) buildRegisterOptions(clusterConfig *ClusterConfig) []ko
 	klog.V(6).Infof("开始构建集群 %s 的注册选项配置", clusterConfig.ClusterID)
 	var opts []kom.RegisterOption
 
-	// 设置代理
-	if clusterConfig.ProxyURL != "" {
+	// 设置代理，经 SSH 跳板机访问时 rest.Config 已指向本机转发入口，不使用代理
+	if clusterConfig.SSH != nil && clusterConfig.SSH.Host != "" {
+		klog.V(6).Infof("集群 %s 经 SSH 跳板机 %s 访问", clusterConfig.ClusterID, clusterConfig.SSH.Host)
+	} else if clusterConfig.ProxyURL != "" {
 		klog.V(6).Infof("设置集群 %s 代理URL: %s", clusterConfig.ClusterID, clusterConfig.ProxyURL)
-		opts = append(opts, kom.RegisterProxyURL(clusterConfig.ProxyURL))
+		if u, err := clusterConfig.proxyURL(); err == nil {
+			opts = append(opts, kom.RegisterProxyFunc(http.ProxyURL(u)))
+		} else {
+			klog.V(4).Infof("集群 %s 代理URL无效: %v", clusterConfig.ClusterID, err)
+		}
 	} else {
 		klog.V(6).Infof("集群 %s 未设置代理URL", clusterConfig.ClusterID)
 	}
@@ -1054,27 +1235,23 @@ func (c *clusterService) buildRegisterOptions(clusterConfig *ClusterConfig) []ko
 }
 
 // UpdateClusterConfig 更新已加载集群的配置参数
-// @Description 根据数据库ID更新已加载集群的ProxyURL、Timeout、QPS、Burst配置，并重新注册已连接的集群
-// @Param dbID 数据库中的集群配置ID
-// @Param proxyURL HTTP代理URL
-// @Param timeout 请求超时时间（秒）
-// @Param qps 每秒查询数限制
-// @Param burst 突发请求数限制
-func (c *clusterService) UpdateClusterConfig(dbID uint, proxyURL string, timeout int, qps float32, burst int) error {
-	klog.V(6).Infof("开始更新集群配置，数据库ID: %d", dbID)
+// @Description 根据数据库中保存的配置更新已加载集群的代理、SSH 跳板机、Timeout、QPS、Burst及模拟用户配置，并重新连接已连接的集群
+// @Param item 已保存的集群配置
+func (c *clusterService) UpdateClusterConfig(item *models.KubeConfig) error {
+	klog.V(6).Infof("开始更新集群配置，数据库ID: %d", item.ID)
 
 	// 查找对应的集群配置
 	var targetCluster *ClusterConfig
-	for _, cluster := range c.clusterConfigs {
-		if cluster.DBID == dbID {
+	for _, cluster := range c.AllClusters() {
+		if cluster.DBID == item.ID {
 			targetCluster = cluster
 			break
 		}
 	}
 
 	if targetCluster == nil {
-		klog.V(4).Infof("未找到数据库ID为 %d 的集群配置", dbID)
-		return fmt.Errorf("未找到数据库ID为 %d 的集群配置", dbID)
+		klog.V(4).Infof("未找到数据库ID为 %d 的集群配置", item.ID)
+		return fmt.Errorf("未找到数据库ID为 %d 的集群配置", item.ID)
 	}
 
 	klog.V(6).Infof("找到集群配置: %s [%s]", targetCluster.ClusterID, targetCluster.Server)
@@ -1084,15 +1261,21 @@ func (c *clusterService) UpdateClusterConfig(dbID uint, proxyURL string, timeout
 	oldTimeout := targetCluster.Timeout
 	oldQPS := targetCluster.QPS
 	oldBurst := targetCluster.Burst
+	oldImpersonate := targetCluster.Impersonate
 
 	// 更新配置参数
-	targetCluster.ProxyURL = proxyURL
-	targetCluster.Timeout = timeout
-	targetCluster.QPS = qps
-	targetCluster.Burst = burst
-
-	klog.V(6).Infof("集群 %s 配置更新: ProxyURL [%s->%s], Timeout [%d->%d], QPS [%.2f->%.2f], Burst [%d->%d]",
-		targetCluster.ClusterID, oldProxyURL, proxyURL, oldTimeout, timeout, oldQPS, qps, oldBurst, burst)
+	targetCluster.ProxyURL = item.ProxyURL
+	targetCluster.ProxyUsername = item.ProxyUsername
+	targetCluster.proxyPassword = item.ProxyPassword
+	targetCluster.SSH = ClusterSSHConfigFromModel(item)
+	targetCluster.Timeout = item.Timeout
+	targetCluster.QPS = item.QPS
+	targetCluster.Burst = item.Burst
+	targetCluster.Impersonate = item.Impersonate
+	targetCluster.ImpersonatePrefix = item.ImpersonatePrefix
+
+	klog.V(6).Infof("集群 %s 配置更新: ProxyURL [%s->%s], SSH [%s], Timeout [%d->%d], QPS [%.2f->%.2f], Burst [%d->%d], Impersonate [%t->%t]",
+		targetCluster.ClusterID, oldProxyURL, item.ProxyURL, item.SSHHost, oldTimeout, item.Timeout, oldQPS, item.QPS, oldBurst, item.Burst, oldImpersonate, item.Impersonate)
 
 	// 如果集群已连接，需要重新注册以应用新配置
 	if targetCluster.ClusterConnectStatus == constants.ClusterConnectStatusConnected {
@@ -1101,6 +1284,8 @@ func (c *clusterService) UpdateClusterConfig(dbID uint, proxyURL string, timeout
 		// 重新连接，这会使用新的配置参数
 		go func() {
 			time.Sleep(200 * time.Millisecond) // 稍微延迟一下再重连
+			// Connect 跳过已连接的集群，先清理连接资源
+			c.disconnectWithOption(targetCluster.ClusterID, false)
 			c.Connect(targetCluster.ClusterID)
 		}()
 
@@ -1143,6 +1328,11 @@ func (c *clusterService) StartHeartbeat(clusterID string) {
 		return
 	}
 
+	// 重启后从数据库恢复心跳历史
+	if cluster.HeartbeatHistory == nil {
+		c.restoreHeartbeatHistory(cluster)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	c.heartbeatCancel.Store(clusterID, cancel)
 
@@ -1166,36 +1356,49 @@ func (c *clusterService) StartHeartbeat(clusterID string) {
 					cancel()
 					return
 				}
+				// kubeconfig 文件中的凭据轮换后重新加载
+				c.reloadKubeconfigFile(cluster)
 				// restConfig 必须存在
 				if cluster.restConfig == nil {
 					failureCount++
 					klog.V(6).Infof("集群 %s 心跳检测失败：restConfig 不存在（累计失败 %d）", clusterID, failureCount)
 					// 记录本次心跳失败
-					c.appendHeartbeatRecord(cluster, false, time.Now())
+					c.appendHeartbeatRecord(cluster, time.Now(), 0, errors.New("restConfig 不存在"))
 				} else {
 					clientset, err := kubernetes.NewForConfig(cluster.restConfig)
 					if err != nil {
 						failureCount++
 						klog.V(6).Infof("集群 %s 创建 clientset 失败：%v（累计失败 %d）", clusterID, err, failureCount)
 						// 记录本次心跳失败
-						c.appendHeartbeatRecord(cluster, false, time.Now())
+						c.appendHeartbeatRecord(cluster, time.Now(), 0, err)
+					} else if err := c.checkSSHTunnel(clusterID); err != nil {
+						failureCount++
+						klog.V(6).Infof("集群 %s 心跳检测 SSH 跳板机失败：%v（累计失败 %d）", clusterID, err, failureCount)
+						cluster.Err = err.Error()
+						cluster.ErrType = constants.ClusterErrorTypeNetwork
+						// 记录本次心跳失败
+						c.appendHeartbeatRecord(cluster, time.Now(), 0, err)
 					} else {
+						start := time.Now()
 						sv, err := clientset.Discovery().ServerVersion()
+						latency := time.Since(start)
 						if err != nil {
 							failureCount++
-							klog.V(6).Infof("集群 %s 心跳检测读取版本失败：%v（累计失败 %d）", clusterID, err, failureCount)
 							cluster.Err = err.Error()
+							cluster.ErrType = classifyClusterError(err)
+							klog.V(6).Infof("集群 %s 心跳检测读取版本失败[%s]：%v（累计失败 %d）", clusterID, cluster.ErrType, err, failureCount)
 							// 记录本次心跳失败
-							c.appendHeartbeatRecord(cluster, false, time.Now())
+							c.appendHeartbeatRecord(cluster, time.Now(), 0, err)
 						} else {
 							// 成功，重置失败计数并同步版本
 							failureCount = 0
+							cluster.ErrType = ""
 							if sv != nil {
 								cluster.ServerVersion = sv.GitVersion
 							}
 							klog.V(6).Infof("集群 %s 心跳检测成功，当前版本：%s", clusterID, cluster.ServerVersion)
 							// 记录本次心跳成功
-							c.appendHeartbeatRecord(cluster, true, time.Now())
+							c.appendHeartbeatRecord(cluster, start, latency, nil)
 						}
 					}
 				}
@@ -1204,6 +1407,7 @@ func (c *clusterService) StartHeartbeat(clusterID string) {
 					// 达到失败阈值，切换为断开并停止心跳，并启动独立的自动重连循环
 					cluster.ClusterConnectStatus = constants.ClusterConnectStatusDisconnected
 					klog.V(6).Infof("集群 %s 心跳连续失败达到阈值，状态切换为未连接，启动自动重连循环", clusterID)
+					ClusterHealthService().RecordStatus(cluster, ClusterHealthReasonHeartbeat)
 
 					// 停止当前心跳循环
 					cancel()
@@ -1290,6 +1494,7 @@ func (c *clusterService) StartReconnect(clusterID string) {
 
 			// 尝试连接
 			c.Connect(id)
+			ClusterHealthService().RecordReconnect(c.GetClusterByID(id), attempt)
 
 			// 若连接成功，结束重连循环
 			if c.IsConnected(id) {
@@ -2,9 +2,11 @@ package service
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
 	"github.com/weibaohui/k8m/pkg/comm/utils"
 	"github.com/weibaohui/k8m/pkg/flag"
 	"github.com/weibaohui/k8m/pkg/models"
@@ -134,6 +136,9 @@ func (s *configService) UpdateFlagFromDBConfig() error {
 	if m.MaxRetryAttempts > 0 {
 		cfg.MaxRetryAttempts = m.MaxRetryAttempts
 	}
+	if m.ExecPluginAllowlist != "" {
+		cfg.ExecPluginAllowlist = m.ExecPluginAllowlist
+	}
 
 	// JwtTokenSecret 暂不启用，因为前端也要处理
 	// cfg.JwtTokenSecret = m.JwtTokenSecret
@@ -143,6 +148,9 @@ func (s *configService) UpdateFlagFromDBConfig() error {
 		klog.Infof("已开启配置信息打印选项。下面是数据库配置的回显.\n%s:\n %+v\n%s\n", color.RedString("↓↓↓↓↓↓生产环境请务必关闭↓↓↓↓↓↓"), utils.ToJSON(m), color.RedString("↑↑↑↑↑↑生产环境请务必关闭↑↑↑↑↑↑"))
 		cfg.ShowConfigCloseMethod()
 	}
+	if err := ai.SetRedactOptions(m.AIRedactEnabled, strings.Split(m.AIRedactEnvPatterns, "\n")); err != nil {
+		klog.Errorf("UpdateFlagFromDBConfig 设置AI脱敏规则失败: %v", err)
+	}
 	_ = AIService().ResetDefaultClient()
 	return nil
 }
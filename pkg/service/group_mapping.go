@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	GroupMappingMatchExact = "exact"
+	GroupMappingMatchRegex = "regex"
+
+	// GroupMappingManagedBy 由映射规则自动维护的集群授权标记
+	GroupMappingManagedBy = "group_mapping"
+	// LdapUserSource LDAP 用户的来源标记
+	LdapUserSource = "ldap_config"
+)
+
+type groupMappingService struct {
+	syncMu     sync.Mutex
+	lastSyncAt time.Time
+}
+
+// ClusterGrant 映射规则产生的集群授权
+type ClusterGrant struct {
+	Cluster    string `json:"cluster"`
+	Role       string `json:"role"`
+	Namespaces string `json:"namespaces"`
+}
+
+// HasRules 是否存在适用于该来源的启用规则，不存在时沿用外部用户组直接作为 k8m 用户组的方式
+func (s *groupMappingService) HasRules(source string) bool {
+	rules, err := s.enabledRules(source)
+	return err == nil && len(rules) > 0
+}
+
+// Preview 计算外部用户组按当前规则映射得到的用户组及集群授权，不修改数据
+func (s *groupMappingService) Preview(source string, externalGroups []string) ([]string, []ClusterGrant, error) {
+	rules, err := s.enabledRules(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, grants := matchGroupMapping(rules, externalGroups)
+	return groups, grants, nil
+}
+
+// Apply 按映射规则重新计算用户的用户组及集群授权
+// 手动分配的用户组保持不变，上次映射得到但本次未匹配的用户组及授权被移除
+// 规则全部删除或停用时同样执行，移除此前映射得到的用户组及授权
+func (s *groupMappingService) Apply(username, source string, externalGroups []string) error {
+	rules, err := s.enabledRules(source)
+	if err != nil {
+		return err
+	}
+	mapped, grants := matchGroupMapping(rules, externalGroups)
+
+	user := &models.User{}
+	if err := dao.DB().Where("username = ? AND source = ?", username, source).First(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 同名用户属于其他来源，不按该来源的规则处理
+			klog.V(2).Infof("未找到来源为[%s]的用户[%s]，跳过用户组映射", source, username)
+			return nil
+		}
+		return err
+	}
+	groupNames := mergeGroupNames(user.GroupNames, user.MappedGroupNames, mapped)
+	mappedNames := strings.Join(mapped, ",")
+	changed := groupNames != user.GroupNames || mappedNames != user.MappedGroupNames
+	if changed {
+		err := dao.DB().Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]any{
+			"group_names":        groupNames,
+			"mapped_group_names": mappedNames,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("更新用户组失败: %w", err)
+		}
+		klog.V(2).Infof("用户[%s]按映射规则更新用户组: [%s] -> [%s]", username, user.GroupNames, groupNames)
+	}
+
+	grantChanged, err := s.syncClusterGrants(username, grants)
+	if err != nil {
+		return err
+	}
+	if changed || grantChanged {
+		UserService().ClearCacheByKey(username)
+		UserService().ClearCacheByKey("cluster")
+	}
+	return nil
+}
+
+// syncClusterGrants 使映射规则维护的集群授权与计算结果一致，返回是否有变更
+func (s *groupMappingService) syncClusterGrants(username string, grants []ClusterGrant) (bool, error) {
+	var existing []*models.ClusterUserRole
+	err := dao.DB().Where("username = ? AND managed_by = ?", username, GroupMappingManagedBy).Find(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	desired := make(map[string]ClusterGrant, len(grants))
+	for _, g := range grants {
+		desired[g.Cluster+"|"+g.Role] = g
+	}
+	changed := false
+	for _, e := range existing {
+		key := e.Cluster + "|" + e.Role
+		if g, ok := desired[key]; ok && g.Namespaces == e.Namespaces {
+			delete(desired, key)
+			continue
+		}
+		if err := dao.DB().Delete(&models.ClusterUserRole{}, e.ID).Error; err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	for _, g := range desired {
+		item := &models.ClusterUserRole{
+			Cluster:           g.Cluster,
+			Username:          username,
+			Role:              g.Role,
+			Namespaces:        g.Namespaces,
+			AuthorizationType: constants.ClusterAuthorizationTypeUser,
+			ManagedBy:         GroupMappingManagedBy,
+		}
+		if err := dao.DB().Create(item).Error; err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func (s *groupMappingService) enabledRules(source string) ([]*models.GroupMappingRule, error) {
+	var rules []*models.GroupMappingRule
+	err := dao.DB().Where("enabled = ? AND (source = '' OR source IS NULL OR source = ?)", true, source).
+		Order("id asc").Find(&rules).Error
+	return rules, err
+}
+
+// SyncLdapUsers 从 LDAP 重新读取所有 LDAP 用户的用户组并应用映射规则，LDAP 中已删除的用户移除映射得到的权限
+func (s *groupMappingService) SyncLdapUsers() (int, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.lastSyncAt = time.Now()
+
+	var users []*models.User
+	if err := dao.DB().Select("username").Where("source = ?", LdapUserSource).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+	// 无规则时无需查询LDAP，仅移除此前映射得到的用户组及授权
+	if !s.HasRules(LdapUserSource) {
+		count := 0
+		for _, user := range users {
+			if err := s.Apply(user.Username, LdapUserSource, nil); err != nil {
+				klog.Errorf("清理LDAP用户[%s]映射的用户组失败: %v", user.Username, err)
+				continue
+			}
+			count++
+		}
+		return count, nil
+	}
+	config := &models.LDAPConfig{}
+	err := dao.DB().Where("enabled = ?", true).Order("id desc").First(config).Error
+	if err != nil {
+		return 0, fmt.Errorf("未找到启用的LDAP配置: %w", err)
+	}
+	conn, err := UserService().ldapConnection(config)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	count := 0
+	for _, user := range users {
+		entry, err := UserService().searchRequest(conn, user.Username, config)
+		var groups []string
+		switch {
+		case err == nil:
+			groups = UserService().LdapGroups(entry, config)
+		case errors.Is(err, ErrLdapUserNotFound):
+			klog.V(2).Infof("LDAP中已不存在用户[%s]，移除映射的用户组及授权", user.Username)
+		default:
+			return count, err
+		}
+		if err := s.Apply(user.Username, LdapUserSource, groups); err != nil {
+			klog.Errorf("同步LDAP用户[%s]用户组失败: %v", user.Username, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// StartLdapSync 按LDAP配置的同步间隔定期同步用户组，ctx 取消后退出，仅在 Leader 上运行
+func (s *groupMappingService) StartLdapSync(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			config := &models.LDAPConfig{}
+			err := dao.DB().Select("sync_interval").Where("enabled = ?", true).Order("id desc").First(config).Error
+			if err != nil || config.SyncInterval <= 0 {
+				continue
+			}
+			s.syncMu.Lock()
+			due := time.Since(s.lastSyncAt) >= time.Duration(config.SyncInterval)*time.Minute
+			s.syncMu.Unlock()
+			if !due {
+				continue
+			}
+			count, err := s.SyncLdapUsers()
+			if err != nil {
+				klog.Errorf("定期同步LDAP用户组失败: %v", err)
+				continue
+			}
+			klog.V(2).Infof("定期同步LDAP用户组完成，处理 %d 个用户", count)
+		}
+	}()
+}
+
+// matchGroupMapping 根据规则计算映射得到的用户组及集群授权，结果去重并排序
+func matchGroupMapping(rules []*models.GroupMappingRule, externalGroups []string) ([]string, []ClusterGrant) {
+	groupSet := map[string]bool{}
+	grants := map[string]*ClusterGrant{}
+	for _, rule := range rules {
+		if !ruleMatches(rule, externalGroups) {
+			continue
+		}
+		for _, g := range utils.SplitAndTrim(rule.UserGroups, ",") {
+			groupSet[g] = true
+		}
+		if rule.Cluster == "" || rule.ClusterRole == "" {
+			continue
+		}
+		key := rule.Cluster + "|" + rule.ClusterRole
+		if existing, ok := grants[key]; ok {
+			existing.Namespaces = mergeNamespaces(existing.Namespaces, rule.Namespaces)
+			continue
+		}
+		grants[key] = &ClusterGrant{Cluster: rule.Cluster, Role: rule.ClusterRole, Namespaces: normalizeNamespaces(rule.Namespaces)}
+	}
+
+	groups := make([]string, 0, len(groupSet))
+	for g := range groupSet {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	result := make([]ClusterGrant, 0, len(grants))
+	for _, g := range grants {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Cluster+"|"+result[i].Role < result[j].Cluster+"|"+result[j].Role
+	})
+	return groups, result
+}
+
+// ruleMatches 判断外部用户组是否命中规则，LDAP DN 同时以首个RDN的值匹配，如 cn=dev,ou=groups 匹配 dev
+func ruleMatches(rule *models.GroupMappingRule, externalGroups []string) bool {
+	var re *regexp.Regexp
+	if rule.MatchType == GroupMappingMatchRegex {
+		var err error
+		re, err = regexp.Compile("^(?:" + rule.ExternalGroup + ")$")
+		if err != nil {
+			klog.Errorf("用户组映射规则[%s]正则错误: %v", rule.Name, err)
+			return false
+		}
+	}
+	for _, group := range externalGroups {
+		for _, candidate := range groupCandidates(group) {
+			if re != nil && re.MatchString(candidate) {
+				return true
+			}
+			if re == nil && strings.EqualFold(candidate, strings.TrimSpace(rule.ExternalGroup)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func groupCandidates(group string) []string {
+	group = strings.TrimSpace(group)
+	candidates := []string{group}
+	if first, _, ok := strings.Cut(group, ","); ok {
+		if _, value, ok := strings.Cut(first, "="); ok {
+			candidates = append(candidates, strings.TrimSpace(value))
+		}
+	}
+	return candidates
+}
+
+// mergeGroupNames 保留手动分配的用户组，替换上次映射得到的用户组
+func mergeGroupNames(current, previousMapped string, mapped []string) string {
+	previous := map[string]bool{}
+	for _, g := range utils.SplitAndTrim(previousMapped, ",") {
+		previous[g] = true
+	}
+	seen := map[string]bool{}
+	var result []string
+	for _, g := range utils.SplitAndTrim(current, ",") {
+		if previous[g] || seen[g] {
+			continue
+		}
+		seen[g] = true
+		result = append(result, g)
+	}
+	for _, g := range mapped {
+		if !seen[g] {
+			seen[g] = true
+			result = append(result, g)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// mergeNamespaces 合并命名空间，任一为空表示全部命名空间
+func mergeNamespaces(a, b string) string {
+	if strings.TrimSpace(a) == "" || strings.TrimSpace(b) == "" {
+		return ""
+	}
+	return normalizeNamespaces(a + "," + b)
+}
+
+func normalizeNamespaces(ns string) string {
+	items := utils.SplitAndTrim(ns, ",")
+	sort.Strings(items)
+	return strings.Join(slices.Compact(items), ",")
+}
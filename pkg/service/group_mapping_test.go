@@ -0,0 +1,68 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestMatchGroupMapping(t *testing.T) {
+	rules := []*models.GroupMappingRule{
+		{Name: "dev", MatchType: GroupMappingMatchExact, ExternalGroup: "Dev", UserGroups: "developers"},
+		{Name: "ops", MatchType: GroupMappingMatchRegex, ExternalGroup: "ops-.*", UserGroups: "ops,developers", Cluster: "prod", ClusterRole: "cluster_readonly", Namespaces: "b,a"},
+		{Name: "ops-ns", MatchType: GroupMappingMatchRegex, ExternalGroup: "ops-.*", Cluster: "prod", ClusterRole: "cluster_readonly", Namespaces: "c"},
+		{Name: "bad", MatchType: GroupMappingMatchRegex, ExternalGroup: "(", UserGroups: "never"},
+	}
+	tests := []struct {
+		name     string
+		external []string
+		groups   []string
+		grants   []ClusterGrant
+	}{
+		{"无外部用户组", nil, []string{}, []ClusterGrant{}},
+		{"精确匹配忽略大小写", []string{"dev"}, []string{"developers"}, []ClusterGrant{}},
+		{"LDAP DN 以首个RDN匹配", []string{"cn=dev,ou=groups,dc=example,dc=com"}, []string{"developers"}, []ClusterGrant{}},
+		{"正则需完整匹配", []string{"xops-team"}, []string{}, []ClusterGrant{}},
+		{"同一集群角色合并命名空间", []string{"ops-team"}, []string{"developers", "ops"},
+			[]ClusterGrant{{Cluster: "prod", Role: "cluster_readonly", Namespaces: "a,b,c"}}},
+	}
+	for _, tt := range tests {
+		groups, grants := matchGroupMapping(rules, tt.external)
+		if !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("%s: 用户组期望 %v，实际 %v", tt.name, tt.groups, groups)
+		}
+		if !reflect.DeepEqual(grants, tt.grants) {
+			t.Errorf("%s: 授权期望 %v，实际 %v", tt.name, tt.grants, grants)
+		}
+	}
+}
+
+func TestMergeGroupNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		previous string
+		mapped   []string
+		want     string
+	}{
+		{"首次映射保留手动用户组", "manual", "", []string{"dev"}, "manual,dev"},
+		{"外部用户组移除后移除映射的用户组", "manual,dev,ops", "dev,ops", []string{"ops"}, "manual,ops"},
+		{"全部移除", "dev", "dev", nil, ""},
+		{"去重", "dev,manual", "", []string{"dev"}, "dev,manual"},
+	}
+	for _, tt := range tests {
+		if got := mergeGroupNames(tt.current, tt.previous, tt.mapped); got != tt.want {
+			t.Errorf("%s: 期望 %q，实际 %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestMergeNamespaces(t *testing.T) {
+	if got := mergeNamespaces("a", ""); got != "" {
+		t.Errorf("任一为空应表示全部命名空间，实际 %q", got)
+	}
+	if got := mergeNamespaces("b,a", "a,c"); got != "a,b,c" {
+		t.Errorf("期望 a,b,c，实际 %q", got)
+	}
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/flag"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	JwtAlgHS256 = "HS256"
+	JwtAlgRS256 = "RS256"
+	JwtAlgES256 = "ES256"
+
+	// jwtKeyReloadInterval 定期从数据库重新加载密钥，使多副本间的轮换生效
+	jwtKeyReloadInterval = 5 * time.Minute
+	// jwtKeyMissReloadInterval 遇到未知 kid 时重新加载的最小间隔，避免伪造 kid 导致频繁查库
+	jwtKeyMissReloadInterval = 10 * time.Second
+)
+
+type jwtKeyService struct {
+	genMu      sync.Mutex // 串行化自动生成，避免并发签发时生成多个密钥
+	mu         sync.RWMutex
+	keys       map[string]*jwtSigningKey
+	active     *jwtSigningKey
+	loadedAt   time.Time
+	missReload time.Time
+}
+
+type jwtSigningKey struct {
+	kid     string
+	alg     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// JWK JSON Web Key，仅包含公钥
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Algorithm 当前配置的签名算法
+func (s *jwtKeyService) Algorithm() string {
+	alg := strings.ToUpper(flag.Init().JwtSigningAlg)
+	switch alg {
+	case JwtAlgRS256, JwtAlgES256:
+		return alg
+	default:
+		return JwtAlgHS256
+	}
+}
+
+// IsDefaultSecret 是否仍在使用公开的默认 Secret
+func (s *jwtKeyService) IsDefaultSecret() bool {
+	return flag.Init().JwtTokenSecret == flag.DefaultJwtTokenSecret
+}
+
+// Sign 使用当前签名密钥签发 Token，非对称算法在头部写入 kid
+func (s *jwtKeyService) Sign(claims jwt.MapClaims) (string, error) {
+	if s.Algorithm() == JwtAlgHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(flag.Init().JwtTokenSecret))
+	}
+	key, err := s.activeKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// KeyFunc 根据 Token 头部的算法及 kid 返回验证密钥，注入 utils.JWTKeyFunc
+// 启用非对称签名后拒绝 HMAC 签名的 Token，避免持有 Secret 者继续签发 Token；
+// 可通过 --jwt-hmac-accept-before 设置过渡截止时间，截止前仍接受非默认 Secret 签名的历史 Token
+func (s *jwtKeyService) KeyFunc(token *jwt.Token, secret string) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if s.Algorithm() != JwtAlgHS256 {
+			if err := checkHMACAccepted(secret, flag.Init().JwtHMACAcceptBefore, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(secret), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		kid, _ := token.Header["kid"].(string)
+		key, err := s.verifyKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.alg != token.Method.Alg() {
+			return nil, fmt.Errorf("签名算法与密钥[%s]不匹配", kid)
+		}
+		return key.public, nil
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+	}
+}
+
+// checkHMACAccepted 启用非对称签名后，判断是否仍在接受 HMAC 签名 Token 的过渡期内
+func checkHMACAccepted(secret, acceptBefore string, now time.Time) error {
+	if secret == flag.DefaultJwtTokenSecret {
+		return errors.New("已启用非对称签名，拒绝使用默认Secret签名的Token")
+	}
+	if acceptBefore == "" {
+		return errors.New("已启用非对称签名，拒绝HS256签名的Token")
+	}
+	deadline, err := ParseJwtHMACAcceptBefore(acceptBefore)
+	if err != nil {
+		return err
+	}
+	if !now.Before(deadline) {
+		return errors.New("已启用非对称签名，HS256签名Token的过渡期已结束")
+	}
+	return nil
+}
+
+// ParseJwtHMACAcceptBefore 解析 HS256 过渡截止时间，支持 2006-01-02 及 RFC3339 格式
+func ParseJwtHMACAcceptBefore(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("jwt-hmac-accept-before 格式错误: %s", v)
+}
+
+// JWKS 返回所有签名密钥的公钥，供其他服务验证 k8m 签发的 Token
+func (s *jwtKeyService) JWKS() ([]JWK, error) {
+	if err := s.ensureLoaded(false); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jwks := make([]JWK, 0, len(s.keys))
+	for _, k := range s.keys {
+		jwk := JWK{Kid: k.kid, Use: "sig", Alg: k.alg}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks, nil
+}
+
+// List 获取所有签名密钥
+func (s *jwtKeyService) List() ([]*models.JwtSigningKey, error) {
+	var keys []*models.JwtSigningKey
+	err := dao.DB().Select("id", "kid", "algorithm", "public_key", "active", "created_at", "updated_at").
+		Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Rotate 按当前算法生成新的签名密钥并设为当前密钥，历史密钥保留用于验证
+func (s *jwtKeyService) Rotate() (string, error) {
+	alg := s.Algorithm()
+	if alg == JwtAlgHS256 {
+		return "", errors.New("当前签名算法为HS256，请修改jwt-token-secret，或将jwt-signing-alg设置为RS256、ES256")
+	}
+	item, err := generateJwtSigningKey(alg)
+	if err != nil {
+		return "", err
+	}
+	err = dao.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.JwtSigningKey{}).Where("active = ?", true).Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Create(item).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("保存签名密钥失败: %w", err)
+	}
+	return item.Kid, s.ensureLoaded(true)
+}
+
+// Delete 删除历史签名密钥，该密钥签发的 Token 将无法验证
+func (s *jwtKeyService) Delete(kid string) error {
+	result := dao.DB().Where("kid = ? AND active = ?", kid, false).Delete(&models.JwtSigningKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("密钥不存在或为当前签名密钥，不能删除")
+	}
+	return s.ensureLoaded(true)
+}
+
+// activeKey 获取当前签名密钥，不存在或算法变更时自动生成
+func (s *jwtKeyService) activeKey() (*jwtSigningKey, error) {
+	if err := s.ensureLoaded(false); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	active := s.active
+	s.mu.RUnlock()
+	if active != nil && active.alg == s.Algorithm() {
+		return active, nil
+	}
+
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	if err := s.ensureLoaded(true); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	active = s.active
+	s.mu.RUnlock()
+	if active != nil && active.alg == s.Algorithm() {
+		return active, nil
+	}
+	kid, err := s.Rotate()
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("已生成 %s JWT签名密钥 [%s]", s.Algorithm(), kid)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.active == nil {
+		return nil, errors.New("未找到JWT签名密钥")
+	}
+	return s.active, nil
+}
+
+// verifyKey 根据 kid 获取验证密钥，未找到时重新加载一次，以识别其他副本新生成的密钥
+func (s *jwtKeyService) verifyKey(kid string) (*jwtSigningKey, error) {
+	if err := s.ensureLoaded(false); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	canReload := time.Since(s.missReload) > jwtKeyMissReloadInterval
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if canReload {
+		s.mu.Lock()
+		s.missReload = time.Now()
+		s.mu.Unlock()
+		if err := s.ensureLoaded(true); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		key, ok = s.keys[kid]
+		s.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+}
+
+// ensureLoaded 从数据库加载签名密钥，force 为 false 时按间隔重新加载
+func (s *jwtKeyService) ensureLoaded(force bool) error {
+	s.mu.RLock()
+	fresh := s.keys != nil && time.Since(s.loadedAt) < jwtKeyReloadInterval
+	s.mu.RUnlock()
+	if fresh && !force {
+		return nil
+	}
+
+	var items []*models.JwtSigningKey
+	if err := dao.DB().Order("created_at desc").Find(&items).Error; err != nil {
+		return fmt.Errorf("加载JWT签名密钥失败: %w", err)
+	}
+	keys := make(map[string]*jwtSigningKey, len(items))
+	var active *jwtSigningKey
+	for _, item := range items {
+		key, err := parseJwtSigningKey(item)
+		if err != nil {
+			klog.Errorf("解析JWT签名密钥[%s]失败: %v", item.Kid, err)
+			continue
+		}
+		keys[key.kid] = key
+		// 多副本同时生成时可能存在多个当前密钥，使用最新的
+		if item.Active && active == nil {
+			active = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keys, s.active, s.loadedAt = keys, active, time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// generateJwtSigningKey 生成密钥对，kid 为生成时间加随机后缀
+func generateJwtSigningKey(alg string) (*models.JwtSigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case JwtAlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case JwtAlgES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	return &models.JwtSigningKey{
+		Kid:        fmt.Sprintf("%s-%s-%s", strings.ToLower(alg), time.Now().Format("20060102150405"), suffix[:6]),
+		Algorithm:  alg,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		Active:     true,
+	}, nil
+}
+
+func parseJwtSigningKey(item *models.JwtSigningKey) (*jwtSigningKey, error) {
+	block, _ := pem.Decode([]byte(item.PrivateKey))
+	if block == nil {
+		return nil, errors.New("私钥格式错误")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("私钥类型不支持")
+	}
+	method := jwt.GetSigningMethod(item.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("不支持的签名算法: %s", item.Algorithm)
+	}
+	return &jwtSigningKey{
+		kid:     item.Kid,
+		alg:     item.Algorithm,
+		method:  method,
+		private: private,
+		public:  private.Public(),
+	}, nil
+}
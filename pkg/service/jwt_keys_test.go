@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestJwtSigningKeySignVerify(t *testing.T) {
+	for _, alg := range []string{JwtAlgRS256, JwtAlgES256} {
+		item, err := generateJwtSigningKey(alg)
+		if err != nil {
+			t.Fatalf("%s 生成密钥失败: %v", alg, err)
+		}
+		key, err := parseJwtSigningKey(item)
+		if err != nil {
+			t.Fatalf("%s 解析密钥失败: %v", alg, err)
+		}
+		token := jwt.NewWithClaims(key.method, jwt.MapClaims{"username": "test"})
+		token.Header["kid"] = key.kid
+		signed, err := token.SignedString(key.private)
+		if err != nil {
+			t.Fatalf("%s 签名失败: %v", alg, err)
+		}
+		parsed, err := jwt.Parse(signed, func(token *jwt.Token) (any, error) {
+			if token.Header["kid"] != key.kid {
+				t.Errorf("%s kid 期望 %s，实际 %v", alg, key.kid, token.Header["kid"])
+			}
+			return key.public, nil
+		})
+		if err != nil || !parsed.Valid {
+			t.Errorf("%s 验证签名失败: %v", alg, err)
+		}
+	}
+}
+
+func TestGenerateJwtSigningKeyUnsupported(t *testing.T) {
+	if _, err := generateJwtSigningKey(JwtAlgHS256); err == nil {
+		t.Errorf("HS256 不应生成密钥对")
+	}
+}
+
+func TestCheckHMACAccepted(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		secret       string
+		acceptBefore string
+		ok           bool
+	}{
+		{"未设置过渡期", "custom-secret", "", false},
+		{"默认Secret", "your-secret-key", "2026-12-31", false},
+		{"过渡期内", "custom-secret", "2026-03-02", true},
+		{"过渡期已结束", "custom-secret", "2026-03-01", false},
+		{"RFC3339", "custom-secret", "2026-03-01T13:00:00Z", true},
+		{"格式错误", "custom-secret", "next week", false},
+	}
+	for _, tt := range tests {
+		err := checkHMACAccepted(tt.secret, tt.acceptBefore, now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: 期望接受 %v，实际错误 %v", tt.name, tt.ok, err)
+		}
+	}
+}
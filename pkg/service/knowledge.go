@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/ai"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/knowledge"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/kom/doc"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	KnowledgeSourceRunbook    = "runbook"
+	KnowledgeSourceOpenAPI    = "openapi"
+	KnowledgeSourceInspection = "inspection"
+	KnowledgeSourceIncident   = "incident"
+
+	knowledgeIndexVector = "vector"
+	knowledgeIndexBM25   = "bm25"
+
+	// 每批向量化的分片数
+	embeddingBatchSize = 16
+	// 向量检索的最低相似度
+	minVectorScore = 0.3
+	// 用于检索的问题最大字符数
+	maxQueryRunes = 2000
+	// 未配置时的知识库分片总数上限
+	defaultKnowledgeMaxChunks = 5000
+)
+
+type knowledgeService struct{}
+
+// KnowledgeHit 检索命中的分片
+type KnowledgeHit struct {
+	DocID   uint    `json:"doc_id"`
+	Title   string  `json:"title"`
+	Source  string  `json:"source"`
+	Content string  `json:"content"`
+	Score   float64 `json:"score"`
+}
+
+// embedder 获取配置的向量模型，未配置时返回 nil，使用 BM25 检索
+func (s *knowledgeService) embedder() (ai.Embedder, string, error) {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil || cfg.EmbeddingModelID == 0 {
+		return nil, "", nil
+	}
+	mc, err := (&models.AIModelConfig{ID: cfg.EmbeddingModelID}).GetOne(nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("获取向量模型配置失败: %w", err)
+	}
+	client, err := AIService().ClientForModel(cfg.EmbeddingModelID)
+	if err != nil {
+		return nil, "", err
+	}
+	e, ok := client.(ai.Embedder)
+	if !ok {
+		return nil, "", fmt.Errorf("模型 %s 不支持 embeddings", mc.ApiModel)
+	}
+	return e, mc.ApiModel, nil
+}
+
+// Index 对文档分片并向量化，向量模型不可用时仅保存分片，检索时使用 BM25
+func (s *knowledgeService) Index(ctx context.Context, d *models.KnowledgeDoc) error {
+	parts := knowledge.Split(d.Content, knowledge.DefaultChunkSize, knowledge.DefaultChunkOverlap)
+	chunks := make([]*models.KnowledgeChunk, 0, len(parts))
+	for i, p := range parts {
+		chunks = append(chunks, &models.KnowledgeChunk{DocID: d.ID, Seq: i, Content: p})
+	}
+
+	d.IndexMode = knowledgeIndexBM25
+	d.IndexError = ""
+	e, modelName, err := s.embedder()
+	if err != nil {
+		d.IndexError = err.Error()
+	}
+	if e != nil {
+		if err := s.embedChunks(ctx, e, modelName, d.Title, chunks); err != nil {
+			klog.Errorf("知识库文档[%s]向量化失败，使用BM25检索: %v", d.Title, err)
+			d.IndexError = err.Error()
+		} else {
+			d.IndexMode = knowledgeIndexVector
+		}
+	}
+	d.ChunkCount = len(chunks)
+
+	return dao.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("doc_id = ?", d.ID).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) > 0 {
+			if err := tx.Create(&chunks).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(d).Select("chunk_count", "index_mode", "index_error").Updates(d).Error
+	})
+}
+
+func (s *knowledgeService) embedChunks(ctx context.Context, e ai.Embedder, modelName string, title string, chunks []*models.KnowledgeChunk) error {
+	ctx = ai.WithFeature(ctx, constants.AIFeatureKnowledge)
+	for start := 0; start < len(chunks); start += embeddingBatchSize {
+		batch := chunks[start:min(start+embeddingBatchSize, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = title + "\n" + c.Content
+		}
+		vectors, err := e.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for i, c := range batch {
+			c.Embedding = knowledge.EncodeVector(vectors[i])
+			c.EmbeddingModel = modelName
+		}
+	}
+	return nil
+}
+
+// Reindex 重建全部文档的索引，切换向量模型后使用
+func (s *knowledgeService) Reindex(ctx context.Context) {
+	var docs []*models.KnowledgeDoc
+	if err := dao.DB().Find(&docs).Error; err != nil {
+		klog.Errorf("加载知识库文档失败: %v", err)
+		return
+	}
+	for _, d := range docs {
+		if err := s.Index(ctx, d); err != nil {
+			klog.Errorf("重建知识库文档[%s]索引失败: %v", d.Title, err)
+		}
+	}
+}
+
+// maxChunks 知识库分片总数上限
+func (s *knowledgeService) maxChunks() int {
+	if cfg, err := ConfigService().GetConfig(); err == nil && cfg.KnowledgeMaxChunks > 0 {
+		return cfg.KnowledgeMaxChunks
+	}
+	return defaultKnowledgeMaxChunks
+}
+
+// checkChunkLimit 检查保存文档后分片总数是否超过上限
+func (s *knowledgeService) checkChunkLimit(d *models.KnowledgeDoc) error {
+	count := len(knowledge.Split(d.Content, knowledge.DefaultChunkSize, knowledge.DefaultChunkOverlap))
+	var others int64
+	if err := dao.DB().Model(&models.KnowledgeChunk{}).Where("doc_id <> ?", d.ID).Count(&others).Error; err != nil {
+		return err
+	}
+	if maxChunks := s.maxChunks(); int(others)+count > maxChunks {
+		return fmt.Errorf("知识库分片数将超过上限 %d（已有 %d，本文档 %d），请删除不再需要的文档或调大上限", maxChunks, others, count)
+	}
+	return nil
+}
+
+// Search 检索与问题最相关的 topK 个分片
+func (s *knowledgeService) Search(ctx context.Context, query string, topK int) ([]*KnowledgeHit, error) {
+	if topK <= 0 {
+		topK = 3
+	}
+	if runes := []rune(query); len(runes) > maxQueryRunes {
+		query = string(runes[:maxQueryRunes])
+	}
+
+	var rows []struct {
+		models.KnowledgeChunk
+		Title  string
+		Source string
+	}
+	err := dao.DB().Model(&models.KnowledgeChunk{}).
+		Select("knowledge_chunks.*, knowledge_docs.title, knowledge_docs.source").
+		Joins("JOIN knowledge_docs ON knowledge_docs.id = knowledge_chunks.doc_id").
+		Where("knowledge_docs.enabled = ?", true).
+		Order("knowledge_chunks.id desc").
+		Limit(s.maxChunks()).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	hits := make([]*KnowledgeHit, 0, len(rows))
+	for _, r := range rows {
+		hits = append(hits, &KnowledgeHit{DocID: r.DocID, Title: r.Title, Source: r.Source, Content: r.Content})
+	}
+
+	// 优先使用向量检索
+	scored := false
+	if e, _, err := s.embedder(); err == nil && e != nil {
+		vectors, err := e.Embed(ai.WithFeature(ctx, constants.AIFeatureKnowledge), []string{query})
+		if err != nil {
+			klog.Errorf("知识库问题向量化失败，使用BM25检索: %v", err)
+		} else {
+			for i, r := range rows {
+				if v := knowledge.DecodeVector(r.Embedding); v != nil {
+					hits[i].Score = knowledge.Cosine(vectors[0], v)
+					scored = scored || hits[i].Score > 0
+				}
+			}
+			if scored {
+				hits = filterHits(hits, minVectorScore)
+			}
+		}
+	}
+	if !scored {
+		docs := make([][]string, len(hits))
+		for i, h := range hits {
+			docs[i] = knowledge.Tokenize(h.Title + "\n" + h.Content)
+		}
+		bm25 := knowledge.NewBM25(docs)
+		q := knowledge.Tokenize(query)
+		for i, h := range hits {
+			h.Score = bm25.Score(q, i)
+		}
+		hits = filterHits(hits, 0)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > topK {
+		hits = hits[:topK]
+	}
+	return hits, nil
+}
+
+func filterHits(hits []*KnowledgeHit, minScore float64) []*KnowledgeHit {
+	result := hits[:0]
+	for _, h := range hits {
+		if h.Score > minScore {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// AugmentPrompt 检索知识库并将命中的资料附加到提问中，要求大模型在回答中标注引用
+func (s *knowledgeService) AugmentPrompt(ctx context.Context, question string) string {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil || !cfg.KnowledgeEnabled {
+		return question
+	}
+	hits, err := s.Search(ctx, question, cfg.KnowledgeTopK)
+	if err != nil {
+		klog.Errorf("检索知识库失败: %v", err)
+		return question
+	}
+	if len(hits) == 0 {
+		return question
+	}
+
+	var sb strings.Builder
+	sb.WriteString("以下是知识库中与问题相关的参考资料：\n\n")
+	for i, h := range hits {
+		sb.WriteString(fmt.Sprintf("[%d] %s（%s）\n%s\n\n", i+1, h.Title, knowledgeSourceName(h.Source), h.Content))
+	}
+	sb.WriteString("请结合以上参考资料回答问题。如使用了参考资料，请在相应内容后以 [编号] 标注引用，并在回答末尾以“参考资料”列出所引用资料的编号与标题；资料与问题无关时忽略即可。\n\n")
+	sb.WriteString("问题：\n")
+	sb.WriteString(question)
+	return sb.String()
+}
+
+func knowledgeSourceName(source string) string {
+	switch source {
+	case KnowledgeSourceRunbook:
+		return "运维手册"
+	case KnowledgeSourceOpenAPI:
+		return "资源字段文档"
+	case KnowledgeSourceInspection:
+		return "巡检总结"
+	case KnowledgeSourceIncident:
+		return "故障复盘"
+	default:
+		return source
+	}
+}
+
+// SaveDoc 保存文档并重建索引，sourceRef 不为空时按来源标识更新已有文档
+func (s *knowledgeService) SaveDoc(ctx context.Context, d *models.KnowledgeDoc) error {
+	if d.ID == 0 && d.SourceRef != "" {
+		var existing models.KnowledgeDoc
+		if err := dao.DB().Where("source_ref = ?", d.SourceRef).First(&existing).Error; err == nil {
+			d.ID = existing.ID
+			d.CreatedBy = existing.CreatedBy
+		}
+	}
+	if err := s.checkChunkLimit(d); err != nil {
+		return err
+	}
+	if err := dao.DB().Save(d).Error; err != nil {
+		return err
+	}
+	return s.Index(ctx, d)
+}
+
+// DeleteDocs 删除文档及其分片
+func (s *knowledgeService) DeleteDocs(ids []int64) error {
+	return dao.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("doc_id IN ?", ids).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.KnowledgeDoc{}).Error
+	})
+}
+
+// ImportInspectionSummaries 导入尚未入库的巡检AI总结，返回导入数量
+func (s *knowledgeService) ImportInspectionSummaries(ctx context.Context, username string) (int, error) {
+	var imported []string
+	err := dao.DB().Model(&models.KnowledgeDoc{}).
+		Where("source = ?", KnowledgeSourceInspection).
+		Pluck("source_ref", &imported).Error
+	if err != nil {
+		return 0, err
+	}
+	var records []*models.InspectionRecord
+	err = dao.DB().Where("ai_summary IS NOT NULL AND ai_summary <> ''").Find(&records).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, r := range records {
+		ref := fmt.Sprintf("%s:%d", KnowledgeSourceInspection, r.ID)
+		if slices.Contains(imported, ref) {
+			continue
+		}
+		title := fmt.Sprintf("巡检总结 %s %s", r.Cluster, r.StartTime.Format("2006-01-02 15:04"))
+		if r.ScheduleName != "" {
+			title = fmt.Sprintf("巡检总结 %s %s %s", r.ScheduleName, r.Cluster, r.StartTime.Format("2006-01-02 15:04"))
+		}
+		d := &models.KnowledgeDoc{
+			Title:     title,
+			Source:    KnowledgeSourceInspection,
+			SourceRef: ref,
+			Content:   r.AISummary,
+			Enabled:   true,
+			CreatedBy: username,
+		}
+		if err := s.SaveDoc(ctx, d); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ImportIncidents 将已恢复的集群中断导入知识库作为故障复盘，返回导入数量
+// 中断来自集群健康历史，仅导入已恢复的中断，已导入的跳过
+func (s *knowledgeService) ImportIncidents(ctx context.Context, username string) (int, error) {
+	var imported []string
+	err := dao.DB().Model(&models.KnowledgeDoc{}).
+		Where("source = ?", KnowledgeSourceIncident).
+		Pluck("source_ref", &imported).Error
+	if err != nil {
+		return 0, err
+	}
+	var clusters []string
+	if err := dao.DB().Model(&models.ClusterStatusEvent{}).Distinct().Pluck("cluster", &clusters).Error; err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, cluster := range clusters {
+		report, err := ClusterHealthService().Report(cluster, time.Time{}, time.Now())
+		if err != nil {
+			return count, err
+		}
+		for _, o := range report.Outages {
+			ref := fmt.Sprintf("%s:%s:%d", KnowledgeSourceIncident, cluster, o.Start.Unix())
+			if o.Ongoing || slices.Contains(imported, ref) {
+				continue
+			}
+			d := &models.KnowledgeDoc{
+				Title:     fmt.Sprintf("故障复盘 集群%s中断 %s", cluster, o.Start.Format("2006-01-02 15:04")),
+				Source:    KnowledgeSourceIncident,
+				SourceRef: ref,
+				Content:   incidentContent(cluster, o),
+				Enabled:   true,
+				CreatedBy: username,
+			}
+			if err := s.SaveDoc(ctx, d); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// incidentContent 将集群中断整理为故障复盘文档
+func incidentContent(cluster string, o *ClusterOutage) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# 集群 %s 连接中断\n\n", cluster))
+	sb.WriteString(fmt.Sprintf("- 开始时间：%s\n", o.Start.Format(time.DateTime)))
+	sb.WriteString(fmt.Sprintf("- 恢复时间：%s\n", o.End.Format(time.DateTime)))
+	sb.WriteString(fmt.Sprintf("- 中断时长：%s\n", (time.Duration(o.DurationSeconds) * time.Second).String()))
+	sb.WriteString(fmt.Sprintf("- 中断原因：%s\n", clusterHealthReasonText(o.Reason)))
+	if o.ErrType != "" {
+		sb.WriteString(fmt.Sprintf("- 错误类型：%s\n", o.ErrType))
+	}
+	if o.Err != "" {
+		sb.WriteString(fmt.Sprintf("- 错误信息：%s\n", o.Err))
+	}
+	return sb.String()
+}
+
+// ImportOpenAPIDoc 将集群中指定资源的 OpenAPI 字段文档导入知识库
+func (s *knowledgeService) ImportOpenAPIDoc(ctx context.Context, cluster string, apiVersion string, kind string, username string) (*models.KnowledgeDoc, error) {
+	if kom.Cluster(cluster) == nil {
+		return nil, fmt.Errorf("集群 %s 不存在", cluster)
+	}
+	node := kom.Cluster(cluster).WithContext(ctx).Status().Docs().FetchByGVK(apiVersion, kind)
+	if node == nil {
+		return nil, fmt.Errorf("未找到资源 %s/%s 的文档", apiVersion, kind)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s (%s)\n\n%s\n", kind, apiVersion, node.Description))
+	writeDocFields(&sb, node.Children, kind, 0)
+
+	d := &models.KnowledgeDoc{
+		Title:     fmt.Sprintf("%s %s 字段说明", apiVersion, kind),
+		Source:    KnowledgeSourceOpenAPI,
+		SourceRef: fmt.Sprintf("%s:%s/%s", KnowledgeSourceOpenAPI, apiVersion, kind),
+		Content:   sb.String(),
+		Enabled:   true,
+		CreatedBy: username,
+	}
+	if err := s.SaveDoc(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// writeDocFields 将字段树展开为 Markdown，顶层字段作为小节以便分片
+func writeDocFields(sb *strings.Builder, nodes []*doc.TreeNode, path string, depth int) {
+	// 限制展开层级，避免递归引用导致文档过大
+	if depth > 6 {
+		return
+	}
+	for _, n := range nodes {
+		fieldPath := path + "." + n.Label
+		if depth == 0 {
+			sb.WriteString(fmt.Sprintf("\n## %s\n", fieldPath))
+		}
+		desc := strings.ReplaceAll(strings.TrimSpace(n.Description), "\n", " ")
+		sb.WriteString(fmt.Sprintf("- %s <%s>: %s\n", fieldPath, n.Type, desc))
+		writeDocFields(sb, n.Children, fieldPath, depth+1)
+	}
+}
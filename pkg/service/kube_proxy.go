@@ -0,0 +1,280 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/client-go/rest"
+)
+
+// kubeProxyStripHeaders 转发前移除的请求头，k8m 的 Token 不能发往集群，客户端也不能借用 k8m 的集群凭据模拟其他身份
+var kubeProxyStripHeaders = []string{"Authorization", "Impersonate-User", "Impersonate-Group", "Impersonate-Uid"}
+
+// kubeExecSubresources 可进入容器执行命令的子资源，按 Exec 权限校验
+var kubeExecSubresources = []string{"exec", "attach", "portforward"}
+
+type kubeProxyService struct {
+	transports sync.Map // 集群ID -> *kubeProxyTransport
+}
+
+type kubeProxyTransport struct {
+	config    *rest.Config
+	server    *url.URL
+	transport http.RoundTripper
+	upgrade   proxy.UpgradeRequestRoundTripper
+}
+
+// KubeRequestInfo 从 Kubernetes API 请求路径中解析出的操作信息
+type KubeRequestInfo struct {
+	IsResourceRequest bool   // 是否为资源请求，否则为 /version、/api 等发现类请求
+	Verb              string // get、list、watch、create、update、patch、delete、deletecollection
+	APIGroup          string
+	APIVersion        string
+	Namespace         string
+	Resource          string
+	Subresource       string
+	Name              string
+}
+
+// Action 转换为 CheckPermissionLogic 使用的操作类型
+func (r *KubeRequestInfo) Action() string {
+	if !r.IsResourceRequest {
+		if r.Verb == "get" {
+			return "get"
+		}
+		return "update"
+	}
+	if r.Resource == "pods" && slices.Contains(kubeExecSubresources, r.Subresource) {
+		return "exec"
+	}
+	// 经 apiserver 代理访问 Pod、Service、Node，可绕过资源权限，要求集群管理员
+	if r.Subresource == "proxy" {
+		return "update"
+	}
+	if r.Verb == "deletecollection" {
+		return "delete"
+	}
+	return r.Verb
+}
+
+// IsReadOnly 是否为只读请求
+func (r *KubeRequestInfo) IsReadOnly() bool {
+	switch r.Action() {
+	case "get", "list", "watch":
+		return true
+	}
+	return false
+}
+
+// IsMutation 是否为需要记录操作日志的变更类请求
+func (r *KubeRequestInfo) IsMutation() bool {
+	return !r.IsReadOnly()
+}
+
+// ParseKubeRequest 按 apiserver 的规则解析请求，path 为去掉代理前缀后的 Kubernetes API 路径
+func ParseKubeRequest(method, apiPath string, query url.Values) *KubeRequestInfo {
+	info := &KubeRequestInfo{Verb: strings.ToLower(method)}
+	parts := splitKubePath(apiPath)
+	if len(parts) == 0 {
+		return info
+	}
+	switch {
+	case parts[0] == "api" && len(parts) >= 3:
+		info.APIVersion = parts[1]
+		parts = parts[2:]
+	case parts[0] == "apis" && len(parts) >= 4:
+		info.APIGroup = parts[1]
+		info.APIVersion = parts[2]
+		parts = parts[3:]
+	default:
+		// /api、/apis/{group}/{version}、/version、/openapi 等非资源请求
+		return info
+	}
+	info.IsResourceRequest = true
+
+	// 旧版 watch 路径：/api/v1/watch/namespaces/{ns}/pods
+	watchPath := false
+	if parts[0] == "watch" {
+		watchPath = true
+		parts = parts[1:]
+		if len(parts) == 0 {
+			info.IsResourceRequest = false
+			return info
+		}
+	}
+
+	if parts[0] == "namespaces" && len(parts) > 1 {
+		info.Namespace = parts[1]
+		if len(parts) > 2 {
+			parts = parts[2:]
+		} else {
+			// 命名空间本身
+			parts = []string{"namespaces", info.Namespace}
+		}
+	}
+	info.Resource = parts[0]
+	if len(parts) > 1 {
+		info.Name = parts[1]
+	}
+	if len(parts) > 2 {
+		info.Subresource = parts[2]
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		info.Verb = "get"
+		if info.Name == "" {
+			info.Verb = "list"
+		}
+		if watchPath || isTrue(query.Get("watch")) {
+			info.Verb = "watch"
+		}
+	case http.MethodPost:
+		info.Verb = "create"
+	case http.MethodPut:
+		info.Verb = "update"
+	case http.MethodPatch:
+		info.Verb = "patch"
+	case http.MethodDelete:
+		info.Verb = "delete"
+		if info.Name == "" {
+			info.Verb = "deletecollection"
+		}
+	}
+	return info
+}
+
+// CheckClusterWide 检查未指定命名空间的请求
+// 跨命名空间访问属于命名空间的资源，如 kubectl get pods -A，要求不限命名空间且无黑名单的授权；
+// 创建、修改、删除集群级资源，如 Node、ClusterRole，要求不限命名空间且无黑名单的集群管理员授权
+func (s *kubeProxyService) CheckClusterWide(username, cluster string, info *KubeRequestInfo) error {
+	if !info.IsResourceRequest || info.Namespace != "" || UserService().IsUserPlatformAdmin(username) {
+		return nil
+	}
+	roles, err := UserService().GetClusters(username)
+	if err != nil {
+		return err
+	}
+	return checkClusterWideRoles(username, cluster, roles, s.isNamespaced(cluster, info.APIGroup, info.Resource), info.IsReadOnly())
+}
+
+// checkClusterWideRoles 按用户在集群上的授权判断是否允许未指定命名空间的请求
+func checkClusterWideRoles(username, cluster string, roles []*models.ClusterUserRole, namespaced, readOnly bool) error {
+	// 集群级资源的只读请求不涉及命名空间，由资源权限检查决定
+	if !namespaced && readOnly {
+		return nil
+	}
+	for _, role := range roles {
+		if role.Cluster != cluster || strings.TrimSpace(role.Namespaces) != "" || strings.TrimSpace(role.BlacklistNamespaces) != "" {
+			continue
+		}
+		if namespaced || role.Role == constants.RoleClusterAdmin {
+			return nil
+		}
+	}
+	if namespaced {
+		return fmt.Errorf("用户[%s]在集群[%s]仅有部分命名空间权限，请指定命名空间", username, cluster)
+	}
+	return fmt.Errorf("用户[%s]在集群[%s]没有不限命名空间的集群管理员权限，不能修改集群级资源", username, cluster)
+}
+
+// NewHandler 创建转发到集群 apiserver 的代理，使用 k8m 保存的集群凭据，支持 watch 及 exec、port-forward 等协议升级
+func (s *kubeProxyService) NewHandler(cluster string, req *http.Request, apiPath string, responder proxy.ErrorResponder) (*proxy.UpgradeAwareHandler, error) {
+	pt, err := s.transport(cluster)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range kubeProxyStripHeaders {
+		req.Header.Del(h)
+	}
+	for h := range req.Header {
+		if strings.HasPrefix(h, "Impersonate-Extra-") {
+			req.Header.Del(h)
+		}
+	}
+
+	location := *pt.server
+	location.Path = path.Join("/", pt.server.Path, apiPath)
+	if strings.HasSuffix(apiPath, "/") && !strings.HasSuffix(location.Path, "/") {
+		location.Path += "/"
+	}
+	query := req.URL.Query()
+	// Token 通过查询参数传递时不能转发
+	if query.Has("token") {
+		query.Del("token")
+		req.URL.RawQuery = query.Encode()
+	}
+	location.RawQuery = req.URL.RawQuery
+
+	handler := proxy.NewUpgradeAwareHandler(&location, pt.transport, false, false, responder)
+	handler.UpgradeTransport = pt.upgrade
+	handler.UseLocationHost = true
+	return handler, nil
+}
+
+// transport 获取集群的连接，集群重新连接后凭据可能变化，据此重建
+func (s *kubeProxyService) transport(cluster string) (*kubeProxyTransport, error) {
+	cc := ClusterService().GetClusterByID(cluster)
+	if cc == nil || !ClusterService().IsConnected(cluster) {
+		return nil, fmt.Errorf("集群未连接: %s", cluster)
+	}
+	config := cc.GetRestConfig()
+	if config == nil {
+		return nil, fmt.Errorf("集群未连接: %s", cluster)
+	}
+	if v, ok := s.transports.Load(cluster); ok && v.(*kubeProxyTransport).config == config {
+		return v.(*kubeProxyTransport), nil
+	}
+
+	server, _, err := rest.DefaultServerUrlFor(config)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	upgrade, err := utils.NewUpgradeTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	pt := &kubeProxyTransport{config: config, server: server, transport: rt, upgrade: upgrade}
+	s.transports.Store(cluster, pt)
+	return pt, nil
+}
+
+// isNamespaced 根据集群的资源发现信息判断资源是否属于命名空间，未知资源按属于命名空间处理
+func (s *kubeProxyService) isNamespaced(cluster, group, resource string) bool {
+	k := kom.Cluster(cluster)
+	if k == nil {
+		return true
+	}
+	for _, r := range k.Status().APIResources() {
+		if r.Group == group && r.Name == resource {
+			return r.Namespaced
+		}
+	}
+	return true
+}
+
+func splitKubePath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func isTrue(v string) bool {
+	return v == "true" || v == "1"
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestParseKubeRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		query     string
+		resource  bool
+		action    string
+		namespace string
+		kind      string
+		object    string
+	}{
+		{"版本信息", http.MethodGet, "/version", "", false, "get", "", "", ""},
+		{"资源发现", http.MethodGet, "/apis/apps/v1", "", false, "get", "", "", ""},
+		{"列出Pod", http.MethodGet, "/api/v1/namespaces/default/pods", "", true, "list", "default", "pods", ""},
+		{"跨命名空间列出Pod", http.MethodGet, "/api/v1/pods", "", true, "list", "", "pods", ""},
+		{"watch参数", http.MethodGet, "/apis/apps/v1/namespaces/dev/deployments", "watch=true", true, "watch", "dev", "deployments", ""},
+		{"旧版watch路径", http.MethodGet, "/api/v1/watch/namespaces/dev/pods/web", "", true, "watch", "dev", "pods", "web"},
+		{"获取命名空间", http.MethodGet, "/api/v1/namespaces/dev", "", true, "get", "dev", "namespaces", "dev"},
+		{"创建Deployment", http.MethodPost, "/apis/apps/v1/namespaces/dev/deployments", "", true, "create", "dev", "deployments", ""},
+		{"更新scale", http.MethodPut, "/apis/apps/v1/namespaces/dev/deployments/web/scale", "", true, "update", "dev", "deployments", "web"},
+		{"批量删除", http.MethodDelete, "/api/v1/namespaces/dev/pods", "", true, "delete", "dev", "pods", ""},
+		{"exec", http.MethodPost, "/api/v1/namespaces/dev/pods/web/exec", "command=sh", true, "exec", "dev", "pods", "web"},
+		{"websocket exec", http.MethodGet, "/api/v1/namespaces/dev/pods/web/exec", "command=sh", true, "exec", "dev", "pods", "web"},
+		{"端口转发", http.MethodPost, "/api/v1/namespaces/dev/pods/web/portforward", "", true, "exec", "dev", "pods", "web"},
+		{"节点代理", http.MethodGet, "/api/v1/nodes/node1/proxy/metrics", "", true, "update", "", "nodes", "node1"},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		info := ParseKubeRequest(tt.method, tt.path, query)
+		if info.IsResourceRequest != tt.resource || info.Action() != tt.action || info.Namespace != tt.namespace ||
+			info.Resource != tt.kind || info.Name != tt.object {
+			t.Errorf("%s: 解析结果不符合预期 %+v，操作 %s", tt.name, info, info.Action())
+		}
+	}
+}
+
+func TestCheckClusterWideRoles(t *testing.T) {
+	nsAdmin := &models.ClusterUserRole{Cluster: "prod", Role: constants.RoleClusterAdmin, Namespaces: "dev"}
+	blacklisted := &models.ClusterUserRole{Cluster: "prod", Role: constants.RoleClusterAdmin, BlacklistNamespaces: "kube-system"}
+	readonly := &models.ClusterUserRole{Cluster: "prod", Role: constants.RoleClusterReadonly}
+	admin := &models.ClusterUserRole{Cluster: "prod", Role: constants.RoleClusterAdmin}
+	otherAdmin := &models.ClusterUserRole{Cluster: "test", Role: constants.RoleClusterAdmin}
+	tests := []struct {
+		name       string
+		roles      []*models.ClusterUserRole
+		namespaced bool
+		readOnly   bool
+		allowed    bool
+	}{
+		{"部分命名空间跨命名空间列出Pod", []*models.ClusterUserRole{nsAdmin}, true, true, false},
+		{"有黑名单跨命名空间列出Pod", []*models.ClusterUserRole{blacklisted}, true, true, false},
+		{"不限命名空间的只读权限列出Pod", []*models.ClusterUserRole{nsAdmin, readonly}, true, true, true},
+		{"其他集群的管理员权限", []*models.ClusterUserRole{otherAdmin}, true, true, false},
+		{"部分命名空间读取集群级资源", []*models.ClusterUserRole{nsAdmin}, false, true, true},
+		{"部分命名空间的管理员修改集群级资源", []*models.ClusterUserRole{nsAdmin}, false, false, false},
+		{"有黑名单的管理员修改集群级资源", []*models.ClusterUserRole{blacklisted}, false, false, false},
+		{"只读权限修改集群级资源", []*models.ClusterUserRole{readonly}, false, false, false},
+		{"不限命名空间的管理员修改集群级资源", []*models.ClusterUserRole{readonly, admin}, false, false, true},
+	}
+	for _, tt := range tests {
+		err := checkClusterWideRoles("alice", "prod", tt.roles, tt.namespaced, tt.readOnly)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: 期望允许 %v，实际错误 %v", tt.name, tt.allowed, err)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/models"
+	"github.com/weibaohui/k8m/pkg/webhook"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"k8s.io/klog/v2"
+)
+
+const (
+	LoginLockKindUser = "user"
+	LoginLockKindIP   = "ip"
+
+	// loginFailureWindow 超过该时间未再失败，失败次数重新计算
+	loginFailureWindow = time.Hour
+	// loginMaxDelay 连续失败后两次尝试之间的最长等待时间
+	loginMaxDelay = time.Minute
+	// loginMaxLock 锁定时长上限，超过该时间未再失败，锁定次数重新计算
+	loginMaxLock = 24 * time.Hour
+	// loginAttemptTimeout 占用的尝试超过该时间未释放视为失效，避免进程异常退出后一直占用
+	loginAttemptTimeout = 30 * time.Second
+	// loginReservationKey 本次请求占用的尝试，保存在 gin.Context 中
+	loginReservationKey = "login_guard_reservation"
+)
+
+// LoginLockedError 登录失败次数过多，需等待后重试
+type LoginLockedError struct {
+	RetryAfter time.Duration
+	Locked     bool // 是否已锁定，否则为失败后的递增等待
+}
+
+func (e *LoginLockedError) Error() string {
+	wait := e.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if e.Locked {
+		return fmt.Sprintf("登录失败次数过多，已临时锁定，请 %s 后重试或联系管理员解锁", formatLoginWait(wait))
+	}
+	return fmt.Sprintf("登录失败，请 %s 后重试", formatLoginWait(wait))
+}
+
+type loginGuardService struct {
+	mu sync.Mutex
+}
+
+// loginReservation 本次请求占用的尝试，失败或结束时释放
+type loginReservation struct {
+	user string
+	ip   string
+	done bool
+}
+
+// Check 登录前检查用户名及来源IP是否被锁定或处于失败后的等待时间内
+func (s *loginGuardService) Check(c *gin.Context, username string) error {
+	cfg := s.config()
+	now := time.Now()
+	if cfg.LoginMaxFailures > 0 {
+		if wait, locked := retryAfterLock(s.find(LoginLockKindUser, username), now, true); wait > 0 {
+			return &LoginLockedError{RetryAfter: wait, Locked: locked}
+		}
+	}
+	if cfg.LoginIPMaxFailures > 0 {
+		if wait, locked := retryAfterLock(s.find(LoginLockKindIP, c.ClientIP()), now, false); wait > 0 {
+			return &LoginLockedError{RetryAfter: wait, Locked: locked}
+		}
+	}
+	return nil
+}
+
+// Reserve 校验密码前检查锁定状态并占用一次尝试，占用成功后须调用 Release 释放
+// 同一用户名同时只允许一次尝试，同一来源IP失败次数与进行中的尝试之和不超过上限，避免并发请求绕过失败次数限制
+func (s *loginGuardService) Reserve(c *gin.Context, username string) error {
+	if err := s.Check(c, username); err != nil {
+		return err
+	}
+	cfg := s.config()
+	r := &loginReservation{}
+	if cfg.LoginMaxFailures > 0 && username != "" {
+		if err := s.reserve(LoginLockKindUser, username, cfg.LoginMaxFailures); err != nil {
+			return err
+		}
+		r.user = username
+	}
+	if ip := c.ClientIP(); cfg.LoginIPMaxFailures > 0 && ip != "" {
+		if err := s.reserve(LoginLockKindIP, ip, cfg.LoginIPMaxFailures); err != nil {
+			s.release(LoginLockKindUser, r.user)
+			return err
+		}
+		r.ip = ip
+	}
+	c.Set(loginReservationKey, r)
+	return nil
+}
+
+// Release 释放本次请求占用且未计入失败的尝试
+func (s *loginGuardService) Release(c *gin.Context) {
+	r := s.reservation(c)
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+	s.release(LoginLockKindUser, r.user)
+	s.release(LoginLockKindIP, r.ip)
+}
+
+// Fail 记录一次失败的登录，写入操作日志，达到次数上限时锁定并通知用户
+// 本次请求已占用尝试时，失败计入所占用的尝试
+func (s *loginGuardService) Fail(c *gin.Context, username, loginType, reason string) {
+	ip := c.ClientIP()
+	klog.V(2).Infof("用户[%s]登录失败，来源IP[%s]，登录方式[%s]: %s", username, ip, loginType, reason)
+	s.audit(username, "login_failed", reason, map[string]string{
+		"client_ip":  ip,
+		"login_type": loginType,
+		"user_agent": c.Request.UserAgent(),
+	})
+
+	cfg := s.config()
+	lockMinutes := max(cfg.LoginLockMinutes, 1)
+	r := s.reservation(c)
+	if r == nil || r.done {
+		r = &loginReservation{}
+	}
+	r.done = true
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanup()
+	if cfg.LoginMaxFailures > 0 && username != "" {
+		until, err := s.record(LoginLockKindUser, username, cfg.LoginMaxFailures, lockMinutes, r.user == username)
+		if err != nil {
+			klog.Errorf("记录用户[%s]登录失败次数失败: %v", username, err)
+		} else if until != nil {
+			klog.Warningf("用户[%s]登录失败次数过多，锁定至 %s", username, until.Format(time.DateTime))
+			s.audit(username, "login_locked", "锁定至 "+until.Format(time.DateTime), map[string]string{"client_ip": ip})
+			go s.notifyLocked(username, ip, *until)
+		}
+	}
+	if cfg.LoginIPMaxFailures > 0 && ip != "" {
+		until, err := s.record(LoginLockKindIP, ip, cfg.LoginIPMaxFailures, lockMinutes, r.ip == ip)
+		if err != nil {
+			klog.Errorf("记录IP[%s]登录失败次数失败: %v", ip, err)
+		} else if until != nil {
+			klog.Warningf("IP[%s]登录失败次数过多，锁定至 %s", ip, until.Format(time.DateTime))
+			s.audit(username, "login_locked", "来源IP锁定至 "+until.Format(time.DateTime), map[string]string{"client_ip": ip})
+		}
+	}
+}
+
+// Succeed 登录成功后清除用户名的失败记录，来源IP的记录不清除，避免攻击者用自己的账号重置计数
+func (s *loginGuardService) Succeed(username string) {
+	err := dao.DB().Where("kind = ? AND value = ?", LoginLockKindUser, username).Delete(&models.LoginLock{}).Error
+	if err != nil {
+		klog.Errorf("清除用户[%s]登录失败记录失败: %v", username, err)
+	}
+}
+
+// Unlock 解除锁定并清除失败次数
+func (s *loginGuardService) Unlock(ids []int64, operator string) (int, error) {
+	var locks []*models.LoginLock
+	if err := dao.DB().Where("id in ?", ids).Find(&locks).Error; err != nil {
+		return 0, err
+	}
+	for _, lock := range locks {
+		if err := dao.DB().Delete(lock).Error; err != nil {
+			return 0, err
+		}
+		username := ""
+		if lock.Kind == LoginLockKindUser {
+			username = lock.Value
+		}
+		s.audit(username, "login_unlock", fmt.Sprintf("%s 解除锁定 %s:%s", operator, lock.Kind, lock.Value), nil)
+	}
+	return len(locks), nil
+}
+
+// record 增加失败次数，达到上限时锁定，锁定时长随连续锁定次数翻倍，返回本次锁定的截止时间
+// reserved 为 true 时同时释放占用的尝试，计数使用条件更新，多副本并发失败时不丢失
+func (s *loginGuardService) record(kind, value string, maxFailures, lockMinutes int, reserved bool) (*time.Time, error) {
+	now := time.Now()
+	if err := s.prepare(kind, value, now); err != nil {
+		return nil, err
+	}
+	updates := map[string]any{
+		"failures":       gorm.Expr("failures + ?", 1),
+		"last_failed_at": now,
+	}
+	if reserved {
+		updates["pending"] = gorm.Expr("CASE WHEN pending > 0 THEN pending - 1 ELSE 0 END")
+	}
+	if err := s.lockQuery(kind, value).UpdateColumns(updates).Error; err != nil {
+		return nil, err
+	}
+	lock := s.find(kind, value)
+	if lock == nil || lock.Failures < maxFailures {
+		return nil, nil
+	}
+	until := now.Add(loginLockDuration(lockMinutes, lock.Locks+1))
+	// 条件更新，并发失败时仅锁定一次
+	result := s.lockQuery(kind, value).Where("failures = ?", lock.Failures).UpdateColumns(map[string]any{
+		"failures":     0,
+		"locks":        gorm.Expr("locks + ?", 1),
+		"locked_until": until,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &until, nil
+}
+
+// reserve 占用一次尝试，已锁定、同一用户名有进行中的尝试或失败次数与进行中的尝试之和达到上限时拒绝
+func (s *loginGuardService) reserve(kind, value string, maxFailures int) error {
+	now := time.Now()
+	if err := s.prepare(kind, value, now); err != nil {
+		return err
+	}
+	db := s.lockQuery(kind, value).
+		Where("locked_until IS NULL OR locked_until <= ?", now).
+		Where("failures + pending < ?", maxFailures)
+	if kind == LoginLockKindUser {
+		db = db.Where("pending = 0")
+	}
+	result := db.UpdateColumns(map[string]any{
+		"pending":     gorm.Expr("pending + ?", 1),
+		"reserved_at": now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if wait, locked := retryAfterLock(s.find(kind, value), now, false); locked {
+		return &LoginLockedError{RetryAfter: wait, Locked: true}
+	}
+	return &LoginLockedError{RetryAfter: time.Second}
+}
+
+// release 释放占用的尝试
+func (s *loginGuardService) release(kind, value string) {
+	if value == "" {
+		return
+	}
+	err := s.lockQuery(kind, value).Where("pending > 0").UpdateColumn("pending", gorm.Expr("pending - ?", 1)).Error
+	if err != nil {
+		klog.Errorf("释放%s[%s]登录尝试失败: %v", kind, value, err)
+	}
+}
+
+// prepare 确保记录存在，并按时间窗口重置失败次数、锁定次数及超时未释放的尝试
+func (s *loginGuardService) prepare(kind, value string, now time.Time) error {
+	err := dao.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginLock{Kind: kind, Value: value}).Error
+	if err != nil {
+		return err
+	}
+	resets := []struct {
+		where string
+		arg   time.Time
+		field string
+	}{
+		{"last_failed_at < ?", now.Add(-loginFailureWindow), "failures"},
+		{"last_failed_at < ?", now.Add(-loginMaxLock), "locks"},
+		{"reserved_at < ?", now.Add(-loginAttemptTimeout), "pending"},
+	}
+	for _, r := range resets {
+		if err := s.lockQuery(kind, value).Where(r.where, r.arg).Where(r.field+" > 0").UpdateColumn(r.field, 0).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *loginGuardService) lockQuery(kind, value string) *gorm.DB {
+	return dao.DB().Model(&models.LoginLock{}).Where("kind = ? AND value = ?", kind, value)
+}
+
+func (s *loginGuardService) reservation(c *gin.Context) *loginReservation {
+	if v, ok := c.Get(loginReservationKey); ok {
+		return v.(*loginReservation)
+	}
+	return nil
+}
+
+func (s *loginGuardService) find(kind, value string) *models.LoginLock {
+	lock := &models.LoginLock{}
+	if err := dao.DB().Where("kind = ? AND value = ?", kind, value).First(lock).Error; err != nil {
+		return nil
+	}
+	return lock
+}
+
+// notifyLocked 通过用户配置的 webhook 及邮箱发送锁定通知
+func (s *loginGuardService) notifyLocked(username, ip string, until time.Time) {
+	user := &models.User{}
+	if err := dao.DB().Select("id", "username", "email", "notify_webhooks").Where("username = ?", username).First(user).Error; err != nil {
+		return
+	}
+	msg := fmt.Sprintf("k8m 账号安全提醒：用户[%s]登录失败次数过多，已被临时锁定至 %s，最近一次失败来源IP %s。如非本人操作，请尽快修改密码并联系管理员。",
+		username, until.Format(time.DateTime), ip)
+
+	if ids := utils.SplitAndTrim(user.NotifyWebhooks, ","); len(ids) > 0 {
+		var receivers []*models.WebhookReceiver
+		if err := dao.DB().Where("id in ?", ids).Find(&receivers).Error; err != nil {
+			klog.Errorf("查询用户[%s]通知webhook失败: %v", username, err)
+		}
+		webhook.PushMsgToAllTargets(msg, "", receivers)
+	}
+	if email := strings.TrimSpace(user.Email); email != "" {
+		err := MailService().Send([]string{email}, "k8m 账号已临时锁定", msg)
+		if err != nil && !errors.Is(err, ErrMailNotConfigured) {
+			klog.Errorf("发送用户[%s]锁定通知邮件失败: %v", username, err)
+		}
+	}
+}
+
+// audit 写入操作日志，便于审计
+func (s *loginGuardService) audit(username, action, result string, params map[string]string) {
+	log := &models.OperationLog{
+		UserName:     username,
+		Kind:         "Login",
+		Action:       action,
+		ActionResult: result,
+	}
+	if params != nil {
+		OperationLogService().Add(log, params)
+		return
+	}
+	OperationLogService().Add(log)
+}
+
+// cleanup 清理已解锁且长时间未再失败的记录
+func (s *loginGuardService) cleanup() {
+	threshold := time.Now().Add(-loginMaxLock)
+	err := dao.DB().Where("(last_failed_at < ? OR (last_failed_at IS NULL AND updated_at < ?)) AND (locked_until IS NULL OR locked_until < ?) AND pending = 0",
+		threshold, threshold, time.Now()).
+		Delete(&models.LoginLock{}).Error
+	if err != nil {
+		klog.V(6).Infof("清理登录失败记录失败: %v", err)
+	}
+}
+
+func (s *loginGuardService) config() *models.Config {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil {
+		return &models.Config{LoginMaxFailures: 5, LoginIPMaxFailures: 20, LoginLockMinutes: 15}
+	}
+	return cfg
+}
+
+// retryAfterLock 计算还需等待的时间，withDelay 为 true 时连续失败后按 1、2、4... 秒递增等待
+func retryAfterLock(lock *models.LoginLock, now time.Time, withDelay bool) (time.Duration, bool) {
+	if lock == nil {
+		return 0, false
+	}
+	if lock.LockedUntil != nil && now.Before(*lock.LockedUntil) {
+		return lock.LockedUntil.Sub(now), true
+	}
+	if !withDelay || lock.Failures <= 0 || lock.LastFailedAt == nil || now.Sub(*lock.LastFailedAt) > loginFailureWindow {
+		return 0, false
+	}
+	until := lock.LastFailedAt.Add(loginFailureDelay(lock.Failures))
+	if now.Before(until) {
+		return until.Sub(now), false
+	}
+	return 0, false
+}
+
+// loginFailureDelay 第 n 次失败后需等待的时间
+func loginFailureDelay(failures int) time.Duration {
+	if failures > 7 {
+		return loginMaxDelay
+	}
+	return min(time.Second<<(failures-1), loginMaxDelay)
+}
+
+// loginLockDuration 第 n 次锁定的时长，首次为配置的分钟数，之后翻倍
+func loginLockDuration(lockMinutes, locks int) time.Duration {
+	d := time.Duration(lockMinutes) * time.Minute
+	for i := 1; i < locks && d < loginMaxLock; i++ {
+		d *= 2
+	}
+	return min(d, loginMaxLock)
+}
+
+func formatLoginWait(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d秒", int(d.Seconds()))
+	}
+	return fmt.Sprintf("%d分钟", int((d+time.Minute-1)/time.Minute))
+}
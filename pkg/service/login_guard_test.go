@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestLoginLockDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		locks int
+		want  time.Duration
+	}{
+		{"首次锁定", 1, 15 * time.Minute},
+		{"第二次锁定翻倍", 2, 30 * time.Minute},
+		{"第四次锁定", 4, 2 * time.Hour},
+		{"不超过24小时", 20, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := loginLockDuration(15, tt.locks); got != tt.want {
+			t.Errorf("%s: 期望 %v，实际 %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRetryAfterLock(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	later := now.Add(10 * time.Minute)
+	tests := []struct {
+		name      string
+		lock      *models.LoginLock
+		withDelay bool
+		wait      time.Duration
+		locked    bool
+	}{
+		{"无记录", nil, true, 0, false},
+		{"锁定中", &models.LoginLock{LockedUntil: &later}, true, 10 * time.Minute, true},
+		{"锁定已过期", &models.LoginLock{LockedUntil: ago(time.Second)}, true, 0, false},
+		{"第三次失败后等待4秒", &models.LoginLock{Failures: 3, LastFailedAt: ago(time.Second)}, true, 3 * time.Second, false},
+		{"等待时间已过", &models.LoginLock{Failures: 3, LastFailedAt: ago(5 * time.Second)}, true, 0, false},
+		{"来源IP不递增等待", &models.LoginLock{Failures: 3, LastFailedAt: ago(time.Second)}, false, 0, false},
+		{"等待不超过60秒", &models.LoginLock{Failures: 30, LastFailedAt: ago(0)}, true, time.Minute, false},
+	}
+	for _, tt := range tests {
+		wait, locked := retryAfterLock(tt.lock, now, tt.withDelay)
+		if wait != tt.wait || locked != tt.locked {
+			t.Errorf("%s: 期望 %v/%v，实际 %v/%v", tt.name, tt.wait, tt.locked, wait, locked)
+		}
+	}
+}
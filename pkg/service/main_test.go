@@ -0,0 +1,12 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/weibaohui/k8m/internal/dao/daotest"
+)
+
+// 测试使用临时目录下的数据库，不在源码目录生成 data/k8m.db
+func TestMain(m *testing.M) {
+	daotest.Run(m)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+const (
+	// MCPToolAccessRead 只读工具，直接执行
+	MCPToolAccessRead = "read"
+	// MCPToolAccessWrite 写入工具，执行前需要用户确认
+	MCPToolAccessWrite = "write"
+)
+
+// readOnlyToolPrefixes 只读工具的名称前缀，其余工具默认视为写操作
+var readOnlyToolPrefixes = []string{"get_", "list_", "describe_", "search_", "query_", "read_", "top_"}
+
+// ToolApprovalRequest 待用户确认的工具调用
+type ToolApprovalRequest struct {
+	ID         string         `json:"id"`
+	ToolName   string         `json:"tool_name"`
+	ServerName string         `json:"server_name"`
+	Arguments  map[string]any `json:"arguments"`
+	Preview    *ToolPreview   `json:"preview,omitempty"`
+}
+
+// ToolApprovalResponse 用户对工具调用的确认结果
+type ToolApprovalResponse struct {
+	ID       string `json:"id"`
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ToolApprover 由对话通道实现，阻塞等待用户确认，返回是否批准及拒绝原因
+type ToolApprover func(ctx context.Context, req *ToolApprovalRequest) ToolApprovalResponse
+
+type toolApproverKey struct{}
+
+// WithToolApprover 在上下文中设置工具调用确认方式
+func WithToolApprover(ctx context.Context, approver ToolApprover) context.Context {
+	return context.WithValue(ctx, toolApproverKey{}, approver)
+}
+
+func toolApproverFromContext(ctx context.Context) ToolApprover {
+	if approver, ok := ctx.Value(toolApproverKey{}).(ToolApprover); ok {
+		return approver
+	}
+	return nil
+}
+
+// MCPToolAccessMode 根据 MCP 工具声明判断读写类型，未声明只读时返回空，执行时再按名称判断
+func MCPToolAccessMode(tool mcp.Tool) string {
+	if tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint {
+		return MCPToolAccessRead
+	}
+	return ""
+}
+
+// isReadOnlyToolName 按工具名称判断是否为只读工具
+func isReadOnlyToolName(toolName string) bool {
+	name := strings.ToLower(toolName)
+	for _, prefix := range readOnlyToolPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsToolReadOnly 判断工具是否为只读，优先使用管理员配置，其次是工具声明与名称
+func (m *MCPHost) IsToolReadOnly(toolName, serverName string) bool {
+	var tool models.MCPTool
+	err := dao.DB().Model(&models.MCPTool{}).
+		Where("name = ? and server_name = ?", toolName, serverName).
+		First(&tool).Error
+	if err == nil && tool.AccessMode != "" {
+		return tool.AccessMode == MCPToolAccessRead
+	}
+
+	m.mutex.RLock()
+	for _, t := range m.Tools[serverName] {
+		if t.Name == toolName && MCPToolAccessMode(t) == MCPToolAccessRead {
+			m.mutex.RUnlock()
+			return true
+		}
+	}
+	m.mutex.RUnlock()
+
+	return isReadOnlyToolName(toolName)
+}
+
+// approveToolCall 写操作执行前请求用户确认，未提供确认通道时直接拒绝
+func (m *MCPHost) approveToolCall(ctx context.Context, id, toolName, serverName string, args map[string]any) (bool, string) {
+	if m.IsToolReadOnly(toolName, serverName) {
+		return true, ""
+	}
+	approver := toolApproverFromContext(ctx)
+	if approver == nil {
+		return false, fmt.Sprintf("工具 %s 会修改集群资源，当前会话不支持人工确认，已拒绝执行", toolName)
+	}
+
+	req := &ToolApprovalRequest{
+		ID:         id,
+		ToolName:   toolName,
+		ServerName: serverName,
+		Arguments:  args,
+		Preview:    previewToolCall(ctx, toolName, args),
+	}
+	resp := approver(ctx, req)
+	if resp.Approved {
+		return true, ""
+	}
+	reason := "用户拒绝执行该操作"
+	if resp.Reason != "" {
+		reason = fmt.Sprintf("%s：%s", reason, resp.Reason)
+	}
+	return false, reason
+}
@@ -407,6 +407,20 @@ func (m *MCPHost) ExecTools(ctx context.Context, toolCalls []openai.ToolCall) []
 				continue
 			}
 			klog.V(6).Infof("解析ToolName: %s, ServerName: %s\n", toolName, serverName)
+			// 写操作需用户确认，拒绝原因作为执行结果反馈给大模型
+			callID := toolCall.ID
+			if callID == "" {
+				callID = utils.RandNLengthString(12)
+			}
+			if approved, reason := m.approveToolCall(ctx, callID, toolName, serverName, args); !approved {
+				klog.V(6).Infof("工具调用未获批准: %s %s\n", toolName, reason)
+				result.Error = reason
+				results = append(results, result)
+				m.LogToolExecution(ctx, toolName, serverName, args, result, time.Since(startTime).Milliseconds())
+				continue
+			}
+			// 等待确认的时间不计入执行耗时
+			startTime = time.Now()
 			// 执行工具调用
 			callRequest := mcp.CallToolRequest{}
 			callRequest.Params.Name = toolName
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/kom/kom"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// ToolPreview 写操作的预览，通过 dry-run 获取变更前后的资源内容
+type ToolPreview struct {
+	Cluster string `json:"cluster"`
+	Before  string `json:"before,omitempty"`
+	After   string `json:"after,omitempty"`
+	Diff    string `json:"diff,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PermissionCheckFunc 校验用户在集群、命名空间上是否有指定操作的权限
+type PermissionCheckFunc func(ctx context.Context, cluster string, nsList []string, ns, name, action string) error
+
+var permissionCheckFunc PermissionCheckFunc
+
+// SetPermissionCheckFunc 设置预览使用的权限校验，与执行时 kom 回调使用同一校验逻辑
+func SetPermissionCheckFunc(fn PermissionCheckFunc) {
+	permissionCheckFunc = fn
+}
+
+// checkPreviewPermission 预览会读取资源内容，按执行时的权限校验用户对该资源的操作权限
+func checkPreviewPermission(ctx context.Context, clusterID, namespace, name, action string) error {
+	if permissionCheckFunc == nil {
+		return fmt.Errorf("未配置权限校验，无法预览")
+	}
+	var nsList []string
+	if namespace != "" {
+		nsList = []string{namespace}
+	}
+	return permissionCheckFunc(ctx, clusterID, nsList, namespace, name, action)
+}
+
+// previewToolCall 对支持的写操作进行 dry-run，返回变更差异；不支持预览的工具返回 nil
+func previewToolCall(ctx context.Context, toolName string, args map[string]any) *ToolPreview {
+	switch toolName {
+	case "patch_k8s_resource", "scale_k8s_deployment", "delete_k8s_resource", "delete_k8s_pod", "apply_k8s_yaml":
+	default:
+		return nil
+	}
+
+	clusterID := argString(args, "cluster")
+	kubectl := kom.Cluster(clusterID)
+	if clusterID == "" {
+		if inst := kom.Clusters().DefaultCluster(); inst != nil {
+			clusterID = inst.ID
+		}
+	}
+	preview := &ToolPreview{Cluster: clusterID}
+	if kubectl == nil {
+		preview.Error = "集群不存在或未连接，无法预览"
+		return preview
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var before, after []string
+	var err error
+	namespace, name := argString(args, "namespace"), argString(args, "name")
+	action := "patch"
+	if toolName == "delete_k8s_resource" || toolName == "delete_k8s_pod" {
+		action = "delete"
+	}
+	// apply 的权限按 YAML 中每个资源分别校验
+	if toolName != "apply_k8s_yaml" {
+		if err := checkPreviewPermission(ctx, clusterID, namespace, name, action); err != nil {
+			preview.Error = fmt.Sprintf("无权限，无法预览: %v", err)
+			return preview
+		}
+	}
+	switch toolName {
+	case "patch_k8s_resource":
+		gvk := schema.GroupVersionKind{Group: argString(args, "group"), Version: argString(args, "version"), Kind: argString(args, "kind")}
+		before, after, err = dryRunPatch(ctx, kubectl, gvk, namespace, name, types.StrategicMergePatchType, []byte(argString(args, "patch_data")))
+	case "scale_k8s_deployment":
+		gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+		patch := fmt.Sprintf(`{"spec":{"replicas":%d}}`, utils.ToInt(argString(args, "replicas")))
+		before, after, err = dryRunPatch(ctx, kubectl, gvk, namespace, name, types.MergePatchType, []byte(patch))
+	case "delete_k8s_resource":
+		gvk := schema.GroupVersionKind{Group: argString(args, "group"), Version: argString(args, "version"), Kind: argString(args, "kind")}
+		before, err = dryRunDelete(ctx, kubectl, gvk, namespace, name)
+	case "delete_k8s_pod":
+		before, err = dryRunDelete(ctx, kubectl, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, namespace, name)
+	case "apply_k8s_yaml":
+		before, after, err = dryRunApply(ctx, kubectl, clusterID, argString(args, "yaml"))
+	}
+	if err != nil {
+		preview.Error = fmt.Sprintf("dry-run 失败: %v", err)
+	}
+	preview.Before = strings.Join(before, "---\n")
+	preview.After = strings.Join(after, "---\n")
+	preview.Diff = utils.LineDiff(preview.Before, preview.After, 3)
+	return preview
+}
+
+// resourceInterface 根据 GVK 获取动态客户端，version 为空时按 kind 查找
+func resourceInterface(kubectl *kom.Kubectl, gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	var gvr schema.GroupVersionResource
+	var namespaced, ok bool
+	if gvk.Version != "" {
+		gvr, namespaced, ok = kubectl.Tools().GetGVRByGVK(gvk)
+	} else {
+		gvr, namespaced = kubectl.Tools().GetGVRByKind(gvk.Kind)
+		ok = gvr.Resource != ""
+	}
+	if !ok {
+		return nil, fmt.Errorf("未找到资源类型 %s", gvk.String())
+	}
+	if namespaced {
+		return kubectl.DynamicClient().Resource(gvr).Namespace(namespace), nil
+	}
+	return kubectl.DynamicClient().Resource(gvr), nil
+}
+
+func dryRunPatch(ctx context.Context, kubectl *kom.Kubectl, gvk schema.GroupVersionKind, namespace, name string, pt types.PatchType, data []byte) ([]string, []string, error) {
+	ri, err := resourceInterface(kubectl, gvk, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	patched, err := ri.Patch(ctx, name, pt, data, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return []string{previewYAML(current)}, nil, err
+	}
+	return []string{previewYAML(current)}, []string{previewYAML(patched)}, nil
+}
+
+func dryRunDelete(ctx context.Context, kubectl *kom.Kubectl, gvk schema.GroupVersionKind, namespace, name string) ([]string, error) {
+	ri, err := resourceInterface(kubectl, gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+	current, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	err = ri.Delete(ctx, name, metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}})
+	return []string{previewYAML(current)}, err
+}
+
+func dryRunApply(ctx context.Context, kubectl *kom.Kubectl, clusterID string, content string) ([]string, []string, error) {
+	var before, after []string
+	for _, doc := range strings.Split(content, "\n---") {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var obj unstructured.Unstructured
+		if err := yaml.Unmarshal([]byte(doc), &obj.Object); err != nil {
+			return before, after, fmt.Errorf("解析YAML失败: %v", err)
+		}
+		if obj.Object == nil {
+			continue
+		}
+		if err := checkPreviewPermission(ctx, clusterID, obj.GetNamespace(), obj.GetName(), "patch"); err != nil {
+			return before, after, fmt.Errorf("无权限: %w", err)
+		}
+		ri, err := resourceInterface(kubectl, obj.GroupVersionKind(), obj.GetNamespace())
+		if err != nil {
+			return before, after, err
+		}
+		current, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		switch {
+		case err == nil:
+			before = append(before, previewYAML(current))
+		case k8serrors.IsNotFound(err):
+			before = append(before, "")
+		default:
+			return before, after, err
+		}
+		applied, err := ri.Apply(ctx, obj.GetName(), &obj, metav1.ApplyOptions{FieldManager: "k8m", Force: true, DryRun: []string{metav1.DryRunAll}})
+		if err != nil {
+			return before, after, err
+		}
+		after = append(after, previewYAML(applied))
+	}
+	return before, after, nil
+}
+
+// previewYAML 转换为 YAML，去掉 managedFields 等与变更无关的字段，Secret 的数据以掩码替代
+func previewYAML(obj *unstructured.Unstructured) string {
+	if obj == nil {
+		return ""
+	}
+	obj = obj.DeepCopy()
+	if obj.GetKind() == "Secret" && obj.GroupVersionKind().Group == "" {
+		redactSecretData(obj)
+	}
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+	}
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// redactSecretData 将 Secret 的 data、stringData 值替换为掩码，仅保留键名
+// last-applied-configuration 中同样包含明文，在 previewYAML 中删除
+func redactSecretData(obj *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj.Object[field].(map[string]any)
+		if !ok {
+			continue
+		}
+		for k := range values {
+			values[k] = "******"
+		}
+	}
+}
+
+// argString 读取工具参数，数字等非字符串参数转换为字符串
+func argString(args map[string]any, key string) string {
+	switch v := args[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%v", v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
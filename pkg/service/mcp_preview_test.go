@@ -0,0 +1,46 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPreviewYAMLRedactsSecret(t *testing.T) {
+	secret := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name":      "db",
+			"namespace": "default",
+			"annotations": map[string]any{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"stringData":{"password":"plain-text"}}`,
+			},
+		},
+		"data":       map[string]any{"password": "c2VjcmV0LXZhbHVl"},
+		"stringData": map[string]any{"token": "plain-text"},
+	}}
+	out := previewYAML(secret)
+	for _, v := range []string{"c2VjcmV0LXZhbHVl", "plain-text"} {
+		if strings.Contains(out, v) {
+			t.Errorf("预览中包含 Secret 明文 %s:\n%s", v, out)
+		}
+	}
+	if !strings.Contains(out, "password: '******'") || !strings.Contains(out, "token: '******'") {
+		t.Errorf("预览中应保留键名:\n%s", out)
+	}
+	if secret.Object["data"].(map[string]any)["password"] != "c2VjcmV0LXZhbHVl" {
+		t.Error("不应修改原对象")
+	}
+
+	cm := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "cfg"},
+		"data":       map[string]any{"key": "value"},
+	}}
+	if out := previewYAML(cm); !strings.Contains(out, "key: value") {
+		t.Errorf("ConfigMap 不应脱敏:\n%s", out)
+	}
+}
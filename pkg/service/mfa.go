@@ -0,0 +1,559 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/comm/utils/totp"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+
+	// mfaPendingTTL 密码校验通过后完成2步验证的最长时间
+	mfaPendingTTL = 5 * time.Minute
+	// mfaMaxAttempts 同一次登录允许的2步验证失败次数
+	mfaMaxAttempts = 5
+	// webauthnRegisterTTL 发起绑定后完成绑定的最长时间
+	webauthnRegisterTTL = 5 * time.Minute
+)
+
+var ErrMFAPendingExpired = errors.New("2步验证已过期，请重新登录")
+
+// mfaService 等待完成的2步验证及 WebAuthn 绑定请求存储在数据库中，多实例部署时任一实例均可完成验证
+type mfaService struct{}
+
+// MFAPending 密码校验通过、等待2步验证的登录
+type MFAPending struct {
+	Username  string
+	LoginType string
+	challenge *models.MFAChallenge
+}
+
+// MFALoginResult 认证通过后按2步验证策略处理的结果
+// 已绑定2步验证时返回 MFAToken，否则创建会话并返回 Token
+type MFALoginResult struct {
+	MFAToken string     // 完成2步验证的临时Token
+	Methods  []string   // 已绑定的验证方式
+	Pair     *TokenPair // 登录会话的 Token
+	Status   *MFAStatus // 未绑定时的2步验证策略状态
+}
+
+// MFAStatus 用户的2步验证状态
+type MFAStatus struct {
+	Required bool       `json:"required"`           // 是否被策略要求启用2步验证
+	Methods  []string   `json:"methods"`            // 已绑定的验证方式
+	Deadline *time.Time `json:"deadline,omitempty"` // 未绑定时的宽限截止时间
+	Enforced bool       `json:"enforced"`           // 已超过宽限期，登录后仅允许绑定2步验证
+}
+
+// Methods 获取用户已绑定的2步验证方式
+func (s *mfaService) Methods(user *models.User) []string {
+	methods := []string{}
+	if user.TwoFAEnabled {
+		methods = append(methods, MFAMethodTOTP)
+	}
+	var count int64
+	dao.DB().Model(&models.WebAuthnCredential{}).Where("username = ?", user.Username).Count(&count)
+	if count > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+	return methods
+}
+
+// IsRequired 按平台配置的2步验证策略判断用户是否必须启用2步验证
+func (s *mfaService) IsRequired(username string) bool {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil {
+		return false
+	}
+	if cfg.MFAEnforcePlatformAdmin && UserService().IsUserPlatformAdmin(username) {
+		return true
+	}
+	if strings.TrimSpace(cfg.MFAEnforceClusters) == "" {
+		return false
+	}
+	roles, err := UserService().GetClusters(username)
+	if err != nil {
+		klog.Errorf("获取用户[%s]集群授权失败: %v", username, err)
+		return false
+	}
+	return requiresMFAOnClusters(cfg.MFAEnforceClusters, roles)
+}
+
+// Status 计算用户的2步验证状态，首次被策略要求时记录起始时间，宽限期由此起算
+func (s *mfaService) Status(user *models.User) (*MFAStatus, error) {
+	status := &MFAStatus{
+		Required: s.IsRequired(user.Username),
+		Methods:  s.Methods(user),
+	}
+	if !status.Required || len(status.Methods) > 0 {
+		if user.MFARequiredAt != nil {
+			user.MFARequiredAt = nil
+			err := dao.DB().Model(&models.User{}).Where("id = ?", user.ID).Update("mfa_required_at", nil).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+		return status, nil
+	}
+	if user.MFARequiredAt == nil {
+		now := time.Now()
+		user.MFARequiredAt = &now
+		if err := dao.DB().Model(&models.User{}).Where("id = ?", user.ID).Update("mfa_required_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	graceDays := 0
+	if cfg, err := ConfigService().GetConfig(); err == nil {
+		graceDays = cfg.MFAGraceDays
+	}
+	deadline := user.MFARequiredAt.AddDate(0, 0, max(graceDays, 0))
+	status.Deadline = &deadline
+	status.Enforced = !time.Now().Before(deadline)
+	return status, nil
+}
+
+// BeginLogin 认证通过后按2步验证策略继续登录，用户名密码、LDAP、SSO 登录共用
+// 已绑定2步验证时返回待验证Token；未绑定但被策略要求时，宽限期内正常登录，超过宽限期仅签发用于绑定2步验证的受限 Token
+func (s *mfaService) BeginLogin(c *gin.Context, user *models.User, loginType string) (*MFALoginResult, error) {
+	if methods := s.Methods(user); len(methods) > 0 {
+		token, err := s.StartPending(user.Username, loginType)
+		if err != nil {
+			return nil, fmt.Errorf("发起2步验证失败: %w", err)
+		}
+		return &MFALoginResult{MFAToken: token, Methods: methods}, nil
+	}
+	status, err := s.Status(user)
+	if err != nil {
+		return nil, fmt.Errorf("获取2步验证状态失败: %w", err)
+	}
+	login := SessionService().Login
+	if status.Enforced {
+		klog.V(2).Infof("用户[%s]超过2步验证宽限期，仅允许绑定2步验证", user.Username)
+		login = SessionService().LoginForMFAEnroll
+	}
+	pair, err := login(c, user.Username, loginType)
+	if err != nil {
+		return nil, err
+	}
+	return &MFALoginResult{Pair: pair, Status: status}, nil
+}
+
+// StartPending 密码校验通过后等待2步验证，返回用于完成验证的临时Token
+func (s *mfaService) StartPending(username, loginType string) (string, error) {
+	s.cleanup()
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	challenge := &models.MFAChallenge{
+		ChallengeKey: mfaLoginKey(token),
+		Username:     username,
+		LoginType:    loginType,
+		ExpiresAt:    time.Now().Add(mfaPendingTTL),
+	}
+	if err := dao.DB().Create(challenge).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyTOTP 使用验证器验证码完成2步验证，成功后临时Token失效
+// 验证码错误时同时返回待验证信息，便于调用方记录登录失败
+func (s *mfaService) VerifyTOTP(token, code string) (*MFAPending, error) {
+	p, err := s.getPending(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.getUser(p.Username)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFAEnabled || !totp.ValidateCode(user.TwoFASecret, code) {
+		return p, s.fail(p, "2FA验证码错误")
+	}
+	if err := s.consume(p.challenge); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// BeginWebAuthnLogin 生成 WebAuthn 验证请求
+func (s *mfaService) BeginWebAuthnLogin(c *gin.Context, token string) (*protocol.CredentialAssertion, error) {
+	p, err := s.getPending(token)
+	if err != nil {
+		return nil, err
+	}
+	wa, err := newWebAuthn(c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.loadWebAuthnUser(p.Username)
+	if err != nil {
+		return nil, err
+	}
+	assertion, session, err := wa.BeginLogin(user)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	err = dao.DB().Model(&models.MFAChallenge{}).Where("id = ?", p.challenge.ID).Update("session", string(data)).Error
+	if err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+// FinishWebAuthnLogin 校验认证器返回的签名，成功后临时Token失效
+// 签名校验失败时同时返回待验证信息，便于调用方记录登录失败
+func (s *mfaService) FinishWebAuthnLogin(c *gin.Context, token string) (*MFAPending, error) {
+	p, err := s.getPending(token)
+	if err != nil {
+		return nil, err
+	}
+	// 会话数据仅使用一次，避免同一签名被重放
+	result := dao.DB().Model(&models.MFAChallenge{}).Where("id = ? AND session = ?", p.challenge.ID, p.challenge.Session).Update("session", "")
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	var session webauthn.SessionData
+	if p.challenge.Session == "" || result.RowsAffected == 0 || json.Unmarshal([]byte(p.challenge.Session), &session) != nil {
+		return nil, errors.New("请先发起WebAuthn验证")
+	}
+	wa, err := newWebAuthn(c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.loadWebAuthnUser(p.Username)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := wa.FinishLogin(user, session, c.Request)
+	if err != nil {
+		klog.Errorf("用户[%s]WebAuthn验证失败: %v", p.Username, err)
+		return p, s.fail(p, "WebAuthn验证失败")
+	}
+	if credential.Authenticator.CloneWarning {
+		klog.Errorf("用户[%s]WebAuthn签名计数器异常，认证器可能被克隆", p.Username)
+		return p, s.fail(p, "WebAuthn验证失败，认证器签名计数异常")
+	}
+	if err := s.updateCredential(credential); err != nil {
+		klog.Errorf("更新用户[%s]WebAuthn凭据失败: %v", p.Username, err)
+	}
+	if err := s.consume(p.challenge); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// BeginWebAuthnRegistration 生成绑定 WebAuthn 凭据的请求，已绑定的凭据不允许重复绑定
+func (s *mfaService) BeginWebAuthnRegistration(c *gin.Context, username string) (*protocol.CredentialCreation, error) {
+	wa, err := newWebAuthn(c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.loadWebAuthnUser(username)
+	if err != nil {
+		return nil, err
+	}
+	creation, session, err := wa.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	s.cleanup()
+	// 同一用户仅保留最近一次绑定请求
+	key := mfaRegisterKey(username)
+	if err := dao.DB().Where("challenge_key = ?", key).Delete(&models.MFAChallenge{}).Error; err != nil {
+		return nil, err
+	}
+	challenge := &models.MFAChallenge{
+		ChallengeKey: key,
+		Username:     username,
+		Session:      string(data),
+		ExpiresAt:    time.Now().Add(webauthnRegisterTTL),
+	}
+	if err := dao.DB().Create(challenge).Error; err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+// FinishWebAuthnRegistration 校验认证器返回的凭据并保存
+func (s *mfaService) FinishWebAuthnRegistration(c *gin.Context, username, name string) error {
+	challenge := &models.MFAChallenge{}
+	err := dao.DB().Where("challenge_key = ?", mfaRegisterKey(username)).First(challenge).Error
+	var session webauthn.SessionData
+	if err != nil || s.consume(challenge) != nil || json.Unmarshal([]byte(challenge.Session), &session) != nil {
+		return errors.New("绑定请求不存在或已过期，请重新绑定")
+	}
+	wa, err := newWebAuthn(c)
+	if err != nil {
+		return err
+	}
+	user, err := s.loadWebAuthnUser(username)
+	if err != nil {
+		return err
+	}
+	credential, err := wa.FinishRegistration(user, session, c.Request)
+	if err != nil {
+		return fmt.Errorf("WebAuthn绑定失败: %w", err)
+	}
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	item := &models.WebAuthnCredential{
+		Username:     username,
+		Name:         credentialName(name, credential),
+		CredentialID: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(data),
+		SignCount:    credential.Authenticator.SignCount,
+	}
+	if err := dao.DB().Create(item).Error; err != nil {
+		return err
+	}
+	klog.V(2).Infof("用户[%s]绑定WebAuthn凭据[%s]", username, item.Name)
+	return nil
+}
+
+// ListWebAuthn 获取用户绑定的 WebAuthn 凭据
+func (s *mfaService) ListWebAuthn(username string) ([]*models.WebAuthnCredential, error) {
+	var items []*models.WebAuthnCredential
+	err := dao.DB().Where("username = ?", username).Order("id asc").Find(&items).Error
+	return items, err
+}
+
+// DeleteWebAuthn 删除用户绑定的 WebAuthn 凭据
+func (s *mfaService) DeleteWebAuthn(username string, id uint) error {
+	result := dao.DB().Where("id = ? AND username = ?", id, username).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("凭据不存在")
+	}
+	return nil
+}
+
+// CheckRemovable 被策略要求启用2步验证时，至少保留一种验证方式
+func (s *mfaService) CheckRemovable(username, method string) error {
+	if !s.IsRequired(username) {
+		return nil
+	}
+	user, err := s.getUser(username)
+	if err != nil {
+		return err
+	}
+	var remaining int64
+	dao.DB().Model(&models.WebAuthnCredential{}).Where("username = ?", username).Count(&remaining)
+	if method == MFAMethodWebAuthn {
+		remaining--
+	}
+	if user.TwoFAEnabled && method != MFAMethodTOTP {
+		remaining++
+	}
+	if remaining <= 0 {
+		return errors.New("2步验证策略要求至少保留一种验证方式")
+	}
+	return nil
+}
+
+func (s *mfaService) getPending(token string) (*MFAPending, error) {
+	if token == "" {
+		return nil, ErrMFAPendingExpired
+	}
+	challenge := &models.MFAChallenge{}
+	if err := dao.DB().Where("challenge_key = ?", mfaLoginKey(token)).First(challenge).Error; err != nil {
+		return nil, ErrMFAPendingExpired
+	}
+	if challenge.ExpiresAt.Before(time.Now()) {
+		dao.DB().Delete(&models.MFAChallenge{}, challenge.ID)
+		return nil, ErrMFAPendingExpired
+	}
+	return &MFAPending{Username: challenge.Username, LoginType: challenge.LoginType, challenge: challenge}, nil
+}
+
+// consume 删除已完成的验证请求，并发完成同一请求时仅一次成功，过期的请求视为不存在
+func (s *mfaService) consume(challenge *models.MFAChallenge) error {
+	result := dao.DB().Where("id = ?", challenge.ID).Delete(&models.MFAChallenge{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 || challenge.ExpiresAt.Before(time.Now()) {
+		return ErrMFAPendingExpired
+	}
+	return nil
+}
+
+// fail 记录一次验证失败，超过次数后需重新输入密码
+func (s *mfaService) fail(p *MFAPending, msg string) error {
+	err := dao.DB().Model(&models.MFAChallenge{}).Where("id = ?", p.challenge.ID).
+		UpdateColumn("attempts", gorm.Expr("attempts + ?", 1)).Error
+	if err != nil {
+		return err
+	}
+	challenge := &models.MFAChallenge{}
+	if err := dao.DB().Select("attempts").Where("id = ?", p.challenge.ID).First(challenge).Error; err != nil || challenge.Attempts >= mfaMaxAttempts {
+		dao.DB().Delete(&models.MFAChallenge{}, p.challenge.ID)
+		return fmt.Errorf("%s，失败次数过多，请重新登录", msg)
+	}
+	return errors.New(msg)
+}
+
+func (s *mfaService) cleanup() {
+	err := dao.DB().Where("expires_at < ?", time.Now()).Delete(&models.MFAChallenge{}).Error
+	if err != nil {
+		klog.V(6).Infof("清理过期的2步验证请求失败: %v", err)
+	}
+}
+
+func mfaLoginKey(token string) string {
+	return "login:" + tokenHash(token)
+}
+
+func mfaRegisterKey(username string) string {
+	return "register:" + username
+}
+
+func (s *mfaService) getUser(username string) (*models.User, error) {
+	user := &models.User{}
+	if err := dao.DB().Where("username = ?", username).First(user).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在")
+	}
+	return user, nil
+}
+
+func (s *mfaService) loadWebAuthnUser(username string) (*webauthnUser, error) {
+	user, err := s.getUser(username)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.ListWebAuthn(username)
+	if err != nil {
+		return nil, err
+	}
+	result := &webauthnUser{user: user}
+	for _, item := range items {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(item.Credential), &credential); err != nil {
+			klog.Errorf("解析用户[%s]WebAuthn凭据[%s]失败: %v", username, item.Name, err)
+			continue
+		}
+		result.credentials = append(result.credentials, credential)
+	}
+	return result, nil
+}
+
+func (s *mfaService) updateCredential(credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	return dao.DB().Model(&models.WebAuthnCredential{}).
+		Where("credential_id = ?", base64.RawURLEncoding.EncodeToString(credential.ID)).
+		UpdateColumns(map[string]any{
+			"credential":   string(data),
+			"sign_count":   credential.Authenticator.SignCount,
+			"last_used_at": time.Now(),
+		}).Error
+}
+
+// webauthnUser 实现 webauthn.User，用户句柄使用用户ID
+type webauthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatUint(uint64(u.user.ID), 10))
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// newWebAuthn 按访问地址构建 WebAuthn 依赖方，RP ID 为访问域名
+// 经反向代理访问时，允许与访问域名一致的页面来源
+func newWebAuthn(c *gin.Context) (*webauthn.WebAuthn, error) {
+	host := c.Request.Host
+	rpID := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		rpID = h
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	origins := []string{scheme + "://" + host}
+	if origin := c.GetHeader("Origin"); origin != "" && !slices.Contains(origins, origin) {
+		if u, err := url.Parse(origin); err == nil && u.Hostname() == rpID {
+			origins = append(origins, origin)
+		}
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: "k8m",
+		RPOrigins:     origins,
+	})
+}
+
+// requiresMFAOnClusters 判断用户在生产集群上是否拥有集群管理员或Exec权限
+func requiresMFAOnClusters(enforceClusters string, roles []*models.ClusterUserRole) bool {
+	clusters := utils.SplitAndTrim(enforceClusters, ",")
+	all := slices.Contains(clusters, "*")
+	for _, role := range roles {
+		if role.Role != constants.RoleClusterAdmin && role.Role != constants.RoleClusterPodExec {
+			continue
+		}
+		if all || slices.Contains(clusters, role.Cluster) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialName 未指定名称时使用凭据ID前缀
+func credentialName(name string, credential *webauthn.Credential) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	id := base64.RawURLEncoding.EncodeToString(credential.ID)
+	return "通行密钥-" + id[:min(len(id), 8)]
+}
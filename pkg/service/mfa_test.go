@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestRequiresMFAOnClusters(t *testing.T) {
+	roles := []*models.ClusterUserRole{
+		{Cluster: "test", Role: constants.RoleClusterAdmin},
+		{Cluster: "prod", Role: constants.RoleClusterReadonly},
+		{Cluster: "prod-b", Role: constants.RoleClusterPodExec},
+	}
+	tests := []struct {
+		name     string
+		clusters string
+		want     bool
+	}{
+		{"未配置生产集群", "", false},
+		{"生产集群上仅有只读权限", "prod", false},
+		{"生产集群上有Exec权限", "prod, prod-b", true},
+		{"全部集群", "*", true},
+		{"集群名需完整匹配", "prod-", false},
+	}
+	for _, tt := range tests {
+		if got := requiresMFAOnClusters(tt.clusters, roles); got != tt.want {
+			t.Errorf("%s: 期望 %v，实际 %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -19,12 +19,13 @@ type ipUsage struct {
 	Available int `json:"available"`
 }
 
-func (n *nodeService) getTTL() time.Duration {
+// getTTL 资源用量缓存时间，开启模拟用户的集群不缓存
+func (n *nodeService) getTTL(selectedCluster string) time.Duration {
 	cfg := flag.Init()
 	if cfg.ResourceCacheTimeout > 0 {
-		return time.Duration(cfg.ResourceCacheTimeout) * time.Second
+		return ClusterService().CacheTTL(selectedCluster, time.Duration(cfg.ResourceCacheTimeout)*time.Second)
 	}
-	return 1 * time.Minute
+	return ClusterService().CacheTTL(selectedCluster, 1*time.Minute)
 }
 
 func (n *nodeService) SetIPUsage(selectedCluster string, item *unstructured.Unstructured) *unstructured.Unstructured {
@@ -95,7 +96,7 @@ func (n *nodeService) SyncNodeStatus(selectedCluster string) {
 	klog.V(6).Infof("Sync Node Status")
 	ctx := utils2.GetContextWithAdmin()
 	var nodes []v1.Node
-	err := kom.Cluster(selectedCluster).WithContext(ctx).Resource(&v1.Node{}).WithCache(n.getTTL()).List(&nodes).Error
+	err := kom.Cluster(selectedCluster).WithContext(ctx).Resource(&v1.Node{}).WithCache(n.getTTL(selectedCluster)).List(&nodes).Error
 	if err != nil {
 		klog.Errorf("监听Node失败:%v", err)
 	}
@@ -110,8 +111,8 @@ func (n *nodeService) SyncNodeStatus(selectedCluster string) {
 func (n *nodeService) CacheIPUsage(selectedCluster string, nodeName string) (ipUsage, error) {
 	cacheKey := fmt.Sprintf("%s/%s", "NodeIPUsage", nodeName)
 	ctx := utils2.GetContextWithAdmin()
-	return utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, n.getTTL(), func() (ipUsage, error) {
-		total, used, available := kom.Cluster(selectedCluster).WithContext(ctx).Name(nodeName).WithCache(n.getTTL()).Ctl().Node().IPUsage()
+	return utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, n.getTTL(selectedCluster), func() (ipUsage, error) {
+		total, used, available := kom.Cluster(selectedCluster).WithContext(ctx).Name(nodeName).WithCache(n.getTTL(selectedCluster)).Ctl().Node().IPUsage()
 		return ipUsage{
 			Total:     total,
 			Used:      used,
@@ -122,8 +123,8 @@ func (n *nodeService) CacheIPUsage(selectedCluster string, nodeName string) (ipU
 func (n *nodeService) CachePodCount(selectedCluster string, nodeName string) (ipUsage, error) {
 	cacheKey := fmt.Sprintf("%s/%s", "NodePodCount", nodeName)
 	ctx := utils2.GetContextWithAdmin()
-	return utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, n.getTTL(), func() (ipUsage, error) {
-		total, used, available := kom.Cluster(selectedCluster).WithContext(ctx).Name(nodeName).WithCache(n.getTTL()).Ctl().Node().PodCount()
+	return utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, n.getTTL(selectedCluster), func() (ipUsage, error) {
+		total, used, available := kom.Cluster(selectedCluster).WithContext(ctx).Name(nodeName).WithCache(n.getTTL(selectedCluster)).Ctl().Node().PodCount()
 		return ipUsage{
 			Total:     total,
 			Used:      used,
@@ -135,8 +136,8 @@ func (n *nodeService) CachePodCount(selectedCluster string, nodeName string) (ip
 func (n *nodeService) CacheAllocatedStatus(selectedCluster string, nodeName string) ([]*kom.ResourceUsageRow, error) {
 	cacheKey := fmt.Sprintf("%s/%s", "NodeAllocatedStatus", nodeName)
 	ctx := utils2.GetContextWithAdmin()
-	return utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, n.getTTL(), func() ([]*kom.ResourceUsageRow, error) {
-		tb, err := kom.Cluster(selectedCluster).WithContext(ctx).Name(nodeName).WithCache(n.getTTL()).Resource(&v1.Node{}).Ctl().Node().ResourceUsageTable()
+	return utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, n.getTTL(selectedCluster), func() ([]*kom.ResourceUsageRow, error) {
+		tb, err := kom.Cluster(selectedCluster).WithContext(ctx).Name(nodeName).WithCache(n.getTTL(selectedCluster)).Resource(&v1.Node{}).Ctl().Node().ResourceUsageTable()
 		return tb, err
 	})
 }
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/models"
+	"golang.org/x/crypto/argon2"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// argon2id 参数，参考 OWASP 推荐的最低配置
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+
+	// passwordChangeTTL 登录时要求修改密码后完成修改的最长时间
+	passwordChangeTTL = 10 * time.Minute
+)
+
+var ErrPasswordChangeExpired = errors.New("修改密码已超时，请重新登录")
+
+type passwordService struct{}
+
+// PasswordChangePending 密码校验通过、等待修改密码的登录
+type PasswordChangePending struct {
+	Username  string
+	LoginType string
+}
+
+// Hash 使用 argon2id 计算密码摘要，返回 PHC 格式字符串
+func (s *passwordService) Hash(plain string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify 校验密码，needsRehash 为 true 表示存储的是旧的加密格式，应在登录成功后重新计算
+func (s *passwordService) Verify(hash, salt, plain string) (ok bool, needsRehash bool) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return verifyArgon2(hash, plain), false
+	}
+	// 旧格式：明文加盐后 AES 加密再 base64
+	stored, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil || hash == "" {
+		return false, false
+	}
+	computed, err := utils.AesEncrypt([]byte(plain + salt))
+	if err != nil {
+		return false, false
+	}
+	ok = subtle.ConstantTimeCompare(stored, computed) == 1
+	return ok, ok
+}
+
+// Rehash 登录成功后将旧格式的密码改为 argon2id 存储，不影响密码修改时间
+func (s *passwordService) Rehash(username, plain string) {
+	hash, err := s.Hash(plain)
+	if err != nil {
+		klog.Errorf("计算用户[%s]密码摘要失败: %v", username, err)
+		return
+	}
+	err = dao.DB().Model(&models.User{}).Where("username = ?", username).
+		Updates(map[string]any{"password": hash, "salt": ""}).Error
+	if err != nil {
+		klog.Errorf("更新用户[%s]密码存储格式失败: %v", username, err)
+		return
+	}
+	klog.V(2).Infof("用户[%s]密码已转换为 argon2id 存储", username)
+}
+
+// Validate 按密码策略检查密码复杂度
+func (s *passwordService) Validate(username, plain string) error {
+	cfg := s.config()
+	return validatePassword(username, plain, cfg.PasswordMinLength, cfg.PasswordMinClasses)
+}
+
+// Set 设置新密码，mustChange 为 true 时用户下次登录须先修改密码
+// checkHistory 为 true 时不允许使用当前密码及最近使用过的密码
+func (s *passwordService) Set(username, plain string, mustChange, checkHistory bool) error {
+	if err := s.Validate(username, plain); err != nil {
+		return err
+	}
+	user := &models.User{}
+	if err := dao.DB().Select("id", "username", "password", "salt").Where("username = ?", username).First(user).Error; err != nil {
+		return fmt.Errorf("用户不存在")
+	}
+	cfg := s.config()
+	if checkHistory && cfg.PasswordHistoryCount > 0 && s.reused(user, plain, cfg.PasswordHistoryCount) {
+		return fmt.Errorf("不能使用最近 %d 次使用过的密码", cfg.PasswordHistoryCount)
+	}
+	hash, err := s.Hash(plain)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return dao.DB().Transaction(func(tx *gorm.DB) error {
+		if user.Password != "" {
+			history := &models.PasswordHistory{Username: username, Password: user.Password, Salt: user.Salt}
+			if err := tx.Create(history).Error; err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&models.User{}).Where("username = ?", username).Updates(map[string]any{
+			"password":             hash,
+			"salt":                 "",
+			"password_changed_at":  now,
+			"password_must_change": mustChange,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return s.trimHistory(tx, username, cfg.PasswordHistoryCount)
+	})
+}
+
+// MustChange 是否须先修改密码才能登录：管理员重置了密码或密码已过期
+func (s *passwordService) MustChange(user *models.User) (bool, string) {
+	if user.PasswordMustChange {
+		return true, "密码已被管理员重置，请修改密码后登录"
+	}
+	cfg := s.config()
+	if cfg.PasswordExpireDays <= 0 {
+		return false, ""
+	}
+	if user.PasswordChangedAt == nil {
+		// 启用有效期前设置的密码，从现在开始计算
+		now := time.Now()
+		user.PasswordChangedAt = &now
+		err := dao.DB().Model(&models.User{}).Where("username = ?", user.Username).Update("password_changed_at", now).Error
+		if err != nil {
+			klog.Errorf("初始化用户[%s]密码修改时间失败: %v", user.Username, err)
+		}
+		return false, ""
+	}
+	if !time.Now().Before(user.PasswordChangedAt.AddDate(0, 0, cfg.PasswordExpireDays)) {
+		return true, "密码已过期，请修改密码后登录"
+	}
+	return false, ""
+}
+
+// ExpiresSoon 密码即将过期时返回过期时间，否则返回 nil，仅用于平台内用户名密码登录
+func (s *passwordService) ExpiresSoon(username string) *time.Time {
+	cfg := s.config()
+	if cfg.PasswordExpireDays <= 0 {
+		return nil
+	}
+	user := &models.User{}
+	if err := dao.DB().Select("id", "password_changed_at").Where("username = ?", username).First(user).Error; err != nil {
+		return nil
+	}
+	if user.PasswordChangedAt == nil {
+		return nil
+	}
+	expiresAt := user.PasswordChangedAt.AddDate(0, 0, cfg.PasswordExpireDays)
+	if time.Now().Before(expiresAt.AddDate(0, 0, -max(cfg.PasswordExpireWarnDays, 0))) {
+		return nil
+	}
+	return &expiresAt
+}
+
+// StartChange 密码校验通过但须修改密码，返回用于修改密码的临时Token
+// Token 仅保存摘要，存储在数据库中，多实例部署时任一实例均可完成修改
+func (s *passwordService) StartChange(username, loginType string) (string, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	challenge := &models.AuthChallenge{
+		ChallengeKey: passwordChangeKey(token),
+		Username:     username,
+		LoginType:    loginType,
+	}
+	if err := AuthChallengeService().Create(challenge, passwordChangeTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// FinishChange 使用临时Token修改密码，成功后Token失效
+// 先消费Token，并发请求只有一个能继续；新密码不符合要求时恢复Token，在原有效期内可重试
+func (s *passwordService) FinishChange(token, plain string) (*PasswordChangePending, error) {
+	if token == "" {
+		return nil, ErrPasswordChangeExpired
+	}
+	challenge, err := AuthChallengeService().Consume(passwordChangeKey(token))
+	if err != nil {
+		if errors.Is(err, ErrAuthChallengeInvalid) {
+			return nil, ErrPasswordChangeExpired
+		}
+		return nil, err
+	}
+	if err := s.Set(challenge.Username, plain, false, true); err != nil {
+		retry := &models.AuthChallenge{
+			ChallengeKey: challenge.ChallengeKey,
+			Username:     challenge.Username,
+			LoginType:    challenge.LoginType,
+		}
+		if e := AuthChallengeService().Create(retry, time.Until(challenge.ExpiresAt)); e != nil {
+			klog.Errorf("恢复用户[%s]修改密码Token失败: %v", challenge.Username, e)
+		}
+		return nil, err
+	}
+	return &PasswordChangePending{Username: challenge.Username, LoginType: challenge.LoginType}, nil
+}
+
+// reused 新密码是否与当前密码或最近的历史密码相同
+func (s *passwordService) reused(user *models.User, plain string, count int) bool {
+	if ok, _ := s.Verify(user.Password, user.Salt, plain); ok {
+		return true
+	}
+	var history []*models.PasswordHistory
+	// 当前密码也计入次数
+	err := dao.DB().Where("username = ?", user.Username).Order("id desc").Limit(max(count-1, 0)).Find(&history).Error
+	if err != nil {
+		klog.Errorf("查询用户[%s]密码历史失败: %v", user.Username, err)
+		return false
+	}
+	for _, h := range history {
+		if ok, _ := s.Verify(h.Password, h.Salt, plain); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// trimHistory 只保留最近使用过的密码
+func (s *passwordService) trimHistory(tx *gorm.DB, username string, count int) error {
+	var ids []uint
+	err := tx.Model(&models.PasswordHistory{}).Where("username = ?", username).Order("id desc").Pluck("id", &ids).Error
+	// 当前密码也计入次数
+	keep := max(count-1, 0)
+	if err != nil || len(ids) <= keep {
+		return err
+	}
+	return tx.Where("id in ?", ids[keep:]).Delete(&models.PasswordHistory{}).Error
+}
+
+func (s *passwordService) config() *models.Config {
+	cfg, err := ConfigService().GetConfig()
+	if err != nil {
+		return &models.Config{PasswordMinLength: 8, PasswordMinClasses: 3, PasswordHistoryCount: 5, PasswordExpireWarnDays: 7}
+	}
+	return cfg
+}
+
+// passwordChangeKey 修改密码临时Token对应的认证请求标识，只保存摘要
+func passwordChangeKey(token string) string {
+	return "password:" + tokenHash(token)
+}
+
+// verifyArgon2 按摘要中记录的参数重新计算并比较
+func verifyArgon2(hash, plain string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	computed := argon2.IDKey([]byte(plain), salt, iterations, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, computed) == 1
+}
+
+// validatePassword 检查长度、字符种类，且不能包含用户名
+func validatePassword(username, plain string, minLength, minClasses int) error {
+	if len([]rune(plain)) < minLength {
+		return fmt.Errorf("密码长度不能少于 %d 位", minLength)
+	}
+	var upper, lower, digit, special bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			special = true
+		}
+	}
+	classes := 0
+	for _, b := range []bool{upper, lower, digit, special} {
+		if b {
+			classes++
+		}
+	}
+	if classes < min(minClasses, 4) {
+		return fmt.Errorf("密码须至少包含大写字母、小写字母、数字、特殊字符中的 %d 类", min(minClasses, 4))
+	}
+	if username != "" && strings.Contains(strings.ToLower(plain), strings.ToLower(username)) {
+		return errors.New("密码不能包含用户名")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		ok       bool
+	}{
+		{"符合策略", "alice", "Str0ng-pass", true},
+		{"长度不足", "alice", "Ab1-", false},
+		{"字符种类不足", "alice", "abcdefgh12", false},
+		{"三类字符", "alice", "Abcdefgh12", true},
+		{"包含用户名", "alice", "Alice-2024x", false},
+		{"中文按字符计算长度", "bob", "密码Ab1密码密码", true},
+	}
+	for _, tt := range tests {
+		err := validatePassword(tt.username, tt.password, 8, 3)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: 期望通过 %v，实际错误 %v", tt.name, tt.ok, err)
+		}
+	}
+}
+
+func TestPasswordHashVerify(t *testing.T) {
+	s := &passwordService{}
+	hash, err := s.Hash("Str0ng-pass")
+	if err != nil {
+		t.Fatalf("计算密码摘要失败: %v", err)
+	}
+	tests := []struct {
+		name        string
+		hash        string
+		salt        string
+		password    string
+		ok          bool
+		needsRehash bool
+	}{
+		{"argon2id 正确密码", hash, "", "Str0ng-pass", true, false},
+		{"argon2id 错误密码", hash, "", "Str0ng-pasS", false, false},
+		// 内置 k8m 用户的旧格式密码
+		{"旧格式正确密码", "8RGCXWw6IzgKDPyeFKt6Kw==", "grfi92rq", "k8m", true, true},
+		{"旧格式错误密码", "8RGCXWw6IzgKDPyeFKt6Kw==", "grfi92rq", "k8m1", false, false},
+		{"摘要格式错误", "$argon2id$v=19$m=19456", "", "k8m", false, false},
+		{"空密码", "", "", "", false, false},
+	}
+	for _, tt := range tests {
+		ok, needsRehash := s.Verify(tt.hash, tt.salt, tt.password)
+		if ok != tt.ok || needsRehash != tt.needsRehash {
+			t.Errorf("%s: 期望 (%v, %v)，实际 (%v, %v)", tt.name, tt.ok, tt.needsRehash, ok, needsRehash)
+		}
+	}
+}
+
+func TestPasswordFinishChange(t *testing.T) {
+	s := PasswordService()
+	username := fmt.Sprintf("psw-change-test-%d", time.Now().UnixNano())
+	token, err := s.StartChange(username, LoginTypePassword)
+	if err != nil {
+		t.Fatalf("发起修改密码失败: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = AuthChallengeService().Consume(passwordChangeKey(token))
+	})
+
+	// 修改失败时Token恢复，有效期内可重试
+	for i := 0; i < 2; i++ {
+		if _, err := s.FinishChange(token, "Str0ng-pass"); err == nil || errors.Is(err, ErrPasswordChangeExpired) {
+			t.Fatalf("第 %d 次修改不存在的用户应返回修改失败，实际 %v", i+1, err)
+		}
+	}
+	if _, err := s.FinishChange("invalid", "Str0ng-pass"); !errors.Is(err, ErrPasswordChangeExpired) {
+		t.Errorf("无效Token应返回已超时，实际 %v", err)
+	}
+}
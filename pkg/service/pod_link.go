@@ -19,7 +19,7 @@ func (p *podService) LinksServices(ctx context.Context, selectedCluster string,
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedService()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedService()
 	return services, err
 }
 
@@ -28,7 +28,7 @@ func (p *podService) LinksEndpoints(ctx context.Context, selectedCluster string,
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedEndpoints()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedEndpoints()
 }
 
 func (p *podService) LinksPVC(ctx context.Context, selectedCluster string, item *v1.Pod) ([]*v1.PersistentVolumeClaim, error) {
@@ -36,7 +36,7 @@ func (p *podService) LinksPVC(ctx context.Context, selectedCluster string, item
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedPVC()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedPVC()
 }
 
 func (p *podService) LinksPV(ctx context.Context, selectedCluster string, item *v1.Pod) ([]*v1.PersistentVolume, error) {
@@ -44,7 +44,7 @@ func (p *podService) LinksPV(ctx context.Context, selectedCluster string, item *
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedPV()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedPV()
 }
 
 func (p *podService) LinksIngress(ctx context.Context, selectedCluster string, item *v1.Pod) ([]*networkingv1.Ingress, error) {
@@ -52,7 +52,7 @@ func (p *podService) LinksIngress(ctx context.Context, selectedCluster string, i
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedIngress()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedIngress()
 }
 
 func (p *podService) LinksEnv(ctx context.Context, selectedCluster string, item *v1.Pod) ([]*kom.Env, error) {
@@ -60,7 +60,7 @@ func (p *podService) LinksEnv(ctx context.Context, selectedCluster string, item
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedEnv()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedEnv()
 	if err != nil {
 		// error executing command: Internal error occurred: Internal error occurred: error executing command in container: failed to exec in container: failed to start exec \"915a4933acbb460d0b1859831d8f392dc96ca1f91447a94dbc41962900b91281\": OCI runtime exec failed: exec failed: unable to start container process: exec: \"env\": executable file not found in $PATH: unknown
 		// 提取executable file not found in $PATH
@@ -79,7 +79,7 @@ func (p *podService) LinksEnvFromPod(ctx context.Context, selectedCluster string
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedEnvFromPod()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedEnvFromPod()
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +91,7 @@ func (p *podService) LinksConfigMap(ctx context.Context, selectedCluster string,
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedConfigMap()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedConfigMap()
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +103,7 @@ func (p *podService) LinksSecret(ctx context.Context, selectedCluster string, it
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedSecret()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedSecret()
 	if err != nil {
 		return nil, err
 	}
@@ -115,5 +115,5 @@ func (p *podService) LinksNode(ctx context.Context, selectedCluster string, item
 		Resource(&v1.Pod{}).
 		Namespace(item.Namespace).
 		Name(item.Name).
-		WithCache(linkCacheTTL).Ctl().Pod().LinkedNode()
+		WithCache(ClusterService().CacheTTL(selectedCluster, linkCacheTTL)).Ctl().Pod().LinkedNode()
 }
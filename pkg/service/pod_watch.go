@@ -30,12 +30,13 @@ type StatusCount struct {
 	MemoryRealtime float64 // 内存实时量
 }
 
-func (p *podService) getTTL() time.Duration {
+// getTTL 资源用量缓存时间，开启模拟用户的集群不缓存
+func (p *podService) getTTL(selectedCluster string) time.Duration {
 	cfg := flag.Init()
 	if cfg.ResourceCacheTimeout > 0 {
-		return time.Duration(cfg.ResourceCacheTimeout) * time.Second
+		return ClusterService().CacheTTL(selectedCluster, time.Duration(cfg.ResourceCacheTimeout)*time.Second)
 	}
-	return 1 * time.Minute
+	return ClusterService().CacheTTL(selectedCluster, 1*time.Minute)
 }
 func (p *podService) IncreasePodCount(selectedCluster string, pod *corev1.Pod) {
 	// 从CountList中看是否有该集群、该namespace的项，有则加1，无则创建为1
@@ -48,7 +49,7 @@ func (p *podService) IncreasePodCount(selectedCluster string, pod *corev1.Pod) {
 	})
 	ctx := utils2.GetContextWithAdmin()
 	cacheKey := fmt.Sprintf("%s/%s/%s/%s", "PodResourceUsage", pod.Namespace, pod.Name, pod.ResourceVersion)
-	table, err := utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(), func() (*kom.ResourceUsageResult, error) {
+	table, err := utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(selectedCluster), func() (*kom.ResourceUsageResult, error) {
 		tb, err := kom.Cluster(selectedCluster).WithContext(ctx).Name(pod.Name).Namespace(pod.Namespace).Resource(&v1.Pod{}).Ctl().Pod().ResourceUsage(kom.DenominatorLimit)
 		return tb, err
 	})
@@ -142,7 +143,7 @@ func (p *podService) ReducePodCount(selectedCluster string, pod *corev1.Pod) {
 	if len(h) == 1 {
 		ctx := utils2.GetContextWithAdmin()
 		cacheKey := fmt.Sprintf("%s/%s/%s/%s", "PodResourceUsage", pod.Namespace, pod.Name, pod.ResourceVersion)
-		table, err := utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(), func() (*kom.ResourceUsageResult, error) {
+		table, err := utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(selectedCluster), func() (*kom.ResourceUsageResult, error) {
 			tb, err := kom.Cluster(selectedCluster).WithContext(ctx).Name(pod.Name).Namespace(pod.Namespace).Resource(&v1.Pod{}).Ctl().Pod().ResourceUsage(kom.DenominatorLimit)
 			return tb, err
 		})
@@ -194,7 +195,7 @@ func (p *podService) SetAllocatedStatusOnPod(selectedCluster string, item *unstr
 	ns := item.GetNamespace()
 	ctx := utils2.GetContextWithAdmin()
 	cacheKey := fmt.Sprintf("%s/%s/%s/%s", "PodAllocatedStatus", ns, podName, version)
-	table, err := utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(), func() ([]*kom.ResourceUsageRow, error) {
+	table, err := utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(selectedCluster), func() ([]*kom.ResourceUsageRow, error) {
 		tb, err := kom.Cluster(selectedCluster).WithContext(ctx).Name(podName).Namespace(ns).Resource(&v1.Pod{}).Ctl().Pod().ResourceUsageTable(kom.DenominatorLimit)
 		return tb, err
 	})
@@ -264,7 +265,7 @@ func (p *podService) CacheAllocatedStatus(selectedCluster string, item *v1.Pod)
 	ns := item.GetNamespace()
 	cacheKey := p.CacheKey(item)
 	ctx := utils2.GetContextWithAdmin()
-	_, _ = utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(), func() ([]*kom.ResourceUsageRow, error) {
+	_, _ = utils.GetOrSetCache(kom.Cluster(selectedCluster).ClusterCache(), cacheKey, p.getTTL(selectedCluster), func() ([]*kom.ResourceUsageRow, error) {
 		tb, err := kom.Cluster(selectedCluster).WithContext(ctx).Name(podName).Namespace(ns).Resource(&v1.Pod{}).Ctl().Pod().ResourceUsageTable(kom.DenominatorLimit)
 		return tb, err
 	})
@@ -0,0 +1,818 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	ScimSchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimSchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimSchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimSchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimSchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	// ScimUserSource 通过 SCIM 创建的用户的来源标记
+	ScimUserSource = "scim"
+	// ScimTokenPrefix SCIM Token 前缀，便于识别及泄露扫描
+	ScimTokenPrefix = "k8m_scim_"
+	// ScimMaxResults 单次查询返回的最大条数
+	ScimMaxResults = 1000
+)
+
+type scimService struct {
+}
+
+// ScimError SCIM 协议错误，按 RFC 7644 返回状态码及 scimType
+type ScimError struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *ScimError) Error() string {
+	return e.Detail
+}
+
+func scimNotFound(resource, id string) error {
+	return &ScimError{Status: http.StatusNotFound, Detail: fmt.Sprintf("%s %s 不存在", resource, id)}
+}
+
+func scimBadRequest(scimType, format string, args ...any) error {
+	return &ScimError{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+type ScimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+type ScimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// ScimUser SCIM 用户资源，对应 models.User，groups 只读，由 Group 的 members 维护
+type ScimUser struct {
+	Schemas    []string     `json:"schemas"`
+	ID         string       `json:"id,omitempty"`
+	ExternalID string       `json:"externalId,omitempty"`
+	UserName   string       `json:"userName"`
+	Active     *bool        `json:"active,omitempty"`
+	Groups     []ScimMember `json:"groups,omitempty"`
+	Meta       *ScimMeta    `json:"meta,omitempty"`
+}
+
+// ScimGroup SCIM 用户组资源，对应 models.UserGroup，members 为用户组名出现在 GroupNames 中的用户
+type ScimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []ScimMember `json:"members"`
+	Meta        *ScimMeta    `json:"meta,omitempty"`
+}
+
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations"`
+}
+
+type ScimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// CreateToken 创建 SCIM Token，明文仅返回这一次
+func (s *scimService) CreateToken(name, createdBy string, expiresAt *time.Time) (string, error) {
+	secret, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	token := ScimTokenPrefix + secret
+	item := &models.ScimToken{
+		Name:        name,
+		TokenHash:   tokenHash(token),
+		TokenPrefix: token[:len(ScimTokenPrefix)+4],
+		ExpiresAt:   expiresAt,
+		CreatedBy:   createdBy,
+	}
+	if err := dao.DB().Create(item).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Authenticate 校验 SCIM Token 并记录使用情况
+func (s *scimService) Authenticate(token, clientIP string) error {
+	if !strings.HasPrefix(token, ScimTokenPrefix) {
+		return errors.New("无效的SCIM Token")
+	}
+	item := &models.ScimToken{}
+	if err := dao.DB().Where("token_hash = ?", tokenHash(token)).First(item).Error; err != nil {
+		return errors.New("无效的SCIM Token")
+	}
+	if item.ExpiresAt != nil && item.ExpiresAt.Before(time.Now()) {
+		return errors.New("SCIM Token 已过期")
+	}
+	err := dao.DB().Model(&models.ScimToken{}).Where("id = ?", item.ID).UpdateColumns(map[string]any{
+		"last_used_at": time.Now(),
+		"last_used_ip": clientIP,
+	}).Error
+	if err != nil {
+		klog.V(6).Infof("记录SCIM Token使用情况失败: %v", err)
+	}
+	return nil
+}
+
+// ListUsers 查询用户，filter 仅支持 eq 比较，startIndex 从1开始
+func (s *scimService) ListUsers(filter string, startIndex, count int) ([]*ScimUser, int64, error) {
+	db := dao.DB().Model(&models.User{})
+	if filter != "" {
+		attr, value, err := parseScimFilter(filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch attr {
+		case "username":
+			db = db.Where("username = ?", value)
+		case "externalid":
+			db = db.Where("external_id = ?", value)
+		case "id":
+			db = db.Where("id = ?", utils.ToUInt(value))
+		default:
+			return nil, 0, scimBadRequest("invalidFilter", "不支持按 %s 过滤", attr)
+		}
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []*models.User
+	// count 为0时仅返回总数
+	if offset, limit := scimPage(startIndex, count); limit > 0 {
+		if err := db.Order("id asc").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	groupIDs, err := s.groupIDsByName()
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]*ScimUser, 0, len(users))
+	for _, u := range users {
+		result = append(result, toScimUser(u, groupIDs))
+	}
+	return result, total, nil
+}
+
+func (s *scimService) GetUser(id string) (*ScimUser, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs, err := s.groupIDsByName()
+	if err != nil {
+		return nil, err
+	}
+	return toScimUser(user, groupIDs), nil
+}
+
+// CreateUser 创建用户，来源标记为 scim，未指定 active 时默认启用
+func (s *scimService) CreateUser(in *ScimUser) (*ScimUser, error) {
+	username := strings.TrimSpace(in.UserName)
+	if username == "" {
+		return nil, scimBadRequest("invalidValue", "userName 不能为空")
+	}
+	var count int64
+	if err := dao.DB().Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, &ScimError{Status: http.StatusConflict, ScimType: "uniqueness", Detail: fmt.Sprintf("用户 %s 已存在", username)}
+	}
+	user := &models.User{
+		Username:   username,
+		Source:     ScimUserSource,
+		ExternalID: in.ExternalID,
+		Disabled:   in.Active != nil && !*in.Active,
+	}
+	if err := dao.DB().Create(user).Error; err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("SCIM 创建用户[%s]", username)
+	return s.GetUser(strconv.FormatUint(uint64(user.ID), 10))
+}
+
+// ReplaceUser 全量更新用户，k8m 中用户名被多处引用，不支持修改
+func (s *scimService) ReplaceUser(id string, in *ScimUser) (*ScimUser, error) {
+	user, err := s.getScimUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateUser(user, in); err != nil {
+		return nil, err
+	}
+	return s.GetUser(id)
+}
+
+// PatchUser 按 PatchOp 更新用户
+func (s *scimService) PatchUser(id string, ops []ScimPatchOperation) (*ScimUser, error) {
+	user, err := s.getScimUser(id)
+	if err != nil {
+		return nil, err
+	}
+	current := toScimUser(user, nil)
+	if err := applyUserPatch(current, ops); err != nil {
+		return nil, err
+	}
+	if err := s.updateUser(user, current); err != nil {
+		return nil, err
+	}
+	return s.GetUser(id)
+}
+
+// DeleteUser 删除用户并注销其登录会话
+func (s *scimService) DeleteUser(id string) error {
+	user, err := s.getScimUser(id)
+	if err != nil {
+		return err
+	}
+	if err := dao.DB().Delete(&models.User{}, user.ID).Error; err != nil {
+		return err
+	}
+	klog.V(2).Infof("SCIM 删除用户[%s]", user.Username)
+	UserService().ClearCacheByKey(user.Username)
+	if _, err := SessionService().RevokeAll(user.Username, "SCIM删除用户", ""); err != nil {
+		klog.Errorf("注销用户[%s]会话失败: %v", user.Username, err)
+	}
+	return nil
+}
+
+func (s *scimService) updateUser(user *models.User, in *ScimUser) error {
+	if in.UserName != "" && in.UserName != user.Username {
+		return scimBadRequest("mutability", "不支持修改用户名 %s", user.Username)
+	}
+	disabled := in.Active != nil && !*in.Active
+	err := dao.DB().Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]any{
+		"external_id": in.ExternalID,
+		"disabled":    disabled,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if disabled == user.Disabled {
+		return nil
+	}
+	// 清除用户的缓存，禁用立即生效
+	UserService().ClearCacheByKey(user.Username)
+	if disabled {
+		klog.V(2).Infof("SCIM 禁用用户[%s]", user.Username)
+		if _, err := SessionService().RevokeAll(user.Username, "SCIM禁用用户", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scimService) getUser(id string) (*models.User, error) {
+	user := &models.User{}
+	err := dao.DB().Where("id = ?", utils.ToUInt(id)).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scimNotFound("User", id)
+	}
+	return user, err
+}
+
+// getScimUser 获取通过 SCIM 创建的用户，本地、LDAP、SSO 等其他来源的用户不允许通过 SCIM 修改或删除
+func (s *scimService) getScimUser(id string) (*models.User, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Source != ScimUserSource {
+		return nil, &ScimError{Status: http.StatusForbidden, Detail: fmt.Sprintf("用户 %s 不是通过 SCIM 创建的，不允许修改", user.Username)}
+	}
+	return user, nil
+}
+
+// ListGroups 查询用户组，excludeMembers 为 true 时不计算成员
+func (s *scimService) ListGroups(filter string, startIndex, count int, excludeMembers bool) ([]*ScimGroup, int64, error) {
+	db := dao.DB().Model(&models.UserGroup{})
+	if filter != "" {
+		attr, value, err := parseScimFilter(filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch attr {
+		case "displayname":
+			db = db.Where("group_name = ?", value)
+		case "id":
+			db = db.Where("id = ?", utils.ToUInt(value))
+		default:
+			return nil, 0, scimBadRequest("invalidFilter", "不支持按 %s 过滤", attr)
+		}
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var groups []*models.UserGroup
+	// count 为0时仅返回总数
+	if offset, limit := scimPage(startIndex, count); limit > 0 {
+		if err := db.Order("id asc").Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	var members map[string][]ScimMember
+	if !excludeMembers {
+		var err error
+		if members, err = s.membersByGroup(); err != nil {
+			return nil, 0, err
+		}
+	}
+	result := make([]*ScimGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, toScimGroup(g, members[g.GroupName]))
+	}
+	return result, total, nil
+}
+
+func (s *scimService) GetGroup(id string) (*ScimGroup, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.membersByGroup()
+	if err != nil {
+		return nil, err
+	}
+	return toScimGroup(group, members[group.GroupName]), nil
+}
+
+// CreateGroup 创建用户组，默认角色为普通用户，集群权限仍由管理员授权
+func (s *scimService) CreateGroup(in *ScimGroup) (*ScimGroup, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return nil, scimBadRequest("invalidValue", "displayName 不能为空")
+	}
+	if err := s.checkGroupName(name, 0); err != nil {
+		return nil, err
+	}
+	want, err := s.resolveMembers(name, memberIDs(in.Members))
+	if err != nil {
+		return nil, err
+	}
+	group := &models.UserGroup{
+		GroupName:   name,
+		Description: "由SCIM创建",
+		Role:        constants.RoleGuest,
+	}
+	if err := dao.DB().Create(group).Error; err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("SCIM 创建用户组[%s]", name)
+	if err := s.setGroupMembers(name, want, true); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(strconv.FormatUint(uint64(group.ID), 10))
+}
+
+// ReplaceGroup 全量更新用户组名称及成员
+func (s *scimService) ReplaceGroup(id string, in *ScimGroup) (*ScimGroup, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateGroup(group, in.DisplayName, memberIDs(in.Members)); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(id)
+}
+
+// PatchGroup 按 PatchOp 更新用户组，常用于增删成员
+func (s *scimService) PatchGroup(id string, ops []ScimPatchOperation) (*ScimGroup, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.membersByGroup()
+	if err != nil {
+		return nil, err
+	}
+	current := toScimGroup(group, members[group.GroupName])
+	if err := applyGroupPatch(current, ops); err != nil {
+		return nil, err
+	}
+	if err := s.updateGroup(group, current.DisplayName, memberIDs(current.Members)); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(id)
+}
+
+// DeleteGroup 删除用户组，并从所有用户的用户组中移除
+func (s *scimService) DeleteGroup(id string) error {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return err
+	}
+	// 用户组删除后从所有来源的用户中移除
+	if err := s.setGroupMembers(group.GroupName, nil, false); err != nil {
+		return err
+	}
+	if err := dao.DB().Delete(&models.UserGroup{}, group.ID).Error; err != nil {
+		return err
+	}
+	klog.V(2).Infof("SCIM 删除用户组[%s]", group.GroupName)
+	UserService().ClearCacheByKey(group.GroupName)
+	return nil
+}
+
+// updateGroup 先校验成员，再修改名称及成员，避免校验失败时名称已被修改
+func (s *scimService) updateGroup(group *models.UserGroup, displayName string, members []string) error {
+	want, err := s.resolveMembers(group.GroupName, members)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(displayName)
+	if name != "" && name != group.GroupName {
+		if err := s.renameGroup(group, name); err != nil {
+			return err
+		}
+	}
+	return s.setGroupMembers(group.GroupName, want, true)
+}
+
+// renameGroup 修改用户组名称，同步更新用户的用户组及按用户组的集群授权
+func (s *scimService) renameGroup(group *models.UserGroup, name string) error {
+	if err := s.checkGroupName(name, group.ID); err != nil {
+		return err
+	}
+	oldName := group.GroupName
+	err := dao.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserGroup{}).Where("id = ?", group.ID).Update("group_name", name).Error; err != nil {
+			return err
+		}
+		var users []*models.User
+		if err := tx.Select("id", "group_names").Where("group_names LIKE ?", "%"+oldName+"%").Find(&users).Error; err != nil {
+			return err
+		}
+		for _, u := range users {
+			groups := utils.SplitAndTrim(u.GroupNames, ",")
+			if !slices.Contains(groups, oldName) {
+				continue
+			}
+			groups = removeScimItem(groups, oldName)
+			if !slices.Contains(groups, name) {
+				groups = append(groups, name)
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", u.ID).Update("group_names", strings.Join(groups, ",")).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.ClusterUserRole{}).
+			Where("username = ? AND authorization_type = ?", oldName, constants.ClusterAuthorizationTypeUserGroup).
+			Update("username", name).Error
+	})
+	if err != nil {
+		return err
+	}
+	klog.V(2).Infof("SCIM 用户组[%s]更名为[%s]", oldName, name)
+	group.GroupName = name
+	UserService().ClearCacheByKey(oldName)
+	UserService().ClearCacheByKey("cluster")
+	return nil
+}
+
+// resolveMembers 校验成员用户ID，全部存在时返回待设置的成员
+// 其他来源的用户不允许通过 SCIM 加入用户组，已在组内的保持不变
+func (s *scimService) resolveMembers(groupName string, userIDs []string) (map[uint]bool, error) {
+	want := map[uint]bool{}
+	for _, id := range userIDs {
+		uid := utils.ToUInt(id)
+		if uid == 0 {
+			return nil, scimBadRequest("invalidValue", "成员ID %s 错误", id)
+		}
+		want[uid] = true
+	}
+	if len(want) == 0 {
+		return want, nil
+	}
+	var users []*models.User
+	err := dao.DB().Select("id", "username", "source", "group_names").Where("id IN ?", slices.Collect(maps.Keys(want))).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(users) != len(want) {
+		return nil, scimBadRequest("invalidValue", "部分成员用户不存在")
+	}
+	for _, u := range users {
+		if u.Source != ScimUserSource && !slices.Contains(utils.SplitAndTrim(u.GroupNames, ","), groupName) {
+			return nil, scimBadRequest("invalidValue", "用户 %s 不是通过 SCIM 创建的，不允许加入用户组", u.Username)
+		}
+	}
+	return want, nil
+}
+
+// setGroupMembers 在同一事务中使用户组的成员与 want 一致
+// scimOnly 为 true 时仅维护通过 SCIM 创建的用户，其他来源的成员保持不变
+func (s *scimService) setGroupMembers(groupName string, want map[uint]bool, scimOnly bool) error {
+	var changed []string
+	err := dao.DB().Transaction(func(tx *gorm.DB) error {
+		db := tx.Select("id", "username", "group_names")
+		if scimOnly {
+			db = db.Where("source = ?", ScimUserSource)
+		}
+		var users []*models.User
+		if err := db.Find(&users).Error; err != nil {
+			return err
+		}
+		for _, u := range users {
+			groups := utils.SplitAndTrim(u.GroupNames, ",")
+			has := slices.Contains(groups, groupName)
+			switch {
+			case want[u.ID] && !has:
+				groups = append(groups, groupName)
+			case !want[u.ID] && has:
+				groups = removeScimItem(groups, groupName)
+			default:
+				continue
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", u.ID).Update("group_names", strings.Join(groups, ",")).Error; err != nil {
+				return err
+			}
+			changed = append(changed, u.Username)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, username := range changed {
+		UserService().ClearCacheByKey(username)
+	}
+	return nil
+}
+
+func (s *scimService) checkGroupName(name string, exceptID uint) error {
+	var count int64
+	err := dao.DB().Model(&models.UserGroup{}).Where("group_name = ? AND id <> ?", name, exceptID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &ScimError{Status: http.StatusConflict, ScimType: "uniqueness", Detail: fmt.Sprintf("用户组 %s 已存在", name)}
+	}
+	return nil
+}
+
+func (s *scimService) getGroup(id string) (*models.UserGroup, error) {
+	group := &models.UserGroup{}
+	err := dao.DB().Where("id = ?", utils.ToUInt(id)).First(group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scimNotFound("Group", id)
+	}
+	return group, err
+}
+
+func (s *scimService) groupIDsByName() (map[string]string, error) {
+	var groups []*models.UserGroup
+	if err := dao.DB().Select("id", "group_name").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(groups))
+	for _, g := range groups {
+		result[g.GroupName] = strconv.FormatUint(uint64(g.ID), 10)
+	}
+	return result, nil
+}
+
+func (s *scimService) membersByGroup() (map[string][]ScimMember, error) {
+	var users []*models.User
+	if err := dao.DB().Select("id", "username", "group_names").Where("group_names <> ''").Order("id asc").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	result := map[string][]ScimMember{}
+	for _, u := range users {
+		for _, g := range utils.SplitAndTrim(u.GroupNames, ",") {
+			result[g] = append(result[g], ScimMember{Value: strconv.FormatUint(uint64(u.ID), 10), Display: u.Username})
+		}
+	}
+	return result, nil
+}
+
+func toScimUser(user *models.User, groupIDs map[string]string) *ScimUser {
+	active := !user.Disabled
+	result := &ScimUser{
+		Schemas:    []string{ScimSchemaUser},
+		ID:         strconv.FormatUint(uint64(user.ID), 10),
+		ExternalID: user.ExternalID,
+		UserName:   user.Username,
+		Active:     &active,
+		Meta:       &ScimMeta{ResourceType: "User", Created: user.CreatedAt, LastModified: user.UpdatedAt},
+	}
+	for _, g := range utils.SplitAndTrim(user.GroupNames, ",") {
+		if id, ok := groupIDs[g]; ok {
+			result.Groups = append(result.Groups, ScimMember{Value: id, Display: g})
+		}
+	}
+	return result
+}
+
+func toScimGroup(group *models.UserGroup, members []ScimMember) *ScimGroup {
+	if members == nil {
+		members = []ScimMember{}
+	}
+	return &ScimGroup{
+		Schemas:     []string{ScimSchemaGroup},
+		ID:          strconv.FormatUint(uint64(group.ID), 10),
+		DisplayName: group.GroupName,
+		Members:     members,
+		Meta:        &ScimMeta{ResourceType: "Group", Created: group.CreatedAt, LastModified: group.UpdatedAt},
+	}
+}
+
+var scimFilterRegex = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// parseScimFilter 解析 attr eq "value" 形式的过滤条件，属性名转为小写
+func parseScimFilter(filter string) (string, string, error) {
+	m := scimFilterRegex.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", scimBadRequest("invalidFilter", "仅支持 attr eq \"value\" 形式的过滤条件: %s", filter)
+	}
+	value, err := strconv.Unquote(`"` + m[2] + `"`)
+	if err != nil {
+		return "", "", scimBadRequest("invalidFilter", "过滤条件取值错误: %s", m[2])
+	}
+	return strings.ToLower(m[1]), value, nil
+}
+
+// applyUserPatch 将 PatchOp 应用到用户，未保存的属性（如 name、emails）忽略
+func applyUserPatch(user *ScimUser, ops []ScimPatchOperation) error {
+	for _, op := range ops {
+		action := strings.ToLower(op.Op)
+		if action != "add" && action != "replace" && action != "remove" {
+			return scimBadRequest("invalidSyntax", "不支持的操作 %s", op.Op)
+		}
+		values := map[string]json.RawMessage{}
+		if path := scimAttrPath(op.Path, ScimSchemaUser); path != "" {
+			values[path] = op.Value
+		} else if err := json.Unmarshal(op.Value, &values); err != nil {
+			return scimBadRequest("invalidValue", "操作取值错误: %v", err)
+		}
+		for key, raw := range values {
+			switch strings.ToLower(key) {
+			case "active":
+				if action == "remove" {
+					continue
+				}
+				active, err := scimBool(raw)
+				if err != nil {
+					return err
+				}
+				user.Active = &active
+			case "externalid":
+				if action == "remove" {
+					user.ExternalID = ""
+					continue
+				}
+				if err := json.Unmarshal(raw, &user.ExternalID); err != nil {
+					return scimBadRequest("invalidValue", "externalId 取值错误")
+				}
+			case "username":
+				if action == "remove" {
+					return scimBadRequest("mutability", "userName 不能删除")
+				}
+				if err := json.Unmarshal(raw, &user.UserName); err != nil {
+					return scimBadRequest("invalidValue", "userName 取值错误")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var scimMemberPathRegex = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*]$`)
+
+// applyGroupPatch 将 PatchOp 应用到用户组，支持修改 displayName 及增删成员
+func applyGroupPatch(group *ScimGroup, ops []ScimPatchOperation) error {
+	for _, op := range ops {
+		action := strings.ToLower(op.Op)
+		if action != "add" && action != "replace" && action != "remove" {
+			return scimBadRequest("invalidSyntax", "不支持的操作 %s", op.Op)
+		}
+		path := scimAttrPath(op.Path, ScimSchemaGroup)
+		if m := scimMemberPathRegex.FindStringSubmatch(path); m != nil {
+			if action != "remove" {
+				return scimBadRequest("invalidPath", "不支持的路径 %s", op.Path)
+			}
+			group.Members = removeScimMembers(group.Members, []string{m[1]})
+			continue
+		}
+		values := map[string]json.RawMessage{}
+		if path != "" {
+			values[path] = op.Value
+		} else if err := json.Unmarshal(op.Value, &values); err != nil {
+			return scimBadRequest("invalidValue", "操作取值错误: %v", err)
+		}
+		for key, raw := range values {
+			switch strings.ToLower(key) {
+			case "displayname":
+				if action == "remove" {
+					return scimBadRequest("mutability", "displayName 不能删除")
+				}
+				if err := json.Unmarshal(raw, &group.DisplayName); err != nil {
+					return scimBadRequest("invalidValue", "displayName 取值错误")
+				}
+			case "members":
+				var members []ScimMember
+				if len(raw) > 0 && string(raw) != "null" {
+					if err := json.Unmarshal(raw, &members); err != nil {
+						return scimBadRequest("invalidValue", "members 取值错误")
+					}
+				}
+				switch {
+				case action == "replace":
+					group.Members = members
+				case action == "add":
+					group.Members = append(group.Members, members...)
+				case len(members) == 0:
+					// 未指定成员时移除全部成员
+					group.Members = nil
+				default:
+					group.Members = removeScimMembers(group.Members, memberIDs(members))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// scimAttrPath 去除路径中的 schema 前缀，如 urn:...:User:active 转为 active
+func scimAttrPath(path, schema string) string {
+	path = strings.TrimSpace(path)
+	if len(path) > len(schema) && strings.EqualFold(path[:len(schema)+1], schema+":") {
+		return path[len(schema)+1:]
+	}
+	return path
+}
+
+// scimBool 解析布尔值，兼容部分身份系统以字符串 "True"/"False" 传递
+func scimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v, nil
+		}
+	}
+	return false, scimBadRequest("invalidValue", "active 取值错误: %s", string(raw))
+}
+
+func scimPage(startIndex, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	return startIndex - 1, max(0, min(count, ScimMaxResults))
+}
+
+func memberIDs(members []ScimMember) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if !slices.Contains(ids, m.Value) {
+			ids = append(ids, m.Value)
+		}
+	}
+	return ids
+}
+
+func removeScimMembers(members []ScimMember, ids []string) []ScimMember {
+	return slices.DeleteFunc(members, func(m ScimMember) bool {
+		return slices.Contains(ids, m.Value)
+	})
+}
+
+func removeScimItem(items []string, item string) []string {
+	return slices.DeleteFunc(items, func(s string) bool {
+		return s == item
+	})
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseScimFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		attr   string
+		value  string
+		ok     bool
+	}{
+		{`userName eq "alice"`, "username", "alice", true},
+		{` externalId EQ "a\"b" `, "externalid", `a"b`, true},
+		{`displayName eq "dev team"`, "displayname", "dev team", true},
+		{`userName co "ali"`, "", "", false},
+		{`userName eq "a" and active eq true`, "", "", false},
+	}
+	for _, tt := range tests {
+		attr, value, err := parseScimFilter(tt.filter)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: 期望成功=%v，实际错误 %v", tt.filter, tt.ok, err)
+			continue
+		}
+		if attr != tt.attr || value != tt.value {
+			t.Errorf("%s: 期望 %s=%s，实际 %s=%s", tt.filter, tt.attr, tt.value, attr, value)
+		}
+	}
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		ops    string
+		active bool
+		extID  string
+		ok     bool
+	}{
+		{"按路径禁用", `[{"op":"replace","path":"active","value":false}]`, false, "old", true},
+		{"字符串布尔值", `[{"op":"Replace","path":"active","value":"False"}]`, false, "old", true},
+		{"无路径对象", `[{"op":"replace","value":{"active":false,"externalId":"new","name":{"givenName":"A"}}}]`, false, "new", true},
+		{"带schema前缀", `[{"op":"replace","path":"urn:ietf:params:scim:schemas:core:2.0:User:externalId","value":"new"}]`, true, "new", true},
+		{"删除userName", `[{"op":"remove","path":"userName"}]`, true, "old", false},
+		{"未知操作", `[{"op":"move","path":"active","value":true}]`, true, "old", false},
+	}
+	for _, tt := range tests {
+		var ops []ScimPatchOperation
+		if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		active := true
+		user := &ScimUser{UserName: "alice", ExternalID: "old", Active: &active}
+		err := applyUserPatch(user, ops)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: 期望成功=%v，实际错误 %v", tt.name, tt.ok, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if *user.Active != tt.active || user.ExternalID != tt.extID || user.UserName != "alice" {
+			t.Errorf("%s: 结果不符 active=%v externalId=%s userName=%s", tt.name, *user.Active, user.ExternalID, user.UserName)
+		}
+	}
+}
+
+func TestApplyGroupPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     string
+		display string
+		members []string
+	}{
+		{"添加成员", `[{"op":"add","path":"members","value":[{"value":"3"},{"value":"1"}]}]`, "dev", []string{"1", "2", "3"}},
+		{"按过滤路径移除成员", `[{"op":"remove","path":"members[value eq \"1\"]"}]`, "dev", []string{"2"}},
+		{"按取值移除成员", `[{"op":"remove","path":"members","value":[{"value":"2"}]}]`, "dev", []string{"1"}},
+		{"移除全部成员", `[{"op":"remove","path":"members"}]`, "dev", []string{}},
+		{"替换成员及名称", `[{"op":"replace","value":{"displayName":"ops","members":[{"value":"9"}]}}]`, "ops", []string{"9"}},
+	}
+	for _, tt := range tests {
+		var ops []ScimPatchOperation
+		if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		group := &ScimGroup{DisplayName: "dev", Members: []ScimMember{{Value: "1"}, {Value: "2"}}}
+		if err := applyGroupPatch(group, ops); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		members := memberIDs(group.Members)
+		if group.DisplayName != tt.display || len(members) != len(tt.members) {
+			t.Errorf("%s: 期望 %s %v，实际 %s %v", tt.name, tt.display, tt.members, group.DisplayName, members)
+			continue
+		}
+		for i := range members {
+			if members[i] != tt.members[i] {
+				t.Errorf("%s: 期望成员 %v，实际 %v", tt.name, tt.members, members)
+				break
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
     "sync"
 
+    "github.com/weibaohui/k8m/pkg/ai"
     "github.com/weibaohui/k8m/pkg/comm/utils"
     "github.com/weibaohui/k8m/pkg/lease"
     "k8s.io/client-go/rest"
@@ -35,7 +36,27 @@ var localUserService = &userService{
 var localOperationLogService = NewOperationLogService()
 var localShellLogService = &shellLogService{}
 var localAiService = &aiService{}
+var localAIUsageService = &aiUsageService{}
+var localAIRedactionService = &aiRedactionService{}
+var localKnowledgeService = &knowledgeService{}
 var localMcpService = &mcpService{}
+var localApiKeyService = &apiKeyService{}
+var localSessionService = &sessionService{}
+var localJwtKeyService = &jwtKeyService{}
+var localGroupMappingService = &groupMappingService{}
+var localScimService = &scimService{}
+var localMFAService = &mfaService{}
+var localAuthChallengeService = &authChallengeService{}
+var localLoginGuardService = &loginGuardService{}
+var localMailService = &mailService{}
+var localPasswordService = &passwordService{}
+var localKubeProxyService = &kubeProxyService{}
+var localUserKubeconfigService = &userKubeconfigService{}
+var localClusterAgentService = &clusterAgentService{}
+var localClusterDiscoveryService = &clusterDiscoveryService{}
+var localClusterTagService = &clusterTagService{}
+var localClusterHealthService = &clusterHealthService{}
+var localClusterCertificateService = &clusterCertificateService{}
 var localPromptService = &promptService{}
 var localLeaseManager = lease.NewManager()
 
@@ -48,6 +69,9 @@ func init() {
         }
         return c.GetRestConfig()
     }
+    ai.SetUsageRecorder(localAIUsageService.Record)
+    ai.SetRedactionRecorder(localAIRedactionService.Record)
+    utils.JWTKeyFunc = localJwtKeyService.KeyFunc
 }
 
 func PromptService() *promptService {
@@ -100,6 +124,86 @@ func AIService() *aiService {
 
 }
 
+func AIUsageService() *aiUsageService {
+	return localAIUsageService
+}
+
+func AIRedactionService() *aiRedactionService {
+	return localAIRedactionService
+}
+
+func KnowledgeService() *knowledgeService {
+	return localKnowledgeService
+}
+
+func ApiKeyService() *apiKeyService {
+	return localApiKeyService
+}
+
+func SessionService() *sessionService {
+	return localSessionService
+}
+
+func JwtKeyService() *jwtKeyService {
+	return localJwtKeyService
+}
+
+func GroupMappingService() *groupMappingService {
+	return localGroupMappingService
+}
+
+func ScimService() *scimService {
+	return localScimService
+}
+
+func MFAService() *mfaService {
+	return localMFAService
+}
+
+func AuthChallengeService() *authChallengeService {
+	return localAuthChallengeService
+}
+
+func LoginGuardService() *loginGuardService {
+	return localLoginGuardService
+}
+
+func MailService() *mailService {
+	return localMailService
+}
+
+func PasswordService() *passwordService {
+	return localPasswordService
+}
+
+func KubeProxyService() *kubeProxyService {
+	return localKubeProxyService
+}
+
+func UserKubeconfigService() *userKubeconfigService {
+	return localUserKubeconfigService
+}
+
+func ClusterAgentService() *clusterAgentService {
+	return localClusterAgentService
+}
+
+func ClusterDiscoveryService() *clusterDiscoveryService {
+	return localClusterDiscoveryService
+}
+
+func ClusterTagService() *clusterTagService {
+	return localClusterTagService
+}
+
+func ClusterHealthService() *clusterHealthService {
+	return localClusterHealthService
+}
+
+func ClusterCertificateService() *clusterCertificateService {
+	return localClusterCertificateService
+}
+
 func McpService() *mcpService {
 
     return localMcpService
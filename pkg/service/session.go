@@ -0,0 +1,294 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/flag"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	LoginTypePassword = "password"
+	LoginTypeLDAP     = "ldap"
+	LoginTypeSSO      = "sso"
+
+	// sessionTouchInterval 会话活跃时间的最小更新间隔，避免每次请求都写库
+	sessionTouchInterval = time.Minute
+	// refreshReuseGrace 刷新 Token 轮换后，旧 Token 在此时间内再次使用视为多标签页并发刷新，不判定为盗用
+	refreshReuseGrace = time.Minute
+	// sessionRetention 已过期或注销的会话保留时长，之后清理
+	sessionRetention = 30 * 24 * time.Hour
+)
+
+// ErrRefreshTokenRotated 刷新 Token 已被其他请求轮换，客户端应使用最新的刷新 Token
+var ErrRefreshTokenRotated = errors.New("刷新Token已更新，请使用最新的刷新Token")
+
+type sessionService struct {
+	touched sync.Map // 会话ID -> 最近写库时间
+}
+
+// TokenPair 登录或刷新后返回给客户端的 Token
+type TokenPair struct {
+	Token        string `json:"token"`         // 访问 Token
+	RefreshToken string `json:"refresh_token"` // 刷新 Token
+	ExpiresIn    int64  `json:"expires_in"`    // 访问 Token 有效期（秒）
+}
+
+// Login 登录成功后创建会话，签发访问 Token 及刷新 Token
+func (s *sessionService) Login(c *gin.Context, username, loginType string) (*TokenPair, error) {
+	return s.login(c, username, loginType, false)
+}
+
+// LoginForMFAEnroll 未满足2步验证策略时创建受限会话，签发的 Token 仅允许绑定2步验证
+func (s *sessionService) LoginForMFAEnroll(c *gin.Context, username, loginType string) (*TokenPair, error) {
+	return s.login(c, username, loginType, true)
+}
+
+func (s *sessionService) login(c *gin.Context, username, loginType string, mfaEnroll bool) (*TokenPair, error) {
+	if username == "" {
+		return nil, errors.New("username cannot be empty")
+	}
+	s.cleanup()
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &models.UserSession{
+		SessionID:        sessionID,
+		Username:         username,
+		LoginType:        loginType,
+		RefreshTokenHash: tokenHash(refreshToken),
+		ClientIP:         c.ClientIP(),
+		UserAgent:        c.Request.UserAgent(),
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(s.refreshTTL()),
+		MFAEnroll:        mfaEnroll,
+	}
+	if err := dao.DB().Create(session).Error; err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+	LoginGuardService().Succeed(username)
+	return s.issue(session, refreshToken)
+}
+
+// Refresh 使用刷新 Token 换取新的访问 Token，同时轮换刷新 Token
+// 已轮换的旧刷新 Token 超过宽限期后再次使用，视为被盗用，注销整个会话
+func (s *sessionService) Refresh(c *gin.Context, refreshToken string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, errors.New("未提供刷新Token")
+	}
+	hash := tokenHash(refreshToken)
+	session := &models.UserSession{}
+	err := dao.DB().Where("refresh_token_hash = ? OR prev_token_hash = ?", hash, hash).First(session).Error
+	if err != nil {
+		return nil, errors.New("刷新Token无效")
+	}
+	if session.Revoked {
+		return nil, errors.New("会话已注销，请重新登录")
+	}
+	now := time.Now()
+	if session.ExpiresAt.Before(now) {
+		return nil, errors.New("会话已过期，请重新登录")
+	}
+	if session.RefreshTokenHash != hash {
+		if now.Sub(session.UpdatedAt) < refreshReuseGrace {
+			return nil, ErrRefreshTokenRotated
+		}
+		klog.Warningf("用户[%s]会话[%s]的刷新Token被重复使用，来源IP %s，已注销该会话", session.Username, session.SessionID, c.ClientIP())
+		_ = s.revoke(session, "刷新Token被重复使用")
+		return nil, errors.New("刷新Token已失效，请重新登录")
+	}
+	if UserService().IsUserDisabled(session.Username) {
+		_ = s.revoke(session, "用户已禁用")
+		return nil, errors.New("用户已被禁用")
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	// 条件更新，避免并发刷新时同一刷新 Token 被轮换两次
+	result := dao.DB().Model(&models.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]any{
+			"refresh_token_hash": tokenHash(newToken),
+			"prev_token_hash":    hash,
+			"client_ip":          c.ClientIP(),
+			"user_agent":         c.Request.UserAgent(),
+			"last_seen_at":       now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRefreshTokenRotated
+	}
+	return s.issue(session, newToken)
+}
+
+// Logout 注销刷新 Token 所属的会话
+func (s *sessionService) Logout(refreshToken string) error {
+	session := &models.UserSession{}
+	err := dao.DB().Where("refresh_token_hash = ?", tokenHash(refreshToken)).First(session).Error
+	if err != nil {
+		return nil
+	}
+	return s.revoke(session, "用户退出登录")
+}
+
+// Validate 校验访问 Token 所属会话是否有效，注销后立即失效
+func (s *sessionService) Validate(sessionID, username string) error {
+	active, err := utils.GetOrSetCache(CacheService().CacheInstance(), s.cacheKey(sessionID), time.Minute, func() (bool, error) {
+		var count int64
+		err := dao.DB().Model(&models.UserSession{}).
+			Where("session_id = ? AND username = ? AND revoked = ?", sessionID, username, false).
+			Count(&count).Error
+		return count > 0, err
+	})
+	if err != nil {
+		return fmt.Errorf("会话校验失败: %w", err)
+	}
+	if !active {
+		return errors.New("会话已注销，请重新登录")
+	}
+	return nil
+}
+
+// Touch 更新会话的最近活跃时间及来源IP，按间隔节流
+func (s *sessionService) Touch(sessionID, clientIP string) {
+	now := time.Now()
+	if v, ok := s.touched.Load(sessionID); ok && now.Sub(v.(time.Time)) < sessionTouchInterval {
+		return
+	}
+	s.touched.Store(sessionID, now)
+	go func() {
+		err := dao.DB().Model(&models.UserSession{}).Where("session_id = ?", sessionID).
+			UpdateColumns(map[string]any{"last_seen_at": now, "client_ip": clientIP}).Error
+		if err != nil {
+			klog.V(6).Infof("更新会话[%s]活跃时间失败: %v", sessionID, err)
+		}
+	}()
+}
+
+// ListActive 获取用户有效的会话
+func (s *sessionService) ListActive(username string) ([]*models.UserSession, error) {
+	var sessions []*models.UserSession
+	err := dao.DB().Where("username = ? AND revoked = ? AND expires_at > ?", username, false, time.Now()).
+		Order("last_seen_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke 注销用户的指定会话
+func (s *sessionService) Revoke(id uint, username, reason string) error {
+	session := &models.UserSession{}
+	if err := dao.DB().Where("id = ? AND username = ?", id, username).First(session).Error; err != nil {
+		return fmt.Errorf("会话不存在")
+	}
+	return s.revoke(session, reason)
+}
+
+// RevokeAll 注销用户的所有会话，except 不为空时保留该会话，返回注销的会话数
+func (s *sessionService) RevokeAll(username, reason, except string) (int, error) {
+	sessions, err := s.ListActive(username)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, session := range sessions {
+		if session.SessionID == except {
+			continue
+		}
+		if err := s.revoke(session, reason); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *sessionService) revoke(session *models.UserSession, reason string) error {
+	now := time.Now()
+	err := dao.DB().Model(&models.UserSession{}).Where("id = ?", session.ID).UpdateColumns(map[string]any{
+		"revoked":        true,
+		"revoked_at":     now,
+		"revoked_reason": reason,
+	}).Error
+	if err != nil {
+		return err
+	}
+	utils.ClearCacheByKey(CacheService().CacheInstance(), s.cacheKey(session.SessionID))
+	s.touched.Delete(session.SessionID)
+	return nil
+}
+
+// issue 签发包含会话ID的访问 Token
+func (s *sessionService) issue(session *models.UserSession, refreshToken string) (*TokenPair, error) {
+	ttl := s.accessTTL()
+	claims := jwt.MapClaims{
+		constants.JwtUserName:  session.Username,
+		constants.JwtSessionID: session.SessionID,
+		"isPlatformAdmin":      UserService().IsUserPlatformAdmin(session.Username), //前端展示平台管理员使用，没有其他用处
+		"exp":                  time.Now().Add(ttl).Unix(),
+	}
+	if session.MFAEnroll {
+		claims[constants.JwtMFAEnroll] = true
+	}
+	signed, err := JwtKeyService().Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{Token: signed, RefreshToken: refreshToken, ExpiresIn: int64(ttl.Seconds())}, nil
+}
+
+// cleanup 清理过期较久的会话记录
+func (s *sessionService) cleanup() {
+	err := dao.DB().Where("expires_at < ?", time.Now().Add(-sessionRetention)).Delete(&models.UserSession{}).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		klog.V(6).Infof("清理过期会话失败: %v", err)
+	}
+}
+
+func (s *sessionService) cacheKey(sessionID string) string {
+	return fmt.Sprintf("session:active:%s", sessionID)
+}
+
+func (s *sessionService) accessTTL() time.Duration {
+	if ttl := flag.Init().AccessTokenTTL; ttl > 0 {
+		return time.Duration(ttl) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+func (s *sessionService) refreshTTL() time.Duration {
+	if ttl := flag.Init().RefreshTokenTTL; ttl > 0 {
+		return time.Duration(ttl) * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// newRefreshToken 生成随机 Token，同时用于会话ID
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成刷新Token失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/models"
+)
+
+func TestSessionRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	s := SessionService()
+	username := fmt.Sprintf("session-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		dao.DB().Where("username = ?", username).Delete(&models.UserSession{})
+	})
+	// sessionOf 按当前刷新 Token 查找会话
+	sessionOf := func(refreshToken string) *models.UserSession {
+		session := &models.UserSession{}
+		if err := dao.DB().Where("refresh_token_hash = ?", tokenHash(refreshToken)).First(session).Error; err != nil {
+			t.Fatalf("查询会话失败: %v", err)
+		}
+		return session
+	}
+	login := func() *TokenPair {
+		pair, err := s.Login(c, username, LoginTypePassword)
+		if err != nil {
+			t.Fatalf("登录失败: %v", err)
+		}
+		return pair
+	}
+
+	// 轮换后旧刷新 Token 被拒绝，新刷新 Token 可继续使用
+	old := login()
+	next, err := s.Refresh(c, old.RefreshToken)
+	if err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	if next.RefreshToken == old.RefreshToken {
+		t.Fatalf("刷新后应轮换刷新Token")
+	}
+	if _, err := s.Refresh(c, old.RefreshToken); !errors.Is(err, ErrRefreshTokenRotated) {
+		t.Errorf("宽限期内重复使用旧Token应返回已轮换，实际 %v", err)
+	}
+	if _, err := s.Refresh(c, next.RefreshToken); err != nil {
+		t.Errorf("新刷新Token应可使用: %v", err)
+	}
+
+	// 超过宽限期后重放旧刷新 Token，视为盗用，注销整个会话，后续轮换出的 Token 一并失效
+	old = login()
+	next, err = s.Refresh(c, old.RefreshToken)
+	if err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	session := sessionOf(next.RefreshToken)
+	dao.DB().Model(&models.UserSession{}).Where("id = ?", session.ID).
+		UpdateColumn("updated_at", time.Now().Add(-2*refreshReuseGrace))
+	if _, err := s.Refresh(c, old.RefreshToken); err == nil || errors.Is(err, ErrRefreshTokenRotated) {
+		t.Errorf("超过宽限期重放旧Token应注销会话，实际 %v", err)
+	}
+	if _, err := s.Refresh(c, next.RefreshToken); err == nil {
+		t.Errorf("会话注销后最新刷新Token也应失效")
+	}
+	if err := s.Validate(session.SessionID, username); err == nil {
+		t.Errorf("会话注销后访问Token应失效")
+	}
+
+	// 会话过期后无法刷新
+	expired := login()
+	session = sessionOf(expired.RefreshToken)
+	dao.DB().Model(&models.UserSession{}).Where("id = ?", session.ID).
+		UpdateColumn("expires_at", time.Now().Add(-time.Minute))
+	if _, err := s.Refresh(c, expired.RefreshToken); err == nil {
+		t.Errorf("会话过期后不应能刷新")
+	}
+	if sessionOf(expired.RefreshToken).ID != session.ID {
+		t.Errorf("会话过期后刷新Token不应被轮换")
+	}
+}
@@ -195,7 +195,17 @@ func (u *userService) GetClusterNames(username string) ([]string, error) {
 // 最终结果包含两种情况：
 // 1. 用户授权类型为用户
 // 2. 用户授权类型为用户组,当前用户所在的用户组，如果有授权，那么也提取出来
+// 按集群标签授权的条目展开为当前匹配的集群，集群标签变化后即时生效
 func (u *userService) GetClusters(username string) ([]*models.ClusterUserRole, error) {
+	items, err := u.getClusterRoles(username)
+	if err != nil {
+		return nil, err
+	}
+	return ClusterTagService().ExpandRoles(items), nil
+}
+
+// getClusterRoles 获取用户及所在用户组的授权条目，按标签授权的条目未展开
+func (u *userService) getClusterRoles(username string) ([]*models.ClusterUserRole, error) {
 	cacheKey := u.formatCacheKey("user:clusters:%s", username)
 
 	result, err := utils.GetOrSetCache(CacheService().CacheInstance(), cacheKey, 5*time.Minute, func() ([]*models.ClusterUserRole, error) {
@@ -236,14 +246,28 @@ func (u *userService) GenerateJWTTokenOnlyUserName(username string, duration tim
 	}
 	name := constants.JwtUserName
 
-	var token = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return JwtKeyService().Sign(jwt.MapClaims{
 		name:              username,
 		"isPlatformAdmin": u.IsUserPlatformAdmin(username), //前端展示平台管理员使用，没有其他用处
 		"exp":             time.Now().Add(duration).Unix(),
 	})
-	cfg := flag.Init()
-	var jwtSecret = []byte(cfg.JwtTokenSecret)
-	return token.SignedString(jwtSecret)
+}
+
+// IsUserDisabled 判断用户是否被禁用，数据库中不存在的用户（如临时管理员）视为未禁用
+func (u *userService) IsUserDisabled(username string) bool {
+	if username == "" {
+		return false
+	}
+	cacheKey := u.formatCacheKey("user:disabled:%s", username)
+	disabled, err := utils.GetOrSetCache(CacheService().CacheInstance(), cacheKey, 5*time.Minute, func() (bool, error) {
+		var users []*models.User
+		err := dao.DB().Select("disabled").Where("username = ?", username).Limit(1).Find(&users).Error
+		if err != nil || len(users) == 0 {
+			return false, err
+		}
+		return users[0].Disabled, nil
+	})
+	return err == nil && disabled
 }
 
 // GetGroupNames 获取用户所在的用户组
@@ -308,7 +332,11 @@ func (u *userService) GetUserByMCPKey(mcpKey string) (string, error) {
 	return item.Username, nil
 }
 
+// ErrUserSourceConflict 用户名已被其他来源的用户使用
+var ErrUserSourceConflict = errors.New("用户名已被其他来源的用户使用")
+
 // CheckAndCreateUser 检查用户是否存在，如果不存在则创建一个新用户
+// 同名用户来源不同时返回 ErrUserSourceConflict
 func (u *userService) CheckAndCreateUser(username, source, groups string) error {
 	params := dao.BuildDefaultParams()
 	user := &models.User{}
@@ -318,6 +346,14 @@ func (u *userService) CheckAndCreateUser(username, source, groups string) error
 	du, err := user.GetOne(params, queryFunc)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 用户名已被其他来源的用户使用时拒绝，避免外部身份登录为同名的本地用户
+			var count int64
+			if err := dao.DB().Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: %s", ErrUserSourceConflict, username)
+			}
 			// 用户不存在，创建新用户
 			du = &models.User{
 				Username:   username,
@@ -354,6 +390,24 @@ func (u *userService) ClearCacheByKey(cacheKey string) {
 	}
 }
 
+// ErrLdapUserNotFound LDAP中未找到用户
+var ErrLdapUserNotFound = errors.New("用户不存在")
+
+// LdapGroups 获取LDAP用户所属的用户组，通常为组的DN
+func (u *userService) LdapGroups(entry *ldap.Entry, config *models.LDAPConfig) []string {
+	if entry == nil {
+		return nil
+	}
+	return entry.GetAttributeValues(ldapGroupAttribute(config))
+}
+
+func ldapGroupAttribute(config *models.LDAPConfig) string {
+	if config.GroupAttribute != "" {
+		return config.GroupAttribute
+	}
+	return "memberOf"
+}
+
 // ldap连接
 func (u *userService) ldapConnection(config *models.LDAPConfig) (*ldap.Conn, error) {
 	conn, err := ldap.Dial("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port))
@@ -373,6 +427,7 @@ func (u *userService) searchRequest(conn *ldap.Conn, username string, config *mo
 		cur              *ldap.SearchResult
 		ldapFieldsFilter = []string{
 			"dn",
+			ldapGroupAttribute(config),
 		}
 	)
 
@@ -408,7 +463,7 @@ func (u *userService) searchRequest(conn *ldap.Conn, username string, config *mo
 
 	if len(cur.Entries) == 0 {
 		klog.Errorf("LDAP中未找到用户: %s", username)
-		return nil, errors.New("用户不存在")
+		return nil, ErrLdapUserNotFound
 	}
 
 	return cur.Entries[0], nil
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/weibaohui/k8m/internal/dao"
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"github.com/weibaohui/k8m/pkg/constants"
+	"github.com/weibaohui/k8m/pkg/models"
+	"gorm.io/gorm"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// UserKubeconfigModeToken kubeconfig 中直接写入短期Token，过期后需重新下载
+	UserKubeconfigModeToken = "token"
+	// UserKubeconfigModeExec kubeconfig 中配置 exec 插件，每次使用时向 k8m 换取短期Token
+	UserKubeconfigModeExec = "exec"
+
+	// UserKubeconfigExecPath exec 插件换取Token的接口
+	UserKubeconfigExecPath = "/auth/kubeconfig/exec_credential"
+
+	// userKubeconfigExecTokenTTL exec 插件换取的Token有效期
+	userKubeconfigExecTokenTTL = time.Hour
+	// userKubeconfigTouchInterval 最后使用时间写库的最小间隔，避免 watch、k9s 等频繁请求反复写库
+	userKubeconfigTouchInterval = time.Minute
+	// userKubeconfigSecretPrefix exec 凭据前缀，便于识别
+	userKubeconfigSecretPrefix = "k8mkc_"
+)
+
+type userKubeconfigService struct {
+	touched sync.Map // kubeconfig ID -> 最后写库时间
+}
+
+// UserKubeconfigRequest 下载 kubeconfig 的参数
+type UserKubeconfigRequest struct {
+	Username    string
+	Description string
+	Mode        string
+	Clusters    []string      // 为空时包含用户有权限的全部集群
+	TTL         time.Duration // token 模式为Token有效期，exec 模式为凭据有效期
+	Server      string        // k8m 访问地址，如 https://k8m.example.com
+	ClientIP    string
+}
+
+// ExecCredential client.authentication.k8s.io/v1 ExecCredential，exec 插件的输出
+type ExecCredential struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Status     ExecCredentialStatus `json:"status"`
+}
+
+type ExecCredentialStatus struct {
+	Token               string `json:"token"`
+	ExpirationTimestamp string `json:"expirationTimestamp"`
+}
+
+// AuthorizedClusters 用户有权限的集群，平台管理员为全部集群
+func (s *userKubeconfigService) AuthorizedClusters(username string) ([]string, error) {
+	var clusters []string
+	if UserService().IsUserPlatformAdmin(username) {
+		for _, cc := range ClusterService().AllClusters() {
+			clusters = append(clusters, cc.GetClusterID())
+		}
+	} else {
+		roles, err := UserService().GetClusters(username)
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range roles {
+			if ClusterService().GetClusterByID(role.Cluster) != nil {
+				clusters = append(clusters, role.Cluster)
+			}
+		}
+	}
+	slices.Sort(clusters)
+	return slices.Compact(clusters), nil
+}
+
+// Issue 生成 kubeconfig，每个集群一个 context，均指向 k8m 的 Kubernetes API 代理
+// kubeconfig 内容不保存，仅在生成时返回一次
+func (s *userKubeconfigService) Issue(req *UserKubeconfigRequest) ([]byte, *models.UserKubeconfig, error) {
+	if req.Mode != UserKubeconfigModeToken && req.Mode != UserKubeconfigModeExec {
+		return nil, nil, fmt.Errorf("不支持的模式: %s", req.Mode)
+	}
+	allowed, err := s.AuthorizedClusters(req.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+	clusters := req.Clusters
+	if len(clusters) == 0 {
+		clusters = allowed
+	}
+	for _, cluster := range clusters {
+		if !slices.Contains(allowed, cluster) {
+			return nil, nil, fmt.Errorf("无权访问集群: %s", cluster)
+		}
+	}
+	if len(clusters) == 0 {
+		return nil, nil, fmt.Errorf("没有可访问的集群")
+	}
+
+	item := &models.UserKubeconfig{
+		Username:    req.Username,
+		Description: req.Description,
+		Mode:        req.Mode,
+		Clusters:    strings.Join(clusters, ","),
+		ExpiresAt:   time.Now().Add(req.TTL),
+		CreatedIP:   req.ClientIP,
+	}
+	var secret string
+	if req.Mode == UserKubeconfigModeExec {
+		random, err := newRefreshToken()
+		if err != nil {
+			return nil, nil, err
+		}
+		secret = userKubeconfigSecretPrefix + random
+		item.SecretHash = tokenHash(secret)
+	}
+	if err := dao.DB().Create(item).Error; err != nil {
+		return nil, nil, err
+	}
+
+	authInfo := &clientcmdapi.AuthInfo{}
+	if req.Mode == UserKubeconfigModeExec {
+		authInfo.Exec = userKubeconfigExecConfig(req.Server, secret)
+	} else {
+		token, err := s.signToken(item, item.ExpiresAt)
+		if err != nil {
+			_ = dao.DB().Delete(item).Error
+			return nil, nil, err
+		}
+		authInfo.Token = token
+	}
+	data, err := buildUserKubeconfig(req.Server, req.Username, clusters, authInfo)
+	if err != nil {
+		_ = dao.DB().Delete(item).Error
+		return nil, nil, err
+	}
+	return data, item, nil
+}
+
+// userKubeconfigExecConfig 构建换取Token的 exec 插件配置
+// 凭据通过环境变量传入，由 shell 内置的 printf 经标准输入交给 curl，不出现在进程的命令行参数中
+func userKubeconfigExecConfig(server, secret string) *clientcmdapi.ExecConfig {
+	return &clientcmdapi.ExecConfig{
+		APIVersion: "client.authentication.k8s.io/v1",
+		Command:    "sh",
+		Args: []string{"-c",
+			`printf 'Authorization: Bearer %s\n' "$K8M_KUBECONFIG_SECRET" | curl -sSf -X POST -H @- "$K8M_KUBECONFIG_URL"`},
+		Env: []clientcmdapi.ExecEnvVar{
+			{Name: "K8M_KUBECONFIG_SECRET", Value: secret},
+			{Name: "K8M_KUBECONFIG_URL", Value: strings.TrimRight(server, "/") + UserKubeconfigExecPath},
+		},
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+		InstallHint:     "使用 k8m 生成的 kubeconfig 需要安装 sh 及 curl 7.55 以上版本",
+	}
+}
+
+// ExecCredential exec 插件使用凭据换取短期Token，Token 有效期不超过凭据的过期时间
+func (s *userKubeconfigService) ExecCredential(secret, clientIP string) (*ExecCredential, error) {
+	if !strings.HasPrefix(secret, userKubeconfigSecretPrefix) {
+		return nil, fmt.Errorf("无效的 kubeconfig 凭据")
+	}
+	item := &models.UserKubeconfig{}
+	err := dao.DB().Where("secret_hash = ? AND mode = ?", tokenHash(secret), UserKubeconfigModeExec).First(item).Error
+	if err != nil {
+		return nil, fmt.Errorf("无效的 kubeconfig 凭据")
+	}
+	if err := s.check(item); err != nil {
+		return nil, err
+	}
+	if UserService().IsUserDisabled(item.Username) {
+		return nil, fmt.Errorf("用户已被禁用")
+	}
+	expiresAt := time.Now().Add(userKubeconfigExecTokenTTL)
+	if item.ExpiresAt.Before(expiresAt) {
+		expiresAt = item.ExpiresAt
+	}
+	token, err := s.signToken(item, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	s.touch(item.ID, clientIP)
+	return &ExecCredential{
+		APIVersion: "client.authentication.k8s.io/v1",
+		Kind:       "ExecCredential",
+		Status: ExecCredentialStatus{
+			Token:               token,
+			ExpirationTimestamp: expiresAt.UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// Authorize 校验 kubeconfig 的吊销状态及有效期，kubeconfig 的Token只能访问 Kubernetes API 代理
+func (s *userKubeconfigService) Authorize(c *gin.Context, id uint, username string) error {
+	if !strings.HasPrefix(c.Request.URL.Path, KubeProxyPathPrefix) {
+		return fmt.Errorf("kubeconfig 的Token仅可用于访问 Kubernetes API")
+	}
+	item, err := s.get(id)
+	if err != nil {
+		return fmt.Errorf("kubeconfig 不存在或已删除")
+	}
+	if item.Username != username {
+		return fmt.Errorf("kubeconfig 与用户不匹配")
+	}
+	if err := s.check(item); err != nil {
+		return err
+	}
+	s.touch(item.ID, c.ClientIP())
+	return nil
+}
+
+// CheckScope 校验请求的集群是否包含在 kubeconfig 中
+func (s *userKubeconfigService) CheckScope(id uint, cluster string) error {
+	item, err := s.get(id)
+	if err != nil {
+		return fmt.Errorf("kubeconfig 不存在或已删除")
+	}
+	if !slices.Contains(splitList(item.Clusters), cluster) {
+		return fmt.Errorf("kubeconfig 不包含集群: %s", cluster)
+	}
+	return nil
+}
+
+// Revoke 吊销 kubeconfig，已签发的Token及 exec 凭据立即失效
+func (s *userKubeconfigService) Revoke(item *models.UserKubeconfig, revokedBy string) error {
+	if item.Revoked {
+		return nil
+	}
+	now := time.Now()
+	err := dao.DB().Model(&models.UserKubeconfig{}).Where("id = ?", item.ID).
+		UpdateColumns(map[string]any{"revoked": true, "revoked_at": now, "revoked_by": revokedBy}).Error
+	if err != nil {
+		return err
+	}
+	item.Revoked, item.RevokedAt, item.RevokedBy = true, &now, revokedBy
+	utils.ClearCacheByKey(CacheService().CacheInstance(), s.cacheKey(item.ID))
+	klog.V(2).Infof("用户[%s]的 kubeconfig[%d]已被[%s]吊销", item.Username, item.ID, revokedBy)
+	return nil
+}
+
+func (s *userKubeconfigService) check(item *models.UserKubeconfig) error {
+	if item.Revoked {
+		return fmt.Errorf("kubeconfig 已吊销")
+	}
+	if item.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("kubeconfig 已过期，请重新下载")
+	}
+	return nil
+}
+
+func (s *userKubeconfigService) signToken(item *models.UserKubeconfig, expiresAt time.Time) (string, error) {
+	return JwtKeyService().Sign(jwt.MapClaims{
+		constants.JwtUserName:     item.Username,
+		constants.JwtKubeconfigID: item.ID,
+		"iat":                     time.Now().Unix(),
+		"exp":                     expiresAt.Unix(),
+	})
+}
+
+// touch 记录最后使用时间及来源IP，按间隔写库
+func (s *userKubeconfigService) touch(id uint, ip string) {
+	now := time.Now()
+	if v, ok := s.touched.Load(id); ok && now.Sub(v.(time.Time)) < userKubeconfigTouchInterval {
+		return
+	}
+	s.touched.Store(id, now)
+	err := dao.DB().Model(&models.UserKubeconfig{}).Where("id = ?", id).
+		UpdateColumns(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error
+	if err != nil {
+		klog.Warningf("更新 kubeconfig[%d]使用时间失败: %v", id, err)
+	}
+}
+
+func (s *userKubeconfigService) cacheKey(id uint) string {
+	return fmt.Sprintf("user_kubeconfig:id:%d", id)
+}
+
+func (s *userKubeconfigService) get(id uint) (*models.UserKubeconfig, error) {
+	return utils.GetOrSetCache(CacheService().CacheInstance(), s.cacheKey(id), 30*time.Second, func() (*models.UserKubeconfig, error) {
+		item := &models.UserKubeconfig{}
+		return item.GetOne(&dao.Params{}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ?", id)
+		})
+	})
+}
+
+// buildUserKubeconfig 每个集群生成一个 cluster 及 context，共用同一个用户凭据，当前 context 为第一个集群
+func buildUserKubeconfig(server, username string, clusters []string, authInfo *clientcmdapi.AuthInfo) ([]byte, error) {
+	server = strings.TrimRight(server, "/")
+	userName := "k8m-" + username
+	cfg := clientcmdapi.NewConfig()
+	cfg.AuthInfos[userName] = authInfo
+	for _, cluster := range clusters {
+		name := "k8m-" + cluster
+		cfg.Clusters[name] = &clientcmdapi.Cluster{
+			Server: server + KubeProxyPathPrefix + utils.UrlSafeBase64Encode(cluster),
+		}
+		cfg.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: userName}
+		if cfg.CurrentContext == "" {
+			cfg.CurrentContext = name
+		}
+	}
+	return clientcmd.Write(*cfg)
+}
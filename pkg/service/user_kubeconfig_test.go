@@ -0,0 +1,56 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/k8m/pkg/comm/utils"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestBuildUserKubeconfig(t *testing.T) {
+	clusters := []string{"config/kind-dev", "config/kind-prod"}
+	authInfo := &clientcmdapi.AuthInfo{Token: "token-value"}
+	data, err := buildUserKubeconfig("https://k8m.example.com/", "alice", clusters, authInfo)
+	if err != nil {
+		t.Fatalf("生成 kubeconfig 失败: %v", err)
+	}
+	cfg, err := clientcmd.Load(data)
+	if err != nil {
+		t.Fatalf("解析 kubeconfig 失败: %v", err)
+	}
+	if cfg.CurrentContext != "k8m-config/kind-dev" {
+		t.Errorf("当前 context 应为第一个集群，实际为 %s", cfg.CurrentContext)
+	}
+	for _, cluster := range clusters {
+		name := "k8m-" + cluster
+		want := "https://k8m.example.com/k8s/proxy/" + utils.UrlSafeBase64Encode(cluster)
+		if c, ok := cfg.Clusters[name]; !ok || c.Server != want {
+			t.Errorf("集群 %s 的 server 应为 %s", cluster, want)
+		}
+		if ctx, ok := cfg.Contexts[name]; !ok || ctx.AuthInfo != "k8m-alice" {
+			t.Errorf("context %s 应使用用户 k8m-alice", name)
+		}
+	}
+	if cfg.AuthInfos["k8m-alice"].Token != "token-value" {
+		t.Errorf("用户凭据未写入 kubeconfig")
+	}
+}
+
+func TestUserKubeconfigExecConfig(t *testing.T) {
+	exec := userKubeconfigExecConfig("https://k8m.example.com/", "k8mkc_secret")
+	if strings.Contains(exec.Command+strings.Join(exec.Args, " "), "k8mkc_secret") {
+		t.Errorf("凭据不应出现在命令行参数中: %v", exec.Args)
+	}
+	env := map[string]string{}
+	for _, e := range exec.Env {
+		env[e.Name] = e.Value
+	}
+	if env["K8M_KUBECONFIG_SECRET"] != "k8mkc_secret" {
+		t.Errorf("凭据应通过环境变量传入")
+	}
+	if env["K8M_KUBECONFIG_URL"] != "https://k8m.example.com"+UserKubeconfigExecPath {
+		t.Errorf("换取Token的地址错误: %s", env["K8M_KUBECONFIG_URL"])
+	}
+}
@@ -3,10 +3,11 @@ import {fetcherResult} from "amis-core/lib/types";
 import {message} from "antd";
 import axios from "axios";
 import {ProcessK8sUrlWithCluster} from "@/utils/utils.ts";
+import {ensureFreshToken, getToken, refreshAccessToken} from "@/utils/auth.ts";
 
 
 export const fetcher = ({url, method = 'get', data, config}: FetcherConfig): Promise<fetcherResult> => {
-    const token = localStorage.getItem('token') || '';
+    const token = getToken();
 
     const ajax = axios.create({
         baseURL: '/',
@@ -18,7 +19,12 @@ export const fetcher = ({url, method = 'get', data, config}: FetcherConfig): Pro
 
     // 请求发送之前的拦截
     ajax.interceptors.request.use(
-        config => {
+        async config => {
+            // 访问Token即将过期时先刷新
+            const freshToken = await ensureFreshToken();
+            if (freshToken) {
+                config.headers.Authorization = `Bearer ${freshToken}`;
+            }
             if (config.url) {
                 const overrideCluster = (config.headers && (config.headers as any)['x-k8m-target-cluster']) || (config.params && (config.params as any).__cluster);
                 config.url = ProcessK8sUrlWithCluster(config.url, overrideCluster as string | undefined);
@@ -37,8 +43,17 @@ export const fetcher = ({url, method = 'get', data, config}: FetcherConfig): Pro
     // 请求发送之前的拦截
     ajax.interceptors.response.use(
         response => response, // 请求成功
-        error => {
+        async error => {
             if (error.response && error.response.status === 401) {
+                // 访问Token过期，使用刷新Token换取后重试一次
+                if (error.config && !error.config.__retried) {
+                    const newToken = await refreshAccessToken();
+                    if (newToken) {
+                        error.config.__retried = true;
+                        error.config.headers.Authorization = `Bearer ${newToken}`;
+                        return ajax.request(error.config);
+                    }
+                }
                 // 如果是401，跳转到登录页面
                 window.location.href = '/#/login';
             }
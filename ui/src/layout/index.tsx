@@ -9,6 +9,7 @@ import styles from './index.module.scss'
 import FloatingChatGPTButton from './FloatingChatGPTButton'
 import { fetcher } from '@/components/Amis/fetcher'
 import I18nTranslateProvider from '@/components/I18n/I18nTranslateProvider';
+import { ensureFreshToken } from '@/utils/auth'
 
 const App = () => {
     const { pathname } = useLocation()
@@ -28,6 +29,14 @@ const App = () => {
         }
     }, [navigate, pathname])
 
+    // 定期检查访问Token，即将过期时提前刷新，保证WebSocket等非Ajax连接使用有效Token
+    useEffect(() => {
+        const timer = setInterval(() => {
+            ensureFreshToken()
+        }, 60 * 1000)
+        return () => clearInterval(timer)
+    }, [])
+
     const [produtcName, setProdutcName] = useState("k8m");
 
     useEffect(() => {
@@ -5,6 +5,7 @@ import 'antd/dist/reset.css'
 import '@/styles/global.scss'
 import '@fortawesome/fontawesome-free/css/all.css';
 import App from './App.tsx'
+import '@/utils/webauthn';
 import { ConfigProvider } from 'antd';
 import zhCN from 'antd/locale/zh_CN';
 import dayjs from 'dayjs';
@@ -1,13 +1,17 @@
-import { Form, Input, Button, Checkbox, message, Space } from 'antd'
+import { Form, Input, Button, Checkbox, message, Space, Alert } from 'antd'
 import { useNavigate } from 'react-router-dom'
 import {
     UserOutlined,
     LockOutlined,
-    SafetyOutlined
+    SafetyOutlined,
+    KeyOutlined
 } from '@ant-design/icons'
 import styles from './index.module.scss'
 import { useCallback, useEffect, useState } from 'react'
 import { encrypt, decrypt } from '@/utils/crypto'
+import { setTokens } from '@/utils/auth'
+import { isWebAuthnSupported, loginWithPasskey } from '@/utils/webauthn'
+import dayjs from 'dayjs'
 
 const FormItem = Form.Item
 
@@ -16,6 +20,18 @@ interface SSOConfig {
     type: string;
 }
 
+// 已绑定2步验证时，密码校验通过后返回的待验证信息
+interface MFAPending {
+    token: string;
+    methods: string[];
+}
+
+// 管理员重置密码或密码过期时，密码校验通过后返回的待修改密码信息
+interface PasswordChangePending {
+    token: string;
+    message: string;
+}
+
 const Login = () => {
     const navigate = useNavigate()
     const [form] = Form.useForm();
@@ -23,6 +39,11 @@ const Login = () => {
     const [loadingSSO, setLoadingSSO] = useState<Record<string, boolean>>({});
     const [isLdap, setIsLdap] = useState(false);
     const [ldapEnabled, setLdapEnabled] = useState(false);
+    const [mfa, setMfa] = useState<MFAPending | null>(null);
+    const [mfaCode, setMfaCode] = useState('');
+    const [verifying, setVerifying] = useState(false);
+    const [pswChange, setPswChange] = useState<PasswordChangePending | null>(null);
+    const [pswForm] = Form.useForm();
 
     // 获取SSO配置
     useEffect(() => {
@@ -70,6 +91,60 @@ const Login = () => {
         }
     }, [form]);
 
+    // 保存Token后进入系统，策略要求绑定2步验证时转到登录设置
+    const finishLogin = useCallback((data: any) => {
+        setTokens(data);
+        if (data.mfa_enroll) {
+            message.warning('已超过2步验证绑定期限，请先绑定2步验证', 8);
+            navigate('/user/profile/login_settings');
+            return;
+        }
+        message.success('登录成功');
+        if (data.mfa_deadline) {
+            message.warning(`请在 ${dayjs(data.mfa_deadline).format('YYYY-MM-DD HH:mm')} 前绑定2步验证，逾期将无法正常使用`, 8);
+        }
+        if (data.password_expires_at) {
+            message.warning(`密码将于 ${dayjs(data.password_expires_at).format('YYYY-MM-DD HH:mm')} 过期，请及时在个人中心修改密码`, 8);
+        }
+        navigate('/');
+    }, [navigate]);
+
+    // 处理登录结果：登录成功、需要2步验证或需要修改密码
+    const onLoginResult = useCallback((res: Response, data: any) => {
+        if (res.ok) {
+            finishLogin(data);
+        } else if (data.mfa_required) {
+            setPswChange(null);
+            setMfaCode('');
+            setMfa({ token: data.mfa_token, methods: data.methods || [] });
+        } else if (data.password_change_required) {
+            pswForm.resetFields();
+            setPswChange({ token: data.psw_token, message: data.message });
+        } else {
+            message.error(data.message || '登录失败');
+        }
+    }, [finishLogin, pswForm]);
+
+    // SSO 认证成功后跳转到登录页，由登录页保存Token或进入2步验证
+    useEffect(() => {
+        const result = sessionStorage.getItem('sso_login');
+        if (!result) {
+            return;
+        }
+        sessionStorage.removeItem('sso_login');
+        try {
+            const data = JSON.parse(result);
+            if (data.mfa_required) {
+                setMfaCode('');
+                setMfa({ token: data.mfa_token, methods: data.methods || [] });
+            } else {
+                finishLogin(data);
+            }
+        } catch {
+            message.error('SSO登录失败');
+        }
+    }, [finishLogin]);
+
     const onSubmit = useCallback(() => {
         form.validateFields().then(async (values) => {
             try {
@@ -87,9 +162,7 @@ const Login = () => {
                     }),
                 });
                 const data = await res.json();
-                if (res.ok) {
-                    message.success('登录成功');
-                    localStorage.setItem('token', data.token);
+                if (res.ok || data.mfa_required) {
                     // 记住密码逻辑
                     const rememberData = {
                         username: values.username,
@@ -102,16 +175,189 @@ const Login = () => {
                     } else {
                         localStorage.removeItem('remember');
                     }
+                }
+                onLoginResult(res, data);
+            } catch (error) {
+                message.error('网络错误');
+            }
+        });
+    }, [form, isLdap, onLoginResult]);
 
-                    navigate('/');
-                } else {
-                    message.error(data.message || '登录失败');
+    const onChangePassword = useCallback(() => {
+        if (!pswChange) {
+            return;
+        }
+        pswForm.validateFields().then(async (values) => {
+            setVerifying(true);
+            try {
+                const encryptedPassword = encrypt(values.password);
+                const res = await fetch('/auth/password/change', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({
+                        psw_token: pswChange.token,
+                        password: encryptedPassword,
+                        confirm_password: encrypt(values.confirmPassword),
+                    }),
+                });
+                const data = await res.json();
+                if (res.ok || data.mfa_required) {
+                    // 记住的密码同步更新为新密码
+                    if (form.getFieldValue('remember')) {
+                        localStorage.setItem('remember', JSON.stringify({
+                            username: form.getFieldValue('username'),
+                            password: encryptedPassword,
+                            remember: true,
+                        }));
+                    }
+                    form.setFieldValue('password', values.password);
+                    message.success('密码修改成功');
+                } else if (res.status === 401) {
+                    // 修改密码超时需重新输入原密码
+                    setPswChange(null);
                 }
+                onLoginResult(res, data);
             } catch (error) {
                 message.error('网络错误');
+            } finally {
+                setVerifying(false);
             }
         });
-    }, [navigate, form,isLdap]);
+    }, [form, pswForm, pswChange, onLoginResult]);
+
+    // 2步验证失败次数过多或超时后需重新输入密码
+    const onMfaFailed = useCallback((msg: string) => {
+        message.error(msg || '验证失败');
+        if (msg.includes('重新登录')) {
+            setMfa(null);
+        }
+    }, []);
+
+    const onVerifyCode = useCallback(async () => {
+        if (!mfa || !mfaCode) {
+            return;
+        }
+        setVerifying(true);
+        try {
+            const res = await fetch('/auth/mfa/totp', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ mfa_token: mfa.token, code: mfaCode }),
+            });
+            const data = await res.json();
+            if (res.ok) {
+                finishLogin(data);
+            } else {
+                onMfaFailed(data.message);
+            }
+        } catch (error) {
+            message.error('网络错误');
+        } finally {
+            setVerifying(false);
+        }
+    }, [mfa, mfaCode, finishLogin, onMfaFailed]);
+
+    const onVerifyPasskey = useCallback(async () => {
+        if (!mfa) {
+            return;
+        }
+        setVerifying(true);
+        try {
+            finishLogin(await loginWithPasskey(mfa.token));
+        } catch (error) {
+            onMfaFailed((error as Error).message);
+        } finally {
+            setVerifying(false);
+        }
+    }, [mfa, finishLogin, onMfaFailed]);
+
+    if (pswChange) {
+        return <section className={styles.login}>
+            <div className={styles.content}>
+                <Form
+                    form={pswForm}
+                    className={styles.form}
+                    autoComplete='off'
+                    onKeyDown={(event) => {
+                        if (event.key === 'Enter') {
+                            event.preventDefault();
+                            onChangePassword();
+                        }
+                    }}
+                >
+                    <div>
+                        <h2 style={{ color: '#666', fontSize: '24px', marginBottom: 20 }}>修改密码</h2>
+                    </div>
+                    <Alert type='warning' showIcon message={pswChange.message || '请修改密码后登录'} style={{ marginBottom: 24 }} />
+                    <FormItem name='password' rules={[{ required: true, message: '请输入新密码' }]}>
+                        <Input.Password autoFocus prefix={<LockOutlined />} placeholder='请输入新密码' />
+                    </FormItem>
+                    <FormItem
+                        name='confirmPassword'
+                        dependencies={['password']}
+                        rules={[
+                            { required: true, message: '请再次输入新密码' },
+                            ({ getFieldValue }) => ({
+                                validator(_, value) {
+                                    if (!value || getFieldValue('password') === value) {
+                                        return Promise.resolve();
+                                    }
+                                    return Promise.reject(new Error('两次输入的密码不一致'));
+                                },
+                            }),
+                        ]}
+                    >
+                        <Input.Password prefix={<LockOutlined />} placeholder='请再次输入新密码' />
+                    </FormItem>
+                    <FormItem>
+                        <Button type='primary' block loading={verifying} onClick={onChangePassword}>修改并登录</Button>
+                    </FormItem>
+                    <Button type='link' onClick={() => setPswChange(null)} style={{ padding: 0 }}>返回重新登录</Button>
+                </Form>
+            </div>
+        </section>
+    }
+
+    if (mfa) {
+        return <section className={styles.login}>
+            <div className={styles.content}>
+                <Form
+                    className={styles.form}
+                    autoComplete='off'
+                    onKeyDown={(event) => {
+                        if (event.key === 'Enter') {
+                            event.preventDefault();
+                            onVerifyCode();
+                        }
+                    }}
+                >
+                    <div>
+                        <h2 style={{ color: '#666', fontSize: '24px', marginBottom: 20 }}>2步验证</h2>
+                    </div>
+                    {mfa.methods.includes('totp') && <>
+                        <FormItem>
+                            <Input
+                                autoFocus
+                                prefix={<SafetyOutlined />}
+                                placeholder='请输入验证器中的验证码'
+                                value={mfaCode}
+                                onChange={(e) => setMfaCode(e.target.value.trim())}
+                            />
+                        </FormItem>
+                        <FormItem>
+                            <Button type='primary' block loading={verifying} disabled={!mfaCode} onClick={onVerifyCode}>验 证</Button>
+                        </FormItem>
+                    </>}
+                    {mfa.methods.includes('webauthn') && isWebAuthnSupported() && (
+                        <FormItem>
+                            <Button block icon={<KeyOutlined />} loading={verifying} onClick={onVerifyPasskey}>使用通行密钥验证</Button>
+                        </FormItem>
+                    )}
+                    <Button type='link' onClick={() => setMfa(null)} style={{ padding: 0 }}>返回重新登录</Button>
+                </Form>
+            </div>
+        </section>
+    }
 
     return <section className={styles.login}>
         <div className={styles.content}>
@@ -773,6 +773,14 @@ export const initialMenu: MenuItem[] = [
                 customEvent: '() => loadJsonPage("/admin/cluster/cluster_all")',
                 order: 1,
             },
+            {
+                key: 'cluster_agent',
+                title: '集群Agent',
+                icon: 'fa-solid fa-tower-broadcast',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/cluster/cluster_agent")',
+                order: 1.5,
+            },
             {
                 key: 'system_config',
                 title: '参数设置',
@@ -863,6 +871,30 @@ export const initialMenu: MenuItem[] = [
                 customEvent: '() => loadJsonPage("/admin/config/ai_model_config")',
                 order: 5,
             },
+            {
+                key: 'ai_usage',
+                title: 'AI用量统计',
+                icon: 'fa-solid fa-chart-column',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/config/ai_usage")',
+                order: 5.5,
+            },
+            {
+                key: 'ai_redaction',
+                title: 'AI脱敏记录',
+                icon: 'fa-solid fa-user-secret',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/config/ai_redaction")',
+                order: 5.6,
+            },
+            {
+                key: 'knowledge_management',
+                title: 'AI知识库',
+                icon: 'fa-solid fa-book',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/knowledge")',
+                order: 5.7,
+            },
             {
                 key: 'ai_prompt_management',
                 title: 'AI提示词管理',
@@ -887,6 +919,38 @@ export const initialMenu: MenuItem[] = [
                 customEvent: '() => loadJsonPage("/admin/user/user_group")',
                 order: 6,
             },
+            {
+                key: 'group_mapping',
+                title: '用户组映射',
+                icon: 'fa-solid fa-diagram-project',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/user/group_mapping")',
+                order: 6.5,
+            },
+            {
+                key: 'scim',
+                title: 'SCIM同步',
+                icon: 'fa-solid fa-arrows-rotate',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/user/scim")',
+                order: 6.6,
+            },
+            {
+                key: 'login_lock',
+                title: '登录锁定',
+                icon: 'fa-solid fa-user-lock',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/user/login_lock")',
+                order: 6.7,
+            },
+            {
+                key: 'user_kubeconfig',
+                title: '用户kubeconfig',
+                icon: 'fa-solid fa-file-shield',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/user/user_kubeconfig")',
+                order: 6.8,
+            },
             {
                 key: 'mcp_management',
                 title: 'MCP管理',
@@ -935,6 +999,14 @@ export const initialMenu: MenuItem[] = [
                 customEvent: '() => loadJsonPage("/admin/config/ldap_config")',
                 order: 11,
             },
+            {
+                key: 'jwt_keys',
+                title: 'JWT签名密钥',
+                icon: 'fa-solid fa-key',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/admin/config/jwt_keys")',
+                order: 11.5,
+            },
             {
                 key: 'operation_audit',
                 title: '操作审计',
@@ -992,6 +1064,14 @@ export const initialMenu: MenuItem[] = [
                 customEvent: '() => loadJsonPage("/user/profile/api_keys")',
                 order: 3,
             },
+            {
+                key: 'user_profile_kubeconfig',
+                title: 'kubeconfig',
+                icon: 'fa-solid fa-file-code',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/user/profile/kubeconfig")',
+                order: 3.5,
+            },
             {
                 key: 'user_profile_mcp_keys',
                 title: '开放MCP',
@@ -1000,6 +1080,14 @@ export const initialMenu: MenuItem[] = [
                 customEvent: '() => loadJsonPage("/user/profile/mcp_keys")',
                 order: 4,
             },
+            {
+                key: 'user_profile_sessions',
+                title: '登录会话',
+                icon: 'fa-solid fa-desktop',
+                eventType: 'custom',
+                customEvent: '() => loadJsonPage("/user/profile/sessions")',
+                order: 5,
+            },
         ],
     },
     {
@@ -0,0 +1,110 @@
+import { jwtDecode } from 'jwt-decode';
+
+const TOKEN_KEY = 'token';
+const REFRESH_TOKEN_KEY = 'refresh_token';
+
+// 访问Token剩余有效期小于该值（秒）时提前刷新
+const REFRESH_BEFORE_SECONDS = 120;
+
+export interface TokenPair {
+    token: string;
+    refresh_token?: string;
+    expires_in?: number;
+}
+
+export const getToken = () => localStorage.getItem(TOKEN_KEY) || '';
+
+// 保存登录或刷新后返回的Token
+export const setTokens = (pair: TokenPair) => {
+    localStorage.setItem(TOKEN_KEY, pair.token);
+    if (pair.refresh_token) {
+        localStorage.setItem(REFRESH_TOKEN_KEY, pair.refresh_token);
+    }
+};
+
+export const clearTokens = () => {
+    localStorage.removeItem(TOKEN_KEY);
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
+};
+
+// 判断访问Token是否将在指定秒数内过期，无法解析时视为不需要刷新
+const expiresWithin = (token: string, seconds: number) => {
+    try {
+        const { exp } = jwtDecode<{ exp?: number }>(token);
+        return !!exp && exp * 1000 - Date.now() < seconds * 1000;
+    } catch {
+        return false;
+    }
+};
+
+let refreshing: Promise<string | null> | null = null;
+
+const doRefresh = async (refreshToken: string): Promise<Response> => fetch('/auth/refresh', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+});
+
+// 使用刷新Token换取新的访问Token，并发调用时只发起一次请求。失败返回 null
+export const refreshAccessToken = (): Promise<string | null> => {
+    if (refreshing) {
+        return refreshing;
+    }
+    refreshing = (async () => {
+        const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+        if (!refreshToken) {
+            return null;
+        }
+        try {
+            const res = await doRefresh(refreshToken);
+            if (res.ok) {
+                const pair: TokenPair = await res.json();
+                setTokens(pair);
+                return pair.token;
+            }
+            // 409 表示其他标签页正在刷新，等待其保存最新Token
+            if (res.status === 409) {
+                for (let i = 0; i < 10; i++) {
+                    const latest = localStorage.getItem(REFRESH_TOKEN_KEY);
+                    if (latest && latest !== refreshToken) {
+                        return getToken();
+                    }
+                    await new Promise(resolve => setTimeout(resolve, 200));
+                }
+                return null;
+            }
+            return null;
+        } catch {
+            return null;
+        }
+    })().finally(() => {
+        refreshing = null;
+    });
+    return refreshing;
+};
+
+// 访问Token即将过期时提前刷新，返回可用的访问Token
+export const ensureFreshToken = async (): Promise<string> => {
+    const token = getToken();
+    if (token && expiresWithin(token, REFRESH_BEFORE_SECONDS) && localStorage.getItem(REFRESH_TOKEN_KEY)) {
+        return (await refreshAccessToken()) || token;
+    }
+    return token;
+};
+
+// 退出登录，注销服务端会话
+export const logout = async () => {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    clearTokens();
+    if (refreshToken) {
+        try {
+            await fetch('/auth/logout', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: refreshToken }),
+            });
+        } catch {
+            // 忽略网络错误，本地Token已清除
+        }
+    }
+};
@@ -0,0 +1,125 @@
+import { getToken } from '@/utils/auth';
+
+// WebAuthn 参数中的二进制字段以 base64url 传输，浏览器 API 需要 ArrayBuffer
+const toBuffer = (value: string): ArrayBuffer => {
+    const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    const padded = base64 + '='.repeat((4 - base64.length % 4) % 4);
+    const binary = atob(padded);
+    const bytes = new Uint8Array(binary.length);
+    for (let i = 0; i < binary.length; i++) {
+        bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes.buffer;
+};
+
+const toBase64url = (buffer: ArrayBuffer | null): string | undefined => {
+    if (!buffer) {
+        return undefined;
+    }
+    let binary = '';
+    new Uint8Array(buffer).forEach(b => binary += String.fromCharCode(b));
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+};
+
+const readMessage = async (res: Response, fallback: string) => {
+    try {
+        const data = await res.json();
+        return data.message || data.msg || fallback;
+    } catch {
+        return fallback;
+    }
+};
+
+export const isWebAuthnSupported = () => typeof window !== 'undefined' && !!window.PublicKeyCredential;
+
+// 绑定通行密钥，成功返回服务端提示信息
+export const registerPasskey = async (name: string): Promise<string> => {
+    if (!isWebAuthnSupported()) {
+        throw new Error('当前浏览器不支持通行密钥');
+    }
+    const headers = { 'Content-Type': 'application/json', Authorization: `Bearer ${getToken()}` };
+    const beginRes = await fetch('/mgm/user/profile/webauthn/register/begin', { method: 'POST', headers });
+    const begin = await beginRes.json();
+    if (begin.status !== 0) {
+        throw new Error(begin.msg || '获取绑定参数失败');
+    }
+    const options = begin.data.publicKey;
+    options.challenge = toBuffer(options.challenge);
+    options.user.id = toBuffer(options.user.id);
+    options.excludeCredentials = (options.excludeCredentials || []).map((c: { id: string }) => ({ ...c, id: toBuffer(c.id) }));
+
+    const credential = await navigator.credentials.create({ publicKey: options }) as PublicKeyCredential | null;
+    if (!credential) {
+        throw new Error('已取消绑定');
+    }
+    const response = credential.response as AuthenticatorAttestationResponse;
+    const finishRes = await fetch(`/mgm/user/profile/webauthn/register/finish?name=${encodeURIComponent(name || '')}`, {
+        method: 'POST',
+        headers,
+        body: JSON.stringify({
+            id: credential.id,
+            rawId: toBase64url(credential.rawId),
+            type: credential.type,
+            response: {
+                attestationObject: toBase64url(response.attestationObject),
+                clientDataJSON: toBase64url(response.clientDataJSON),
+                transports: response.getTransports ? response.getTransports() : [],
+            },
+        }),
+    });
+    const finish = await finishRes.json();
+    if (finish.status !== 0) {
+        throw new Error(finish.msg || '绑定失败');
+    }
+    return finish.msg;
+};
+
+// 登录时使用通行密钥完成2步验证，成功返回 Token
+export const loginWithPasskey = async (mfaToken: string) => {
+    const beginRes = await fetch('/auth/mfa/webauthn/begin', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ mfa_token: mfaToken }),
+    });
+    if (!beginRes.ok) {
+        throw new Error(await readMessage(beginRes, '发起验证失败'));
+    }
+    const options = (await beginRes.json()).publicKey;
+    options.challenge = toBuffer(options.challenge);
+    options.allowCredentials = (options.allowCredentials || []).map((c: { id: string }) => ({ ...c, id: toBuffer(c.id) }));
+
+    const credential = await navigator.credentials.get({ publicKey: options }) as PublicKeyCredential | null;
+    if (!credential) {
+        throw new Error('已取消验证');
+    }
+    const response = credential.response as AuthenticatorAssertionResponse;
+    const finishRes = await fetch(`/auth/mfa/webauthn/finish?mfa_token=${encodeURIComponent(mfaToken)}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            id: credential.id,
+            rawId: toBase64url(credential.rawId),
+            type: credential.type,
+            response: {
+                authenticatorData: toBase64url(response.authenticatorData),
+                clientDataJSON: toBase64url(response.clientDataJSON),
+                signature: toBase64url(response.signature),
+                userHandle: toBase64url(response.userHandle),
+            },
+        }),
+    });
+    if (!finishRes.ok) {
+        throw new Error(await readMessage(finishRes, '验证失败'));
+    }
+    return finishRes.json();
+};
+
+declare global {
+    interface Window {
+        registerPasskey: typeof registerPasskey;
+    }
+}
+
+if (typeof window !== 'undefined') {
+    window.registerPasskey = registerPasskey;
+}